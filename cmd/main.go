@@ -3,9 +3,13 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/Vovarama1992/go-utils/httputil"
@@ -13,20 +17,30 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
 	"github.com/Vovarama1992/make_ziper/internal/ai"
+	"github.com/Vovarama1992/make_ziper/internal/audit"
 	"github.com/Vovarama1992/make_ziper/internal/bots"
 	"github.com/Vovarama1992/make_ziper/internal/classes"
 	"github.com/Vovarama1992/make_ziper/internal/delivery"
 	"github.com/Vovarama1992/make_ziper/internal/doc"
 	"github.com/Vovarama1992/make_ziper/internal/domain"
+	"github.com/Vovarama1992/make_ziper/internal/entitlements"
+	"github.com/Vovarama1992/make_ziper/internal/gifts"
 	"github.com/Vovarama1992/make_ziper/internal/infra"
+	"github.com/Vovarama1992/make_ziper/internal/memory"
 	"github.com/Vovarama1992/make_ziper/internal/minutes_packages"
 	error_notificator "github.com/Vovarama1992/make_ziper/internal/notificator"
+	"github.com/Vovarama1992/make_ziper/internal/payments"
 	"github.com/Vovarama1992/make_ziper/internal/pdf"
 	"github.com/Vovarama1992/make_ziper/internal/ports"
+	"github.com/Vovarama1992/make_ziper/internal/promo"
+	"github.com/Vovarama1992/make_ziper/internal/quota"
+	"github.com/Vovarama1992/make_ziper/internal/referral"
 	"github.com/Vovarama1992/make_ziper/internal/speech"
+	"github.com/Vovarama1992/make_ziper/internal/support"
 	"github.com/Vovarama1992/make_ziper/internal/telegram"
 	"github.com/Vovarama1992/make_ziper/internal/textrules"
 	"github.com/Vovarama1992/make_ziper/internal/trial"
+	"github.com/Vovarama1992/make_ziper/internal/usage"
 	"github.com/Vovarama1992/make_ziper/internal/user"
 
 	"github.com/go-chi/chi/v5"
@@ -44,6 +58,10 @@ func main() {
 
 	_ = godotenv.Load()
 
+	// SIGTERM (docker compose stop) → graceful shutdown, см. конец main
+	appCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
@@ -90,6 +108,7 @@ func main() {
 
 	recordRepo := infra.NewRecordRepo(db)
 	subscriptionRepo := infra.NewSubscriptionRepo(db)
+	subscriptionPeriodRepo := infra.NewSubscriptionPeriodRepo(db)
 	tariffRepo := infra.NewTariffRepo(db)
 	botRepo := bots.NewRepo(db)
 	minutePackageRepo := minutes_packages.NewMinutePackageRepo(db)
@@ -99,12 +118,22 @@ func main() {
 	var authRepo ports.AuthRepo = infra.NewAuthRepo(db)
 
 	textRuleRepo := textrules.NewRepo(db)
+	usageRepo := usage.NewRepo(db)
+	memoryRepo := memory.NewRepo(db)
+	quotaRepo := quota.NewRepo(db)
+	promoRepo := promo.NewRepo(db)
+	referralRepo := referral.NewRepo(db)
+	giftRepo := gifts.NewRepo(db)
+	supportRepo := support.NewRepo(db)
+	auditRepo := audit.NewRepo(db)
+	paymentEventRepo := payments.NewRepo(db)
 
 	// =========================================================================
 	// ERROR NOTIFICATION
 	// =========================================================================
 
-	errInfra := error_notificator.NewInfra(nil)
+	alertRepo := error_notificator.NewRepo(db)
+	errInfra := error_notificator.NewInfra(nil, alertRepo)
 	errService := error_notificator.NewService(errInfra)
 
 	// =========================================================================
@@ -115,7 +144,18 @@ func main() {
 	perplexityTTS := speech.NewPerplexityTTS()
 	ttsClient := speech.NewElevenLabsClient()
 	perplexityClient := ai.NewPerplexityClient()
-	paymentProvider := infra.NewYooKassaProvider()
+
+	// чек 54-ФЗ и return_url — из bot_configs и контакта пользователя
+	checkout := payments.NewCheckout(botRepo, userRepo)
+	// доступы к кабинетам платёжек — свои у каждого бота
+	merchants := payments.NewMerchants(botRepo)
+	yookassaProvider := infra.NewYooKassaProvider(checkout, merchants)
+
+	// платёжка выбирается по bot_configs.payment_provider
+	paymentProvider := payments.NewRouter(botRepo, map[string]ports.PaymentProvider{
+		payments.ProviderYooKassa:      yookassaProvider,
+		payments.ProviderCloudPayments: infra.NewCloudPaymentsProvider(checkout, merchants),
+	})
 
 	// =========================================================================
 	// DOMAIN SERVICES
@@ -127,43 +167,75 @@ func main() {
 	pdfService := pdf.NewPDFService(pdfConverter)
 	docService := doc.NewService(docConverter)
 	authService := domain.NewAuthService(authRepo, os.Getenv("AUTH_SECRET"))
+	// первый запуск после перехода на учётки: owner "admin" со старым паролем
+	if err := authService.Bootstrap(context.Background()); err != nil {
+		log.Fatalf("failed to bootstrap admin accounts: %v", err)
+	}
 
 	tariffService := domain.NewTariffService(tariffRepo)
+	promoService := promo.NewService(promoRepo)
+	referralService := referral.NewService(referralRepo, subscriptionRepo, trialRepo)
 	minutePackageService := minutes_packages.NewService(
 		minutePackageRepo,
 		paymentProvider,
+		promoService,
 	)
 	classService := classes.NewClassService(classRepo)
 	userService := user.NewService(userRepo)
 
 	recordService := domain.NewRecordService(recordRepo, errService)
+	usageService := usage.NewService(usageRepo)
+	quotaService := quota.NewService(quotaRepo)
+	paymentsService := payments.NewService(paymentEventRepo)
 
 	speechService := speech.NewService(
 		openAIClient,
 		ttsClient,
 		botService,
 		errService,
+		usageService,
 	)
 
+	// LLM-провайдеры, bot_configs.provider выбирает один из них
+	chatProviders := []ai.ChatProvider{openAIClient, perplexityClient}
+	if compatClient, ok := ai.NewCompatibleClientFromEnv(); ok {
+		chatProviders = append(chatProviders, compatClient)
+	}
+
 	aiService := ai.NewAiService(
-		openAIClient,
+		chatProviders,
 		perplexityClient,
 		recordService,
+		usageService,
 		botRepo,
 		classService,
 		errService,
 	)
 
+	// память об ученике: сжимает моделью бота историю, выпавшую из окна
+	memoryService := memory.NewService(memoryRepo, aiService)
+	aiService.SetMemory(memoryService)
+
 	subscriptionService := domain.NewSubscriptionService(
 		subscriptionRepo,
+		subscriptionPeriodRepo,
 		tariffRepo,
 		trialRepo,
 		minutePackageService,
 		errService,
 		paymentProvider,
+		paymentProvider, // автосписания по сохранённым картам
+		paymentsService,
+		promoService,
+		referralService,
 	)
 
+	// подарки: платит один пользователь, период получает тот, кто ввёл код
+	giftService := gifts.NewService(giftRepo, tariffRepo, paymentProvider, subscriptionService, errService)
+	supportService := support.NewService(supportRepo)
+
 	textRuleService := textrules.NewService(textRuleRepo)
+	auditService := audit.NewService(auditRepo)
 
 	// =========================================================================
 	// TELEGRAM BOTS
@@ -191,9 +263,19 @@ func main() {
 	)
 
 	botApp.SetAdminBotUsername(os.Getenv("ADMIN_BOT_USERNAME"))
+	botApp.SetEntitlements(entitlements.NewService(subscriptionService, tariffService))
+	botApp.SetQuota(quotaService)
+	botApp.SetPromo(promoService)
+	botApp.SetReferral(referralService)
+	botApp.SetGifts(giftService)
+	botApp.SetAudit(auditService)
+	botApp.SetSupport(supportService)
+
+	// нотификатор всегда видит актуальный реестр ботов (hot reload)
+	botApp.SetBotsChangedHook(errInfra.SetBots)
 
-	// ⬇️ ВАЖНО: БЕЗ TIMEOUT
-	botCtx := context.Background()
+	// ⬇️ ВАЖНО: БЕЗ TIMEOUT — живёт до SIGTERM
+	botCtx := appCtx
 
 	if err := botApp.InitBots(botCtx); err != nil {
 		log.Fatalf("failed to init telegram bots: %v", err)
@@ -218,13 +300,21 @@ func main() {
 	}))
 
 	recordHandler := delivery.NewRecordHandler(recordService, zl)
-	subHandler := delivery.NewSubscriptionHandler(subscriptionService)
+	subHandler := delivery.NewSubscriptionHandler(subscriptionService, paymentsService, yookassaProvider, giftService, merchants, errService)
 	tariffHandler := delivery.NewTariffHandler(tariffService)
 	botHandler := bots.NewHandler(botService)
 	minPkgHandler := delivery.NewMinutePackageHandler(minutePackageService)
 	classHandler := delivery.NewClassHandler(classService, botService)
 	authHandler := delivery.NewAuthHandler(authService)
 	textRuleHandler := delivery.NewTextRuleHandler(textRuleRepo)
+	usageHandler := usage.NewHandler(usageService)
+	memoryHandler := memory.NewHandler(memoryService)
+	quotaHandler := quota.NewHandler(quotaService)
+	promoHandler := promo.NewHandler(promoService)
+	referralHandler := referral.NewHandler(referralService)
+	giftHandler := gifts.NewHandler(giftService)
+	auditHandler := audit.NewHandler(auditService)
+	alertHandler := error_notificator.NewHandler(alertRepo)
 
 	delivery.RegisterRoutes(
 		r,
@@ -236,8 +326,20 @@ func main() {
 		classHandler,
 		authHandler,
 		textRuleHandler,
+		usageHandler,
+		memoryHandler,
+		quotaHandler,
+		promoHandler,
+		referralHandler,
+		giftHandler,
+		auditHandler,
+		alertHandler,
+		delivery.NewAuditor(auditService),
 	)
 
+	// вебхуки Telegram (TG_UPDATES_MODE=webhook)
+	botApp.RegisterWebhookRoutes(r)
+
 	r.With(httputil.RecoverMiddleware).Get("/ping", func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(200)
 		w.Write([]byte("pong"))
@@ -247,18 +349,40 @@ func main() {
 	// BACKGROUND JOBS
 	// =========================================================================
 
+	var jobs sync.WaitGroup
+
+	// контекст текущего прохода джоб: отменяется, если при остановке
+	// не уложились в shutdownCtx, — чтобы запросы к БД/Telegram прервались
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
+	jobs.Add(1)
 	go func() {
+		defer jobs.Done()
+
 		ticker := time.NewTicker(5 * time.Minute)
 		defer ticker.Stop()
 
-		for range ticker.C {
-			ctx := context.Background()
+		for {
+			select {
+			case <-appCtx.Done():
+				log.Println("[jobs] stopped")
+				return
+			case <-ticker.C:
+			}
+
+			ctx := jobsCtx
 
 			// 1) чистим pending
 			if err := subscriptionService.CleanupPending(ctx, 5*time.Minute); err != nil {
 				log.Printf("[cleanup-pending] error: %v", err)
 			}
 
+			// 1.1) автопродление — до истечения, чтобы подписка не успела закрыться
+			if err := subscriptionService.RenewDue(ctx); err != nil {
+				log.Printf("[renew] error: %v", err)
+			}
+
 			// 2) переводим все истёкшие active → expired
 			expired, err := subscriptionRepo.ExpireDue(ctx)
 			if err != nil {
@@ -294,7 +418,7 @@ func main() {
 				}
 
 				text := botApp.BuildSubscriptionText(ctx, sub.BotID)
-				kb := botApp.BuildSubscriptionMenu(ctx, sub.BotID)
+				kb := botApp.BuildSubscriptionMenu(ctx, sub.BotID, sub.TelegramID)
 
 				msg := tgbotapi.NewMessage(sub.TelegramID, text)
 				msg.ReplyMarkup = kb
@@ -305,6 +429,41 @@ func main() {
 
 				_ = subscriptionRepo.MarkTrialNotified(ctx, sub.ID)
 			}
+
+			// 4) старые счётчики квот
+			if err := quotaService.Cleanup(ctx); err != nil {
+				log.Printf("[quota] cleanup error: %v", err)
+			}
+		}
+	}()
+
+	// сводка подавленных повторов ошибок
+	digestEvery := time.Hour
+	if v := os.Getenv("ALERT_DIGEST_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			digestEvery = d
+		} else {
+			log.Printf("[alerts] bad ALERT_DIGEST_INTERVAL=%q, using %s", v, digestEvery)
+		}
+	}
+
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+
+		ticker := time.NewTicker(digestEvery)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-appCtx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := errInfra.SendDigest(jobsCtx); err != nil {
+				log.Printf("[alerts] digest error: %v", err)
+			}
 		}
 	}()
 
@@ -319,7 +478,54 @@ func main() {
 		Service: "make_ziper",
 	})
 
-	if err := http.ListenAndServe(addr, r); err != nil {
-		log.Fatalf("server error: %v", err)
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: r,
 	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("server error: %v", err)
+		}
+	}()
+
+	// =========================================================================
+	// GRACEFUL SHUTDOWN
+	// =========================================================================
+
+	<-appCtx.Done()
+	log.Println("[shutdown] signal received")
+
+	// укладываемся в stop_grace_period из docker-compose
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelShutdown()
+
+	// 1) перестаём принимать апдейты, дожидаемся обработчиков
+	if err := botApp.Shutdown(shutdownCtx); err != nil {
+		log.Printf("[shutdown] bots: %v", err)
+	}
+
+	// 2) фоновые тикеры: текущий проход доделываем, но не дольше shutdownCtx
+	stopJobs := context.AfterFunc(shutdownCtx, cancelJobs)
+	defer stopJobs()
+
+	jobsDone := make(chan struct{})
+	go func() {
+		jobs.Wait()
+		close(jobsDone)
+	}()
+
+	select {
+	case <-jobsDone:
+	case <-shutdownCtx.Done():
+		log.Printf("[shutdown] jobs: %v", shutdownCtx.Err())
+	}
+
+	// 3) HTTP
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("[shutdown] http: %v", err)
+	}
+
+	// 4) БД закрывается defer'ом выше
+	log.Println("[shutdown] done")
 }
@@ -0,0 +1,175 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// CompatibleClient — любой OpenAI-совместимый endpoint (vLLM, Ollama, OpenRouter, …)
+type CompatibleClient struct {
+	name         string
+	client       *openai.Client
+	defaultModel string
+}
+
+func NewCompatibleClient(name, baseURL, apiKey, defaultModel string) *CompatibleClient {
+	cfg := openai.DefaultConfig(apiKey)
+	cfg.BaseURL = strings.TrimRight(baseURL, "/")
+
+	return &CompatibleClient{
+		name:         name,
+		client:       openai.NewClientWithConfig(cfg),
+		defaultModel: defaultModel,
+	}
+}
+
+// NewCompatibleClientFromEnv — LLM_COMPAT_BASE_URL / LLM_COMPAT_API_KEY / LLM_COMPAT_MODEL.
+// Без LLM_COMPAT_BASE_URL провайдер не поднимается.
+func NewCompatibleClientFromEnv() (*CompatibleClient, bool) {
+	baseURL := os.Getenv("LLM_COMPAT_BASE_URL")
+	if baseURL == "" {
+		return nil, false
+	}
+
+	return NewCompatibleClient(
+		ProviderCompatible,
+		baseURL,
+		os.Getenv("LLM_COMPAT_API_KEY"),
+		os.Getenv("LLM_COMPAT_MODEL"),
+	), true
+}
+
+func (c *CompatibleClient) Name() string {
+	return c.name
+}
+
+func (c *CompatibleClient) GetCompletion(
+	ctx context.Context,
+	messages []openai.ChatCompletionMessage,
+	model string,
+) (*Completion, error) {
+	if model == "" {
+		model = c.defaultModel
+	}
+	return chatCompletion(ctx, c.client, c.name, messages, model)
+}
+
+func (c *CompatibleClient) GetCompletionStream(
+	ctx context.Context,
+	messages []openai.ChatCompletionMessage,
+	model string,
+	onDelta func(text string),
+) (*Completion, error) {
+	if model == "" {
+		model = c.defaultModel
+	}
+	// stream_options поддерживают не все совместимые API — usage досчитаем сами
+	return chatCompletionStream(ctx, c.client, c.name, messages, model, false, onDelta)
+}
+
+// ---------------------
+//
+//	ОБЩИЙ КОД ДЛЯ OPENAI-СОВМЕСТИМЫХ API
+//
+// ---------------------
+
+func chatCompletion(
+	ctx context.Context,
+	client *openai.Client,
+	tag string,
+	messages []openai.ChatCompletionMessage,
+	model string,
+) (*Completion, error) {
+
+	log.Printf("[%s] using model: %s, messages: %d", tag, model, len(messages))
+
+	resp, err := client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model:    model,
+		Messages: messages,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Completion{
+		Model:            model,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+	}
+	if len(resp.Choices) > 0 {
+		out.Text = resp.Choices[0].Message.Content
+	}
+
+	return out, nil
+}
+
+// chatCompletionStream — onDelta получает весь накопленный на данный момент текст (не только дельту).
+// includeUsage — просить usage последним чанком (stream_options).
+func chatCompletionStream(
+	ctx context.Context,
+	client *openai.Client,
+	tag string,
+	messages []openai.ChatCompletionMessage,
+	model string,
+	includeUsage bool,
+	onDelta func(text string),
+) (*Completion, error) {
+
+	log.Printf("[%s] stream using model: %s, messages: %d", tag, model, len(messages))
+
+	req := openai.ChatCompletionRequest{
+		Model:    model,
+		Messages: messages,
+		Stream:   true,
+	}
+	if includeUsage {
+		req.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+	}
+
+	stream, err := client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	var sb strings.Builder
+	out := &Completion{Model: model}
+
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.Usage != nil {
+			out.PromptTokens = resp.Usage.PromptTokens
+			out.CompletionTokens = resp.Usage.CompletionTokens
+		}
+
+		if len(resp.Choices) == 0 {
+			continue
+		}
+
+		delta := resp.Choices[0].Delta.Content
+		if delta == "" {
+			continue
+		}
+
+		sb.WriteString(delta)
+		if onDelta != nil {
+			onDelta(sb.String())
+		}
+	}
+
+	out.Text = sb.String()
+	return out, nil
+}
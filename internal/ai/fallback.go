@@ -0,0 +1,419 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vovarama1992/make_ziper/internal/bots"
+	"github.com/Vovarama1992/make_ziper/internal/tokens"
+	"github.com/Vovarama1992/make_ziper/internal/usage"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	// таймаут одного обращения к модели
+	gptCallTimeout = 120 * time.Second
+
+	// повторы на одной модели при 429/5xx
+	gptMaxRetries = 2
+	gptRetryBase  = 1 * time.Second
+
+	// после стольких сбоев подряд модель пропускается на breakerCooldown
+	breakerThreshold = 3
+	breakerCooldown  = 2 * time.Minute
+)
+
+// Reply — ответ модели и то, кто его на самом деле дал (с учётом fallback)
+type Reply struct {
+	Text     string
+	Provider string
+	Model    string
+
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// ModelRef — "provider:model", как в bot_configs.fallback_models и records.model
+func (r *Reply) ModelRef() string {
+	return r.Provider + ":" + r.Model
+}
+
+type modelCandidate struct {
+	provider ChatProvider
+	model    string
+}
+
+func (c modelCandidate) key() string {
+	return c.provider.Name() + ":" + c.model
+}
+
+// ErrModelNotAllowed — тариф пользователя не разрешает ни одну модель из цепочки бота
+var ErrModelNotAllowed = errors.New("no model allowed by tariff")
+
+type allowedModelsKey struct{}
+
+// WithAllowedModels — ограничение цепочки моделей для запроса (features.models тарифа).
+// Пустой список — без ограничений.
+func WithAllowedModels(ctx context.Context, models []string) context.Context {
+	if len(models) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, allowedModelsKey{}, models)
+}
+
+func allowedModels(ctx context.Context) []string {
+	models, _ := ctx.Value(allowedModelsKey{}).([]string)
+	return models
+}
+
+// candidates — основная модель бота + fallback_models.
+// Элемент fallback: "model" (тот же провайдер) или "provider:model".
+func (s *AiService) candidates(ctx context.Context, cfg *bots.BotConfig) ([]modelCandidate, error) {
+	primary, err := s.providerFor(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []modelCandidate{{provider: primary, model: cfg.Model}}
+
+	for _, ref := range cfg.FallbackModels {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+
+		c := modelCandidate{provider: primary, model: ref}
+
+		if name, model, ok := strings.Cut(ref, ":"); ok {
+			p, found := s.providers[name]
+			if !found {
+				log.Printf("[ai] bot=%s fallback %q: unknown provider, skip", cfg.BotID, ref)
+				continue
+			}
+			c = modelCandidate{provider: p, model: model}
+		}
+
+		out = append(out, c)
+	}
+
+	if allowed := allowedModels(ctx); len(allowed) > 0 {
+		filtered := out[:0]
+		for _, c := range out {
+			if modelAllowed(allowed, c) {
+				filtered = append(filtered, c)
+			}
+		}
+		if len(filtered) == 0 {
+			return nil, ErrModelNotAllowed
+		}
+		out = filtered
+	}
+
+	return out, nil
+}
+
+func modelAllowed(allowed []string, c modelCandidate) bool {
+	for _, ref := range allowed {
+		ref = strings.TrimSpace(ref)
+		if ref == c.model || ref == c.key() {
+			return true
+		}
+	}
+	return false
+}
+
+// complete — проходит по цепочке моделей бота до первого успешного ответа.
+// 429/5xx повторяются с backoff, деградировавшие модели пропускаются (circuit breaker).
+// onDelta == nil → обычный запрос, иначе стрим. Успешный ответ пишется в usage.
+// Перед повтором или следующей моделью onDelta получает "" — накопленный текст начнётся заново.
+func (s *AiService) complete(
+	ctx context.Context,
+	cfg *bots.BotConfig,
+	telegramID int64,
+	branch string,
+	messages []openai.ChatCompletionMessage,
+	onDelta func(text string),
+) (*Reply, error) {
+
+	all, err := s.candidates(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := make([]modelCandidate, 0, len(all))
+	for _, c := range all {
+		if s.breaker.allow(c.key()) {
+			chain = append(chain, c)
+		} else {
+			log.Printf("[ai] bot=%s skip %s: circuit open", cfg.BotID, c.key())
+		}
+	}
+	// открыты все — всё равно пробуем, лучше медленно, чем никак
+	if len(chain) == 0 {
+		chain = all
+	}
+
+	var (
+		lastErr  error
+		lastCand modelCandidate
+	)
+
+	for i, c := range chain {
+		if i > 0 && onDelta != nil {
+			onDelta("")
+		}
+
+		comp, err := s.callWithRetry(ctx, c, messages, onDelta)
+		if err == nil {
+			s.breaker.success(c.key())
+			if i > 0 {
+				log.Printf("[ai] bot=%s answered by fallback %s", cfg.BotID, c.key())
+			}
+
+			reply := &Reply{
+				Text:             comp.Text,
+				Provider:         c.provider.Name(),
+				Model:            comp.Model,
+				PromptTokens:     comp.PromptTokens,
+				CompletionTokens: comp.CompletionTokens,
+			}
+			s.recordUsage(ctx, cfg.BotID, telegramID, branch, messages, reply)
+			return reply, nil
+		}
+
+		lastErr, lastCand = err, c
+
+		// пользователь ушёл / сервис останавливается — дальше не идём
+		if ctx.Err() != nil {
+			return nil, err
+		}
+
+		if classifyGptError(err).degraded() {
+			s.breaker.failure(c.key())
+		}
+
+		log.Printf("[ai] bot=%s %s failed: %v", cfg.BotID, c.key(), err)
+	}
+
+	s.notifyGptError(ctx, cfg.BotID, lastCand.provider.Name(), lastCand.model, lastErr)
+	return nil, lastErr
+}
+
+func (s *AiService) callWithRetry(
+	ctx context.Context,
+	c modelCandidate,
+	messages []openai.ChatCompletionMessage,
+	onDelta func(text string),
+) (*Completion, error) {
+
+	for attempt := 0; ; attempt++ {
+		comp, err := s.call(ctx, c, messages, onDelta)
+		if err == nil {
+			return comp, nil
+		}
+
+		if attempt >= gptMaxRetries || !classifyGptError(err).retryable() {
+			return nil, err
+		}
+
+		// экспоненциальный backoff с джиттером: 1s, 2s (+ до 50%)
+		delay := gptRetryBase << attempt
+		delay += time.Duration(rand.Int63n(int64(delay / 2)))
+
+		log.Printf("[ai] %s retry %d in %s: %v", c.key(), attempt+1, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+
+		if onDelta != nil {
+			onDelta("")
+		}
+	}
+}
+
+func (s *AiService) call(
+	ctx context.Context,
+	c modelCandidate,
+	messages []openai.ChatCompletionMessage,
+	onDelta func(text string),
+) (*Completion, error) {
+	ctxGPT, cancel := context.WithTimeout(ctx, gptCallTimeout)
+	defer cancel()
+
+	if onDelta == nil {
+		return c.provider.GetCompletion(ctxGPT, messages, c.model)
+	}
+	return c.provider.GetCompletionStream(ctxGPT, messages, c.model, onDelta)
+}
+
+// recordUsage — токены от провайдера, а если он их не вернул — оценка tiktoken
+func (s *AiService) recordUsage(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	branch string,
+	messages []openai.ChatCompletionMessage,
+	reply *Reply,
+) {
+	if s.usage == nil {
+		return
+	}
+
+	if reply.PromptTokens == 0 && reply.CompletionTokens == 0 {
+		reply.PromptTokens = tokens.CountMessages(reply.Model, messages)
+		reply.CompletionTokens = tokens.Count(reply.Model, reply.Text)
+	}
+
+	s.usage.Record(ctx, &usage.Event{
+		BotID:            botID,
+		TelegramID:       telegramID,
+		Branch:           branch,
+		Kind:             usage.KindChat,
+		Provider:         reply.Provider,
+		Model:            reply.Model,
+		PromptTokens:     reply.PromptTokens,
+		CompletionTokens: reply.CompletionTokens,
+	})
+}
+
+// ==================================================
+// КЛАССИФИКАЦИЯ ОШИБОК
+// ==================================================
+
+type gptErrorClass int
+
+const (
+	gptErrUnknown gptErrorClass = iota
+	gptErrAuth
+	gptErrModelNotFound
+	gptErrRateLimit
+	gptErrBadModel
+	gptErrBadRequest
+	gptErrServer
+	gptErrTimeout
+)
+
+// retryable — имеет смысл повторить на той же модели
+func (c gptErrorClass) retryable() bool {
+	return c == gptErrRateLimit || c == gptErrServer
+}
+
+// degraded — модель/провайдер сейчас не справляется, считаем в circuit breaker
+func (c gptErrorClass) degraded() bool {
+	return c == gptErrRateLimit || c == gptErrServer || c == gptErrTimeout
+}
+
+func classifyGptError(err error) gptErrorClass {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return gptErrTimeout
+	}
+
+	status := 0
+
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		status = reqErr.HTTPStatusCode
+	}
+
+	msg := strings.ToLower(err.Error())
+	if status == 0 {
+		// не-openai клиенты и старые форматы ошибок — по тексту
+		for _, code := range []int{401, 404, 429, 400, 500, 502, 503, 504} {
+			if strings.Contains(msg, fmt.Sprintf("status code: %d", code)) {
+				status = code
+				break
+			}
+		}
+	}
+
+	switch {
+	case status == 401:
+		return gptErrAuth
+	case status == 404:
+		return gptErrModelNotFound
+	case status == 429:
+		return gptErrRateLimit
+	case status == 400 && strings.Contains(msg, "model"):
+		return gptErrBadModel
+	case status == 400:
+		return gptErrBadRequest
+	case status >= 500:
+		return gptErrServer
+	}
+	return gptErrUnknown
+}
+
+// ==================================================
+// CIRCUIT BREAKER
+// ==================================================
+
+type breakerState struct {
+	failures  int
+	openUntil time.Time
+}
+
+// circuitBreaker — по ключу "provider:model".
+// После breakerThreshold сбоев подряд ключ закрыт на cooldown,
+// затем пропускается пробный запрос: успех сбрасывает счётчик, сбой снова открывает.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	states    map[string]*breakerState
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		states:    make(map[string]*breakerState),
+	}
+}
+
+func (b *circuitBreaker) allow(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st, ok := b.states[key]
+	if !ok {
+		return true
+	}
+	return !time.Now().Before(st.openUntil)
+}
+
+func (b *circuitBreaker) success(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.states, key)
+}
+
+func (b *circuitBreaker) failure(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	st, ok := b.states[key]
+	if !ok {
+		st = &breakerState{}
+		b.states[key] = st
+	}
+
+	st.failures++
+	if st.failures >= b.threshold {
+		st.openUntil = time.Now().Add(b.cooldown)
+		log.Printf("[ai] circuit open %s for %s", key, b.cooldown)
+	}
+}
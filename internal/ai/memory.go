@@ -0,0 +1,84 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Vovarama1992/make_ziper/internal/memory"
+	"github.com/Vovarama1992/make_ziper/internal/usage"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+var _ memory.Summarizer = (*AiService)(nil)
+
+const memoryPrompt = `Ты ведёшь краткую память репетитора об ученике.
+Тебе дают прежнюю память (может быть пустой) и фрагмент переписки, который больше не помещается в контекст.
+Перепиши память целиком с учётом нового фрагмента:
+- какие темы и задачи уже разобраны, к каким выводам пришли;
+- в чём ученик путается, что ему даётся легко;
+- договорённости и предпочтения (формат объяснений, цели, сроки).
+Пиши по-русски, пунктами, без приветствий и оценок. Не более 1500 символов.
+Если во фрагменте нет ничего важного — верни прежнюю память без изменений.`
+
+// Summarize — сжимает выпавшую из окна историю моделью (и fallback-цепочкой) самого бота
+func (s *AiService) Summarize(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	previous string,
+	transcript string,
+) (string, error) {
+
+	cfg, err := s.botsRepo.Get(ctx, botID)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.TrimSpace(previous) == "" {
+		previous = "(пусто)"
+	}
+
+	messages := []openai.ChatCompletionMessage{
+		{Role: "system", Content: memoryPrompt},
+		{Role: "user", Content: "Прежняя память:\n" + previous + "\n\nНовый фрагмент переписки:\n" + transcript},
+	}
+
+	reply, err := s.complete(ctx, cfg, telegramID, usage.BranchMemory, messages, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return reply.Text, nil
+}
+
+// appendMemory — память об ученике отдельным system-сообщением перед историей
+func (s *AiService) appendMemory(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	messages []openai.ChatCompletionMessage,
+) []openai.ChatCompletionMessage {
+
+	if s.memory == nil {
+		return messages
+	}
+
+	m, err := s.memory.Get(ctx, botID, telegramID)
+	if err != nil {
+		log.Printf("[ai] memory bot=%s tg=%d: %v", botID, telegramID, err)
+		return messages
+	}
+	if m == nil || strings.TrimSpace(m.Summary) == "" {
+		return messages
+	}
+
+	return append(messages, openai.ChatCompletionMessage{
+		Role: "system",
+		Content: fmt.Sprintf(
+			"Память об ученике (краткое содержание прошлых занятий, которых уже нет в истории):\n%s",
+			m.Summary,
+		),
+	})
+}
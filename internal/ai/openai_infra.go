@@ -23,6 +23,10 @@ func NewOpenAIClient() *OpenAIClient {
 	}
 }
 
+func (c *OpenAIClient) Name() string {
+	return ProviderOpenAI
+}
+
 // ---------------------
 //
 //	CHAT COMPLETION
@@ -32,27 +36,29 @@ func (c *OpenAIClient) GetCompletion(
 	ctx context.Context,
 	messages []openai.ChatCompletionMessage,
 	model string,
-) (string, error) {
+) (*Completion, error) {
 
 	if model == "" {
 		model = openai.GPT4oMini
 	}
 
-	log.Printf("[openai] using model: %s, messages: %d", model, len(messages))
+	return chatCompletion(ctx, c.client, "openai", messages, model)
+}
 
-	resp, err := c.client.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
-		Model:    model,
-		Messages: messages,
-	})
-	if err != nil {
-		return "", err
-	}
+// GetCompletionStream — то же, что GetCompletion, но отдаёт ответ по мере генерации.
+// onDelta получает весь накопленный на данный момент текст (не только дельту).
+func (c *OpenAIClient) GetCompletionStream(
+	ctx context.Context,
+	messages []openai.ChatCompletionMessage,
+	model string,
+	onDelta func(text string),
+) (*Completion, error) {
 
-	if len(resp.Choices) == 0 {
-		return "", nil
+	if model == "" {
+		model = openai.GPT4oMini
 	}
 
-	return resp.Choices[0].Message.Content, nil
+	return chatCompletionStream(ctx, c.client, "openai", messages, model, true, onDelta)
 }
 
 // ---------------------
@@ -8,11 +8,16 @@ import (
 	"net/http"
 	"os"
 	"time"
+
+	openai "github.com/sashabaranov/go-openai"
 )
 
 type PerplexityClient struct {
 	apiKey string
 	client *http.Client
+
+	// тот же API в OpenAI-совместимом виде — для ботов с provider=perplexity
+	chat *CompatibleClient
 }
 
 func NewPerplexityClient() *PerplexityClient {
@@ -24,9 +29,31 @@ func NewPerplexityClient() *PerplexityClient {
 	return &PerplexityClient{
 		apiKey: key,
 		client: &http.Client{Timeout: 60 * time.Second},
+		chat:   NewCompatibleClient(ProviderPerplexity, "https://api.perplexity.ai", key, "sonar"),
 	}
 }
 
+func (c *PerplexityClient) Name() string {
+	return ProviderPerplexity
+}
+
+func (c *PerplexityClient) GetCompletion(
+	ctx context.Context,
+	messages []openai.ChatCompletionMessage,
+	model string,
+) (*Completion, error) {
+	return c.chat.GetCompletion(ctx, messages, model)
+}
+
+func (c *PerplexityClient) GetCompletionStream(
+	ctx context.Context,
+	messages []openai.ChatCompletionMessage,
+	model string,
+	onDelta func(text string),
+) (*Completion, error) {
+	return c.chat.GetCompletionStream(ctx, messages, model, onDelta)
+}
+
 type perplexityRequest struct {
 	Model    string `json:"model"`
 	Messages []struct {
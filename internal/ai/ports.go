@@ -1,6 +1,39 @@
 package ai
 
-import "context"
+import (
+	"context"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// имена провайдеров — значения bot_configs.provider
+const (
+	ProviderOpenAI     = "openai"
+	ProviderPerplexity = "perplexity"
+	ProviderCompatible = "compatible"
+)
+
+// Completion — ответ провайдера. Токены 0, если провайдер их не вернул.
+type Completion struct {
+	Text             string
+	Model            string // фактическая модель (с учётом дефолта провайдера)
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// ChatProvider — LLM-бэкенд с чатом в формате OpenAI.
+// model пустая → модель провайдера по умолчанию.
+type ChatProvider interface {
+	Name() string
+	GetCompletion(ctx context.Context, messages []openai.ChatCompletionMessage, model string) (*Completion, error)
+	// onDelta получает весь накопленный текст ответа
+	GetCompletionStream(
+		ctx context.Context,
+		messages []openai.ChatCompletionMessage,
+		model string,
+		onDelta func(text string),
+	) (*Completion, error)
+}
 
 type Service interface {
 	// GetReply получает ответ GPT на новое сообщение пользователя.
@@ -9,56 +9,101 @@ import (
 
 	"github.com/Vovarama1992/make_ziper/internal/bots"
 	"github.com/Vovarama1992/make_ziper/internal/classes"
+	"github.com/Vovarama1992/make_ziper/internal/memory"
 	notificator "github.com/Vovarama1992/make_ziper/internal/notificator"
 	"github.com/Vovarama1992/make_ziper/internal/ports"
+	"github.com/Vovarama1992/make_ziper/internal/tokens"
+	"github.com/Vovarama1992/make_ziper/internal/usage"
 	openai "github.com/sashabaranov/go-openai"
 )
 
 type AiService struct {
-	openaiClient     *OpenAIClient
+	// ChatProvider по bot_configs.provider
+	providers        map[string]ChatProvider
+	breaker          *circuitBreaker
 	perplexityClient *PerplexityClient
 
 	recordService ports.RecordService
+	usage         usage.Service
 	botsRepo      bots.Repo
 	classService  classes.ClassService
 	Notifier      notificator.Notificator
+
+	// память об ученике; nil — выпавшая из окна история просто теряется
+	memory memory.Service
 }
 
 func NewAiService(
-	openaiClient *OpenAIClient,
+	providers []ChatProvider,
 	perplexityClient *PerplexityClient,
 	recordSvc ports.RecordService,
+	usageSvc usage.Service,
 	botsRepo bots.Repo,
 	classSvc classes.ClassService,
 	notifier notificator.Notificator,
 ) *AiService {
-	return &AiService{
-		openaiClient:     openaiClient,
+	s := &AiService{
+		providers:        make(map[string]ChatProvider),
+		breaker:          newCircuitBreaker(breakerThreshold, breakerCooldown),
 		perplexityClient: perplexityClient,
 		recordService:    recordSvc,
+		usage:            usageSvc,
 		botsRepo:         botsRepo,
 		classService:     classSvc,
 		Notifier:         notifier,
 	}
+
+	for _, p := range providers {
+		s.RegisterProvider(p)
+	}
+
+	return s
+}
+
+// SetMemory — memory.Service сам зависит от AiService (Summarizer), поэтому ставится после создания
+func (s *AiService) SetMemory(m memory.Service) {
+	s.memory = m
+}
+
+// RegisterProvider — добавляет/подменяет провайдера (в т.ч. фейкового в тестах)
+func (s *AiService) RegisterProvider(p ChatProvider) {
+	s.providers[p.Name()] = p
+}
+
+// providerFor — провайдер из конфига бота, по умолчанию openai
+func (s *AiService) providerFor(ctx context.Context, cfg *bots.BotConfig) (ChatProvider, error) {
+	name := strings.TrimSpace(cfg.Provider)
+	if name == "" {
+		name = ProviderOpenAI
+	}
+
+	p, ok := s.providers[name]
+	if !ok {
+		err := fmt.Errorf("unknown provider %q", name)
+		s.notifyConfigError(ctx, cfg.BotID, err)
+		return nil, err
+	}
+
+	return p, nil
 }
 
 // диагностика ошибок GPT
 func analyzeOpenAIError(err error) string {
-	msg := strings.ToLower(err.Error())
-
-	switch {
-	case strings.Contains(msg, "status code: 401"):
+	switch classifyGptError(err) {
+	case gptErrAuth:
 		return "Неверный API-ключ OpenAI."
-	case strings.Contains(msg, "status code: 404"):
+	case gptErrModelNotFound:
 		return "Модель не найдена."
-	case strings.Contains(msg, "status code: 429"):
+	case gptErrRateLimit:
 		return "Превышен лимит OpenAI."
-	case strings.Contains(msg, "status code: 400") && strings.Contains(msg, "model"):
+	case gptErrBadModel:
 		return "Неверно указана модель."
-	case strings.Contains(msg, "status code: 400"):
+	case gptErrBadRequest:
 		return "Некорректный запрос к OpenAI."
-	case strings.Contains(msg, "status code: 500"):
+	case gptErrServer:
 		return "Внутренняя ошибка OpenAI."
+	case gptErrTimeout:
+		return "Модель не ответила вовремя."
 	}
 	return "Неизвестная ошибка OpenAI: " + err.Error()
 }
@@ -69,11 +114,11 @@ func (s *AiService) notifyConfigError(ctx context.Context, botID string, err err
 		fmt.Sprintf("Ошибка конфигурации бота %s: %v", botID, err))
 }
 
-func (s *AiService) notifyGptError(ctx context.Context, botID, model string, err error) {
+func (s *AiService) notifyGptError(ctx context.Context, botID, provider, model string, err error) {
 	diag := analyzeOpenAIError(err)
 	s.Notifier.Notify(ctx, botID, err,
-		fmt.Sprintf("Ошибка GPT\nБот: %s\nМодель: %s\n%v\n\n%s",
-			botID, model, err, diag))
+		fmt.Sprintf("Ошибка GPT\nБот: %s\nПровайдер: %s\nМодель: %s\n%v\n\n%s",
+			botID, provider, model, err, diag))
 }
 
 // === главный метод ===
@@ -84,7 +129,7 @@ func (s *AiService) GetReply(
 	branch string, // может быть пустым
 	userText string,
 	imageURL *string,
-) (string, error) {
+) (*Reply, error) {
 
 	if branch == "" {
 		branch = "text"
@@ -93,11 +138,69 @@ func (s *AiService) GetReply(
 	start := time.Now()
 	log.Printf("[ai] >>> START bot=%s tg=%d branch=%s", botID, telegramID, branch)
 
+	cfg, messages, err := s.buildReplyMessages(ctx, botID, telegramID, branch, userText, imageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := s.complete(ctx, cfg, telegramID, branch, messages, nil)
+	log.Printf("[ai][%.1fs] GPT done err=%v", time.Since(start).Seconds(), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// GetReplyStream — потоковый вариант GetReply.
+// onDelta вызывается с накопленным текстом ответа по мере генерации;
+// "" — ответ пошёл заново (повтор или следующая модель в цепочке).
+func (s *AiService) GetReplyStream(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	branch string,
+	userText string,
+	imageURL *string,
+	onDelta func(text string),
+) (*Reply, error) {
+
+	if branch == "" {
+		branch = "text"
+	}
+
+	start := time.Now()
+	log.Printf("[ai] >>> START STREAM bot=%s tg=%d branch=%s", botID, telegramID, branch)
+
+	cfg, messages, err := s.buildReplyMessages(ctx, botID, telegramID, branch, userText, imageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := s.complete(ctx, cfg, telegramID, branch, messages, onDelta)
+	log.Printf("[ai][%.1fs] GPT STREAM done err=%v", time.Since(start).Seconds(), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// buildReplyMessages — конфиг бота + полный набор messages для GetReply / GetReplyStream
+func (s *AiService) buildReplyMessages(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	branch string,
+	userText string,
+	imageURL *string,
+) (*bots.BotConfig, []openai.ChatCompletionMessage, error) {
+
 	// 1) конфиг бота
 	cfg, err := s.botsRepo.Get(ctx, botID)
 	if err != nil {
 		s.notifyConfigError(ctx, botID, err)
-		return "", err
+		return nil, nil, err
 	}
 
 	// 2) стилевой промпт по ветке
@@ -156,15 +259,17 @@ func (s *AiService) GetReply(
 Отвечай на запрос, используя текст последнего сообщения и только связанные с ним файлы.
 `
 
-	// 6) история
-	history, _ := s.recordService.GetFittingHistory(ctx, botID, telegramID)
-	log.Printf("[ai] history entries: %d", len(history))
-
-	// 7) формируем messages
+	// 6) системные промпты, память и последнее сообщение — история заполняет остаток окна
 	messages := []openai.ChatCompletionMessage{
 		{Role: "system", Content: superPrompt},
 		{Role: "system", Content: "Стилевой промпт: " + fullStyle},
 	}
+	messages = s.appendMemory(ctx, botID, telegramID, messages)
+	last := lastUserMessage(userText, imageURL)
+
+	// 7) история
+	history := s.fittingHistory(ctx, cfg, telegramID, append(messages, last), 0)
+	log.Printf("[ai] history entries: %d", len(history))
 
 	for _, r := range history {
 		role := "user"
@@ -198,50 +303,72 @@ func (s *AiService) GetReply(
 	}
 
 	// 8) последнее сообщение
-	if imageURL != nil {
-		if strings.TrimSpace(userText) != "" {
-			messages = append(messages, openai.ChatCompletionMessage{
-				Role: "user",
-				MultiContent: []openai.ChatMessagePart{
-					{Type: openai.ChatMessagePartTypeText, Text: userText},
-					{
-						Type:     openai.ChatMessagePartTypeImageURL,
-						ImageURL: &openai.ChatMessageImageURL{URL: *imageURL},
-					},
-				},
-			})
-		} else {
-			messages = append(messages, openai.ChatCompletionMessage{
-				Role: "user",
-				MultiContent: []openai.ChatMessagePart{
-					{
-						Type:     openai.ChatMessagePartTypeImageURL,
-						ImageURL: &openai.ChatMessageImageURL{URL: *imageURL},
-					},
-				},
-			})
-		}
-	} else {
-		messages = append(messages, openai.ChatCompletionMessage{
+	messages = append(messages, last)
+
+	return cfg, messages, nil
+}
+
+func lastUserMessage(userText string, imageURL *string) openai.ChatCompletionMessage {
+	if imageURL == nil {
+		return openai.ChatCompletionMessage{
 			Role:    "user",
 			Content: userText,
-		})
+		}
 	}
 
-	// 9) GPT
+	parts := []openai.ChatMessagePart{}
+	if strings.TrimSpace(userText) != "" {
+		parts = append(parts, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: userText})
+	}
+	parts = append(parts, openai.ChatMessagePart{
+		Type:     openai.ChatMessagePartTypeImageURL,
+		ImageURL: &openai.ChatMessageImageURL{URL: *imageURL},
+	})
 
-	ctxGPT, cancel := context.WithTimeout(ctx, 120*time.Second)
-	defer cancel()
+	return openai.ChatCompletionMessage{
+		Role:         "user",
+		MultiContent: parts,
+	}
+}
 
-	reply, err := s.openaiClient.GetCompletion(ctxGPT, messages, cfg.Model)
-	log.Printf("[ai][%.1fs] GPT done err=%v", time.Since(start).Seconds(), err)
+// fittingHistory — история, которая влезает в окно модели вместе с prompt
+// (системные промпты + текущее сообщение) и extraTokens сверху.
+// Окно берём по самой "узкой" модели цепочки, чтобы fallback не упёрся в лимит.
+func (s *AiService) fittingHistory(
+	ctx context.Context,
+	cfg *bots.BotConfig,
+	telegramID int64,
+	prompt []openai.ChatCompletionMessage,
+	extraTokens int,
+) []ports.Record {
+
+	model := cfg.Model
+	for _, ref := range cfg.FallbackModels {
+		if _, m, ok := strings.Cut(ref, ":"); ok {
+			ref = m
+		}
+		ref = strings.TrimSpace(ref)
+		if ref != "" && tokens.ContextWindow(ref) < tokens.ContextWindow(model) {
+			model = ref
+		}
+	}
+
+	promptTokens := tokens.CountMessages(model, prompt) + extraTokens
+	budget := tokens.HistoryBudget(model, promptTokens)
 
+	history, err := s.recordService.GetFittingHistory(ctx, cfg.BotID, telegramID, model, budget)
 	if err != nil {
-		s.notifyGptError(ctx, botID, cfg.Model, err)
-		return "", err
+		log.Printf("[ai] history bot=%s tg=%d: %v", cfg.BotID, telegramID, err)
+		return nil
 	}
 
-	return reply, nil
+	// всё, что старше окна, сжимается в память об ученике
+	if s.memory != nil && len(history) > 0 {
+		s.memory.Compact(cfg.BotID, telegramID, history[0].ID)
+	}
+
+	log.Printf("[ai] window model=%s prompt=%d history_budget=%d", model, promptTokens, budget)
+	return history
 }
 
 func (s *AiService) GetReplyWithDirectImage(
@@ -250,7 +377,7 @@ func (s *AiService) GetReplyWithDirectImage(
 	telegramID int64,
 	userText string,
 	imageURL string,
-) (string, error) {
+) (*Reply, error) {
 
 	start := time.Now()
 	log.Printf("[ai] >>> START DIRECT_IMAGE bot=%s tg=%d", botID, telegramID)
@@ -258,7 +385,7 @@ func (s *AiService) GetReplyWithDirectImage(
 	cfg, err := s.botsRepo.Get(ctx, botID)
 	if err != nil {
 		s.notifyConfigError(ctx, botID, err)
-		return "", err
+		return nil, err
 	}
 
 	stylePrompt := strings.TrimSpace(cfg.PhotoStylePrompt)
@@ -283,12 +410,23 @@ func (s *AiService) GetReplyWithDirectImage(
 Не пытайся искать или использовать другие изображения из истории.
 Не проси повторно изображение.`
 
-	history, _ := s.recordService.GetFittingHistory(ctx, botID, telegramID)
-
 	messages := []openai.ChatCompletionMessage{
 		{Role: "system", Content: superPrompt},
 		{Role: "system", Content: "Стилевой промпт: " + fullStyle},
 	}
+	messages = s.appendMemory(ctx, botID, telegramID, messages)
+	last := openai.ChatCompletionMessage{
+		Role: "user",
+		MultiContent: []openai.ChatMessagePart{
+			{Type: openai.ChatMessagePartTypeText, Text: userText},
+			{
+				Type:     openai.ChatMessagePartTypeImageURL,
+				ImageURL: &openai.ChatMessageImageURL{URL: imageURL},
+			},
+		},
+	}
+
+	history := s.fittingHistory(ctx, cfg, telegramID, append(messages, last), 0)
 
 	for _, r := range history {
 		role := "user"
@@ -303,26 +441,12 @@ func (s *AiService) GetReplyWithDirectImage(
 		}
 	}
 
-	messages = append(messages, openai.ChatCompletionMessage{
-		Role: "user",
-		MultiContent: []openai.ChatMessagePart{
-			{Type: openai.ChatMessagePartTypeText, Text: userText},
-			{
-				Type:     openai.ChatMessagePartTypeImageURL,
-				ImageURL: &openai.ChatMessageImageURL{URL: imageURL},
-			},
-		},
-	})
-
-	ctxGPT, cancel := context.WithTimeout(ctx, 120*time.Second)
-	defer cancel()
+	messages = append(messages, last)
 
-	reply, err := s.openaiClient.GetCompletion(ctxGPT, messages, cfg.Model)
+	reply, err := s.complete(ctx, cfg, telegramID, "photo", messages, nil)
 	log.Printf("[ai][%.1fs] DIRECT_IMAGE done err=%v", time.Since(start).Seconds(), err)
-
 	if err != nil {
-		s.notifyGptError(ctx, botID, cfg.Model, err)
-		return "", err
+		return nil, err
 	}
 
 	return reply, nil
@@ -334,15 +458,65 @@ func (s *AiService) GetReplyPDFOptimized(
 	telegramID int64,
 	userText string,
 	maxImages int, // например 1 или 2
-) (string, error) {
+) (*Reply, error) {
 
 	start := time.Now()
 	log.Printf("[ai] >>> START PDF_OPT bot=%s tg=%d", botID, telegramID)
 
+	cfg, messages, err := s.buildPDFMessages(ctx, botID, telegramID, userText, maxImages)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := s.complete(ctx, cfg, telegramID, "pdf", messages, nil)
+	log.Printf("[ai][%.1fs] PDF_OPT done err=%v", time.Since(start).Seconds(), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// GetReplyPDFOptimizedStream — потоковый вариант GetReplyPDFOptimized
+func (s *AiService) GetReplyPDFOptimizedStream(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	userText string,
+	maxImages int,
+	onDelta func(text string),
+) (*Reply, error) {
+
+	start := time.Now()
+	log.Printf("[ai] >>> START PDF_OPT STREAM bot=%s tg=%d", botID, telegramID)
+
+	cfg, messages, err := s.buildPDFMessages(ctx, botID, telegramID, userText, maxImages)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := s.complete(ctx, cfg, telegramID, "pdf", messages, onDelta)
+	log.Printf("[ai][%.1fs] PDF_OPT STREAM done err=%v", time.Since(start).Seconds(), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// buildPDFMessages — messages для разбора PDF: текстовая история + последние maxImages страниц
+func (s *AiService) buildPDFMessages(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	userText string,
+	maxImages int,
+) (*bots.BotConfig, []openai.ChatCompletionMessage, error) {
+
 	cfg, err := s.botsRepo.Get(ctx, botID)
 	if err != nil {
 		s.notifyConfigError(ctx, botID, err)
-		return "", err
+		return nil, nil, err
 	}
 
 	stylePrompt := strings.TrimSpace(cfg.PhotoStylePrompt)
@@ -358,7 +532,20 @@ func (s *AiService) GetReplyPDFOptimized(
 Документ представлен изображениями в истории.
 Используй только последние релевантные страницы.`
 
-	history, _ := s.recordService.GetFittingHistory(ctx, botID, telegramID)
+	last := openai.ChatCompletionMessage{
+		Role:    "user",
+		Content: userText,
+	}
+
+	messages := []openai.ChatCompletionMessage{
+		{Role: "system", Content: superPrompt},
+		{Role: "system", Content: "Стилевой промпт: " + fullStyle},
+	}
+	messages = s.appendMemory(ctx, botID, telegramID, messages)
+
+	// страницы документа идут в запрос отдельно — резервируем под них место
+	history := s.fittingHistory(ctx, cfg, telegramID, append(messages, last),
+		maxImages*(tokens.ImageTokens+tokens.MessageOverhead))
 
 	// --- собираем последние N image_url ---
 	imageURLs := make([]string, 0, maxImages)
@@ -368,11 +555,6 @@ func (s *AiService) GetReplyPDFOptimized(
 		}
 	}
 
-	messages := []openai.ChatCompletionMessage{
-		{Role: "system", Content: superPrompt},
-		{Role: "system", Content: "Стилевой промпт: " + fullStyle},
-	}
-
 	// текстовую историю добавляем всю
 	for _, r := range history {
 		role := "user"
@@ -402,23 +584,9 @@ func (s *AiService) GetReplyPDFOptimized(
 	}
 
 	// финальный текстовый запрос
-	messages = append(messages, openai.ChatCompletionMessage{
-		Role:    "user",
-		Content: userText,
-	})
-
-	ctxGPT, cancel := context.WithTimeout(ctx, 120*time.Second)
-	defer cancel()
-
-	reply, err := s.openaiClient.GetCompletion(ctxGPT, messages, cfg.Model)
-	log.Printf("[ai][%.1fs] PDF_OPT done err=%v", time.Since(start).Seconds(), err)
+	messages = append(messages, last)
 
-	if err != nil {
-		s.notifyGptError(ctx, botID, cfg.Model, err)
-		return "", err
-	}
-
-	return reply, nil
+	return cfg, messages, nil
 }
 
 func (s *AiService) GetPerplexityReply(
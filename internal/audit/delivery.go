@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Handler struct {
+	svc Service
+}
+
+func NewHandler(svc Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// GET /audit?bot_id=&actor_type=&actor_id=&action=&entity=&entity_id=&from=&to=&limit=&offset=
+// from/to — RFC3339 или YYYY-MM-DD (to — не включительно)
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	f := Filter{
+		BotID:     q.Get("bot_id"),
+		ActorType: q.Get("actor_type"),
+		ActorID:   q.Get("actor_id"),
+		Action:    q.Get("action"),
+		Entity:    q.Get("entity"),
+		EntityID:  q.Get("entity_id"),
+	}
+
+	var err error
+	if f.From, err = parseTime(q.Get("from")); err != nil {
+		http.Error(w, "invalid from", http.StatusBadRequest)
+		return
+	}
+	if f.To, err = parseTime(q.Get("to")); err != nil {
+		http.Error(w, "invalid to", http.StatusBadRequest)
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if f.Offset, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	items, err := h.svc.List(r.Context(), f)
+	if err != nil {
+		http.Error(w, "failed to list audit log", http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = []*Entry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+func parseTime(v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
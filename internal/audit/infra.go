@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+)
+
+type repo struct {
+	db *sql.DB
+}
+
+func NewRepo(db *sql.DB) Repo {
+	return &repo{db: db}
+}
+
+func (r *repo) Insert(ctx context.Context, e *Entry) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO audit_log (
+			actor_type, actor_id, actor_name,
+			bot_id, action, entity, entity_id,
+			before, after
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at
+	`,
+		e.ActorType, e.ActorID, e.ActorName,
+		e.BotID, e.Action, e.Entity, e.EntityID,
+		nullJSON(e.Before), nullJSON(e.After),
+	).Scan(&e.ID, &e.CreatedAt)
+}
+
+func (r *repo) List(ctx context.Context, f Filter) ([]*Entry, error) {
+	q := `
+		SELECT id, actor_type, actor_id, actor_name,
+		       bot_id, action, entity, entity_id,
+		       before, after, created_at
+		FROM audit_log
+		WHERE 1=1
+	`
+	args := []any{}
+	add := func(cond string, v any) {
+		args = append(args, v)
+		q += " AND " + cond + " $" + strconv.Itoa(len(args))
+	}
+
+	if f.BotID != "" {
+		add("bot_id =", f.BotID)
+	}
+	if f.ActorType != "" {
+		add("actor_type =", f.ActorType)
+	}
+	if f.ActorID != "" {
+		add("actor_id =", f.ActorID)
+	}
+	if f.Action != "" {
+		add("action =", f.Action)
+	}
+	if f.Entity != "" {
+		add("entity =", f.Entity)
+	}
+	if f.EntityID != "" {
+		add("entity_id =", f.EntityID)
+	}
+	if f.From != nil {
+		add("created_at >=", *f.From)
+	}
+	if f.To != nil {
+		add("created_at <", *f.To)
+	}
+
+	args = append(args, f.Limit, f.Offset)
+	q += " ORDER BY id DESC LIMIT $" + strconv.Itoa(len(args)-1) + " OFFSET $" + strconv.Itoa(len(args))
+
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*Entry
+	for rows.Next() {
+		var e Entry
+		var before, after []byte
+		if err := rows.Scan(
+			&e.ID, &e.ActorType, &e.ActorID, &e.ActorName,
+			&e.BotID, &e.Action, &e.Entity, &e.EntityID,
+			&before, &after, &e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		e.Before = before
+		e.After = after
+		out = append(out, &e)
+	}
+	return out, rows.Err()
+}
+
+// nullJSON — пустое значение пишем как NULL, а не как невалидный jsonb
+func nullJSON(raw []byte) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// кто совершил действие
+const (
+	ActorAdmin    = "admin"     // учётка админки (HTTP API)
+	ActorAdminBot = "admin_bot" // оператор поддержки в админ-боте
+)
+
+// Entry — одно изменение: кто, что, над чем, было/стало
+type Entry struct {
+	ID        int64  `json:"id"`
+	ActorType string `json:"actor_type"`
+	ActorID   string `json:"actor_id"`   // id учётки или telegram id
+	ActorName string `json:"actor_name"` // логин или @username
+
+	BotID    string `json:"bot_id"`
+	Action   string `json:"action"` // tariff.update, prompt.delete, support.reply...
+	Entity   string `json:"entity"`
+	EntityID string `json:"entity_id"`
+
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Filter — выборка для GET /audit; пустые поля не фильтруют
+type Filter struct {
+	BotID     string
+	ActorType string
+	ActorID   string
+	Action    string
+	Entity    string
+	EntityID  string
+	From      *time.Time
+	To        *time.Time
+	Limit     int
+	Offset    int
+}
+
+// Snapshot — состояние сущности для before/after; Value nil — сущности нет
+type Snapshot struct {
+	EntityID string
+	BotID    string
+	Value    any
+}
+
+// SnapshotFunc — как достать сущность, которую меняет запрос;
+// botID — bot_id из пути, query, X-Bot-ID или тела ("" — не передан)
+type SnapshotFunc func(r *http.Request, botID string) (*Snapshot, error)
+
+type Repo interface {
+	Insert(ctx context.Context, e *Entry) error
+	List(ctx context.Context, f Filter) ([]*Entry, error)
+}
+
+type Service interface {
+	// Record — пишет запись; секреты (token, password...) в before/after маскируются
+	Record(ctx context.Context, e *Entry) error
+	List(ctx context.Context, f Filter) ([]*Entry, error)
+}
@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+)
+
+// лимиты выборки GET /audit
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// ключи, значения которых в журнал не попадают
+var secretKeys = map[string]bool{
+	"token":         true,
+	"password":      true,
+	"password_hash": true,
+	"secret":        true,
+	"api_key":       true,
+
+	"cloudpayments_api_secret": true,
+	"yookassa_secret_key":      true,
+}
+
+type service struct {
+	repo Repo
+}
+
+func NewService(repo Repo) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) Record(ctx context.Context, e *Entry) error {
+	e.Before = redact(e.Before)
+	e.After = redact(e.After)
+
+	if err := s.repo.Insert(ctx, e); err != nil {
+		return err
+	}
+
+	log.Printf("[audit] %s %s:%s by %s:%s bot=%s", e.Action, e.Entity, e.EntityID, e.ActorType, e.ActorName, e.BotID)
+	return nil
+}
+
+func (s *service) List(ctx context.Context, f Filter) ([]*Entry, error) {
+	if f.Limit <= 0 {
+		f.Limit = defaultLimit
+	}
+	if f.Limit > maxLimit {
+		f.Limit = maxLimit
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	return s.repo.List(ctx, f)
+}
+
+// redact — маскирует секреты на любом уровне вложенности; не JSON оставляем как есть
+func redact(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		out, _ := json.Marshal(string(raw))
+		return out
+	}
+
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+func redactValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if secretKeys[strings.ToLower(k)] {
+				if val != nil && val != "" {
+					t[k] = "***"
+				}
+				continue
+			}
+			t[k] = redactValue(val)
+		}
+	case []any:
+		for i := range t {
+			t[i] = redactValue(t[i])
+		}
+	}
+	return v
+}
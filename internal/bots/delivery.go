@@ -3,7 +3,10 @@ package bots
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
+	"github.com/Vovarama1992/make_ziper/internal/audit"
+	"github.com/Vovarama1992/make_ziper/internal/ports"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -22,16 +25,27 @@ func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to list bot configs", 500)
 		return
 	}
-	_ = json.NewEncoder(w).Encode(items)
+
+	// operator видит только свои боты
+	admin := ports.AdminFromContext(r.Context())
+	out := make([]*BotConfig, 0, len(items))
+	for _, b := range items {
+		if admin.CanAccessBot(b.BotID) {
+			out = append(out, b)
+		}
+	}
+	_ = json.NewEncoder(w).Encode(out)
 }
 
 func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 	var body struct {
-		BotID      string `json:"bot_id"`
-		Token      string `json:"token"`
-		Model      string `json:"model"`
-		VoiceID    string `json:"voice_id"`
-		ClassLabel string `json:"class_label"`
+		BotID           string `json:"bot_id"`
+		Token           string `json:"token"`
+		Model           string `json:"model"`
+		Provider        string `json:"provider"`
+		PaymentProvider string `json:"payment_provider"`
+		VoiceID         string `json:"voice_id"`
+		ClassLabel      string `json:"class_label"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
@@ -44,12 +58,19 @@ func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if body.PaymentProvider != "" && !ValidPaymentProvider(body.PaymentProvider) {
+		http.Error(w, "payment_provider must be yookassa or cloudpayments", 400)
+		return
+	}
+
 	in := &CreateInput{
-		BotID:      body.BotID,
-		Token:      body.Token,
-		Model:      body.Model,
-		VoiceID:    body.VoiceID,
-		ClassLabel: body.ClassLabel,
+		BotID:           body.BotID,
+		Token:           body.Token,
+		Model:           body.Model,
+		Provider:        body.Provider,
+		PaymentProvider: body.PaymentProvider,
+		VoiceID:         body.VoiceID,
+		ClassLabel:      body.ClassLabel,
 	}
 
 	out, err := h.svc.Create(r.Context(), in)
@@ -88,18 +109,32 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var body struct {
-		NewBotID           *string `json:"new_bot_id"`
-		Token              *string `json:"token"`
-		Model              *string `json:"model"`
-		TextStylePrompt    *string `json:"text_style_prompt"`
-		VoiceStylePrompt   *string `json:"voice_style_prompt"`
-		PhotoStylePrompt   *string `json:"photo_style_prompt"`
-		VoiceID            *string `json:"voice_id"`
-		ClassLabel         *string `json:"class_label"`
-		WelcomeText        *string `json:"welcome_text"`
-		TariffText         *string `json:"tariff_text"`
-		AfterContinueText  *string `json:"after_continue_text"`
-		NoVoiceMinutesText *string `json:"no_voice_minutes_text"`
+		NewBotID           *string   `json:"new_bot_id"`
+		Token              *string   `json:"token"`
+		Model              *string   `json:"model"`
+		Provider           *string   `json:"provider"`
+		FallbackModels     *[]string `json:"fallback_models"`
+		PaymentProvider    *string   `json:"payment_provider"`
+		TextStylePrompt    *string   `json:"text_style_prompt"`
+		VoiceStylePrompt   *string   `json:"voice_style_prompt"`
+		PhotoStylePrompt   *string   `json:"photo_style_prompt"`
+		VoiceID            *string   `json:"voice_id"`
+		ClassLabel         *string   `json:"class_label"`
+		WelcomeText        *string   `json:"welcome_text"`
+		TariffText         *string   `json:"tariff_text"`
+		AfterContinueText  *string   `json:"after_continue_text"`
+		NoVoiceMinutesText *string   `json:"no_voice_minutes_text"`
+
+		PaymentReturnURL      *string `json:"payment_return_url"`
+		ReceiptVatCode        *int    `json:"receipt_vat_code"`
+		ReceiptTaxSystem      *int    `json:"receipt_tax_system"`
+		ReceiptPaymentSubject *string `json:"receipt_payment_subject"`
+		ReceiptEmail          *string `json:"receipt_email"`
+
+		CloudPaymentsPublicID  *string `json:"cloudpayments_public_id"`
+		CloudPaymentsAPISecret *string `json:"cloudpayments_api_secret"`
+		YooKassaShopID         *string `json:"yookassa_shop_id"`
+		YooKassaSecretKey      *string `json:"yookassa_secret_key"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
@@ -107,11 +142,34 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if body.PaymentProvider != nil && !ValidPaymentProvider(*body.PaymentProvider) {
+		http.Error(w, "payment_provider must be yookassa or cloudpayments", 400)
+		return
+	}
+
+	if body.ReceiptVatCode != nil && (*body.ReceiptVatCode < 1 || *body.ReceiptVatCode > 12) {
+		http.Error(w, "receipt_vat_code must be 1..12 (YooKassa vat_code)", 400)
+		return
+	}
+
+	if body.ReceiptTaxSystem != nil && (*body.ReceiptTaxSystem < 0 || *body.ReceiptTaxSystem > 6) {
+		http.Error(w, "receipt_tax_system must be 1..6, 0 to unset", 400)
+		return
+	}
+
+	if body.ReceiptPaymentSubject != nil && strings.TrimSpace(*body.ReceiptPaymentSubject) == "" {
+		http.Error(w, "receipt_payment_subject must not be empty", 400)
+		return
+	}
+
 	in := &UpdateInput{
 		BotID:              botID,
 		NewBotID:           body.NewBotID,
 		Token:              body.Token,
 		Model:              body.Model,
+		Provider:           body.Provider,
+		FallbackModels:     body.FallbackModels,
+		PaymentProvider:    body.PaymentProvider,
 		TextStylePrompt:    body.TextStylePrompt,
 		VoiceStylePrompt:   body.VoiceStylePrompt,
 		PhotoStylePrompt:   body.PhotoStylePrompt,
@@ -121,6 +179,17 @@ func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
 		TariffText:         body.TariffText,
 		AfterContinueText:  body.AfterContinueText,
 		NoVoiceMinutesText: body.NoVoiceMinutesText,
+
+		PaymentReturnURL:      body.PaymentReturnURL,
+		ReceiptVatCode:        body.ReceiptVatCode,
+		ReceiptTaxSystem:      body.ReceiptTaxSystem,
+		ReceiptPaymentSubject: body.ReceiptPaymentSubject,
+		ReceiptEmail:          body.ReceiptEmail,
+
+		CloudPaymentsPublicID:  body.CloudPaymentsPublicID,
+		CloudPaymentsAPISecret: body.CloudPaymentsAPISecret,
+		YooKassaShopID:         body.YooKassaShopID,
+		YooKassaSecretKey:      body.YooKassaSecretKey,
 	}
 
 	out, err := h.svc.Update(r.Context(), in)
@@ -176,3 +245,13 @@ func (h *Handler) UploadWelcomeVideo(w http.ResponseWriter, r *http.Request) {
 		"url": url,
 	})
 }
+
+// AuditSnapshot — настройки бота из пути /bots/{bot_id} для журнала (токены маскирует audit)
+func (h *Handler) AuditSnapshot(r *http.Request, _ string) (*audit.Snapshot, error) {
+	botID := chi.URLParam(r, "bot_id")
+	cfg, err := h.svc.Get(r.Context(), botID)
+	if err != nil || cfg == nil {
+		return nil, err
+	}
+	return &audit.Snapshot{EntityID: botID, BotID: botID, Value: cfg}, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+
+	"github.com/lib/pq"
 )
 
 type repo struct {
@@ -15,57 +17,27 @@ func NewRepo(db *sql.DB) Repo {
 }
 
 func (r *repo) Create(ctx context.Context, in *CreateInput) (*BotConfig, error) {
-	var b BotConfig
-
-	err := r.db.QueryRowContext(ctx, `
+	row := r.db.QueryRowContext(ctx, `
 		INSERT INTO bot_configs (
 			bot_id,
 			token,
 			model,
 			voice_id,
-			class_label
-		) VALUES ($1, $2, $3, $4, $5)
-		RETURNING
-			bot_id,
-			token,
-			model,
-			text_style_prompt,
-			voice_style_prompt,
-			photo_style_prompt,
-			voice_id,
 			class_label,
-			welcome_text,
-			tariff_text,
-			after_continue_text,
-			no_voice_minutes_text,
-			welcome_video
-	`,
+			provider,
+			payment_provider
+		) VALUES ($1, $2, $3, $4, $5, COALESCE(NULLIF($6, ''), 'openai'), COALESCE(NULLIF($7, ''), 'yookassa'))
+		RETURNING `+botConfigColumns,
 		in.BotID,
 		in.Token,
 		in.Model,
 		in.VoiceID,
 		in.ClassLabel,
-	).Scan(
-		&b.BotID,
-		&b.Token,
-		&b.Model,
-		&b.TextStylePrompt,
-		&b.VoiceStylePrompt,
-		&b.PhotoStylePrompt,
-		&b.VoiceID,
-		&b.ClassLabel,
-		&b.WelcomeText,
-		&b.TariffText,
-		&b.AfterContinueText,
-		&b.NoVoiceMinutesText,
-		&b.WelcomeVideo,
+		in.Provider,
+		in.PaymentProvider,
 	)
 
-	if err != nil {
-		return nil, err
-	}
-
-	return &b, nil
+	return scanBotConfig(row)
 }
 
 // --------------------------------------------------
@@ -74,17 +46,7 @@ func (r *repo) Create(ctx context.Context, in *CreateInput) (*BotConfig, error)
 
 func (r *repo) ListAll(ctx context.Context) ([]*BotConfig, error) {
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT bot_id, token, model,
-		       text_style_prompt,
-		       voice_style_prompt,
-		       photo_style_prompt,
-		       voice_id,
-		       class_label,
-		       welcome_text,
-		       tariff_text,
-		       after_continue_text,
-		       no_voice_minutes_text,
-		       welcome_video
+		SELECT `+botConfigColumns+`
 		FROM bot_configs
 		ORDER BY bot_id
 	`)
@@ -96,25 +58,11 @@ func (r *repo) ListAll(ctx context.Context) ([]*BotConfig, error) {
 	var out []*BotConfig
 
 	for rows.Next() {
-		var b BotConfig
-		if err := rows.Scan(
-			&b.BotID,
-			&b.Token,
-			&b.Model,
-			&b.TextStylePrompt,
-			&b.VoiceStylePrompt,
-			&b.PhotoStylePrompt,
-			&b.VoiceID,
-			&b.ClassLabel,
-			&b.WelcomeText,
-			&b.TariffText,
-			&b.AfterContinueText,
-			&b.NoVoiceMinutesText,
-			&b.WelcomeVideo,
-		); err != nil {
+		b, err := scanBotConfig(rows)
+		if err != nil {
 			return nil, err
 		}
-		out = append(out, &b)
+		out = append(out, b)
 	}
 
 	return out, rows.Err()
@@ -125,43 +73,13 @@ func (r *repo) ListAll(ctx context.Context) ([]*BotConfig, error) {
 // --------------------------------------------------
 
 func (r *repo) Get(ctx context.Context, botID string) (*BotConfig, error) {
-	var b BotConfig
-
-	err := r.db.QueryRowContext(ctx, `
-		SELECT bot_id, token, model,
-		       text_style_prompt,
-		       voice_style_prompt,
-		       photo_style_prompt,
-		       voice_id,
-		       class_label,
-		       welcome_text,
-		       tariff_text,
-		       after_continue_text,
-		       no_voice_minutes_text,
-		       welcome_video
+	row := r.db.QueryRowContext(ctx, `
+		SELECT `+botConfigColumns+`
 		FROM bot_configs
 		WHERE bot_id = $1
-	`, botID).Scan(
-		&b.BotID,
-		&b.Token,
-		&b.Model,
-		&b.TextStylePrompt,
-		&b.VoiceStylePrompt,
-		&b.PhotoStylePrompt,
-		&b.VoiceID,
-		&b.ClassLabel,
-		&b.WelcomeText,
-		&b.TariffText,
-		&b.AfterContinueText,
-		&b.NoVoiceMinutesText,
-		&b.WelcomeVideo,
-	)
+	`, botID)
 
-	if err != nil {
-		return nil, err
-	}
-
-	return &b, nil
+	return scanBotConfig(row)
 }
 
 // --------------------------------------------------
@@ -194,6 +112,33 @@ func (r *repo) Update(ctx context.Context, in *UpdateInput) (*BotConfig, error)
 	appendField("after_continue_text", in.AfterContinueText)
 	appendField("no_voice_minutes_text", in.NoVoiceMinutesText)
 	appendField("welcome_video", in.WelcomeVideo)
+	appendField("provider", in.Provider)
+	appendField("payment_provider", in.PaymentProvider)
+	appendField("payment_return_url", in.PaymentReturnURL)
+	appendField("receipt_payment_subject", in.ReceiptPaymentSubject)
+	appendField("receipt_email", in.ReceiptEmail)
+	appendField("cloudpayments_public_id", in.CloudPaymentsPublicID)
+	appendField("cloudpayments_api_secret", in.CloudPaymentsAPISecret)
+	appendField("yookassa_shop_id", in.YooKassaShopID)
+	appendField("yookassa_secret_key", in.YooKassaSecretKey)
+
+	if in.ReceiptVatCode != nil {
+		q += "receipt_vat_code=$" + itoa(idx) + ","
+		args = append(args, *in.ReceiptVatCode)
+		idx++
+	}
+
+	if in.ReceiptTaxSystem != nil {
+		q += "receipt_tax_system=NULLIF($" + itoa(idx) + ", 0),"
+		args = append(args, *in.ReceiptTaxSystem)
+		idx++
+	}
+
+	if in.FallbackModels != nil {
+		q += "fallback_models=$" + itoa(idx) + ","
+		args = append(args, pq.Array(*in.FallbackModels))
+		idx++
+	}
 
 	if len(args) == 0 {
 		return r.Get(ctx, in.BotID)
@@ -203,7 +148,31 @@ func (r *repo) Update(ctx context.Context, in *UpdateInput) (*BotConfig, error)
 
 	q += `
 		WHERE bot_id = $` + itoa(idx) + `
-		RETURNING
+		RETURNING ` + botConfigColumns
+
+	args = append(args, in.BotID)
+
+	b, err := scanBotConfig(r.db.QueryRowContext(ctx, q, args...))
+	if err != nil {
+		return nil, fmt.Errorf("update bot_configs failed: %w | SQL=%s ARGS=%v", err, q, args)
+	}
+
+	return b, nil
+}
+
+func (r *repo) Delete(ctx context.Context, botID string) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		`DELETE FROM bot_configs WHERE bot_id = $1`,
+		botID,
+	)
+	return err
+}
+
+// --------------------------------------------------
+
+// botConfigColumns — порядок колонок должен совпадать со scanBotConfig
+const botConfigColumns = `
 			bot_id,
 			token,
 			model,
@@ -216,13 +185,29 @@ func (r *repo) Update(ctx context.Context, in *UpdateInput) (*BotConfig, error)
 			tariff_text,
 			after_continue_text,
 			no_voice_minutes_text,
-			welcome_video
-	`
-
-	args = append(args, in.BotID)
+			welcome_video,
+			provider,
+			fallback_models,
+			payment_provider,
+			payment_return_url,
+			receipt_vat_code,
+			receipt_tax_system,
+			receipt_payment_subject,
+			receipt_email,
+			cloudpayments_public_id,
+			cloudpayments_api_secret,
+			yookassa_shop_id,
+			yookassa_secret_key
+`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
+func scanBotConfig(row rowScanner) (*BotConfig, error) {
 	var b BotConfig
-	err := r.db.QueryRowContext(ctx, q, args...).Scan(
+
+	err := row.Scan(
 		&b.BotID,
 		&b.Token,
 		&b.Model,
@@ -236,25 +221,26 @@ func (r *repo) Update(ctx context.Context, in *UpdateInput) (*BotConfig, error)
 		&b.AfterContinueText,
 		&b.NoVoiceMinutesText,
 		&b.WelcomeVideo,
+		&b.Provider,
+		pq.Array(&b.FallbackModels),
+		&b.PaymentProvider,
+		&b.PaymentReturnURL,
+		&b.ReceiptVatCode,
+		&b.ReceiptTaxSystem,
+		&b.ReceiptPaymentSubject,
+		&b.ReceiptEmail,
+		&b.CloudPaymentsPublicID,
+		&b.CloudPaymentsAPISecret,
+		&b.YooKassaShopID,
+		&b.YooKassaSecretKey,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("update bot_configs failed: %w | SQL=%s ARGS=%v", err, q, args)
+		return nil, err
 	}
 
 	return &b, nil
 }
 
-func (r *repo) Delete(ctx context.Context, botID string) error {
-	_, err := r.db.ExecContext(
-		ctx,
-		`DELETE FROM bot_configs WHERE bot_id = $1`,
-		botID,
-	)
-	return err
-}
-
-// --------------------------------------------------
-
 func itoa(i int) string {
 	return fmt.Sprintf("%d", i)
 }
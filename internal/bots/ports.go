@@ -22,12 +22,45 @@ type Service interface {
 
 	// загрузка приветственного видео → S3 → запись в bot_configs
 	UploadWelcomeVideo(ctx context.Context, botID string, file io.Reader, filename string) (string, error)
+
+	// подписка на изменения конфигов (hot reload ботов)
+	SetChangeListener(l ChangeListener)
+}
+
+// ChangeListener — получает мутации bot_configs после успешной записи в БД
+type ChangeListener interface {
+	// prevBotID — bot_id до изменения (при создании совпадает с cfg.BotID)
+	BotUpserted(ctx context.Context, prevBotID string, cfg *BotConfig)
+	BotDeleted(ctx context.Context, botID string)
 }
 
 type BotConfig struct {
-	BotID            string `json:"bot_id"`
-	Token            string `json:"token"`
-	Model            string `json:"model"`
+	BotID    string `json:"bot_id"`
+	Token    string `json:"token"`
+	Model    string `json:"model"`
+	Provider string `json:"provider"` // ai.ChatProvider: openai | perplexity | compatible
+
+	// запасные модели по порядку: "model" или "provider:model"
+	FallbackModels []string `json:"fallback_models"`
+
+	// платёжка бота: yookassa | cloudpayments
+	PaymentProvider string `json:"payment_provider"`
+
+	// кабинет CloudPayments бота, nil — CLOUDPAYMENTS_* из env; секрет наружу не отдаём
+	CloudPaymentsPublicID  *string `json:"cloudpayments_public_id"`
+	CloudPaymentsAPISecret *string `json:"-"`
+
+	// магазин ЮKassa бота, nil — YOOKASSA_* из env
+	YooKassaShopID    *string `json:"yookassa_shop_id"`
+	YooKassaSecretKey *string `json:"-"`
+
+	// оплата и чек 54-ФЗ
+	PaymentReturnURL      *string `json:"payment_return_url"`      // nil — PAYMENT_RETURN_URL
+	ReceiptVatCode        int     `json:"receipt_vat_code"`        // коды ЮKassa, 1 — без НДС
+	ReceiptTaxSystem      *int    `json:"receipt_tax_system"`      // 1–6, nil — не передаём
+	ReceiptPaymentSubject string  `json:"receipt_payment_subject"` // service
+	ReceiptEmail          *string `json:"receipt_email"`           // email магазина, если покупатель контакт не оставил
+
 	TextStylePrompt  string `json:"text_style_prompt"`
 	VoiceStylePrompt string `json:"voice_style_prompt"`
 	PhotoStylePrompt string `json:"photo_style_prompt"`
@@ -47,6 +80,9 @@ type UpdateInput struct {
 	NewBotID           *string
 	Token              *string
 	Model              *string
+	Provider           *string
+	FallbackModels     *[]string
+	PaymentProvider    *string
 	TextStylePrompt    *string
 	VoiceStylePrompt   *string
 	PhotoStylePrompt   *string
@@ -57,6 +93,17 @@ type UpdateInput struct {
 	AfterContinueText  *string
 	NoVoiceMinutesText *string
 
+	PaymentReturnURL      *string
+	ReceiptVatCode        *int
+	ReceiptTaxSystem      *int // 0 — не передавать
+	ReceiptPaymentSubject *string
+	ReceiptEmail          *string
+
+	CloudPaymentsPublicID  *string
+	CloudPaymentsAPISecret *string
+	YooKassaShopID         *string
+	YooKassaSecretKey      *string
+
 	// INTERNAL USE ONLY
 	WelcomeVideo *string
 }
@@ -69,4 +116,15 @@ type CreateInput struct {
 	Model      string
 	VoiceID    string
 	ClassLabel string
+
+	// пусто → openai
+	Provider string
+
+	// пусто → yookassa
+	PaymentProvider string
+}
+
+// ValidPaymentProvider — платёжки, которые умеет payments.Router
+func ValidPaymentProvider(p string) bool {
+	return p == "yookassa" || p == "cloudpayments"
 }
@@ -10,6 +10,8 @@ import (
 type service struct {
 	repo Repo
 	s3   ports.S3Service
+
+	listener ChangeListener
 }
 
 func NewService(repo Repo, s3 ports.S3Service) Service {
@@ -19,8 +21,20 @@ func NewService(repo Repo, s3 ports.S3Service) Service {
 	}
 }
 
+func (s *service) SetChangeListener(l ChangeListener) {
+	s.listener = l
+}
+
 func (s *service) Create(ctx context.Context, in *CreateInput) (*BotConfig, error) {
-	return s.repo.Create(ctx, in)
+	cfg, err := s.repo.Create(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.listener != nil {
+		s.listener.BotUpserted(ctx, cfg.BotID, cfg)
+	}
+	return cfg, nil
 }
 
 func (s *service) ListAll(ctx context.Context) ([]*BotConfig, error) {
@@ -32,11 +46,26 @@ func (s *service) Get(ctx context.Context, botID string) (*BotConfig, error) {
 }
 
 func (s *service) Update(ctx context.Context, in *UpdateInput) (*BotConfig, error) {
-	return s.repo.Update(ctx, in)
+	cfg, err := s.repo.Update(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.listener != nil {
+		s.listener.BotUpserted(ctx, in.BotID, cfg)
+	}
+	return cfg, nil
 }
 
 func (s *service) Delete(ctx context.Context, botID string) error {
-	return s.repo.Delete(ctx, botID)
+	if err := s.repo.Delete(ctx, botID); err != nil {
+		return err
+	}
+
+	if s.listener != nil {
+		s.listener.BotDeleted(ctx, botID)
+	}
+	return nil
 }
 
 // =========================================================
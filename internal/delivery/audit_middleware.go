@@ -0,0 +1,189 @@
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Vovarama1992/make_ziper/internal/audit"
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+	"github.com/go-chi/chi/v5"
+)
+
+// сколько ответа хендлера держим в памяти для after
+const maxAuditResponse = 64 << 10
+
+// Auditor — middleware журнала изменений для мутирующих ручек
+type Auditor struct {
+	svc audit.Service
+}
+
+func NewAuditor(svc audit.Service) *Auditor {
+	return &Auditor{svc: svc}
+}
+
+// Track — пишет в журнал успешный запрос.
+// snap достаёт сущность до и после изменения; без snap after — ответ хендлера или тело запроса.
+func (a *Auditor) Track(action, entity string, snap audit.SnapshotFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reqBody := readJSONBody(r)
+
+			e := &audit.Entry{
+				ActorType: audit.ActorAdmin,
+				Action:    action,
+				Entity:    entity,
+				EntityID:  urlParamsID(r),
+			}
+			if admin := ports.AdminFromContext(r.Context()); admin != nil {
+				e.ActorID = strconv.FormatInt(admin.ID, 10)
+				e.ActorName = admin.Login
+			}
+			if ids, err := requestBotIDs(r); err == nil && len(ids) > 0 {
+				e.BotID = ids[0]
+			}
+
+			// до изменения (у создания snap нет)
+			if snap != nil {
+				if s := takeSnapshot(snap, r, e.BotID); s != nil {
+					applySnapshot(e, s)
+					e.Before = marshalAudit(s.Value)
+				}
+			}
+
+			rec := &auditRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			if rec.status >= 300 {
+				return
+			}
+
+			switch {
+			case r.Method == http.MethodDelete:
+				// после удаления сущности нет
+			case snap != nil:
+				if s := takeSnapshot(snap, r, e.BotID); s != nil {
+					applySnapshot(e, s)
+					e.After = marshalAudit(s.Value)
+				}
+			case isJSON(rec.body.Bytes()):
+				e.After = append(json.RawMessage(nil), rec.body.Bytes()...)
+				if e.EntityID == "" {
+					e.EntityID = jsonID(rec.body.Bytes())
+				}
+			case isJSON(reqBody):
+				e.After = reqBody
+			}
+
+			// запрос уже выполнен: журнал пишем и после отмены клиентом
+			ctx := context.WithoutCancel(r.Context())
+			if err := a.svc.Record(ctx, e); err != nil {
+				log.Printf("[audit] record %s %s:%s: %v", e.Action, e.Entity, e.EntityID, err)
+			}
+		})
+	}
+}
+
+func takeSnapshot(snap audit.SnapshotFunc, r *http.Request, botID string) *audit.Snapshot {
+	s, err := snap(r, botID)
+	if err != nil {
+		log.Printf("[audit] snapshot %s %s: %v", r.Method, r.URL.Path, err)
+		return nil
+	}
+	return s
+}
+
+func applySnapshot(e *audit.Entry, s *audit.Snapshot) {
+	if s.EntityID != "" {
+		e.EntityID = s.EntityID
+	}
+	if s.BotID != "" {
+		e.BotID = s.BotID
+	}
+}
+
+func marshalAudit(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
+// auditRecorder — статус и начало тела ответа
+type auditRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *auditRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *auditRecorder) Write(p []byte) (int, error) {
+	if room := maxAuditResponse - r.body.Len(); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		r.body.Write(p[:room])
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// readJSONBody — JSON-тело запроса без потери его для хендлера
+func readJSONBody(r *http.Request) json.RawMessage {
+	if r.Body == nil {
+		return nil
+	}
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if ct != "application/json" && ct != "" {
+		return nil
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(r.Body, maxScopedBody))
+	if err != nil {
+		return nil
+	}
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+
+	if !isJSON(raw) {
+		return nil
+	}
+	return raw
+}
+
+func isJSON(raw []byte) bool {
+	raw = bytes.TrimSpace(raw)
+	return len(raw) > 0 && json.Valid(raw)
+}
+
+// urlParamsID — id сущности из пути: значения всех параметров через "/"
+func urlParamsID(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return ""
+	}
+	return strings.Join(rctx.URLParams.Values, "/")
+}
+
+// jsonID — поле id созданной сущности из ответа
+func jsonID(raw []byte) string {
+	var v struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if json.Unmarshal(raw, &v) != nil || len(v.ID) == 0 {
+		return ""
+	}
+	return strings.Trim(string(v.ID), `"`)
+}
@@ -2,9 +2,12 @@ package delivery
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/Vovarama1992/make_ziper/internal/ports"
+	"github.com/go-chi/chi/v5"
 )
 
 type AuthHandler struct {
@@ -15,8 +18,10 @@ func NewAuthHandler(auth ports.AuthService) *AuthHandler {
 	return &AuthHandler{auth: auth}
 }
 
+// POST /auth/login {login, password}; без login — учётка "admin"
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req struct {
+		Login    string `json:"login"`
 		Password string `json:"password"`
 	}
 
@@ -25,14 +30,123 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := h.auth.Login(r.Context(), req.Password)
-	if err != nil {
+	token, err := h.auth.Login(r.Context(), req.Login, req.Password)
+	if errors.Is(err, ports.ErrInvalidCredentials) {
 		http.Error(w, "invalid password", http.StatusUnauthorized)
 		return
 	}
+	if err != nil {
+		http.Error(w, "login failed", http.StatusInternalServerError)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]string{
 		"token": token,
 	})
 }
+
+// POST /auth/logout — отзыв текущего токена
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if err := h.auth.Logout(r.Context(), requestToken(r)); err != nil {
+		http.Error(w, "logout failed", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /auth/me — кто вошёл и какие боты ему доступны
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ports.AdminFromContext(r.Context()))
+}
+
+// ==================================================
+// ADMINS (только owner)
+// ==================================================
+
+// GET /admins
+func (h *AuthHandler) ListAdmins(w http.ResponseWriter, r *http.Request) {
+	items, err := h.auth.ListAdmins(r.Context())
+	if err != nil {
+		http.Error(w, "failed to list admins", http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = []*ports.Admin{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// POST /admins {login, password, role, bot_ids}
+func (h *AuthHandler) CreateAdmin(w http.ResponseWriter, r *http.Request) {
+	var in ports.AdminInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	a, err := h.auth.CreateAdmin(r.Context(), in)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(a)
+}
+
+// PATCH /admins/{id} {login?, password?, role?, bot_ids?, active?}
+func (h *AuthHandler) UpdateAdmin(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var in ports.AdminInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	a, err := h.auth.UpdateAdmin(r.Context(), id, in)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(a)
+}
+
+// DELETE /admins/{id}/sessions — выйти со всех устройств
+func (h *AuthHandler) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.auth.RevokeSessions(r.Context(), id); err != nil {
+		http.Error(w, "failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeAdminError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ports.ErrInvalidAdminInput):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ports.ErrAdminExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ports.ErrAdminNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, "failed to save admin", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,130 @@
+package delivery
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+	"github.com/go-chi/chi/v5"
+)
+
+// предел тела, которое middleware читает в поисках bot_id
+const maxScopedBody = 1 << 20
+
+// requestToken — "Authorization: Bearer <token>" или cookie token (так его хранит админка)
+func requestToken(r *http.Request) string {
+	if v := r.Header.Get("Authorization"); strings.HasPrefix(v, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(v, "Bearer "))
+	}
+	if c, err := r.Cookie("token"); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// RequireAdmin — любой вошедший админ; кладёт его в контекст (ports.AdminFromContext)
+func (h *AuthHandler) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a, err := h.auth.ValidateToken(r.Context(), requestToken(r))
+		if errors.Is(err, ports.ErrInvalidToken) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			log.Printf("[auth] validate token: %v", err)
+			http.Error(w, "auth failed", http.StatusInternalServerError)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ports.WithAdmin(r.Context(), a)))
+	})
+}
+
+// RequireOwner — глобальные ручки: все боты сразу, правила текста, учётки
+func (h *AuthHandler) RequireOwner(next http.Handler) http.Handler {
+	return h.RequireAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ports.AdminFromContext(r.Context()).Role != ports.RoleOwner {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// RequireBot — ручки одного бота: operator проходит, только если каждый bot_id запроса — его
+func (h *AuthHandler) RequireBot(next http.Handler) http.Handler {
+	return h.RequireAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a := ports.AdminFromContext(r.Context())
+		if a.Role == ports.RoleOwner {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		botIDs, err := requestBotIDs(r)
+		if err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if len(botIDs) == 0 {
+			http.Error(w, "bot_id required", http.StatusForbidden)
+			return
+		}
+		for _, id := range botIDs {
+			if !a.CanAccessBot(id) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// requestBotIDs — bot_id из пути, query, X-Bot-ID, формы и JSON-тела.
+// Берём все: хендлеры читают bot_id из разных мест, подменить один из них нельзя.
+func requestBotIDs(r *http.Request) ([]string, error) {
+	var out []string
+	add := func(id string) {
+		if id = strings.TrimSpace(id); id != "" {
+			out = append(out, id)
+		}
+	}
+
+	add(chi.URLParam(r, "bot_id"))
+	add(r.URL.Query().Get("bot_id"))
+	add(r.Header.Get("X-Bot-ID"))
+
+	if r.Body == nil || r.Method == http.MethodGet {
+		return out, nil
+	}
+
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch ct {
+	case "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+		add(r.PostForm.Get("bot_id"))
+
+	case "application/json", "":
+		raw, err := io.ReadAll(io.LimitReader(r.Body, maxScopedBody))
+		if err != nil {
+			return nil, err
+		}
+		// хендлер прочитает тело заново
+		r.Body = io.NopCloser(bytes.NewReader(raw))
+
+		var body struct {
+			BotID string `json:"bot_id"`
+		}
+		if len(bytes.TrimSpace(raw)) > 0 && json.Unmarshal(raw, &body) == nil {
+			add(body.BotID)
+		}
+	}
+	return out, nil
+}
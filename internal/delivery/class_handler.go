@@ -1,12 +1,15 @@
 package delivery
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
 
+	"github.com/Vovarama1992/make_ziper/internal/audit"
 	"github.com/Vovarama1992/make_ziper/internal/bots"
 	cl "github.com/Vovarama1992/make_ziper/internal/classes"
+	"github.com/Vovarama1992/make_ziper/internal/ports"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -48,7 +51,11 @@ func (h *ClassHandler) ListClasses(w http.ResponseWriter, r *http.Request) {
 	var out []*cl.Class
 
 	// 2. по каждому боту грузим классы
+	admin := ports.AdminFromContext(ctx)
 	for _, b := range bots {
+		if !admin.CanAccessBot(b.BotID) {
+			continue
+		}
 		list, err := h.svc.ListClasses(ctx, b.BotID)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
@@ -224,3 +231,72 @@ func (h *ClassHandler) DeletePrompt(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(204)
 }
+
+//
+// ----------------------
+//   ЖУРНАЛ
+// ----------------------
+//
+
+// classSnapshot — класс из пути /classes/{class_id}; удаление идёт без bot_id, поэтому ищем по всем ботам
+func (h *ClassHandler) classSnapshot(r *http.Request, botID string) (*audit.Snapshot, error) {
+	id, err := strconv.Atoi(chi.URLParam(r, "class_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	botIDs, err := h.snapshotBots(r.Context(), botID)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range botIDs {
+		list, err := h.svc.ListClasses(r.Context(), b)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range list {
+			if c.ID == id {
+				return &audit.Snapshot{EntityID: strconv.Itoa(id), BotID: b, Value: c}, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// promptSnapshot — промпт из пути /prompts/{prompt_id} (бот — X-Bot-ID)
+func (h *ClassHandler) promptSnapshot(r *http.Request, botID string) (*audit.Snapshot, error) {
+	id, err := strconv.Atoi(chi.URLParam(r, "prompt_id"))
+	if err != nil {
+		return nil, err
+	}
+
+	classes, err := h.svc.ListClasses(r.Context(), botID)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range classes {
+		p, err := h.svc.GetPromptByClassID(r.Context(), botID, c.ID)
+		if err != nil {
+			return nil, err
+		}
+		if p != nil && p.ID == id {
+			return &audit.Snapshot{EntityID: strconv.Itoa(id), BotID: botID, Value: p}, nil
+		}
+	}
+	return nil, nil
+}
+
+func (h *ClassHandler) snapshotBots(ctx context.Context, botID string) ([]string, error) {
+	if botID != "" {
+		return []string{botID}, nil
+	}
+	list, err := h.botSvc.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(list))
+	for _, b := range list {
+		out = append(out, b.BotID)
+	}
+	return out, nil
+}
@@ -0,0 +1,416 @@
+package delivery
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/Vovarama1992/make_ziper/internal/gifts"
+	"github.com/Vovarama1992/make_ziper/internal/notificator"
+	"github.com/Vovarama1992/make_ziper/internal/payments"
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+)
+
+// коды ответа на уведомления CloudPayments
+const (
+	cpCodeOK             = 0
+	cpCodeBadInvoice     = 10
+	cpCodeWrongAmount    = 12
+	cpCodeCannotAccept   = 13
+	cpCodeAlreadyExpired = 20
+)
+
+// errCPWrongAmount — сумма уведомления не совпала с заказом
+var errCPWrongAmount = errors.New("amount does not match the order")
+
+// cpNotification — общие поля Check/Pay/Fail
+type cpNotification struct {
+	TransactionID string
+	Amount        float64
+	InvoiceID     string
+	AccountID     string
+	Status        string
+	Reason        string // Fail
+	Token         string // токен карты — для автопродления
+	Data          cpData
+}
+
+// cpData — JsonData, который мы передаём при создании заказа
+type cpData struct {
+	PaymentType string `json:"payment_type"`
+	BotID       string `json:"bot_id"`
+	TelegramID  int64  `json:"telegram_id"`
+	PackageID   int64  `json:"package_id"`
+
+	SubscriptionID int64 `json:"subscription_id"` // renewal
+}
+
+func (n *cpNotification) item() paidItem {
+	return paidItem{
+		PaymentType: n.Data.PaymentType,
+		BotID:       n.Data.BotID,
+		TelegramID:  n.Data.TelegramID,
+		PackageID:   n.Data.PackageID,
+		InvoiceID:   n.InvoiceID,
+		PaymentID:   n.TransactionID,
+		Amount:      n.Amount,
+
+		SubscriptionID:  n.Data.SubscriptionID,
+		Provider:        payments.ProviderCloudPayments,
+		PaymentMethodID: n.Token,
+	}
+}
+
+// POST /payments/cloudpayments/check — до списания: можно ли принять платёж
+func (h *SubscriptionHandler) CloudPaymentsCheck(w http.ResponseWriter, r *http.Request) {
+	n, _, ok := h.readCloudPayments(w, r, "check")
+	if !ok {
+		return
+	}
+
+	switch n.Data.PaymentType {
+
+	case "subscription":
+		order, err := h.service.GetOrder(r.Context(), n.InvoiceID)
+		if err != nil {
+			log.Println("[PAY][CP] check load order error:", err)
+			http.Error(w, "internal error", 500)
+			return
+		}
+		if order == nil {
+			writeCPCode(w, cpCodeBadInvoice)
+			return
+		}
+		if order.Status == ports.PeriodCanceled {
+			writeCPCode(w, cpCodeAlreadyExpired)
+			return
+		}
+		if order.Status != ports.PeriodPending {
+			writeCPCode(w, cpCodeCannotAccept)
+			return
+		}
+
+	case "gift":
+		g, err := h.gifts.GetByInvoice(r.Context(), n.InvoiceID)
+		if err != nil {
+			log.Println("[PAY][CP] check load gift error:", err)
+			http.Error(w, "internal error", 500)
+			return
+		}
+		if g == nil {
+			writeCPCode(w, cpCodeBadInvoice)
+			return
+		}
+		if g.Status != gifts.StatusPending {
+			writeCPCode(w, cpCodeCannotAccept)
+			return
+		}
+
+	case "renewal":
+		// списание по токену инициировали мы сами
+		if n.Data.SubscriptionID == 0 {
+			writeCPCode(w, cpCodeBadInvoice)
+			return
+		}
+
+	case "minute_package":
+		if n.Data.PackageID == 0 {
+			writeCPCode(w, cpCodeBadInvoice)
+			return
+		}
+
+	default:
+		log.Println("[PAY][CP] check unknown payment_type:", n.Data.PaymentType)
+		writeCPCode(w, cpCodeBadInvoice)
+		return
+	}
+
+	// сумму из виджета можно подменить — сверяем с заказом до списания
+	if _, err := h.checkCPAmount(r.Context(), n); err != nil {
+		if errors.Is(err, errCPWrongAmount) {
+			log.Printf("[PAY][CP] check tx=%s invoice=%s: %v", n.TransactionID, n.InvoiceID, err)
+			writeCPCode(w, cpCodeWrongAmount)
+			return
+		}
+		log.Println("[PAY][CP] check amount error:", err)
+		http.Error(w, "internal error", 500)
+		return
+	}
+
+	writeCPCode(w, cpCodeOK)
+}
+
+// checkCPAmount — сумма уведомления против суммы заказа, подарка, пакета или автосписания.
+// Возвращает ожидаемую сумму.
+func (h *SubscriptionHandler) checkCPAmount(ctx context.Context, n *cpNotification) (float64, error) {
+	var want float64
+
+	switch n.Data.PaymentType {
+	case "subscription":
+		order, err := h.service.GetOrder(ctx, n.InvoiceID)
+		if err != nil {
+			return 0, err
+		}
+		if order == nil {
+			return 0, fmt.Errorf("order not found invoice=%s", n.InvoiceID)
+		}
+		// в заказе — цена уже со скидкой промокода
+		want = order.Amount
+
+	case "gift":
+		g, err := h.gifts.GetByInvoice(ctx, n.InvoiceID)
+		if err != nil {
+			return 0, err
+		}
+		if g == nil {
+			return 0, fmt.Errorf("gift not found invoice=%s", n.InvoiceID)
+		}
+		want = g.Amount
+
+	case "minute_package":
+		var err error
+		if want, err = h.service.PackagePrice(ctx, n.Data.BotID, n.Data.PackageID, n.InvoiceID); err != nil {
+			return 0, err
+		}
+
+	case "renewal":
+		var err error
+		if want, err = h.service.RenewalPrice(ctx, n.Data.SubscriptionID); err != nil {
+			return 0, err
+		}
+
+	default:
+		return 0, fmt.Errorf("unknown payment_type %q", n.Data.PaymentType)
+	}
+
+	if math.Abs(n.Amount-want) >= 0.01 {
+		return want, fmt.Errorf("%w: got %.2f, want %.2f", errCPWrongAmount, n.Amount, want)
+	}
+	return want, nil
+}
+
+// POST /payments/cloudpayments/pay — платёж прошёл
+func (h *SubscriptionHandler) CloudPaymentsPay(w http.ResponseWriter, r *http.Request) {
+	h.processCloudPayments(w, r, payments.KindCPPay, func(ctx context.Context, n *cpNotification) error {
+		// Check мог не вызываться (или быть подделан) — сумму сверяем ещё раз перед начислением
+		want, err := h.checkCPAmount(ctx, n)
+		if err != nil {
+			if errors.Is(err, errCPWrongAmount) {
+				// деньги уже списаны — не начисляем; событие уходит в needs_review для возврата
+				log.Printf("[PAY][CP] pay tx=%s invoice=%s NOT applied: %v", n.TransactionID, n.InvoiceID, err)
+				h.notifier.NotifyLevel(ctx, notificator.SeverityCritical, n.Data.BotID, err, fmt.Sprintf(
+					"CloudPayments: оплата списана, но не начислена — сумма не совпала с заказом. "+
+						"invoice=%s tx=%s tg=%d type=%s пришло %.2f, ожидалось %.2f. Нужен возврат или ручное начисление",
+					n.InvoiceID, n.TransactionID, n.Data.TelegramID, n.Data.PaymentType, n.Amount, want,
+				))
+				return fmt.Errorf("%w: %w", payments.ErrNeedsReview, err)
+			}
+			return err
+		}
+		return h.applyPayment(ctx, paymentSucceeded, n.item())
+	})
+}
+
+// POST /payments/cloudpayments/fail — отказ по карте.
+// Заказ остаётся pending: ученик может повторить оплату другой картой по тому же счёту,
+// брошенный заказ закроет CleanupPending.
+func (h *SubscriptionHandler) CloudPaymentsFail(w http.ResponseWriter, r *http.Request) {
+	h.processCloudPayments(w, r, payments.KindCPFail, func(ctx context.Context, n *cpNotification) error {
+		log.Printf("[PAY][CP] declined tx=%s invoice=%s type=%s reason=%s",
+			n.TransactionID, n.InvoiceID, n.Data.PaymentType, n.Reason)
+
+		// автосписание инициируем мы сами — отказ считается попыткой
+		if n.Data.PaymentType == "renewal" {
+			return h.applyPayment(ctx, paymentCanceled, n.item())
+		}
+		return nil
+	})
+}
+
+func (h *SubscriptionHandler) processCloudPayments(
+	w http.ResponseWriter,
+	r *http.Request,
+	kind string,
+	apply func(ctx context.Context, n *cpNotification) error,
+) {
+	n, body, ok := h.readCloudPayments(w, r, kind)
+	if !ok {
+		return
+	}
+
+	if n.TransactionID == "" {
+		http.Error(w, "missing TransactionId", 400)
+		return
+	}
+
+	item := n.item()
+
+	ev := &payments.Event{
+		Provider:    payments.ProviderCloudPayments,
+		ObjectID:    n.TransactionID,
+		Kind:        kind,
+		PaymentID:   n.TransactionID,
+		BotID:       item.BotID,
+		TelegramID:  item.TelegramID,
+		PaymentType: item.PaymentType,
+		Amount:      n.Amount,
+		Payload:     body,
+	}
+
+	dup, err := h.payments.Process(r.Context(), ev, func(ctx context.Context) error {
+		return apply(ctx, n)
+	})
+	if err != nil {
+		// не code=0 → CloudPayments повторит уведомление
+		log.Printf("[PAY][CP] %s process error: %v", kind, err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if dup {
+		log.Printf("[PAY][CP] already processed %s tx=%s", kind, n.TransactionID)
+	}
+
+	writeCPCode(w, cpCodeOK)
+}
+
+// readCloudPayments — тело, проверка HMAC и разбор уведомления.
+// ok=false — ответ уже записан.
+func (h *SubscriptionHandler) readCloudPayments(
+	w http.ResponseWriter,
+	r *http.Request,
+	kind string,
+) (*cpNotification, []byte, bool) {
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "bad body", 400)
+		return nil, nil, false
+	}
+
+	// подпись считается ключом кабинета бота, а бот — в JsonData:
+	// разбираем до проверки, но ничего не делаем, пока подпись не сошлась
+	n, err := parseCloudPayments(r, body)
+	if err != nil {
+		log.Printf("[PAY][CP] %s parse error: %v", kind, err)
+		http.Error(w, "bad request", 400)
+		return nil, nil, false
+	}
+
+	creds, err := h.merchants.Credentials(r.Context(), n.Data.BotID, payments.ProviderCloudPayments)
+	if err != nil {
+		log.Printf("[PAY][CP] %s no credentials bot=%s remote=%s: %v", kind, n.Data.BotID, r.RemoteAddr, err)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	if !verifyCloudPaymentsHMAC(r, body, creds.Secret) {
+		log.Printf("[PAY][CP] %s bad hmac bot=%s remote=%s", kind, n.Data.BotID, r.RemoteAddr)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	log.Printf("[PAY][CP] %s tx=%s invoice=%s amount=%.2f status=%s data=%+v",
+		kind, n.TransactionID, n.InvoiceID, n.Amount, n.Status, n.Data)
+
+	return n, body, true
+}
+
+// verifyCloudPaymentsHMAC — Content-HMAC: base64(HMAC-SHA256(тело, API secret)).
+// X-Content-HMAC считается по раскодированным параметрам — принимаем любой из двух.
+func verifyCloudPaymentsHMAC(r *http.Request, body []byte, secret string) bool {
+	sign := func(data []byte) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(data)
+		return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	if got := r.Header.Get("Content-HMAC"); got != "" &&
+		hmac.Equal([]byte(got), []byte(sign(body))) {
+		return true
+	}
+
+	if got := r.Header.Get("X-Content-HMAC"); got != "" {
+		decoded, err := url.QueryUnescape(string(body))
+		if err == nil && hmac.Equal([]byte(got), []byte(sign([]byte(decoded)))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseCloudPayments — уведомления приходят form-urlencoded или JSON (настройка в ЛК)
+func parseCloudPayments(r *http.Request, body []byte) (*cpNotification, error) {
+	values := url.Values{}
+
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if ct == "application/json" {
+		var m map[string]any
+		if err := json.Unmarshal(body, &m); err != nil {
+			return nil, err
+		}
+		for k, v := range m {
+			switch v := v.(type) {
+			case nil:
+			case string:
+				values.Set(k, v)
+			case float64:
+				values.Set(k, strconv.FormatFloat(v, 'f', -1, 64))
+			default:
+				raw, _ := json.Marshal(v)
+				values.Set(k, string(raw))
+			}
+		}
+	} else {
+		var err error
+		if values, err = url.ParseQuery(string(body)); err != nil {
+			return nil, err
+		}
+	}
+
+	n := &cpNotification{
+		TransactionID: values.Get("TransactionId"),
+		InvoiceID:     values.Get("InvoiceId"),
+		AccountID:     values.Get("AccountId"),
+		Status:        values.Get("Status"),
+		Reason:        values.Get("Reason"),
+		Token:         values.Get("Token"),
+	}
+
+	if v := values.Get("Amount"); v != "" {
+		amount, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad Amount %q: %w", v, err)
+		}
+		n.Amount = amount
+	}
+
+	if v := values.Get("Data"); v != "" {
+		if err := json.Unmarshal([]byte(v), &n.Data); err != nil {
+			return nil, fmt.Errorf("bad Data: %w", err)
+		}
+	}
+
+	// AccountId — telegram_id, на случай если JsonData потерялся
+	if n.Data.TelegramID == 0 {
+		n.Data.TelegramID, _ = strconv.ParseInt(n.AccountID, 10, 64)
+	}
+
+	return n, nil
+}
+
+func writeCPCode(w http.ResponseWriter, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]int{"code": code})
+}
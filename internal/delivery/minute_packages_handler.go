@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/Vovarama1992/make_ziper/internal/audit"
 	"github.com/go-chi/chi/v5"
 
 	mp "github.com/Vovarama1992/make_ziper/internal/minutes_packages"
@@ -120,3 +121,16 @@ func (h *MinutePackageHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// packageSnapshot — пакет из пути /minute-packages/{id}
+func (h *MinutePackageHandler) packageSnapshot(r *http.Request, botID string) (*audit.Snapshot, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	p, err := h.svc.GetByID(r.Context(), botID, id)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	return &audit.Snapshot{EntityID: chi.URLParam(r, "id"), BotID: botID, Value: p}, nil
+}
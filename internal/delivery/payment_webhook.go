@@ -0,0 +1,111 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// итог платежа — общий для всех провайдеров
+type paymentOutcome int
+
+const (
+	paymentSucceeded paymentOutcome = iota
+	paymentCanceled
+	paymentRefunded // только полный возврат
+)
+
+// paidItem — что именно оплачивалось (из metadata / JsonData платежа)
+type paidItem struct {
+	PaymentType string // subscription | minute_package | renewal | gift
+	BotID       string
+	TelegramID  int64
+	PackageID   int64
+	InvoiceID   string
+	PaymentID   string // id платежа у провайдера
+	Amount      float64
+
+	// автопродление
+	SubscriptionID  int64  // для renewal
+	Provider        string // чей способ оплаты
+	PaymentMethodID string // сохранённый способ оплаты, если провайдер его вернул
+}
+
+// applyPayment — начисление/отмена по итогу платежа. Вызывается внутри payments.Service.Process,
+// поэтому на одно событие провайдера срабатывает ровно один раз.
+func (h *SubscriptionHandler) applyPayment(ctx context.Context, outcome paymentOutcome, it paidItem) error {
+	switch it.PaymentType {
+
+	case "minute_package":
+		switch outcome {
+		case paymentSucceeded:
+			return h.service.AddMinutesFromPackage(ctx, it.BotID, it.TelegramID, it.PackageID, it.InvoiceID)
+		case paymentCanceled:
+			// минут не начисляли, возвращаем только промокод
+			return h.service.CancelMinutesPayment(ctx, it.InvoiceID)
+		case paymentRefunded:
+			return h.service.RefundMinutesFromPackage(ctx, it.BotID, it.TelegramID, it.PackageID, it.InvoiceID)
+		}
+		return nil
+
+	case "subscription":
+		if it.InvoiceID == "" {
+			return fmt.Errorf("missing invoice_id (bot=%s tg=%d)", it.BotID, it.TelegramID)
+		}
+
+		switch outcome {
+		case paymentSucceeded:
+			if err := h.service.Activate(ctx, it.InvoiceID); err != nil {
+				return err
+			}
+			if it.PaymentMethodID != "" {
+				// подписка уже активна — из-за карты событие не переигрываем
+				if err := h.service.SavePaymentMethod(ctx, it.InvoiceID, it.Provider, it.PaymentMethodID); err != nil {
+					log.Printf("[PAY] save payment method invoice=%s: %v", it.InvoiceID, err)
+				}
+			}
+			return nil
+		case paymentCanceled:
+			return h.service.CancelPayment(ctx, it.InvoiceID)
+		case paymentRefunded:
+			return h.service.Refund(ctx, it.InvoiceID)
+		}
+		return nil
+
+	case "gift":
+		if it.InvoiceID == "" {
+			return fmt.Errorf("missing invoice_id (bot=%s tg=%d)", it.BotID, it.TelegramID)
+		}
+
+		// доступ выдаётся не плательщику, а тому, кто активирует код
+		switch outcome {
+		case paymentSucceeded:
+			return h.gifts.Paid(ctx, it.InvoiceID)
+		case paymentCanceled:
+			return h.gifts.Canceled(ctx, it.InvoiceID)
+		case paymentRefunded:
+			return h.gifts.Refunded(ctx, it.InvoiceID)
+		}
+		return nil
+
+	case "renewal":
+		if it.SubscriptionID == 0 {
+			return fmt.Errorf("missing subscription_id (bot=%s tg=%d)", it.BotID, it.TelegramID)
+		}
+
+		switch outcome {
+		case paymentSucceeded:
+			return h.service.ApplyRenewal(ctx, it.SubscriptionID, it.PaymentID, it.Amount)
+		case paymentCanceled:
+			return h.service.RenewalFailed(ctx, it.SubscriptionID)
+		case paymentRefunded:
+			// период уже идёт — решение о доступе за админом
+			log.Printf("[PAY] renewal refunded sub=%d bot=%s tg=%d — nothing revoked",
+				it.SubscriptionID, it.BotID, it.TelegramID)
+		}
+		return nil
+	}
+
+	log.Println("[PAY] unknown payment_type:", it.PaymentType)
+	return nil
+}
@@ -2,7 +2,15 @@ package delivery
 
 import (
 	"github.com/Vovarama1992/go-utils/httputil"
+	"github.com/Vovarama1992/make_ziper/internal/audit"
 	"github.com/Vovarama1992/make_ziper/internal/bots"
+	"github.com/Vovarama1992/make_ziper/internal/gifts"
+	"github.com/Vovarama1992/make_ziper/internal/memory"
+	"github.com/Vovarama1992/make_ziper/internal/notificator"
+	"github.com/Vovarama1992/make_ziper/internal/promo"
+	"github.com/Vovarama1992/make_ziper/internal/quota"
+	"github.com/Vovarama1992/make_ziper/internal/referral"
+	"github.com/Vovarama1992/make_ziper/internal/usage"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -16,133 +24,292 @@ func RegisterRoutes(
 	hClass *ClassHandler,
 	hAuth *AuthHandler,
 	hTextRules *TextRuleHandler,
+	hUsage *usage.Handler,
+	hMemory *memory.Handler,
+	hQuota *quota.Handler,
+	hPromo *promo.Handler,
+	hReferral *referral.Handler,
+	hGifts *gifts.Handler,
+	hAudit *audit.Handler,
+	hAlerts *notificator.Handler,
+	auditor *Auditor,
 ) {
+	// мутирующие ручки пишут в журнал через auditor.Track (кто, что, было/стало)
+
 	// --- auth ---
+	// без токена — только вход и уведомления платёжек (у них своя подпись);
+	// RequireBot — ручки одного бота, RequireOwner — глобальные
 	r.With(httputil.RecoverMiddleware).
 		Post("/auth/login", hAuth.Login)
 
+	r.With(httputil.RecoverMiddleware, hAuth.RequireAdmin).
+		Post("/auth/logout", hAuth.Logout)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireAdmin).
+		Get("/auth/me", hAuth.Me)
+
+	// --- учётки админки (только owner) ---
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner).
+		Get("/admins", hAuth.ListAdmins)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner,
+		auditor.Track("admin.create", "admin", nil)).
+		Post("/admins", hAuth.CreateAdmin)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner,
+		auditor.Track("admin.update", "admin", nil)).
+		Patch("/admins/{id}", hAuth.UpdateAdmin)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner,
+		auditor.Track("admin.revoke_sessions", "admin", nil)).
+		Delete("/admins/{id}/sessions", hAuth.RevokeSessions)
+
 	// --- записи ---
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("record.add_user", "record", nil)).
 		Post("/record/text/user", h.AddTextRecordJSON)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("record.add_tutor", "record", nil)).
 		Post("/record/text/tutor", h.AddTextRecordForm)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner,
+		auditor.Track("record.delete_all", "record", nil)).
 		Delete("/records", h.DeleteAll)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
 		Get("/history/{telegram_id}", h.GetHistory)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner).
 		Get("/users", h.ListUsers)
 
 	// --- подписки ---
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("subscription.create_payment", "subscription", nil)).
 		Post("/subscribe/create", hSubs.Create)
 
+	// уведомления ЮKassa: платёж перепроверяется через API
 	r.With(httputil.RecoverMiddleware).
 		Post("/subscribe/activate", hSubs.Activate)
 
+	// --- уведомления CloudPayments ---
 	r.With(httputil.RecoverMiddleware).
-		Get("/subscribe/status/{telegram_id}", hSubs.GetStatus)
+		Post("/payments/cloudpayments/check", hSubs.CloudPaymentsCheck)
 
 	r.With(httputil.RecoverMiddleware).
-		Get("/subscriptions", hSubs.ListAll)
+		Post("/payments/cloudpayments/pay", hSubs.CloudPaymentsPay)
 
 	r.With(httputil.RecoverMiddleware).
+		Post("/payments/cloudpayments/fail", hSubs.CloudPaymentsFail)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
+		Get("/subscribe/status/{telegram_id}", hSubs.GetStatus)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireAdmin).
+		Get("/subscriptions", hSubs.ListAll)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
+		Get("/subscriptions/{bot_id}/{telegram_id}/timeline", hSubs.Timeline)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("subscription.delete", "subscription", hSubs.subscriptionSnapshot)).
 		Delete("/subscribe/{bot_id}/{telegram_id}", hSubs.Delete)
 
 	// --- тарифы ---
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireAdmin).
 		Get("/tariffs", hTariff.List)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("tariff.create", "tariff", nil)).
 		Post("/tariffs", hTariff.Create)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("tariff.update", "tariff", hTariff.tariffSnapshot)).
 		Put("/tariffs/{id}", hTariff.Update)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner,
+		auditor.Track("tariff.delete", "tariff", hTariff.tariffSnapshot)).
 		Delete("/tariffs/{id}", hTariff.Delete)
 
 	// --- боты ---
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireAdmin).
 		Get("/bots", hBots.List)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
 		Get("/bots/{bot_id}", hBots.Get)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("bot.update", "bot", hBots.AuditSnapshot)).
 		Patch("/bots/{bot_id}", hBots.Update)
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner,
+		auditor.Track("bot.delete", "bot", hBots.AuditSnapshot)).
 		Delete("/bots/{bot_id}", hBots.Delete)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner,
+		auditor.Track("bot.create", "bot", nil)).
 		Post("/bots", hBots.Create)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("bot.welcome_video", "bot", hBots.AuditSnapshot)).
 		Post("/bots/{bot_id}/welcome-video", hBots.UploadWelcomeVideo)
 
 	// --- пакеты минут ---
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
 		Get("/minute-packages", hPkg.List)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("minute_package.create", "minute_package", nil)).
 		Post("/minute-packages", hPkg.Create)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
 		Get("/minute-packages/{id}", hPkg.Get)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("minute_package.update", "minute_package", hPkg.packageSnapshot)).
 		Patch("/minute-packages/{id}", hPkg.Update)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("minute_package.delete", "minute_package", hPkg.packageSnapshot)).
 		Delete("/minute-packages/{id}", hPkg.Delete)
 
 	// --- классы ---
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireAdmin).
 		Get("/classes", hClass.ListClasses)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("class.create", "class", nil)).
 		Post("/classes", hClass.CreateClass)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("class.update", "class", hClass.classSnapshot)).
 		Patch("/classes/{class_id}", hClass.UpdateClass)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner,
+		auditor.Track("class.delete", "class", hClass.classSnapshot)).
 		Delete("/classes/{class_id}", hClass.DeleteClass)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
 		Get("/classes/{class_id}/prompts", hClass.GetPrompt)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("prompt.create", "prompt", nil)).
 		Post("/classes/{class_id}/prompts", hClass.CreatePrompt)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("prompt.update", "prompt", hClass.promptSnapshot)).
 		Patch("/prompts/{prompt_id}", hClass.UpdatePrompt)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("prompt.delete", "prompt", hClass.promptSnapshot)).
 		Delete("/prompts/{prompt_id}", hClass.DeletePrompt)
 
 	// --- text rules ---
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner).
 		Get("/text-rules/letters", hTextRules.ListLetterRules)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner,
+		auditor.Track("text_rule.add_letter", "text_rule", nil)).
 		Post("/text-rules/letters", hTextRules.AddLetterRule)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner,
+		auditor.Track("text_rule.delete_letter", "text_rule", nil)).
 		Delete("/text-rules/letters", hTextRules.DeleteLetterRule)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner).
 		Get("/text-rules/words", hTextRules.ListWordRules)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner,
+		auditor.Track("text_rule.add_word", "text_rule", nil)).
 		Post("/text-rules/words", hTextRules.AddWordRule)
 
-	r.Patch("/subscribe/{id}", hSubs.UpdateLimits)
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner,
+		auditor.Track("subscription.update_limits", "subscription", hSubs.subscriptionSnapshot)).
+		Patch("/subscribe/{id}", hSubs.UpdateLimits)
 
-	r.With(httputil.RecoverMiddleware).
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner,
+		auditor.Track("text_rule.delete_word", "text_rule", nil)).
 		Delete("/text-rules/words", hTextRules.DeleteWordRule)
+
+	// --- расход AI (токены / аудио / TTS) ---
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner).
+		Get("/usage/bots", hUsage.ByBot)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
+		Get("/usage/users", hUsage.ByUser)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
+		Get("/usage/days", hUsage.ByDay)
+
+	// --- память об ученике (сжатая старая история) ---
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
+		Get("/memory/{bot_id}/{telegram_id}", hMemory.Get)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("memory.reset", "memory", nil)).
+		Delete("/memory/{bot_id}/{telegram_id}", hMemory.Reset)
+
+	// --- квоты ученика (расход за сутки / месяц) ---
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
+		Get("/quota/{bot_id}/{telegram_id}", hQuota.Get)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("quota.reset", "quota", nil)).
+		Delete("/quota/{bot_id}/{telegram_id}", hQuota.Reset)
+
+	// --- промокоды ---
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
+		Get("/promo-codes", hPromo.List)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("promo_code.create", "promo_code", nil)).
+		Post("/promo-codes", hPromo.Create)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
+		Get("/promo-codes/{id}", hPromo.Get)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("promo_code.update", "promo_code", hPromo.AuditSnapshot)).
+		Patch("/promo-codes/{id}", hPromo.Update)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("promo_code.delete", "promo_code", hPromo.AuditSnapshot)).
+		Delete("/promo-codes/{id}", hPromo.Delete)
+
+	// --- реферальная программа ---
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
+		Get("/referrals/{bot_id}/settings", hReferral.GetSettings)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot,
+		auditor.Track("referral.settings", "referral_settings", hReferral.AuditSnapshot)).
+		Put("/referrals/{bot_id}/settings", hReferral.SaveSettings)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
+		Get("/referrals/{bot_id}/stats", hReferral.Stats)
+
+	// --- подарочные подписки ---
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
+		Get("/gifts", hGifts.List)
+
+	// --- журнал изменений ---
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
+		Get("/audit", hAudit.List)
+
+	// --- алерты об ошибках: кому слать и что накопилось ---
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner).
+		Get("/alert-routes", hAlerts.ListRoutes)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner,
+		auditor.Track("alert_route.create", "alert_route", nil)).
+		Post("/alert-routes", hAlerts.CreateRoute)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner,
+		auditor.Track("alert_route.update", "alert_route", hAlerts.AuditSnapshot)).
+		Patch("/alert-routes/{id}", hAlerts.UpdateRoute)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireOwner,
+		auditor.Track("alert_route.delete", "alert_route", hAlerts.AuditSnapshot)).
+		Delete("/alert-routes/{id}", hAlerts.DeleteRoute)
+
+	r.With(httputil.RecoverMiddleware, hAuth.RequireBot).
+		Get("/alerts", hAlerts.ListEvents)
 }
@@ -2,22 +2,47 @@ package delivery
 
 import (
 	"encoding/json"
-	"io"
-	"log"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/Vovarama1992/make_ziper/internal/audit"
+	"github.com/Vovarama1992/make_ziper/internal/gifts"
+	"github.com/Vovarama1992/make_ziper/internal/notificator"
+	"github.com/Vovarama1992/make_ziper/internal/payments"
 	"github.com/Vovarama1992/make_ziper/internal/ports"
 	"github.com/go-chi/chi/v5"
 )
 
 type SubscriptionHandler struct {
-	service ports.SubscriptionService
+	service  ports.SubscriptionService
+	payments payments.Service
+	yookassa ports.PaymentVerifier
+	gifts    gifts.Service
+
+	// ключи CloudPayments для проверки подписи уведомлений
+	merchants ports.CredentialsResolver
+
+	// оплата, которую нельзя начислить, — критичный алерт
+	notifier notificator.Notificator
 }
 
-func NewSubscriptionHandler(service ports.SubscriptionService) *SubscriptionHandler {
-	return &SubscriptionHandler{service: service}
+func NewSubscriptionHandler(
+	service ports.SubscriptionService,
+	paymentsSvc payments.Service,
+	yookassa ports.PaymentVerifier,
+	giftsSvc gifts.Service,
+	merchants ports.CredentialsResolver,
+	notifier notificator.Notificator,
+) *SubscriptionHandler {
+	return &SubscriptionHandler{
+		service:   service,
+		payments:  paymentsSvc,
+		yookassa:  yookassa,
+		gifts:     giftsSvc,
+		merchants: merchants,
+		notifier:  notifier,
+	}
 }
 
 // POST /subscribe/create
@@ -26,6 +51,7 @@ func (h *SubscriptionHandler) Create(w http.ResponseWriter, r *http.Request) {
 		BotID      string `json:"bot_id"`
 		TelegramID int64  `json:"telegram_id"`
 		PlanCode   string `json:"plan_code"`
+		AutoRenew  bool   `json:"auto_renew"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid json", http.StatusBadRequest)
@@ -36,7 +62,7 @@ func (h *SubscriptionHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	paymentURL, err := h.service.Create(r.Context(), req.BotID, req.TelegramID, req.PlanCode)
+	paymentURL, err := h.service.Create(r.Context(), req.BotID, req.TelegramID, req.PlanCode, req.AutoRenew)
 	if err != nil {
 		http.Error(w, "failed to create subscription: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -60,111 +86,6 @@ func (h *SubscriptionHandler) Create(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// POST /subscribe/activate
-func (h *SubscriptionHandler) Activate(w http.ResponseWriter, r *http.Request) {
-	log.Println("[PAY][YK] webhook hit")
-
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Println("[PAY][YK] read body error:", err)
-		http.Error(w, "bad body", 400)
-		return
-	}
-
-	log.Println("[PAY][YK] raw body:", string(body))
-
-	var notif struct {
-		Type   string `json:"type"`
-		Event  string `json:"event"`
-		Object struct {
-			ID       string `json:"id"`
-			Status   string `json:"status"`
-			Paid     bool   `json:"paid"`
-			Metadata struct {
-				BotID      string `json:"bot_id"`
-				TelegramID string `json:"telegram_id"`
-				PackageID  string `json:"package_id"`
-				Type       string `json:"payment_type"`
-				PlanCode   string `json:"plan_code"`
-				InvoiceID  string `json:"invoice_id"`
-			} `json:"metadata"`
-		} `json:"object"`
-	}
-
-	if err := json.Unmarshal(body, &notif); err != nil {
-		log.Println("[PAY][YK] json decode error:", err)
-		http.Error(w, "bad json", 400)
-		return
-	}
-
-	log.Printf("[PAY][YK] event=%s status=%s paid=%v paymentID=%s\n",
-		notif.Event,
-		notif.Object.Status,
-		notif.Object.Paid,
-		notif.Object.ID,
-	)
-
-	if notif.Event != "payment.succeeded" {
-		log.Println("[PAY][YK] ignore event:", notif.Event)
-		w.WriteHeader(200)
-		return
-	}
-
-	meta := notif.Object.Metadata
-	log.Printf("[PAY][YK] meta: %+v\n", meta)
-
-	tgID, _ := strconv.ParseInt(meta.TelegramID, 10, 64)
-	pkgID, _ := strconv.ParseInt(meta.PackageID, 10, 64)
-
-	switch meta.Type {
-
-	case "minute_package":
-		log.Println("[PAY][YK] add minutes start")
-
-		if err := h.service.AddMinutesFromPackage(
-			r.Context(),
-			meta.BotID,
-			tgID,
-			pkgID,
-		); err != nil {
-			log.Println("[PAY][YK] add minutes error:", err)
-			http.Error(w, err.Error(), 500)
-			return
-		}
-
-		log.Println("[PAY][YK] minutes added OK")
-
-	case "subscription":
-		log.Println("[PAY][YK] activate subscription start")
-
-		invoiceID := notif.Object.Metadata.InvoiceID
-		log.Println("[PAY][YK] invoice_id:", invoiceID)
-
-		if invoiceID == "" {
-			log.Println("[PAY][YK] missing invoice_id in metadata")
-			http.Error(w, "missing invoice_id", 400)
-			return
-		}
-
-		if err := h.service.Activate(
-			r.Context(),
-			invoiceID,
-		); err != nil {
-			log.Println("[PAY][YK] activate sub error:", err)
-			http.Error(w, err.Error(), 500)
-			return
-		}
-
-		log.Println("[PAY][YK] subscription activated")
-
-	default:
-		log.Println("[PAY][YK] unknown payment_type:", meta.Type)
-	}
-
-	w.WriteHeader(200)
-	w.Write([]byte("ok"))
-}
-
 // GET /subscribe/status/{telegram_id}?bot_id=...
 func (h *SubscriptionHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
 	botID := r.URL.Query().Get("bot_id")
@@ -207,8 +128,14 @@ func (h *SubscriptionHandler) ListAll(w http.ResponseWriter, r *http.Request) {
 		VoiceMinutes *float64 `json:"voice_minutes"`
 	}
 	out := make([]dto, 0, len(subs))
+	admin := ports.AdminFromContext(r.Context())
 
 	for _, s := range subs {
+		// operator видит только подписки своих ботов
+		if !admin.CanAccessBot(s.BotID) {
+			continue
+		}
+
 		var started, expires *string
 
 		if s.StartedAt != nil {
@@ -264,6 +191,43 @@ func (h *SubscriptionHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GET /subscriptions/{bot_id}/{telegram_id}/timeline — все покупки пользователя
+func (h *SubscriptionHandler) Timeline(w http.ResponseWriter, r *http.Request) {
+	botID := chi.URLParam(r, "bot_id")
+	if botID == "" {
+		http.Error(w, "missing bot_id", http.StatusBadRequest)
+		return
+	}
+
+	telegramID, err := strconv.ParseInt(chi.URLParam(r, "telegram_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid telegram_id", http.StatusBadRequest)
+		return
+	}
+
+	periods, err := h.service.Timeline(r.Context(), botID, telegramID)
+	if err != nil {
+		http.Error(w, "failed to load timeline: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	current, err := h.service.Get(r.Context(), botID, telegramID)
+	if err != nil {
+		http.Error(w, "failed to load subscription: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if periods == nil {
+		periods = []*ports.SubscriptionPeriod{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"current": current,
+		"periods": periods,
+	})
+}
+
 // PATCH /subscribe/{id}
 func (h *SubscriptionHandler) UpdateLimits(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
@@ -306,3 +270,33 @@ func (h *SubscriptionHandler) UpdateLimits(w http.ResponseWriter, r *http.Reques
 		"status": "ok",
 	})
 }
+
+// ==================================================
+// ЖУРНАЛ
+// ==================================================
+
+// subscriptionSnapshot — подписка из /subscribe/{id} или /subscribe/{bot_id}/{telegram_id}
+func (h *SubscriptionHandler) subscriptionSnapshot(r *http.Request, _ string) (*audit.Snapshot, error) {
+	if idStr := chi.URLParam(r, "id"); idStr != "" {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		s, err := h.service.GetByID(r.Context(), id)
+		if err != nil || s == nil {
+			return nil, err
+		}
+		return &audit.Snapshot{EntityID: idStr, BotID: s.BotID, Value: s}, nil
+	}
+
+	botID := chi.URLParam(r, "bot_id")
+	telegramID, err := strconv.ParseInt(chi.URLParam(r, "telegram_id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	s, err := h.service.Get(r.Context(), botID, telegramID)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	return &audit.Snapshot{EntityID: strconv.FormatInt(s.ID, 10), BotID: botID, Value: s}, nil
+}
@@ -1,11 +1,13 @@
 package delivery
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"github.com/Vovarama1992/make_ziper/internal/audit"
 	"github.com/Vovarama1992/make_ziper/internal/ports"
 )
 
@@ -25,7 +27,16 @@ func (h *TariffHandler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_ = json.NewEncoder(w).Encode(items)
+	// operator видит только тарифы своих ботов
+	admin := ports.AdminFromContext(r.Context())
+	out := make([]*ports.TariffPlan, 0, len(items))
+	for _, p := range items {
+		if admin.CanAccessBot(p.BotID) {
+			out = append(out, p)
+		}
+	}
+
+	_ = json.NewEncoder(w).Encode(out)
 }
 
 func (h *TariffHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -40,6 +51,11 @@ func (h *TariffHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, err := input.ParseFeatures(); err != nil {
+		http.Error(w, "invalid features: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	created, err := h.svc.Create(r.Context(), &input)
 	if err != nil {
 		http.Error(w, "failed to create tariff", http.StatusInternalServerError)
@@ -67,6 +83,11 @@ func (h *TariffHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, err := input.ParseFeatures(); err != nil {
+		http.Error(w, "invalid features: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	input.ID = id
 
 	updated, err := h.svc.Update(r.Context(), &input)
@@ -100,3 +121,30 @@ func extractIDFromURL(path string) (int, error) {
 	}
 	return strconv.Atoi(parts[len(parts)-1])
 }
+
+// tariffSnapshot — тариф из пути /tariffs/{id} для журнала
+func (h *TariffHandler) tariffSnapshot(r *http.Request, _ string) (*audit.Snapshot, error) {
+	id, err := extractIDFromURL(r.URL.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := h.find(r.Context(), id)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	return &audit.Snapshot{EntityID: strconv.Itoa(id), BotID: p.BotID, Value: p}, nil
+}
+
+func (h *TariffHandler) find(ctx context.Context, id int) (*ports.TariffPlan, error) {
+	items, err := h.svc.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range items {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
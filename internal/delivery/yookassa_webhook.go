@@ -0,0 +1,175 @@
+package delivery
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Vovarama1992/make_ziper/internal/payments"
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+)
+
+// POST /subscribe/activate — вебхук YooKassa.
+// Тело уведомления не считается доказательством: платёж (и возврат) перечитываются
+// по API магазина, а применяется каждое событие ровно один раз через payment_events.
+func (h *SubscriptionHandler) Activate(w http.ResponseWriter, r *http.Request) {
+	log.Println("[PAY][YK] webhook hit")
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("[PAY][YK] read body error:", err)
+		http.Error(w, "bad body", 400)
+		return
+	}
+
+	log.Println("[PAY][YK] raw body:", string(body))
+
+	var notif struct {
+		Type   string `json:"type"`
+		Event  string `json:"event"`
+		Object struct {
+			ID        string            `json:"id"`
+			PaymentID string            `json:"payment_id"` // refund
+			Metadata  map[string]string `json:"metadata"`   // payment
+		} `json:"object"`
+	}
+
+	if err := json.Unmarshal(body, &notif); err != nil {
+		log.Println("[PAY][YK] json decode error:", err)
+		http.Error(w, "bad json", 400)
+		return
+	}
+
+	ctx := r.Context()
+
+	var (
+		pay      *ports.ProviderPayment
+		objectID = notif.Object.ID
+	)
+
+	// ключи магазина — по боту. Из тела берём только его: подделка бота даст
+	// запрос не в тот магазин, и платёж там просто не найдётся
+	botID := notif.Object.Metadata["bot_id"]
+	if notif.Event == payments.KindYKRefundSucceeded {
+		botID, err = h.payments.BotForPayment(ctx, payments.ProviderYooKassa, notif.Object.PaymentID)
+		if err != nil {
+			log.Println("[PAY][YK] load refund bot error:", err)
+			http.Error(w, "internal error", 500)
+			return
+		}
+	}
+
+	switch notif.Event {
+
+	case payments.KindYKPaymentSucceeded, payments.KindYKPaymentCanceled:
+		pay, err = h.yookassa.GetPayment(ctx, botID, objectID)
+		if err != nil {
+			// сеть/API недоступны — YooKassa повторит уведомление
+			log.Println("[PAY][YK] verify payment error:", err)
+			http.Error(w, "verify failed", 502)
+			return
+		}
+
+		want := "succeeded"
+		if notif.Event == payments.KindYKPaymentCanceled {
+			want = "canceled"
+		}
+		if pay.Status != want {
+			log.Printf("[PAY][YK] status mismatch event=%s api_status=%s id=%s", notif.Event, pay.Status, objectID)
+			http.Error(w, "status mismatch", 400)
+			return
+		}
+
+	case payments.KindYKRefundSucceeded:
+		ref, err := h.yookassa.GetRefund(ctx, botID, objectID)
+		if err != nil {
+			log.Println("[PAY][YK] verify refund error:", err)
+			http.Error(w, "verify failed", 502)
+			return
+		}
+		if ref.Status != "succeeded" {
+			log.Printf("[PAY][YK] refund status mismatch api_status=%s id=%s", ref.Status, objectID)
+			http.Error(w, "status mismatch", 400)
+			return
+		}
+
+		pay, err = h.yookassa.GetPayment(ctx, botID, ref.PaymentID)
+		if err != nil {
+			log.Println("[PAY][YK] verify refunded payment error:", err)
+			http.Error(w, "verify failed", 502)
+			return
+		}
+
+	default:
+		log.Println("[PAY][YK] ignore event:", notif.Event)
+		w.WriteHeader(200)
+		return
+	}
+
+	meta := pay.Metadata
+	tgID, _ := strconv.ParseInt(meta["telegram_id"], 10, 64)
+	pkgID, _ := strconv.ParseInt(meta["package_id"], 10, 64)
+	subID, _ := strconv.ParseInt(meta["subscription_id"], 10, 64)
+
+	log.Printf("[PAY][YK] verified event=%s payment=%s status=%s meta=%v",
+		notif.Event, pay.ID, pay.Status, meta)
+
+	item := paidItem{
+		PaymentType: meta["payment_type"],
+		BotID:       meta["bot_id"],
+		TelegramID:  tgID,
+		PackageID:   pkgID,
+		InvoiceID:   meta["invoice_id"],
+		PaymentID:   pay.ID,
+		Amount:      pay.Amount,
+
+		SubscriptionID: subID,
+		Provider:       payments.ProviderYooKassa,
+	}
+	if pay.PaymentMethodSaved {
+		item.PaymentMethodID = pay.PaymentMethodID
+	}
+
+	ev := &payments.Event{
+		Provider:    payments.ProviderYooKassa,
+		ObjectID:    objectID,
+		Kind:        notif.Event,
+		PaymentID:   pay.ID,
+		BotID:       item.BotID,
+		TelegramID:  item.TelegramID,
+		PaymentType: item.PaymentType,
+		Amount:      pay.Amount,
+		Payload:     body,
+	}
+
+	dup, err := h.payments.Process(ctx, ev, func(ctx context.Context) error {
+		switch notif.Event {
+		case payments.KindYKPaymentSucceeded:
+			return h.applyPayment(ctx, paymentSucceeded, item)
+		case payments.KindYKPaymentCanceled:
+			return h.applyPayment(ctx, paymentCanceled, item)
+		}
+
+		// частичный возврат — доступ и минуты не трогаем
+		if pay.RefundedAmount < pay.Amount {
+			log.Printf("[PAY][YK] partial refund %.2f/%.2f payment=%s — nothing revoked",
+				pay.RefundedAmount, pay.Amount, pay.ID)
+			return nil
+		}
+		return h.applyPayment(ctx, paymentRefunded, item)
+	})
+	if err != nil {
+		log.Println("[PAY][YK] process error:", err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if dup {
+		log.Printf("[PAY][YK] already processed event=%s id=%s", notif.Event, objectID)
+	}
+
+	w.WriteHeader(200)
+	w.Write([]byte("ok"))
+}
@@ -3,42 +3,250 @@ package domain
 import (
 	"context"
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
-	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/Vovarama1992/make_ziper/internal/ports"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// срок токена, если AUTH_TOKEN_TTL не задан
+const defaultTokenTTL = 24 * time.Hour
+
+// логин учётки, заведённой из старого пароля bot_auth
+const legacyAdminLogin = "admin"
+
 type authService struct {
 	repo   ports.AuthRepo
 	secret string
+	ttl    time.Duration
 }
 
 func NewAuthService(repo ports.AuthRepo, secret string) ports.AuthService {
+	ttl := defaultTokenTTL
+	if v := os.Getenv("AUTH_TOKEN_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			ttl = d
+		} else {
+			log.Printf("[auth] bad AUTH_TOKEN_TTL=%q, using %s", v, ttl)
+		}
+	}
+
 	return &authService{
 		repo:   repo,
 		secret: secret,
+		ttl:    ttl,
 	}
 }
 
-func (s *authService) Login(ctx context.Context, password string) (string, error) {
-	realPass, err := s.repo.GetPassword(ctx)
+// ==================================================
+// TOKENS
+// ==================================================
+
+func (s *authService) Login(ctx context.Context, login, password string) (string, error) {
+	login = strings.TrimSpace(login)
+	if login == "" {
+		login = legacyAdminLogin
+	}
+
+	a, hash, err := s.repo.GetAdminByLogin(ctx, login)
 	if err != nil {
 		return "", err
 	}
-	if realPass == "" || password != realPass {
-		return "", errors.New("invalid password")
+	if a == nil || !a.Active || password == "" {
+		return "", ports.ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return "", ports.ErrInvalidCredentials
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	if err := s.repo.CreateSession(ctx, s.sign(token), a.ID, time.Now().Add(s.ttl)); err != nil {
+		return "", err
+	}
+
+	log.Printf("[auth] login admin=%s role=%s", a.Login, a.Role)
+	return token, nil
+}
+
+func (s *authService) ValidateToken(ctx context.Context, token string) (*ports.Admin, error) {
+	if token == "" {
+		return nil, ports.ErrInvalidToken
+	}
+	a, err := s.repo.GetSessionAdmin(ctx, s.sign(token))
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, ports.ErrInvalidToken
 	}
-	return s.sign("allowed"), nil
+	return a, nil
 }
 
-func (s *authService) ValidateToken(ctx context.Context, token string) (bool, error) {
-	return token == s.sign("allowed"), nil
+func (s *authService) Logout(ctx context.Context, token string) error {
+	return s.repo.RevokeSession(ctx, s.sign(token))
 }
 
+// sign — в базе лежит HMAC токена: утечка admin_sessions не даёт войти
 func (s *authService) sign(msg string) string {
 	h := hmac.New(sha256.New, []byte(s.secret))
 	h.Write([]byte(msg))
 	return hex.EncodeToString(h.Sum(nil))
 }
+
+func (s *authService) Bootstrap(ctx context.Context) error {
+	n, err := s.repo.CountAdmins(ctx)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	password, err := s.repo.GetPassword(ctx)
+	if err != nil {
+		return err
+	}
+	if password == "" {
+		log.Printf("[auth] no admins and no bot_auth password: admin API is closed until an owner is created")
+		return nil
+	}
+
+	// старый пароль мог быть короче нынешнего минимума — переносим как есть
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	a := &ports.Admin{Login: legacyAdminLogin, Role: ports.RoleOwner, BotIDs: []string{}, Active: true}
+	if err := s.repo.CreateAdmin(ctx, a, string(hash)); err != nil {
+		return err
+	}
+
+	log.Printf("[auth] owner %q created from bot_auth password", a.Login)
+	return nil
+}
+
+// ==================================================
+// ADMINS
+// ==================================================
+
+func (s *authService) ListAdmins(ctx context.Context) ([]*ports.Admin, error) {
+	return s.repo.ListAdmins(ctx)
+}
+
+func (s *authService) CreateAdmin(ctx context.Context, in ports.AdminInput) (*ports.Admin, error) {
+	if in.Login == nil || strings.TrimSpace(*in.Login) == "" {
+		return nil, fmt.Errorf("%w: login required", ports.ErrInvalidAdminInput)
+	}
+	if in.Password == nil {
+		return nil, fmt.Errorf("%w: password required", ports.ErrInvalidAdminInput)
+	}
+
+	a := &ports.Admin{
+		Login:  strings.TrimSpace(*in.Login),
+		Role:   ports.RoleOperator,
+		BotIDs: []string{},
+		Active: true,
+	}
+	if err := applyAdminInput(a, in); err != nil {
+		return nil, err
+	}
+
+	hash, err := hashPassword(*in.Password)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateAdmin(ctx, a, hash); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (s *authService) UpdateAdmin(ctx context.Context, id int64, in ports.AdminInput) (*ports.Admin, error) {
+	a, err := s.repo.GetAdmin(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, ports.ErrAdminNotFound
+	}
+
+	if in.Login != nil {
+		if strings.TrimSpace(*in.Login) == "" {
+			return nil, fmt.Errorf("%w: login required", ports.ErrInvalidAdminInput)
+		}
+		a.Login = strings.TrimSpace(*in.Login)
+	}
+	if err := applyAdminInput(a, in); err != nil {
+		return nil, err
+	}
+
+	var hash *string
+	if in.Password != nil {
+		h, err := hashPassword(*in.Password)
+		if err != nil {
+			return nil, err
+		}
+		hash = &h
+	}
+
+	if err := s.repo.UpdateAdmin(ctx, a, hash); err != nil {
+		return nil, err
+	}
+
+	// права изменились — старые токены не должны их сохранять
+	if in.Password != nil || in.Role != nil || in.BotIDs != nil || in.Active != nil {
+		if err := s.repo.RevokeAdminSessions(ctx, a.ID); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func (s *authService) RevokeSessions(ctx context.Context, adminID int64) error {
+	return s.repo.RevokeAdminSessions(ctx, adminID)
+}
+
+// applyAdminInput — роль, боты и активность из запроса
+func applyAdminInput(a *ports.Admin, in ports.AdminInput) error {
+	if in.Role != nil {
+		if *in.Role != ports.RoleOwner && *in.Role != ports.RoleOperator {
+			return fmt.Errorf("%w: role must be %s or %s", ports.ErrInvalidAdminInput, ports.RoleOwner, ports.RoleOperator)
+		}
+		a.Role = *in.Role
+	}
+	if in.BotIDs != nil {
+		a.BotIDs = []string{}
+		for _, id := range *in.BotIDs {
+			if id = strings.TrimSpace(id); id != "" {
+				a.BotIDs = append(a.BotIDs, id)
+			}
+		}
+	}
+	if in.Active != nil {
+		a.Active = *in.Active
+	}
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < 8 {
+		return "", fmt.Errorf("%w: password must be at least 8 characters", ports.ErrInvalidAdminInput)
+	}
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(h), nil
+}
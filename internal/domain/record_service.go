@@ -7,9 +7,13 @@ import (
 
 	error_notificator "github.com/Vovarama1992/make_ziper/internal/notificator"
 	"github.com/Vovarama1992/make_ziper/internal/ports"
-	tiktoken "github.com/pkoukk/tiktoken-go"
+	"github.com/Vovarama1992/make_ziper/internal/tokens"
 )
 
+// historyScanLimit — сколько последних записей (штук, не токенов) читаем из базы под окно истории.
+// Это потолок чтения: при коротких сообщениях окно упрётся в него раньше, чем в бюджет токенов.
+const historyScanLimit = 500
+
 type recordService struct {
 	repo     ports.RecordRepo
 	notifier error_notificator.Notificator
@@ -30,9 +34,16 @@ func (s *recordService) AddText(ctx context.Context, botID string, telegramID in
 		return 0, err
 	}
 
-	go func() {
-		_ = s.RecalcHistoryState(context.Background(), botID, telegramID)
-	}()
+	return id, nil
+}
+
+func (s *recordService) AddReply(ctx context.Context, botID string, telegramID int64, text, model string) (int64, error) {
+	id, err := s.repo.CreateReply(ctx, botID, telegramID, text, model)
+	if err != nil {
+		s.notifier.Notify(ctx, botID, err,
+			fmt.Sprintf("Ошибка записи ответа в history: tg=%d", telegramID))
+		return 0, err
+	}
 
 	return id, nil
 }
@@ -52,10 +63,6 @@ func (s *recordService) AddImage(
 		return 0, err
 	}
 
-	go func() {
-		_ = s.RecalcHistoryState(context.Background(), botID, telegramID)
-	}()
-
 	return id, nil
 }
 
@@ -67,58 +74,55 @@ func (s *recordService) ListUsers(ctx context.Context) ([]ports.UserBots, error)
 	return s.repo.ListUsers(ctx)
 }
 
-func (s *recordService) RecalcHistoryState(ctx context.Context, botID string, telegramID int64) error {
-	records, err := s.repo.GetHistory(ctx, botID, telegramID)
-	if err != nil {
-		return err
-	}
+// GetFittingHistory — хвост истории, который влезает в budget токенов модели.
+// Считается синхронно на каждый запрос, поэтому окно всегда соответствует
+// последнему сообщению и фактическому размеру системных промптов.
+// Записи возвращаются в хронологическом порядке.
+func (s *recordService) GetFittingHistory(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	model string,
+	budget int,
+) ([]ports.Record, error) {
 
-	enc, err := tiktoken.EncodingForModel("gpt-4o-mini")
+	recent, err := s.repo.GetLastNRecords(ctx, botID, telegramID, historyScanLimit)
 	if err != nil {
-		log.Printf("tokenizer init fail: %v", err)
-		return err
+		return nil, err
 	}
 
-	limit := 90000
-	totalTokens := 0
-	lastN := 0
+	total := 0
+	start := len(recent)
 
-	for i := len(records) - 1; i >= 0; i-- {
-		tokens := countTokens(records[i], enc)
-		if totalTokens+tokens > limit {
+	for i := len(recent) - 1; i >= 0; i-- {
+		t := countTokens(model, recent[i])
+		if total+t > budget {
 			break
 		}
-		totalTokens += tokens
-		lastN++
+		total += t
+		start = i
+	}
+
+	fitting := recent[start:]
+
+	// снимок окна — для диагностики, на выборку не влияет
+	if err := s.repo.UpsertHistoryState(ctx, botID, telegramID, len(fitting), total); err != nil {
+		log.Printf("[records] history state bot=%s tg=%d: %v", botID, telegramID, err)
 	}
 
-	return s.repo.UpsertHistoryState(ctx, botID, telegramID, lastN, totalTokens)
+	return fitting, nil
 }
 
-func countTokens(r ports.Record, enc *tiktoken.Tiktoken) int {
+func countTokens(model string, r ports.Record) int {
 	switch {
 	case r.Type == "text" && r.Text != nil:
-		return len(enc.Encode(*r.Text, nil, nil))
+		return tokens.Count(model, *r.Text) + tokens.MessageOverhead
 	case r.Type == "image":
-		return 60
+		return tokens.ImageTokens + tokens.MessageOverhead
 	}
 	return 0
 }
 
-func (s *recordService) GetFittingHistory(
-	ctx context.Context,
-	botID string,
-	telegramID int64,
-) ([]ports.Record, error) {
-
-	lastN, _, err := s.repo.GetHistoryState(ctx, botID, telegramID)
-	if err != nil {
-		return nil, err
-	}
-
-	return s.repo.GetLastNRecords(ctx, botID, telegramID, lastN)
-}
-
 func (s *recordService) DeleteAll(ctx context.Context) error {
 	return s.repo.DeleteAll(ctx)
 }
@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+)
+
+// GrantGift — заказ на получателя по уже оплаченному подарку и обычная активация:
+// тот же тариф продлевает доступ, другой — с зачётом остатка, как при покупке
+func (s *SubscriptionService) GrantGift(
+	ctx context.Context,
+	botID string,
+	recipientID int64,
+	planID int64,
+	paymentID string,
+	amount float64,
+) error {
+
+	order, err := s.periods.GetByPaymentID(ctx, paymentID)
+	if err != nil {
+		return fmt.Errorf("load gift order: %w", err)
+	}
+
+	if order == nil {
+		plan, err := s.tariffRepo.GetByID(ctx, botID, int(planID))
+		if err != nil {
+			return fmt.Errorf("load plan: %w", err)
+		}
+		if plan == nil {
+			return fmt.Errorf("plan not found id=%d", planID)
+		}
+
+		order = &ports.SubscriptionPeriod{
+			BotID:        botID,
+			TelegramID:   recipientID,
+			PlanID:       &planID,
+			Kind:         ports.PeriodKindGift,
+			Status:       ports.PeriodPending,
+			PaymentID:    &paymentID,
+			Amount:       amount,
+			VoiceMinutes: plan.VoiceMinutes,
+		}
+		if err := s.periods.Create(ctx, order); err != nil {
+			return err
+		}
+	} else if order.TelegramID != recipientID {
+		// прошлая попытка активации оборвалась на другом получателе
+		return fmt.Errorf("gift %s already granted to tg=%d", paymentID, order.TelegramID)
+	}
+
+	return s.Activate(ctx, paymentID)
+}
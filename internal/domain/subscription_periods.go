@@ -0,0 +1,148 @@
+package domain
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+)
+
+// placement — куда встаёт новый оплаченный период относительно текущего доступа
+type placement struct {
+	startsAt time.Time
+	endsAt   time.Time
+	credit   float64 // ₽, зачтено из текущих периодов при смене тарифа
+
+	stacked   bool // тот же тариф — встал в хвост текущему доступу
+	supersede bool // другой тариф — текущие периоды закрываются, остаток уходит в credit
+}
+
+// place — тот же тариф продлевает доступ с даты окончания,
+// другой начинается сейчас, а неиспользованная стоимость текущих периодов
+// переводится в дополнительное время нового тарифа по его цене
+func (s *SubscriptionService) place(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	plan *ports.TariffPlan,
+	now time.Time,
+) (*placement, error) {
+
+	current, err := s.periods.ListCurrent(ctx, botID, telegramID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	dur := time.Duration(plan.DurationMinutes) * time.Minute
+
+	if len(current) == 0 {
+		return &placement{startsAt: now, endsAt: now.Add(dur)}, nil
+	}
+
+	// ListCurrent отсортирован по ends_at — последний и есть хвост доступа
+	last := current[len(current)-1]
+	if last.PlanID != nil && *last.PlanID == int64(plan.ID) {
+		return &placement{
+			startsAt: *last.EndsAt,
+			endsAt:   last.EndsAt.Add(dur),
+			stacked:  true,
+		}, nil
+	}
+
+	credit := unusedValue(current, now)
+
+	var extra time.Duration
+	if plan.Price > 0 {
+		extra = time.Duration(float64(dur) * credit / plan.Price)
+	}
+
+	return &placement{
+		startsAt:  now,
+		endsAt:    now.Add(dur + extra),
+		credit:    credit,
+		supersede: true,
+	}, nil
+}
+
+// unusedValue — сколько рублей из текущих периодов ещё не «прожито».
+// Стоимость периода — оплата плюс зачтённый в него остаток, делится пропорционально времени.
+func unusedValue(periods []*ports.SubscriptionPeriod, now time.Time) float64 {
+	var total float64
+
+	for _, p := range periods {
+		if p.StartsAt == nil || p.EndsAt == nil {
+			continue
+		}
+
+		value := p.Amount + p.Credit
+		length := p.EndsAt.Sub(*p.StartsAt)
+		if value <= 0 || length <= 0 {
+			continue
+		}
+
+		from := *p.StartsAt
+		if now.After(from) {
+			from = now
+		}
+
+		left := p.EndsAt.Sub(from)
+		if left <= 0 {
+			continue
+		}
+
+		total += value * float64(left) / float64(length)
+	}
+
+	return math.Round(total*100) / 100
+}
+
+// refreshEntitlement — пересчитывает строку subscriptions из текущих оплаченных периодов.
+// active=false — действующих периодов нет, строка не менялась.
+func (s *SubscriptionService) refreshEntitlement(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	now time.Time,
+) (id int64, active bool, err error) {
+
+	current, err := s.periods.ListCurrent(ctx, botID, telegramID, now)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(current) == 0 {
+		return 0, false, nil
+	}
+
+	// тариф — того периода, что идёт сейчас, иначе ближайшего
+	planID := current[0].PlanID
+	started := current[0].StartsAt
+	for _, p := range current {
+		if p.StartsAt == nil {
+			continue
+		}
+		if !p.StartsAt.After(now) {
+			planID = p.PlanID
+		}
+		if started == nil || p.StartsAt.Before(*started) {
+			started = p.StartsAt
+		}
+	}
+	expires := current[len(current)-1].EndsAt
+
+	id, err = s.repo.SetEntitlement(ctx, botID, telegramID, planID, "active", started, expires)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return id, true, nil
+}
+
+// Timeline — все покупки пользователя, старые сверху
+func (s *SubscriptionService) Timeline(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+) ([]*ports.SubscriptionPeriod, error) {
+	return s.periods.ListByUser(ctx, botID, telegramID)
+}
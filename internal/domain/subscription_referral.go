@@ -0,0 +1,163 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+)
+
+// rewardReferrer — первая оплата приглашённого: пригласившему минуты и/или дни
+func (s *SubscriptionService) rewardReferrer(ctx context.Context, order *ports.SubscriptionPeriod) {
+	if order.PaymentID == nil {
+		return
+	}
+
+	rw, err := s.referral.Reward(ctx, order.BotID, order.TelegramID, *order.PaymentID)
+	if err != nil {
+		s.notifier.Notify(ctx, order.BotID, err,
+			fmt.Sprintf("Реферальный бонус: ошибка (приглашённый tg=%d)", order.TelegramID))
+		return
+	}
+	if rw == nil {
+		return
+	}
+
+	if rw.BonusMinutes > 0 {
+		if err := s.repo.AddVoiceMinutes(ctx, order.BotID, rw.ReferrerID, rw.BonusMinutes); err != nil {
+			s.notifier.Notify(ctx, order.BotID, err,
+				fmt.Sprintf("Реферальный бонус: минуты не начислены (tg=%d)", rw.ReferrerID))
+			return
+		}
+	}
+
+	if rw.BonusDays > 0 {
+		if err := s.addBonusDays(ctx, order.BotID, rw.ReferrerID, rw.BonusDays, *order.PaymentID); err != nil {
+			s.notifier.Notify(ctx, order.BotID, err,
+				fmt.Sprintf("Реферальный бонус: дни не начислены (tg=%d)", rw.ReferrerID))
+			return
+		}
+	}
+
+	text := "🎉 Приглашённый тобой друг оформил подписку! Тебе начислено:"
+	if rw.BonusDays > 0 {
+		text += fmt.Sprintf("\n📅 +%d дн подписки", rw.BonusDays)
+	}
+	if rw.BonusMinutes > 0 {
+		text += fmt.Sprintf("\n🎧 +%.0f мин голоса", rw.BonusMinutes)
+	}
+	_ = s.notifier.UserNotify(ctx, order.BotID, rw.ReferrerID, text)
+}
+
+// revokeReferrerBonus — оплату приглашённого вернули: снять с пригласившего минуты и бонусный период
+func (s *SubscriptionService) revokeReferrerBonus(ctx context.Context, order *ports.SubscriptionPeriod) {
+	if order.PaymentID == nil {
+		return
+	}
+
+	rw, err := s.referral.Revoke(ctx, order.BotID, *order.PaymentID)
+	if err != nil {
+		s.notifier.Notify(ctx, order.BotID, err,
+			fmt.Sprintf("Реферальный бонус: не снят после возврата (приглашённый tg=%d)", order.TelegramID))
+		return
+	}
+	if rw == nil {
+		return
+	}
+
+	if rw.BonusMinutes > 0 {
+		if err := s.repo.SubtractVoiceMinutes(ctx, order.BotID, rw.ReferrerID, rw.BonusMinutes); err != nil {
+			s.notifier.Notify(ctx, order.BotID, err,
+				fmt.Sprintf("Реферальный бонус: минуты не сняты после возврата (tg=%d)", rw.ReferrerID))
+		}
+	}
+
+	if rw.BonusDays > 0 {
+		if err := s.revokeBonusDays(ctx, order.BotID, rw.ReferrerID, *order.PaymentID); err != nil {
+			s.notifier.Notify(ctx, order.BotID, err,
+				fmt.Sprintf("Реферальный бонус: дни не сняты после возврата (tg=%d)", rw.ReferrerID))
+		}
+	}
+}
+
+// bonusPaymentID — ключ бонусного периода: по нему период находится при возврате оплаты приглашённого
+func bonusPaymentID(inviteePaymentID string) string {
+	return "ref_" + inviteePaymentID
+}
+
+func (s *SubscriptionService) revokeBonusDays(ctx context.Context, botID string, telegramID int64, inviteePaymentID string) error {
+	period, err := s.periods.GetByPaymentID(ctx, bonusPaymentID(inviteePaymentID))
+	if err != nil {
+		return err
+	}
+	if period == nil || period.Status != ports.PeriodPaid {
+		return nil
+	}
+
+	if err := s.periods.SetStatus(ctx, period.ID, ports.PeriodRevoked); err != nil {
+		return err
+	}
+
+	if _, _, err := s.refreshEntitlement(ctx, botID, telegramID, time.Now()); err != nil {
+		return err
+	}
+
+	log.Printf("[SUB][bonus] revoked bot=%s tg=%d period=%d", botID, telegramID, period.ID)
+	return nil
+}
+
+// addBonusDays — бесплатный период в хвост текущему доступу (или с сегодняшнего дня, если доступа нет).
+// inviteePaymentID — оплата приглашённого, за которую бонус (для снятия при возврате).
+func (s *SubscriptionService) addBonusDays(ctx context.Context, botID string, telegramID int64, days int, inviteePaymentID string) error {
+	now := time.Now()
+
+	current, err := s.periods.ListCurrent(ctx, botID, telegramID, now)
+	if err != nil {
+		return err
+	}
+
+	startsAt := now
+	var planID *int64
+
+	if len(current) > 0 {
+		last := current[len(current)-1]
+		startsAt = *last.EndsAt
+		planID = last.PlanID
+	} else {
+		sub, err := s.repo.Get(ctx, botID, telegramID)
+		if err != nil {
+			return err
+		}
+		if sub == nil || sub.PlanID == nil {
+			return fmt.Errorf("no subscription to extend bot=%s tg=%d", botID, telegramID)
+		}
+		planID = sub.PlanID
+	}
+
+	endsAt := startsAt.AddDate(0, 0, days)
+	note := "реферальный бонус"
+	paymentID := bonusPaymentID(inviteePaymentID)
+
+	if err := s.periods.Create(ctx, &ports.SubscriptionPeriod{
+		BotID:      botID,
+		TelegramID: telegramID,
+		PlanID:     planID,
+		Kind:       ports.PeriodKindBonus,
+		Status:     ports.PeriodPaid,
+		PaymentID:  &paymentID,
+		StartsAt:   &startsAt,
+		EndsAt:     &endsAt,
+		Note:       &note,
+	}); err != nil {
+		return err
+	}
+
+	if _, _, err := s.refreshEntitlement(ctx, botID, telegramID, now); err != nil {
+		return err
+	}
+
+	log.Printf("[SUB][bonus] bot=%s tg=%d +%d days → %s", botID, telegramID, days, endsAt.Format(time.RFC3339))
+	return nil
+}
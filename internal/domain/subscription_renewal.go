@@ -0,0 +1,359 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Vovarama1992/make_ziper/internal/payments"
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+)
+
+const (
+	// за сколько до окончания подписки пробуем списать
+	renewLead = 24 * time.Hour
+
+	// провайдер ещё не ответил окончательно — перепроверим позже, вебхук может прийти раньше
+	renewPendingRecheck = 6 * time.Hour
+
+	// запрос на списание не дошёл или ответ потерялся — повторяем тем же ключом, попытку не тратим
+	renewTransportRetry = 15 * time.Minute
+)
+
+// renewRetryDelays — паузы между повторными списаниями после отказа.
+// После последней попытки автопродление выключается.
+var renewRetryDelays = []time.Duration{
+	6 * time.Hour,
+	24 * time.Hour,
+	48 * time.Hour,
+}
+
+// ==================================================
+// НАСТРОЙКИ
+// ==================================================
+
+func (s *SubscriptionService) SavePaymentMethod(ctx context.Context, paymentID, provider, methodID string) error {
+	if methodID == "" {
+		return nil
+	}
+
+	order, err := s.periods.GetByPaymentID(ctx, paymentID)
+	if err != nil {
+		return fmt.Errorf("load order: %w", err)
+	}
+
+	// без согласия на автопродление карту не храним, даже если провайдер её сохранил
+	if order == nil || !order.AutoRenew {
+		return nil
+	}
+
+	sub, err := s.repo.Get(ctx, order.BotID, order.TelegramID)
+	if err != nil {
+		return fmt.Errorf("load subscription: %w", err)
+	}
+	if sub == nil {
+		return nil
+	}
+
+	if err := s.repo.SetAutoRenew(ctx, sub.BotID, sub.TelegramID, true); err != nil {
+		return err
+	}
+
+	if err := s.repo.SetPaymentMethod(ctx, sub.ID, provider, methodID); err != nil {
+		s.notifier.Notify(ctx, sub.BotID, err,
+			fmt.Sprintf("Не удалось сохранить способ оплаты для автопродления (tg=%d)", sub.TelegramID))
+		return err
+	}
+
+	log.Printf("[SUB][Renew] payment method saved sub=%d provider=%s", sub.ID, provider)
+	return nil
+}
+
+func (s *SubscriptionService) SetAutoRenew(ctx context.Context, botID string, telegramID int64, on bool) error {
+	if err := s.repo.SetAutoRenew(ctx, botID, telegramID, on); err != nil {
+		s.notifier.Notify(ctx, botID, err,
+			fmt.Sprintf("Ошибка переключения автопродления (tg=%d)", telegramID))
+		return err
+	}
+
+	log.Printf("[SUB][Renew] auto_renew=%v bot=%s tg=%d", on, botID, telegramID)
+	return nil
+}
+
+// ==================================================
+// ДЖОБА
+// ==================================================
+
+// RenewDue — безакцептные списания по подпискам, которые скоро истекают.
+// Результат применяется через payments.Process с теми же ключами, что и у вебхука,
+// поэтому синхронный ответ провайдера и его уведомление не продлят подписку дважды.
+func (s *SubscriptionService) RenewDue(ctx context.Context) error {
+	if s.charger == nil {
+		return nil
+	}
+
+	subs, err := s.repo.ListRenewalsDue(ctx, renewLead)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		if err := s.renew(ctx, sub); err != nil {
+			log.Printf("[SUB][Renew] sub=%d bot=%s tg=%d: %v", sub.ID, sub.BotID, sub.TelegramID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *SubscriptionService) renew(ctx context.Context, sub *ports.Subscription) error {
+	if sub.PlanID == nil || sub.PaymentMethodID == nil || sub.PaymentMethodProvider == nil {
+		return fmt.Errorf("subscription has no plan or payment method")
+	}
+
+	plan, err := s.tariffRepo.GetByID(ctx, sub.BotID, int(*sub.PlanID))
+	if err != nil {
+		return fmt.Errorf("load plan: %w", err)
+	}
+	if plan == nil || plan.IsTrial {
+		// тариф удалили или это trial — продлевать нечего
+		return s.repo.SetAutoRenew(ctx, sub.BotID, sub.TelegramID, false)
+	}
+
+	provider := *sub.PaymentMethodProvider
+
+	pay, err := s.charger.ChargeSaved(ctx, &ports.RecurringCharge{
+		Provider:       provider,
+		MethodID:       *sub.PaymentMethodID,
+		BotID:          sub.BotID,
+		TelegramID:     sub.TelegramID,
+		SubscriptionID: sub.ID,
+		PlanCode:       plan.Code,
+		Amount:         plan.Price,
+		InvoiceID:      renewalInvoiceID(sub),
+	})
+	var cfgErr *ports.CheckoutConfigError
+	if errors.As(err, &cfgErr) {
+		// списания не было — это не отказ карты: попытку не тратим, чиним настройки и ждём
+		s.notifier.Notify(ctx, sub.BotID, err,
+			fmt.Sprintf("Автосписание не отправлено: проверьте настройки оплаты бота (tg=%d)", sub.TelegramID))
+		next := time.Now().Add(renewPendingRecheck)
+		return s.repo.ScheduleRenewal(ctx, sub.ID, sub.RenewAttempts, &next)
+	}
+	if err != nil {
+		// исход неизвестен: списание могло пройти. Пока доступ не кончился — повторяем
+		// с тем же ключом, провайдер вернёт уже созданный платёж вместо нового
+		if sub.ExpiresAt != nil && sub.ExpiresAt.After(time.Now()) {
+			s.notifier.Notify(ctx, sub.BotID, err,
+				fmt.Sprintf("Автосписание: провайдер не ответил, повторим (tg=%d)", sub.TelegramID))
+			next := time.Now().Add(renewTransportRetry)
+			return s.repo.ScheduleRenewal(ctx, sub.ID, sub.RenewAttempts, &next)
+		}
+
+		// подписка уже истекла, а провайдер так и не ответил — считаем неудачной попыткой
+		s.notifier.Notify(ctx, sub.BotID, err,
+			fmt.Sprintf("Ошибка автосписания по подписке (tg=%d)", sub.TelegramID))
+		return s.RenewalFailed(ctx, sub.ID)
+	}
+
+	event := &payments.Event{
+		Provider:    provider,
+		ObjectID:    pay.ID,
+		PaymentID:   pay.ID,
+		BotID:       sub.BotID,
+		TelegramID:  sub.TelegramID,
+		PaymentType: "renewal",
+		Amount:      pay.Amount,
+	}
+
+	switch pay.Status {
+	case "succeeded":
+		event.Kind = payments.SucceededKind(provider)
+		_, err = s.payments.Process(ctx, event, func(ctx context.Context) error {
+			return s.ApplyRenewal(ctx, sub.ID, pay.ID, pay.Amount)
+		})
+		return err
+
+	case "canceled":
+		event.Kind = payments.FailedKind(provider)
+		_, err = s.payments.Process(ctx, event, func(ctx context.Context) error {
+			return s.RenewalFailed(ctx, sub.ID)
+		})
+		return err
+	}
+
+	// pending — итог придёт вебхуком; не списываем повторно, пока ждём
+	next := time.Now().Add(renewPendingRecheck)
+	return s.repo.ScheduleRenewal(ctx, sub.ID, sub.RenewAttempts, &next)
+}
+
+// renewalInvoiceID — ключ идемпотентности списания: один на срок подписки и попытку.
+// Повтор после сетевой ошибки идёт с тем же ключом; новый ключ — только после
+// продления (сдвинулся expires_at) или отказа банка (выросло renew_attempts).
+func renewalInvoiceID(sub *ports.Subscription) string {
+	var expires int64
+	if sub.ExpiresAt != nil {
+		expires = sub.ExpiresAt.Unix()
+	}
+	return fmt.Sprintf("renew_%d_%d_%d", sub.ID, expires, sub.RenewAttempts)
+}
+
+func (s *SubscriptionService) RenewalPrice(ctx context.Context, subscriptionID int64) (float64, error) {
+	sub, err := s.repo.GetByID(ctx, subscriptionID)
+	if err != nil {
+		return 0, fmt.Errorf("load subscription: %w", err)
+	}
+	if sub == nil || sub.PlanID == nil {
+		return 0, fmt.Errorf("subscription %d not found or has no plan", subscriptionID)
+	}
+
+	plan, err := s.tariffRepo.GetByID(ctx, sub.BotID, int(*sub.PlanID))
+	if err != nil {
+		return 0, fmt.Errorf("load plan: %w", err)
+	}
+	if plan == nil {
+		return 0, fmt.Errorf("plan not found id=%d", *sub.PlanID)
+	}
+	return plan.Price, nil
+}
+
+// ==================================================
+// РЕЗУЛЬТАТ СПИСАНИЯ
+// ==================================================
+
+// ApplyRenewal — новый период того же тарифа: встаёт в хвост текущему доступу
+// (или начинается сейчас, если подписка уже истекла)
+func (s *SubscriptionService) ApplyRenewal(
+	ctx context.Context,
+	subscriptionID int64,
+	paymentID string,
+	amount float64,
+) error {
+
+	sub, err := s.repo.GetByID(ctx, subscriptionID)
+	if err != nil {
+		return fmt.Errorf("load subscription: %w", err)
+	}
+	if sub == nil {
+		return fmt.Errorf("subscription not found id=%d", subscriptionID)
+	}
+	if sub.PlanID == nil {
+		return fmt.Errorf("subscription %d has nil plan_id", sub.ID)
+	}
+
+	plan, err := s.tariffRepo.GetByID(ctx, sub.BotID, int(*sub.PlanID))
+	if err != nil {
+		return fmt.Errorf("load plan: %w", err)
+	}
+	if plan == nil {
+		return fmt.Errorf("plan not found id=%d", *sub.PlanID)
+	}
+
+	now := time.Now()
+
+	pl, err := s.place(ctx, sub.BotID, sub.TelegramID, plan, now)
+	if err != nil {
+		return fmt.Errorf("place period: %w", err)
+	}
+	// прежние периоды закрываются вместе с созданием нового, одной транзакцией:
+	// сбой после списания не оставит пользователя без доступа
+	var supersedeAt *time.Time
+	if pl.supersede {
+		supersedeAt = &now
+	}
+
+	period := &ports.SubscriptionPeriod{
+		BotID:        sub.BotID,
+		TelegramID:   sub.TelegramID,
+		PlanID:       sub.PlanID,
+		Kind:         ports.PeriodKindRenewal,
+		Status:       ports.PeriodPaid,
+		Amount:       amount,
+		Credit:       pl.credit,
+		VoiceMinutes: plan.VoiceMinutes,
+		AutoRenew:    true,
+		StartsAt:     &pl.startsAt,
+		EndsAt:       &pl.endsAt,
+	}
+	if paymentID != "" {
+		period.PaymentID = &paymentID
+	}
+
+	created, err := s.periods.CreatePaid(ctx, period, supersedeAt)
+	if err != nil {
+		s.notifier.Notify(ctx, sub.BotID, err,
+			fmt.Sprintf("Автосписание прошло, но подписка не продлена (tg=%d)", sub.TelegramID))
+		return err
+	}
+	if !created {
+		// платёж уже применён; прошлая попытка могла упасть до пересчёта доступа — повторяем его
+		log.Printf("[SUB][Renew] payment=%s already applied", paymentID)
+		if _, _, err := s.refreshEntitlement(ctx, sub.BotID, sub.TelegramID, now); err != nil {
+			return fmt.Errorf("refresh entitlement: %w", err)
+		}
+		return nil
+	}
+
+	if _, _, err := s.refreshEntitlement(ctx, sub.BotID, sub.TelegramID, now); err != nil {
+		s.notifier.Notify(ctx, sub.BotID, err,
+			fmt.Sprintf("Автосписание прошло, но подписка не продлена (tg=%d)", sub.TelegramID))
+		return err
+	}
+
+	// новый расчётный период — минуты по тарифу заново
+	if err := s.repo.SetVoiceMinutes(ctx, sub.ID, plan.VoiceMinutes); err != nil {
+		log.Printf("[SUB][Renew] voice minutes sub=%d: %v", sub.ID, err)
+	}
+
+	if err := s.repo.ScheduleRenewal(ctx, sub.ID, 0, nil); err != nil {
+		log.Printf("[SUB][Renew] reset attempts sub=%d: %v", sub.ID, err)
+	}
+
+	log.Printf("[SUB][Renew] renewed sub=%d period=%d until=%s", sub.ID, period.ID, pl.endsAt.Format(time.RFC3339))
+
+	_ = s.notifier.UserNotify(ctx, sub.BotID, sub.TelegramID,
+		fmt.Sprintf("✅ Подписка «%s» продлена до %s", plan.Name, pl.endsAt.Format("02.01.2006")))
+
+	return nil
+}
+
+// RenewalFailed — отказ в списании: следующая попытка по renewRetryDelays,
+// после последней автопродление выключается
+func (s *SubscriptionService) RenewalFailed(ctx context.Context, subscriptionID int64) error {
+	sub, err := s.repo.GetByID(ctx, subscriptionID)
+	if err != nil {
+		return fmt.Errorf("load subscription: %w", err)
+	}
+	if sub == nil {
+		return fmt.Errorf("subscription not found id=%d", subscriptionID)
+	}
+
+	attempts := sub.RenewAttempts + 1
+
+	if attempts > len(renewRetryDelays) {
+		if err := s.repo.SetAutoRenew(ctx, sub.BotID, sub.TelegramID, false); err != nil {
+			return err
+		}
+
+		log.Printf("[SUB][Renew] gave up sub=%d after %d attempts", sub.ID, attempts-1)
+
+		_ = s.notifier.UserNotify(ctx, sub.BotID, sub.TelegramID,
+			"❌ Не удалось списать оплату за подписку, автопродление отключено.\n"+
+				"Оформить подписку заново можно в меню тарифов.")
+		return nil
+	}
+
+	next := time.Now().Add(renewRetryDelays[attempts-1])
+	if err := s.repo.ScheduleRenewal(ctx, sub.ID, attempts, &next); err != nil {
+		return err
+	}
+
+	log.Printf("[SUB][Renew] failed sub=%d attempt=%d next=%s", sub.ID, attempts, next.Format(time.RFC3339))
+
+	_ = s.notifier.UserNotify(ctx, sub.BotID, sub.TelegramID,
+		"⚠️ Не удалось списать оплату за продление подписки. "+
+			"Попробуем ещё раз позже — проверьте, что на карте достаточно средств.")
+	return nil
+}
@@ -8,34 +8,52 @@ import (
 
 	"github.com/Vovarama1992/make_ziper/internal/minutes_packages"
 	"github.com/Vovarama1992/make_ziper/internal/notificator"
+	"github.com/Vovarama1992/make_ziper/internal/payments"
 	"github.com/Vovarama1992/make_ziper/internal/ports"
+	"github.com/Vovarama1992/make_ziper/internal/promo"
+	"github.com/Vovarama1992/make_ziper/internal/referral"
 	"github.com/Vovarama1992/make_ziper/internal/trial"
 )
 
 type SubscriptionService struct {
 	repo            ports.SubscriptionRepo
+	periods         ports.SubscriptionPeriodRepo
 	tariffRepo      ports.TariffRepo
 	trialRepo       trial.RepoInf
 	minuteSvc       minutes_packages.MinutePackageService
 	notifier        notificator.Notificator
 	paymentProvider ports.PaymentProvider
+	charger         ports.RecurringCharger
+	payments        payments.Service
+	promo           promo.Service
+	referral        referral.Service
 }
 
 func NewSubscriptionService(
 	repo ports.SubscriptionRepo,
+	periods ports.SubscriptionPeriodRepo,
 	tariffRepo ports.TariffRepo,
 	trialRepo trial.RepoInf,
 	minuteSvc minutes_packages.MinutePackageService,
 	notifier notificator.Notificator,
 	paymentProvider ports.PaymentProvider,
+	charger ports.RecurringCharger,
+	paymentsSvc payments.Service,
+	promoSvc promo.Service,
+	referralSvc referral.Service,
 ) ports.SubscriptionService {
 	return &SubscriptionService{
 		repo:            repo,
+		periods:         periods,
 		tariffRepo:      tariffRepo,
 		trialRepo:       trialRepo,
 		minuteSvc:       minuteSvc,
 		notifier:        notifier,
 		paymentProvider: paymentProvider,
+		charger:         charger,
+		payments:        paymentsSvc,
+		promo:           promoSvc,
+		referral:        referralSvc,
 	}
 }
 
@@ -47,6 +65,7 @@ func (s *SubscriptionService) Create(
 	botID string,
 	telegramID int64,
 	planCode string,
+	autoRenew bool,
 ) (string, error) {
 
 	tariffs, err := s.tariffRepo.ListAll(ctx)
@@ -68,20 +87,66 @@ func (s *SubscriptionService) Create(
 	// ВАЖНО: генерим InvoiceId сами
 	invoiceID := fmt.Sprintf("sub_%d_%d", telegramID, time.Now().Unix())
 
+	now := time.Now()
+	planID := int64(plan.ID)
+
+	// заказ — отдельный период; даты проставятся при оплате
+	order := &ports.SubscriptionPeriod{
+		BotID:        botID,
+		TelegramID:   telegramID,
+		PlanID:       &planID,
+		Kind:         ports.PeriodKindPurchase,
+		Status:       ports.PeriodPending,
+		PaymentID:    &invoiceID,
+		Amount:       plan.Price,
+		VoiceMinutes: plan.VoiceMinutes,
+		AutoRenew:    autoRenew,
+	}
+
+	// введённый в боте промокод — в цену заказа; автопродления идут по полной цене
+	disc, err := s.promo.Reserve(ctx, botID, telegramID, promo.TargetSubscription, plan.Price, invoiceID)
+	if err != nil {
+		return "", err
+	}
+	if disc != nil {
+		order.Amount = disc.Price
+		order.PromoCode = &disc.Code
+		order.Discount = disc.Off
+		order.VoiceMinutes += disc.BonusMinutes
+		order.BonusDays = disc.BonusDays
+	}
+
 	payURL, _, err := s.paymentProvider.CreateSubscriptionPayment(
 		ctx,
 		botID,
 		telegramID,
 		plan.Code,
-		plan.Price,
+		order.Amount,
 		invoiceID, // передаём внутрь
+		autoRenew,
 	)
 	if err != nil {
+		if disc != nil {
+			if rerr := s.promo.Release(ctx, invoiceID); rerr != nil {
+				log.Printf("[SUB][Create] release promo invoice=%s: %v", invoiceID, rerr)
+			}
+		}
 		return "", err
 	}
 
-	now := time.Now()
-	planID := int64(plan.ID)
+	if err := s.periods.Create(ctx, order); err != nil {
+		return "", err
+	}
+
+	// действующий доступ не трогаем: оплата продлит его или зачтёт остаток
+	current, err := s.repo.Get(ctx, botID, telegramID)
+	if err != nil {
+		return "", err
+	}
+	if current != nil && current.Status == "active" &&
+		current.ExpiresAt != nil && current.ExpiresAt.After(now) {
+		return payURL, nil
+	}
 
 	sub := &ports.Subscription{
 		BotID:             botID,
@@ -90,6 +155,7 @@ func (s *SubscriptionService) Create(
 		Status:            "pending",
 		StartedAt:         &now,
 		YookassaPaymentID: &invoiceID, // <-- ВМЕСТО Model.Id
+		AutoRenew:         autoRenew,
 	}
 
 	if err := s.repo.Create(ctx, sub); err != nil {
@@ -108,54 +174,193 @@ func (s *SubscriptionService) Get(ctx context.Context, botID string, telegramID
 	return sub, nil
 }
 
+func (s *SubscriptionService) GetOrder(ctx context.Context, paymentID string) (*ports.SubscriptionPeriod, error) {
+	return s.periods.GetByPaymentID(ctx, paymentID)
+}
+
 // ==================================================
 // ACTIVATE
 // ==================================================
 func (s *SubscriptionService) Activate(ctx context.Context, paymentID string) error {
 
-	sub, err := s.repo.GetByPaymentID(ctx, paymentID)
+	order, err := s.periods.GetByPaymentID(ctx, paymentID)
 	if err != nil {
 		s.notifier.Notify(ctx, "unknown", err,
-			"Ошибка загрузки подписки по paymentID в Activate()")
-		return fmt.Errorf("load subscription: %w", err)
+			"Ошибка загрузки заказа по paymentID в Activate()")
+		return fmt.Errorf("load order: %w", err)
 	}
-	if sub == nil {
-		err := fmt.Errorf("subscription not found for paymentID=%s", paymentID)
+	if order == nil {
+		err := fmt.Errorf("order not found for paymentID=%s", paymentID)
 		s.notifier.Notify(ctx, "unknown", err,
-			fmt.Sprintf("Webhook YooKassa, но подписка не найдена (%s)", paymentID))
+			fmt.Sprintf("Оплата пришла, но заказ не найден (%s)", paymentID))
 		return err
 	}
 
-	if sub.PlanID == nil {
-		err := fmt.Errorf("subscription %d has nil plan_id", sub.ID)
-		s.notifier.Notify(ctx, sub.BotID, err,
-			"Webhook YooKassa: plan_id is NULL")
+	if order.Status == ports.PeriodPaid {
+		log.Printf("[SUB][Activate] already paid order=%d", order.ID)
+		return nil
+	}
+
+	if order.PlanID == nil {
+		err := fmt.Errorf("order %d has nil plan_id", order.ID)
+		s.notifier.Notify(ctx, order.BotID, err,
+			"Оплата подписки: plan_id is NULL")
 		return err
 	}
 
-	plan, err := s.tariffRepo.GetByID(ctx, sub.BotID, int(*sub.PlanID))
+	plan, err := s.tariffRepo.GetByID(ctx, order.BotID, int(*order.PlanID))
 	if err != nil {
-		s.notifier.Notify(ctx, sub.BotID, err,
+		s.notifier.Notify(ctx, order.BotID, err,
 			"Ошибка загрузки тарифного плана при активации")
 		return fmt.Errorf("load plan: %w", err)
 	}
 
 	if plan == nil {
-		err := fmt.Errorf("plan not found id=%d", *sub.PlanID)
-		s.notifier.Notify(ctx, sub.BotID, err,
-			"Webhook YooKassa: тариф не найден!")
+		err := fmt.Errorf("plan not found id=%d", *order.PlanID)
+		s.notifier.Notify(ctx, order.BotID, err,
+			"Оплата подписки: тариф не найден!")
 		return err
 	}
 
-	start := time.Now()
-	exp := start.Add(time.Duration(plan.DurationMinutes) * time.Minute)
+	now := time.Now()
+
+	pl, err := s.place(ctx, order.BotID, order.TelegramID, plan, now)
+	if err != nil {
+		return fmt.Errorf("place period: %w", err)
+	}
+	pl.endsAt = pl.endsAt.AddDate(0, 0, order.BonusDays)
 
-	if err := s.repo.Activate(ctx, sub.ID, start, exp, plan.VoiceMinutes); err != nil {
-		s.notifier.Notify(ctx, sub.BotID, err,
+	// прежние периоды закрываются вместе с оплатой нового, одной транзакцией
+	var supersedeAt *time.Time
+	if pl.supersede {
+		supersedeAt = &now
+	}
+
+	paid, err := s.periods.MarkPaid(ctx, order.ID, pl.startsAt, pl.endsAt, pl.credit, supersedeAt)
+	if err != nil {
+		return fmt.Errorf("mark paid: %w", err)
+	}
+	if !paid {
+		log.Printf("[SUB][Activate] order=%d is %s, skip", order.ID, order.Status)
+		return nil
+	}
+
+	subID, _, err := s.refreshEntitlement(ctx, order.BotID, order.TelegramID, now)
+	if err != nil {
+		s.notifier.Notify(ctx, order.BotID, err,
 			"Не удалось активировать подписку в БД")
 		return fmt.Errorf("activate: %w", err)
 	}
 
+	if _, err := s.promo.Redeem(ctx, paymentID); err != nil {
+		log.Printf("[SUB][Activate] redeem promo order=%d: %v", order.ID, err)
+	}
+
+	// тот же тариф — минуты нового периода добавляются, новый тариф — выдаются заново.
+	// Минуты заказа — по тарифу плюс бонус промокода.
+	if pl.stacked {
+		err = s.repo.AddVoiceMinutes(ctx, order.BotID, order.TelegramID, order.VoiceMinutes)
+	} else {
+		err = s.repo.SetVoiceMinutes(ctx, subID, order.VoiceMinutes)
+	}
+	if err != nil {
+		s.notifier.Notify(ctx, order.BotID, err,
+			fmt.Sprintf("Подписка активирована, но минуты не начислены (tg=%d)", order.TelegramID))
+		return err
+	}
+
+	log.Printf("[SUB][Activate] order=%d bot=%s tg=%d %s → %s stacked=%v credit=%.2f",
+		order.ID, order.BotID, order.TelegramID,
+		pl.startsAt.Format(time.RFC3339), pl.endsAt.Format(time.RFC3339), pl.stacked, pl.credit)
+
+	// оплата приглашённого — бонус пригласившему (сбой бонуса оплату не откатывает)
+	s.rewardReferrer(ctx, order)
+
+	return nil
+}
+
+// ==================================================
+// CANCEL / REFUND
+// ==================================================
+
+func (s *SubscriptionService) CancelPayment(ctx context.Context, paymentID string) error {
+	order, err := s.periods.GetByPaymentID(ctx, paymentID)
+	if err != nil {
+		return fmt.Errorf("load order: %w", err)
+	}
+	if order != nil && order.Status == ports.PeriodPending {
+		if err := s.periods.SetStatus(ctx, order.ID, ports.PeriodCanceled); err != nil {
+			return err
+		}
+	}
+
+	// промокод не сгорает вместе с неудачной оплатой
+	if err := s.promo.Release(ctx, paymentID); err != nil {
+		log.Printf("[SUB][Cancel] release promo paymentID=%s: %v", paymentID, err)
+	}
+
+	sub, err := s.repo.GetByPaymentID(ctx, paymentID)
+	if err != nil {
+		return fmt.Errorf("load subscription: %w", err)
+	}
+
+	// pending уже подчистил CleanupPending или подписку перекрыла новая покупка
+	if sub == nil || sub.Status != "pending" {
+		log.Printf("[SUB][Cancel] nothing to cancel paymentID=%s", paymentID)
+		return nil
+	}
+
+	return s.repo.UpdateStatus(ctx, sub.ID, "inactive")
+}
+
+// Refund — оплаченный период помечается возвращённым, доступ пересчитывается из оставшихся.
+// Периоды, которые эта покупка закрыла при смене тарифа, не восстанавливаются.
+func (s *SubscriptionService) Refund(ctx context.Context, paymentID string) error {
+	order, err := s.periods.GetByPaymentID(ctx, paymentID)
+	if err != nil {
+		return fmt.Errorf("load order: %w", err)
+	}
+	if order == nil {
+		err := fmt.Errorf("order not found for paymentID=%s", paymentID)
+		s.notifier.Notify(ctx, "unknown", err,
+			fmt.Sprintf("Возврат по подписке, но заказ не найден (%s)", paymentID))
+		return err
+	}
+
+	if order.Status != ports.PeriodPaid {
+		log.Printf("[SUB][Refund] order=%d is %s — nothing to revoke", order.ID, order.Status)
+		return nil
+	}
+
+	if err := s.periods.SetStatus(ctx, order.ID, ports.PeriodRefunded); err != nil {
+		return err
+	}
+
+	_, active, err := s.refreshEntitlement(ctx, order.BotID, order.TelegramID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if active {
+		err = s.repo.SubtractVoiceMinutes(ctx, order.BotID, order.TelegramID, order.VoiceMinutes)
+	} else {
+		var sub *ports.Subscription
+		sub, err = s.repo.Get(ctx, order.BotID, order.TelegramID)
+		if err == nil && sub != nil {
+			err = s.repo.Revoke(ctx, sub.ID, order.VoiceMinutes)
+		}
+	}
+	if err != nil {
+		s.notifier.Notify(ctx, order.BotID, err,
+			fmt.Sprintf("Не удалось закрыть подписку после возврата (tg=%d)", order.TelegramID))
+		return err
+	}
+
+	log.Printf("[SUB][Refund] refunded order=%d bot=%s tg=%d still_active=%v",
+		order.ID, order.BotID, order.TelegramID, active)
+
+	// бонус пригласившего был за эту оплату — снимается вместе с ней
+	s.revokeReferrerBonus(ctx, order)
 	return nil
 }
 
@@ -208,6 +413,20 @@ func (s *SubscriptionService) ActivateTrial(
 		return err
 	}
 
+	// 4.1 История
+	if err := s.periods.Create(ctx, &ports.SubscriptionPeriod{
+		BotID:        botID,
+		TelegramID:   telegramID,
+		PlanID:       &planID,
+		Kind:         ports.PeriodKindTrial,
+		Status:       ports.PeriodPaid,
+		VoiceMinutes: plan.VoiceMinutes,
+		StartsAt:     &start,
+		EndsAt:       &exp,
+	}); err != nil {
+		log.Printf("[SUB][Trial] period bot=%s tg=%d: %v", botID, telegramID, err)
+	}
+
 	// 5. Фиксируем факт trial
 	if err := s.trialRepo.Create(ctx, botID, telegramID); err != nil {
 		// подписка создана — не откатываем
@@ -285,6 +504,15 @@ func (s *SubscriptionService) ExpireAndNotifyTrials(ctx context.Context) error {
 }
 
 // ==================================================
+func (s *SubscriptionService) GetByID(ctx context.Context, id int64) (*ports.Subscription, error) {
+	sub, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		s.notifier.Notify(ctx, "global", err, fmt.Sprintf("Ошибка загрузки подписки id=%d", id))
+		return nil, err
+	}
+	return sub, nil
+}
+
 func (s *SubscriptionService) ListAll(ctx context.Context) ([]*ports.Subscription, error) {
 	list, err := s.repo.ListAll(ctx)
 	if err != nil {
@@ -307,6 +535,7 @@ func (s *SubscriptionService) AddMinutesFromPackage(
 	botID string,
 	telegramID int64,
 	packageID int64,
+	invoiceID string,
 ) error {
 
 	log.Printf("[MINUTES] start bot=%s tg=%d pkg=%d", botID, telegramID, packageID)
@@ -327,18 +556,108 @@ func (s *SubscriptionService) AddMinutesFromPackage(
 
 	log.Printf("[MINUTES] pkg loaded id=%d minutes=%.2f", pkg.ID, pkg.Minutes)
 
-	err = s.repo.AddVoiceMinutes(ctx, botID, telegramID, float64(pkg.Minutes))
+	minutes := float64(pkg.Minutes)
+
+	red, err := s.promo.Redeem(ctx, invoiceID)
+	if err != nil {
+		log.Printf("[MINUTES] redeem promo invoice=%s: %v", invoiceID, err)
+	}
+	if red != nil {
+		minutes += red.BonusMinutes
+	}
+
+	err = s.repo.AddVoiceMinutes(ctx, botID, telegramID, minutes)
 	if err != nil {
 		log.Printf("[MINUTES] add minutes error: %v", err)
 		return err
 	}
 
-	log.Printf("[MINUTES] success bot=%s tg=%d added=%.2f", botID, telegramID, pkg.Minutes)
+	log.Printf("[MINUTES] success bot=%s tg=%d added=%.0f", botID, telegramID, minutes)
+	return nil
+}
+
+func (s *SubscriptionService) PackagePrice(ctx context.Context, botID string, packageID int64, invoiceID string) (float64, error) {
+	pkg, err := s.minuteSvc.GetByID(ctx, botID, packageID)
+	if err != nil {
+		return 0, err
+	}
+	if pkg == nil {
+		return 0, fmt.Errorf("minute package not found: %d", packageID)
+	}
+
+	red, err := s.promo.GetByPayment(ctx, invoiceID)
+	if err != nil {
+		return 0, fmt.Errorf("load promo: %w", err)
+	}
+	if red != nil {
+		return pkg.Price - red.Discount, nil
+	}
+	return pkg.Price, nil
+}
+
+// CancelMinutesPayment — оплата пакета не прошла, промокод возвращается пользователю
+func (s *SubscriptionService) CancelMinutesPayment(ctx context.Context, invoiceID string) error {
+	return s.promo.Release(ctx, invoiceID)
+}
+
+func (s *SubscriptionService) RefundMinutesFromPackage(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	packageID int64,
+	invoiceID string,
+) error {
+
+	pkg, err := s.minuteSvc.GetByID(ctx, botID, packageID)
+	if err != nil {
+		return err
+	}
+	if pkg == nil {
+		return fmt.Errorf("minute package not found: %d", packageID)
+	}
+
+	minutes := float64(pkg.Minutes)
+
+	// бонус промокода снимается вместе с пакетом, сам код не возвращается
+	red, err := s.promo.GetByPayment(ctx, invoiceID)
+	if err != nil {
+		log.Printf("[MINUTES] load promo invoice=%s: %v", invoiceID, err)
+	}
+	if red != nil && red.Status == promo.RedemptionRedeemed {
+		minutes += red.BonusMinutes
+	}
+
+	if err := s.repo.SubtractVoiceMinutes(ctx, botID, telegramID, minutes); err != nil {
+		s.notifier.Notify(ctx, botID, err,
+			fmt.Sprintf("Не удалось снять минуты после возврата (tg=%d pkg=%d)", telegramID, packageID))
+		return err
+	}
+
+	log.Printf("[MINUTES] refund bot=%s tg=%d removed=%.0f", botID, telegramID, minutes)
 	return nil
 }
 
+// orderTTL — сколько заказ ждёт оплату, прежде чем промокод вернётся пользователю
+const orderTTL = 24 * time.Hour
+
 func (s *SubscriptionService) CleanupPending(ctx context.Context, olderThan time.Duration) error {
-	return s.repo.CleanupPending(ctx, olderThan)
+	if err := s.repo.CleanupPending(ctx, olderThan); err != nil {
+		return err
+	}
+
+	// брошенные заказы подписки: код под ними снова доступен пользователю
+	ids, err := s.periods.CancelStale(ctx, orderTTL)
+	if err != nil {
+		return fmt.Errorf("cancel stale orders: %w", err)
+	}
+	for _, id := range ids {
+		if err := s.promo.Release(ctx, id); err != nil {
+			log.Printf("[SUB][Cleanup] release promo paymentID=%s: %v", id, err)
+		}
+	}
+
+	// у пакетов минут заказа в БД нет — брошенный счёт виден только по резерву кода
+	return s.promo.ReleaseStale(ctx, orderTTL)
 }
 
 func (s *SubscriptionService) Delete(
@@ -361,6 +680,11 @@ func (s *SubscriptionService) Delete(
 		}
 	}
 
+	// история покупок остаётся, действующие периоды закрываются
+	if err := s.periods.CloseCurrent(ctx, botID, telegramID, time.Now(), ports.PeriodRevoked); err != nil {
+		return err
+	}
+
 	// удаляем подписку
 	if err := s.repo.Delete(ctx, botID, telegramID); err != nil {
 		s.notifier.Notify(ctx, botID, err,
@@ -441,11 +765,41 @@ func (s *SubscriptionService) UpdateLimits(
 		return fmt.Errorf("expiresAt is required")
 	}
 
+	now := time.Now()
+
 	status := "active"
-	if time.Now().After(*expiresAt) {
+	if now.After(*expiresAt) {
 		status = "expired"
 	}
 
+	// правка админа перекрывает текущие периоды и сама ложится в историю
+	sub, err := s.repo.GetByID(ctx, subscriptionID)
+	if err != nil {
+		return err
+	}
+	if sub != nil {
+		if err := s.periods.CloseCurrent(ctx, sub.BotID, sub.TelegramID, now, ports.PeriodSuperseded); err != nil {
+			return err
+		}
+
+		if status == "active" {
+			note := "правка админа"
+			if err := s.periods.Create(ctx, &ports.SubscriptionPeriod{
+				BotID:        sub.BotID,
+				TelegramID:   sub.TelegramID,
+				PlanID:       sub.PlanID,
+				Kind:         ports.PeriodKindManual,
+				Status:       ports.PeriodPaid,
+				VoiceMinutes: voiceMinutes,
+				StartsAt:     &now,
+				EndsAt:       expiresAt,
+				Note:         &note,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
 	if err := s.repo.UpdateLimits(
 		ctx,
 		subscriptionID,
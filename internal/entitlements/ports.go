@@ -0,0 +1,65 @@
+package entitlements
+
+import (
+	"context"
+
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+)
+
+// проверяемые возможности
+const (
+	FeatureVoice    = "voice"
+	FeaturePhoto    = "photo"
+	FeaturePDF      = "pdf"
+	FeatureWord     = "word"
+	FeaturePDFPages = "pdf_pages"
+	FeatureQuota    = "quota"
+	FeatureModel    = "model"
+)
+
+// Check — что пользователь собирается сделать
+type Check struct {
+	Feature string
+	Pages   int    // FeaturePDFPages: страниц в документе
+	Model   string // FeatureModel: "model" или "provider:model"
+
+	// FeatureQuota: сколько нужно в окне вместе с текущим запросом
+	Metric string // quota.Metric*
+	Window string // quota.Window*
+	Used   int
+}
+
+// Entitlements — возможности действующего тарифа пользователя
+type Entitlements struct {
+	PlanID   int
+	PlanCode string
+	PlanName string // пусто — подписка без тарифа (демо), всё разрешено
+	Features ports.TariffFeatures
+}
+
+// Denial — отказ и тариф, в котором это есть
+type Denial struct {
+	Check   Check
+	Current *Entitlements
+	Upgrade *ports.TariffPlan // nil — ни в одном тарифе бота
+}
+
+type Service interface {
+	// Resolve — возможности по действующей подписке пользователя
+	Resolve(ctx context.Context, botID string, telegramID int64) (*Entitlements, error)
+
+	// Authorize — nil, если можно; иначе Denial с самым дешёвым подходящим тарифом
+	Authorize(ctx context.Context, botID string, telegramID int64, ent *Entitlements, c Check) (*Denial, error)
+}
+
+type ctxKey struct{}
+
+// WithContext — возможности, посчитанные один раз на апдейт
+func WithContext(ctx context.Context, ent *Entitlements) context.Context {
+	return context.WithValue(ctx, ctxKey{}, ent)
+}
+
+func FromContext(ctx context.Context) *Entitlements {
+	ent, _ := ctx.Value(ctxKey{}).(*Entitlements)
+	return ent
+}
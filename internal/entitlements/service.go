@@ -0,0 +1,118 @@
+package entitlements
+
+import (
+	"context"
+	"log"
+
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+)
+
+type service struct {
+	subs    ports.SubscriptionService
+	tariffs ports.TariffService
+}
+
+func NewService(subs ports.SubscriptionService, tariffs ports.TariffService) Service {
+	return &service{
+		subs:    subs,
+		tariffs: tariffs,
+	}
+}
+
+func (s *service) Resolve(ctx context.Context, botID string, telegramID int64) (*Entitlements, error) {
+	ent := &Entitlements{Features: ports.DefaultTariffFeatures()}
+
+	sub, err := s.subs.Get(ctx, botID, telegramID)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil || sub.PlanID == nil {
+		return ent, nil
+	}
+
+	plan, err := s.tariffs.GetByID(ctx, botID, int(*sub.PlanID))
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		return ent, nil
+	}
+
+	features, err := plan.ParseFeatures()
+	if err != nil {
+		// кривые features не должны отрезать платного пользователя от бота
+		log.Printf("[entitlements] bot=%s plan=%s: %v", botID, plan.Code, err)
+	}
+
+	ent.PlanID = plan.ID
+	ent.PlanCode = plan.Code
+	ent.PlanName = plan.Name
+	ent.Features = features
+
+	return ent, nil
+}
+
+func (s *service) Authorize(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	ent *Entitlements,
+	c Check,
+) (*Denial, error) {
+
+	if allows(ent.Features, c) {
+		return nil, nil
+	}
+
+	upgrade, err := s.upgradeFor(ctx, botID, ent, c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Denial{Check: c, Current: ent, Upgrade: upgrade}, nil
+}
+
+// upgradeFor — самый дешёвый платный тариф бота, где проверка проходит
+func (s *service) upgradeFor(ctx context.Context, botID string, ent *Entitlements, c Check) (*ports.TariffPlan, error) {
+	plans, err := s.tariffs.ListAll(ctx) // уже по цене
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range plans {
+		if p.BotID != botID || p.IsTrial || p.ID == ent.PlanID {
+			continue
+		}
+
+		f, err := p.ParseFeatures()
+		if err != nil {
+			continue
+		}
+		if allows(f, c) {
+			return p, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func allows(f ports.TariffFeatures, c Check) bool {
+	switch c.Feature {
+	case FeatureVoice:
+		return f.Voice
+	case FeaturePhoto:
+		return f.Photo
+	case FeaturePDF:
+		return f.PDF
+	case FeatureWord:
+		return f.Word
+	case FeaturePDFPages:
+		return f.PDF && (f.MaxPDFPages == 0 || c.Pages <= f.MaxPDFPages)
+	case FeatureQuota:
+		limit := f.Quotas.Limit(c.Metric, c.Window)
+		return limit == 0 || c.Used <= limit
+	case FeatureModel:
+		return f.AllowsModel(c.Model)
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package gifts
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type Handler struct {
+	svc Service
+}
+
+func NewHandler(svc Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// GET /gifts?bot_id=xxx — все подарки бота, новые сверху
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	botID := r.URL.Query().Get("bot_id")
+	if botID == "" {
+		http.Error(w, "bot_id required", http.StatusBadRequest)
+		return
+	}
+
+	items, err := h.svc.List(r.Context(), botID)
+	if err != nil {
+		http.Error(w, "failed to list gifts", 500)
+		return
+	}
+	if items == nil {
+		items = []*Gift{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
@@ -0,0 +1,152 @@
+package gifts
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+type repo struct {
+	db *sql.DB
+}
+
+func NewRepo(db *sql.DB) Repo {
+	return &repo{db: db}
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+const giftColumns = `
+	id, bot_id, plan_id, plan_name, amount,
+	payer_id, invoice_id, code, status,
+	recipient_id,
+	created_at, paid_at, redeemed_at
+`
+
+func scanGift(row rowScanner) (*Gift, error) {
+	var g Gift
+	err := row.Scan(
+		&g.ID, &g.BotID, &g.PlanID, &g.PlanName, &g.Amount,
+		&g.PayerID, &g.InvoiceID, &g.Code, &g.Status,
+		&g.RecipientID,
+		&g.CreatedAt, &g.PaidAt, &g.RedeemedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (r *repo) Create(ctx context.Context, g *Gift) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO gifts (bot_id, plan_id, plan_name, amount, payer_id, invoice_id, code, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`,
+		g.BotID, g.PlanID, g.PlanName, g.Amount,
+		g.PayerID, g.InvoiceID, g.Code, g.Status,
+	).Scan(&g.ID, &g.CreatedAt)
+}
+
+func (r *repo) GetByInvoice(ctx context.Context, invoiceID string) (*Gift, error) {
+	return scanGift(r.db.QueryRowContext(ctx, `
+		SELECT `+giftColumns+`
+		FROM gifts
+		WHERE invoice_id = $1
+	`, invoiceID))
+}
+
+func (r *repo) GetByCode(ctx context.Context, botID, code string) (*Gift, error) {
+	return scanGift(r.db.QueryRowContext(ctx, `
+		SELECT `+giftColumns+`
+		FROM gifts
+		WHERE bot_id = $1 AND code = $2
+	`, botID, code))
+}
+
+func (r *repo) ListByBot(ctx context.Context, botID string) ([]*Gift, error) {
+	return r.list(ctx, `
+		SELECT `+giftColumns+`
+		FROM gifts
+		WHERE bot_id = $1
+		ORDER BY created_at DESC
+	`, botID)
+}
+
+func (r *repo) ListByPayer(ctx context.Context, botID string, payerID int64) ([]*Gift, error) {
+	return r.list(ctx, `
+		SELECT `+giftColumns+`
+		FROM gifts
+		WHERE bot_id = $1 AND payer_id = $2
+		ORDER BY created_at DESC
+	`, botID, payerID)
+}
+
+func (r *repo) list(ctx context.Context, query string, args ...any) ([]*Gift, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*Gift
+	for rows.Next() {
+		g, err := scanGift(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+func (r *repo) SetStatus(ctx context.Context, id int64, to string, from ...string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE gifts
+		SET status  = $2::text,
+		    paid_at = CASE WHEN $2::text = 'paid' THEN COALESCE(paid_at, NOW()) ELSE paid_at END
+		WHERE id = $1 AND status = ANY(string_to_array($3, ','))
+	`, id, to, strings.Join(from, ","))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *repo) MarkRedeemed(ctx context.Context, id int64, recipientID int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE gifts
+		SET status       = 'redeemed',
+		    recipient_id = $2,
+		    redeemed_at  = NOW()
+		WHERE id = $1 AND status = 'paid'
+	`, id, recipientID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *repo) Unredeem(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE gifts
+		SET status       = 'paid',
+		    recipient_id = NULL,
+		    redeemed_at  = NULL
+		WHERE id = $1 AND status = 'redeemed'
+	`, id)
+	return err
+}
@@ -0,0 +1,121 @@
+package gifts
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// статусы подарка
+const (
+	StatusPending  = "pending"  // ждём оплату
+	StatusPaid     = "paid"     // оплачен, код можно активировать
+	StatusRedeemed = "redeemed" // активирован получателем
+	StatusCanceled = "canceled" // оплата не прошла
+	StatusRefunded = "refunded" // деньги вернули, код не действует
+)
+
+// префикс deep link: t.me/<bot>?start=gift_<CODE>
+const payloadPrefix = "gift_"
+
+// без похожих символов (0/O, 1/I/L) — код диктуют и переписывают руками
+const (
+	codeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+	codeLength   = 10
+)
+
+var (
+	ErrNotFound        = errors.New("gift not found")
+	ErrNotPaid         = errors.New("gift is not paid yet")
+	ErrAlreadyRedeemed = errors.New("gift already redeemed")
+	ErrUnavailable     = errors.New("gift canceled or refunded")
+	ErrPlanNotFound    = errors.New("tariff not found")
+)
+
+// Gift — оплаченный одним пользователем тариф, который активирует другой
+type Gift struct {
+	ID       int64   `json:"id"`
+	BotID    string  `json:"bot_id"`
+	PlanID   int64   `json:"plan_id"`
+	PlanName string  `json:"plan_name"`
+	Amount   float64 `json:"amount"`
+
+	PayerID   int64  `json:"payer_id"`
+	InvoiceID string `json:"invoice_id"` // gift_...: платёж и период получателя
+	Code      string `json:"code"`       // одноразовый код активации
+	Status    string `json:"status"`
+
+	RecipientID *int64 `json:"recipient_id"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	PaidAt     *time.Time `json:"paid_at"`
+	RedeemedAt *time.Time `json:"redeemed_at"`
+}
+
+// NewCode — случайный код подарка
+func NewCode() (string, error) {
+	var sb strings.Builder
+	max := big.NewInt(int64(len(codeAlphabet)))
+
+	for i := 0; i < codeLength; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(codeAlphabet[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+// NormalizeCode — коды сравниваются без учёта регистра и пробелов
+func NormalizeCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+}
+
+// Payload — payload для /start
+func Payload(code string) string {
+	return payloadPrefix + code
+}
+
+// ParsePayload — код подарка из payload /start (ok=false — не подарочная ссылка)
+func ParsePayload(payload string) (string, bool) {
+	if !strings.HasPrefix(payload, payloadPrefix) {
+		return "", false
+	}
+	code := NormalizeCode(strings.TrimPrefix(payload, payloadPrefix))
+	return code, code != ""
+}
+
+type Repo interface {
+	Create(ctx context.Context, g *Gift) error
+	GetByInvoice(ctx context.Context, invoiceID string) (*Gift, error)
+	GetByCode(ctx context.Context, botID, code string) (*Gift, error)
+	ListByBot(ctx context.Context, botID string) ([]*Gift, error)
+	ListByPayer(ctx context.Context, botID string, payerID int64) ([]*Gift, error)
+
+	// SetStatus — переход только из одного из from (false — подарок уже в другом статусе)
+	SetStatus(ctx context.Context, id int64, to string, from ...string) (bool, error)
+	// MarkRedeemed — paid → redeemed за получателем (false — уже не paid)
+	MarkRedeemed(ctx context.Context, id int64, recipientID int64) (bool, error)
+	// Unredeem — redeemed → paid: доступ получателю выдать не удалось
+	Unredeem(ctx context.Context, id int64) error
+}
+
+type Service interface {
+	// Create — заказ подарка и ссылка на оплату
+	Create(ctx context.Context, botID string, payerID int64, planCode string) (payURL string, err error)
+	GetByInvoice(ctx context.Context, invoiceID string) (*Gift, error)
+	List(ctx context.Context, botID string) ([]*Gift, error)
+	ListByPayer(ctx context.Context, botID string, payerID int64) ([]*Gift, error)
+
+	// ==== вебхук ====
+	Paid(ctx context.Context, invoiceID string) error
+	Canceled(ctx context.Context, invoiceID string) error
+	Refunded(ctx context.Context, invoiceID string) error
+
+	// Redeem — получатель вводит код; ошибки ErrNotFound / ErrNotPaid / ErrAlreadyRedeemed / ErrUnavailable
+	Redeem(ctx context.Context, botID string, recipientID int64, code string) (*Gift, error)
+}
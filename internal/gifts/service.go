@@ -0,0 +1,227 @@
+package gifts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Vovarama1992/make_ziper/internal/notificator"
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+)
+
+type service struct {
+	repo     Repo
+	tariffs  ports.TariffRepo
+	provider ports.PaymentProvider
+	subs     ports.SubscriptionService
+	notifier notificator.Notificator
+}
+
+func NewService(
+	repo Repo,
+	tariffs ports.TariffRepo,
+	provider ports.PaymentProvider,
+	subs ports.SubscriptionService,
+	notifier notificator.Notificator,
+) Service {
+	return &service{
+		repo:     repo,
+		tariffs:  tariffs,
+		provider: provider,
+		subs:     subs,
+		notifier: notifier,
+	}
+}
+
+// ==================================================
+// CREATE
+// ==================================================
+
+func (s *service) Create(ctx context.Context, botID string, payerID int64, planCode string) (string, error) {
+	plan, err := s.findPlan(ctx, botID, planCode)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := NewCode()
+	if err != nil {
+		return "", fmt.Errorf("generate gift code: %w", err)
+	}
+
+	invoiceID := fmt.Sprintf("gift_%d_%d", payerID, time.Now().Unix())
+
+	payURL, _, err := s.provider.CreateGiftPayment(ctx, botID, payerID, plan.Code, plan.Price, invoiceID)
+	if err != nil {
+		return "", err
+	}
+
+	g := &Gift{
+		BotID:     botID,
+		PlanID:    int64(plan.ID),
+		PlanName:  plan.Name,
+		Amount:    plan.Price,
+		PayerID:   payerID,
+		InvoiceID: invoiceID,
+		Code:      code,
+		Status:    StatusPending,
+	}
+	if err := s.repo.Create(ctx, g); err != nil {
+		return "", err
+	}
+
+	log.Printf("[gift] created bot=%s payer=%d plan=%s invoice=%s", botID, payerID, plan.Code, invoiceID)
+	return payURL, nil
+}
+
+// findPlan — платный тариф бота по коду (пробный не дарится)
+func (s *service) findPlan(ctx context.Context, botID, planCode string) (*ports.TariffPlan, error) {
+	plans, err := s.tariffs.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range plans {
+		if p.BotID == botID && p.Code == planCode && !p.IsTrial {
+			return p, nil
+		}
+	}
+	return nil, ErrPlanNotFound
+}
+
+func (s *service) GetByInvoice(ctx context.Context, invoiceID string) (*Gift, error) {
+	return s.repo.GetByInvoice(ctx, invoiceID)
+}
+
+func (s *service) List(ctx context.Context, botID string) ([]*Gift, error) {
+	return s.repo.ListByBot(ctx, botID)
+}
+
+func (s *service) ListByPayer(ctx context.Context, botID string, payerID int64) ([]*Gift, error) {
+	return s.repo.ListByPayer(ctx, botID, payerID)
+}
+
+// ==================================================
+// WEBHOOK
+// ==================================================
+
+// Paid — оплата прошла (в том числе после отмены): код начинает действовать, покупатель получает его в боте
+func (s *service) Paid(ctx context.Context, invoiceID string) error {
+	g, err := s.load(ctx, invoiceID)
+	if err != nil {
+		return err
+	}
+
+	ok, err := s.repo.SetStatus(ctx, g.ID, StatusPaid, StatusPending, StatusCanceled)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		log.Printf("[gift] paid skip invoice=%s status=%s", invoiceID, g.Status)
+		return nil
+	}
+
+	log.Printf("[gift] paid bot=%s payer=%d invoice=%s", g.BotID, g.PayerID, invoiceID)
+
+	_ = s.notifier.UserNotify(ctx, g.BotID, g.PayerID, fmt.Sprintf(
+		"🎁 Подарок оплачен: тариф «%s».\n\n"+
+			"Код подарка: %s\n\n"+
+			"Получатель открывает этого бота и отправляет:\n/gift %s\n\n"+
+			"Ссылка для получателя — в /gifts.",
+		g.PlanName, g.Code, g.Code,
+	))
+	return nil
+}
+
+func (s *service) Canceled(ctx context.Context, invoiceID string) error {
+	g, err := s.load(ctx, invoiceID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.repo.SetStatus(ctx, g.ID, StatusCanceled, StatusPending); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Refunded — код перестаёт действовать; если подарок уже активирован, доступ получателя закрывается
+func (s *service) Refunded(ctx context.Context, invoiceID string) error {
+	g, err := s.load(ctx, invoiceID)
+	if err != nil {
+		return err
+	}
+
+	if g.Status == StatusRedeemed {
+		if err := s.subs.Refund(ctx, invoiceID); err != nil {
+			return err
+		}
+	}
+
+	ok, err := s.repo.SetStatus(ctx, g.ID, StatusRefunded, StatusPaid, StatusRedeemed)
+	if err != nil {
+		return err
+	}
+	if ok {
+		log.Printf("[gift] refunded bot=%s payer=%d invoice=%s was=%s", g.BotID, g.PayerID, invoiceID, g.Status)
+	}
+	return nil
+}
+
+func (s *service) load(ctx context.Context, invoiceID string) (*Gift, error) {
+	g, err := s.repo.GetByInvoice(ctx, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	if g == nil {
+		return nil, fmt.Errorf("%w: invoice=%s", ErrNotFound, invoiceID)
+	}
+	return g, nil
+}
+
+// ==================================================
+// REDEEM
+// ==================================================
+
+func (s *service) Redeem(ctx context.Context, botID string, recipientID int64, code string) (*Gift, error) {
+	g, err := s.repo.GetByCode(ctx, botID, NormalizeCode(code))
+	if err != nil {
+		return nil, err
+	}
+	if g == nil {
+		return nil, ErrNotFound
+	}
+
+	switch g.Status {
+	case StatusPending:
+		return nil, ErrNotPaid
+	case StatusRedeemed:
+		return nil, ErrAlreadyRedeemed
+	case StatusCanceled, StatusRefunded:
+		return nil, ErrUnavailable
+	}
+
+	// сначала занимаем код — два получателя одновременно его не активируют
+	ok, err := s.repo.MarkRedeemed(ctx, g.ID, recipientID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrAlreadyRedeemed
+	}
+
+	if err := s.subs.GrantGift(ctx, g.BotID, recipientID, g.PlanID, g.InvoiceID, g.Amount); err != nil {
+		if uerr := s.repo.Unredeem(ctx, g.ID); uerr != nil {
+			log.Printf("[gift] unredeem id=%d: %v", g.ID, uerr)
+		}
+		return nil, err
+	}
+
+	log.Printf("[gift] redeemed bot=%s payer=%d recipient=%d invoice=%s", g.BotID, g.PayerID, recipientID, g.InvoiceID)
+
+	if g.PayerID != recipientID {
+		_ = s.notifier.UserNotify(ctx, g.BotID, g.PayerID,
+			fmt.Sprintf("🎁 Твой подарок (тариф «%s») активирован получателем.", g.PlanName))
+	}
+
+	return g, nil
+}
@@ -3,6 +3,11 @@ package infra
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+	"github.com/lib/pq"
 )
 
 type AuthRepo struct {
@@ -25,3 +30,151 @@ func (r *AuthRepo) GetPassword(ctx context.Context) (string, error) {
 	}
 	return password, err
 }
+
+// ==================================================
+// ADMINS
+// ==================================================
+
+const adminColumns = `id, login, role, bot_ids, active, created_at`
+
+func scanAdmin(row interface{ Scan(dest ...any) error }, extra ...any) (*ports.Admin, error) {
+	var a ports.Admin
+	var botIDs pq.StringArray
+
+	dest := append([]any{&a.ID, &a.Login, &a.Role, &botIDs, &a.Active, &a.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	a.BotIDs = []string(botIDs)
+	if a.BotIDs == nil {
+		a.BotIDs = []string{}
+	}
+	return &a, nil
+}
+
+func (r *AuthRepo) CountAdmins(ctx context.Context) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM admin_users`).Scan(&n)
+	return n, err
+}
+
+func (r *AuthRepo) CreateAdmin(ctx context.Context, a *ports.Admin, passwordHash string) error {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO admin_users (login, password_hash, role, bot_ids, active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, a.Login, passwordHash, a.Role, pq.Array(a.BotIDs), a.Active).Scan(&a.ID, &a.CreatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ports.ErrAdminExists
+	}
+	return err
+}
+
+func (r *AuthRepo) GetAdminByLogin(ctx context.Context, login string) (*ports.Admin, string, error) {
+	var hash string
+	a, err := scanAdmin(r.db.QueryRowContext(ctx, `
+		SELECT `+adminColumns+`, password_hash
+		FROM admin_users
+		WHERE login = $1
+	`, login), &hash)
+	if err != nil || a == nil {
+		return nil, "", err
+	}
+	return a, hash, nil
+}
+
+func (r *AuthRepo) GetAdmin(ctx context.Context, id int64) (*ports.Admin, error) {
+	return scanAdmin(r.db.QueryRowContext(ctx, `
+		SELECT `+adminColumns+`
+		FROM admin_users
+		WHERE id = $1
+	`, id))
+}
+
+func (r *AuthRepo) ListAdmins(ctx context.Context) ([]*ports.Admin, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+adminColumns+`
+		FROM admin_users
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*ports.Admin
+	for rows.Next() {
+		a, err := scanAdmin(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func (r *AuthRepo) UpdateAdmin(ctx context.Context, a *ports.Admin, passwordHash *string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE admin_users
+		SET login         = $2,
+		    role          = $3,
+		    bot_ids       = $4,
+		    active        = $5,
+		    password_hash = COALESCE($6, password_hash),
+		    updated_at    = NOW()
+		WHERE id = $1
+	`, a.ID, a.Login, a.Role, pq.Array(a.BotIDs), a.Active, passwordHash)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ports.ErrAdminExists
+	}
+	return err
+}
+
+// ==================================================
+// SESSIONS
+// ==================================================
+
+func (r *AuthRepo) CreateSession(ctx context.Context, tokenHash string, adminID int64, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO admin_sessions (token_hash, admin_id, expires_at)
+		VALUES ($1, $2, $3)
+	`, tokenHash, adminID, expiresAt)
+	return err
+}
+
+func (r *AuthRepo) GetSessionAdmin(ctx context.Context, tokenHash string) (*ports.Admin, error) {
+	return scanAdmin(r.db.QueryRowContext(ctx, `
+		SELECT a.id, a.login, a.role, a.bot_ids, a.active, a.created_at
+		FROM admin_sessions s
+		JOIN admin_users a ON a.id = s.admin_id
+		WHERE s.token_hash = $1
+		  AND s.revoked_at IS NULL
+		  AND s.expires_at > NOW()
+		  AND a.active
+	`, tokenHash))
+}
+
+func (r *AuthRepo) RevokeSession(ctx context.Context, tokenHash string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE admin_sessions
+		SET revoked_at = NOW()
+		WHERE token_hash = $1 AND revoked_at IS NULL
+	`, tokenHash)
+	return err
+}
+
+func (r *AuthRepo) RevokeAdminSessions(ctx context.Context, adminID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE admin_sessions
+		SET revoked_at = NOW()
+		WHERE admin_id = $1 AND revoked_at IS NULL
+	`, adminID)
+	return err
+}
@@ -8,7 +8,6 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/Vovarama1992/make_ziper/internal/ports"
@@ -16,12 +15,84 @@ import (
 
 type CloudPaymentsProvider struct {
 	httpClient *http.Client
+	checkout   ports.CheckoutResolver
+	merchants  ports.CredentialsResolver
 }
 
-func NewCloudPaymentsProvider() ports.PaymentProvider {
+var (
+	_ ports.PaymentProvider  = (*CloudPaymentsProvider)(nil)
+	_ ports.RecurringCharger = (*CloudPaymentsProvider)(nil)
+)
+
+func NewCloudPaymentsProvider(checkout ports.CheckoutResolver, merchants ports.CredentialsResolver) *CloudPaymentsProvider {
 	return &CloudPaymentsProvider{
 		httpClient: &http.Client{Timeout: 10 * time.Second},
+		checkout:   checkout,
+		merchants:  merchants,
+	}
+}
+
+// credentials — Public ID и API secret кабинета бота
+func (p *CloudPaymentsProvider) credentials(ctx context.Context, botID string) (*ports.MerchantCredentials, error) {
+	return p.merchants.Credentials(ctx, botID, "cloudpayments")
+}
+
+// ставки НДС: код ЮKassa (bot_configs.receipt_vat_code) → vat CloudKassir, nil — без НДС
+var cpVat = map[int]any{
+	1: nil, 2: 0, 3: 10, 4: 20, 5: 110, 6: 120,
+	7: 5, 8: 7, 9: 105, 10: 107, 11: 22, 12: 122,
+}
+
+// признак предмета расчёта: значения ЮKassa → object CloudKassir
+var cpObject = map[string]int{
+	"commodity": 1,
+	"job":       3,
+	"service":   4,
+}
+
+// withCheckout — контакт покупателя, возврат после оплаты и чек 54-ФЗ (JsonData.CloudPayments.CustomerReceipt)
+func withCheckout(body map[string]any, co *ports.Checkout, description string, price float64) {
+	object, ok := cpObject[co.PaymentSubject]
+	if !ok {
+		object = 4
+	}
+
+	receipt := map[string]any{
+		"Items": []map[string]any{
+			{
+				"label":    description,
+				"price":    price,
+				"quantity": 1,
+				"amount":   price,
+				"vat":      cpVat[co.VatCode],
+				"method":   4, // полный расчёт
+				"object":   object,
+			},
+		},
+		"amounts": map[string]any{"electronic": price},
+	}
+	if co.Email != "" {
+		body["Email"] = co.Email
+		receipt["email"] = co.Email
+	}
+	if co.Phone != "" {
+		body["Phone"] = co.Phone
+		receipt["phone"] = co.Phone
+	}
+	// у CloudKassir системы налогообложения с нуля, у ЮKassa — с единицы
+	if co.TaxSystem > 0 {
+		receipt["taxationSystem"] = co.TaxSystem - 1
+	}
+	if co.ReturnURL != "" {
+		body["SuccessRedirectUrl"] = co.ReturnURL
+	}
+
+	data, _ := body["JsonData"].(map[string]any)
+	if data == nil {
+		data = map[string]any{}
+		body["JsonData"] = data
 	}
+	data["CloudPayments"] = map[string]any{"CustomerReceipt": receipt}
 }
 
 func (p *CloudPaymentsProvider) CreateMinutePackagePayment(
@@ -32,13 +103,19 @@ func (p *CloudPaymentsProvider) CreateMinutePackagePayment(
 	price float64,
 	title string,
 	minutes int,
+	invoiceID string,
 ) (string, string, error) {
 
+	co, err := p.checkout.Checkout(ctx, botID, telegramID)
+	if err != nil {
+		return "", "", err
+	}
+
 	body := map[string]any{
 		"Amount":      price,
 		"Currency":    "RUB",
 		"Description": fmt.Sprintf("Minute package '%s' (%d min)", title, minutes),
-		"InvoiceId":   fmt.Sprintf("pkg_%d_%d", telegramID, time.Now().Unix()),
+		"InvoiceId":   invoiceID,
 		"AccountId":   fmt.Sprintf("%d", telegramID),
 		"JsonData": map[string]any{
 			"payment_type": "minute_package",
@@ -47,8 +124,9 @@ func (p *CloudPaymentsProvider) CreateMinutePackagePayment(
 			"package_id":   packageID,
 		},
 	}
+	withCheckout(body, co, title, price)
 
-	return p.createOrder(ctx, body)
+	return p.createOrder(ctx, botID, body)
 }
 
 func (p *CloudPaymentsProvider) CreateSubscriptionPayment(
@@ -58,8 +136,14 @@ func (p *CloudPaymentsProvider) CreateSubscriptionPayment(
 	planName string,
 	price float64,
 	invoiceID string,
+	_ bool, // токен карты CloudPayments присылает в Pay-уведомлении всегда
 ) (string, string, error) {
 
+	co, err := p.checkout.Checkout(ctx, botID, telegramID)
+	if err != nil {
+		return "", "", err
+	}
+
 	body := map[string]any{
 		"Amount":   price,
 		"Currency": "RUB",
@@ -76,29 +160,77 @@ func (p *CloudPaymentsProvider) CreateSubscriptionPayment(
 			"plan_name":    planName,
 		},
 	}
+	withCheckout(body, co, "Подписка "+planName, price)
 
-	return p.createOrder(ctx, body)
+	return p.createOrder(ctx, botID, body)
+}
+
+func (p *CloudPaymentsProvider) CreateGiftPayment(
+	ctx context.Context,
+	botID string,
+	payerID int64,
+	planName string,
+	price float64,
+	invoiceID string,
+) (string, string, error) {
+
+	co, err := p.checkout.Checkout(ctx, botID, payerID)
+	if err != nil {
+		return "", "", err
+	}
+
+	body := map[string]any{
+		"Amount":   price,
+		"Currency": "RUB",
+		"Description": fmt.Sprintf(
+			"Подписка %s в подарок",
+			planName,
+		),
+		"InvoiceId": invoiceID,
+		"AccountId": fmt.Sprintf("%d", payerID),
+		"JsonData": map[string]any{
+			"payment_type": "gift",
+			"bot_id":       botID,
+			"telegram_id":  payerID,
+			"plan_name":    planName,
+		},
+	}
+	withCheckout(body, co, "Подписка "+planName+" в подарок", price)
+
+	return p.createOrder(ctx, botID, body)
+}
+
+// cpLogSummary — заказ для лога без персональных данных чека
+func cpLogSummary(payload map[string]any) string {
+	data, _ := payload["JsonData"].(map[string]any)
+	return fmt.Sprintf("bot=%v tg=%v type=%v invoice=%v amount=%v",
+		data["bot_id"], data["telegram_id"], data["payment_type"], payload["InvoiceId"], payload["Amount"])
 }
 
 func (p *CloudPaymentsProvider) createOrder(
 	ctx context.Context,
+	botID string,
 	payload map[string]any,
 ) (string, string, error) {
 
 	apiURL := "https://api.cloudpayments.ru/orders/create"
-	publicID := os.Getenv("CLOUDPAYMENTS_PUBLIC_ID")
-	secret := os.Getenv("CLOUDPAYMENTS_API_SECRET")
+
+	creds, err := p.credentials(ctx, botID)
+	if err != nil {
+		return "", "", err
+	}
 
 	reqBody, _ := json.Marshal(payload)
 
-	log.Printf("[CP] POST %s body=%s", apiURL, string(reqBody))
+	// тело не пишем: Email, Phone и чек с контактами покупателя
+	log.Printf("[CP] POST %s %s", apiURL, cpLogSummary(payload))
 
 	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(reqBody))
 	if err != nil {
 		return "", "", err
 	}
 
-	req.SetBasicAuth(publicID, secret)
+	req.SetBasicAuth(creds.Login, creds.Secret)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := p.httpClient.Do(req)
@@ -109,7 +241,8 @@ func (p *CloudPaymentsProvider) createOrder(
 
 	raw, _ := io.ReadAll(resp.Body)
 
-	log.Printf("[CP] status=%d resp=%s", resp.StatusCode, string(raw))
+	// в ответе заказа те же Email и Phone — только статус
+	log.Printf("[CP] status=%d", resp.StatusCode)
 
 	var cp struct {
 		Success bool   `json:"Success"`
@@ -133,3 +266,93 @@ func (p *CloudPaymentsProvider) createOrder(
 
 	return cp.Model.Url, cp.Model.Id, nil
 }
+
+// ChargeSaved — оплата по токену карты (POST /payments/tokens/charge)
+func (p *CloudPaymentsProvider) ChargeSaved(ctx context.Context, c *ports.RecurringCharge) (*ports.ProviderPayment, error) {
+	co, err := p.checkout.Checkout(ctx, c.BotID, c.TelegramID)
+	if err != nil {
+		return nil, &ports.CheckoutConfigError{Err: err}
+	}
+
+	creds, err := p.credentials(ctx, c.BotID)
+	if err != nil {
+		return nil, &ports.CheckoutConfigError{Err: err}
+	}
+
+	body := map[string]any{
+		"Amount":      c.Amount,
+		"Currency":    "RUB",
+		"AccountId":   fmt.Sprintf("%d", c.TelegramID),
+		"Token":       c.MethodID,
+		"InvoiceId":   c.InvoiceID,
+		"Description": fmt.Sprintf("Продление подписки %s", c.PlanCode),
+		"JsonData": map[string]any{
+			"payment_type":    "renewal",
+			"bot_id":          c.BotID,
+			"telegram_id":     c.TelegramID,
+			"subscription_id": c.SubscriptionID,
+		},
+	}
+	withCheckout(body, co, "Продление подписки "+c.PlanCode, c.Amount)
+
+	reqBody, _ := json.Marshal(body)
+
+	log.Printf("[CP] token charge bot=%s tg=%d sub=%d amount=%.2f",
+		c.BotID, c.TelegramID, c.SubscriptionID, c.Amount)
+
+	req, err := http.NewRequestWithContext(ctx, "POST",
+		"https://api.cloudpayments.ru/payments/tokens/charge", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(creds.Login, creds.Secret)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-ID", c.InvoiceID)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(resp.Body)
+
+	var cp struct {
+		Success bool   `json:"Success"`
+		Message string `json:"Message"`
+		Model   *struct {
+			TransactionID int64   `json:"TransactionId"`
+			Amount        float64 `json:"Amount"`
+		} `json:"Model"`
+	}
+	if err := json.Unmarshal(raw, &cp); err != nil {
+		return nil, fmt.Errorf("cloudpayments charge status=%d: %w", resp.StatusCode, err)
+	}
+
+	var txID int64
+	if cp.Model != nil {
+		txID = cp.Model.TransactionID
+	}
+	log.Printf("[CP] token charge status=%d success=%v tx=%d invoice=%s message=%s",
+		resp.StatusCode, cp.Success, txID, c.InvoiceID, cp.Message)
+
+	// без транзакции — запрос не дошёл до банка
+	if cp.Model == nil || cp.Model.TransactionID == 0 {
+		return nil, fmt.Errorf("cloudpayments charge error: %s", cp.Message)
+	}
+
+	// без Success списание не прошло: отказ банка или нужен 3-D Secure,
+	// который без участия пользователя не пройти
+	status := "canceled"
+	if cp.Success {
+		status = "succeeded"
+	}
+
+	return &ports.ProviderPayment{
+		ID:              fmt.Sprintf("%d", cp.Model.TransactionID),
+		Status:          status,
+		Paid:            cp.Success,
+		Amount:          cp.Model.Amount,
+		PaymentMethodID: c.MethodID,
+	}, nil
+}
@@ -28,6 +28,17 @@ func (r *recordRepo) CreateText(ctx context.Context, botID string, telegramID in
 	return id, err
 }
 
+// CreateReply — ответ тьютора с моделью, которая его сгенерировала
+func (r *recordRepo) CreateReply(ctx context.Context, botID string, telegramID int64, text, model string) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO records (bot_id, telegram_id, role, record_type, text_content, model, created_at)
+		VALUES ($1, $2, 'tutor', 'text', $3, $4, $5)
+		RETURNING id
+	`, botID, telegramID, text, model, time.Now()).Scan(&id)
+	return id, err
+}
+
 func (r *recordRepo) CreateImage(ctx context.Context, botID string, telegramID int64, role, imageURL string) (int64, error) {
 	var id int64
 	err := r.db.QueryRowContext(ctx, `
@@ -40,7 +51,7 @@ func (r *recordRepo) CreateImage(ctx context.Context, botID string, telegramID i
 
 func (r *recordRepo) GetHistory(ctx context.Context, botID string, telegramID int64) ([]ports.Record, error) {
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, telegram_id, bot_id, user_ref, role, record_type, text_content, image_url, created_at
+		SELECT id, telegram_id, bot_id, user_ref, role, record_type, text_content, image_url, model, created_at
 		FROM records
 		WHERE telegram_id = $1 AND bot_id = $2
 		ORDER BY created_at ASC
@@ -62,6 +73,7 @@ func (r *recordRepo) GetHistory(ctx context.Context, botID string, telegramID in
 			&rec.Type,
 			&rec.Text,
 			&rec.ImageURL,
+			&rec.Model,
 			&rec.CreatedAt,
 		); err != nil {
 			return nil, err
@@ -149,7 +161,7 @@ func (r *recordRepo) GetHistoryState(ctx context.Context, botID string, telegram
 
 func (r *recordRepo) GetLastNRecords(ctx context.Context, botID string, telegramID int64, n int) ([]ports.Record, error) {
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, telegram_id, bot_id, user_ref, role, record_type, text_content, image_url, created_at
+		SELECT id, telegram_id, bot_id, user_ref, role, record_type, text_content, image_url, model, created_at
 		FROM records
 		WHERE telegram_id = $1 AND bot_id = $2
 		ORDER BY created_at DESC
@@ -172,6 +184,7 @@ func (r *recordRepo) GetLastNRecords(ctx context.Context, botID string, telegram
 			&rec.Type,
 			&rec.Text,
 			&rec.ImageURL,
+			&rec.Model,
 			&rec.CreatedAt,
 		); err != nil {
 			return nil, err
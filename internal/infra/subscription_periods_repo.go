@@ -0,0 +1,289 @@
+package infra
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+)
+
+type subscriptionPeriodRepo struct {
+	db *sql.DB
+}
+
+func NewSubscriptionPeriodRepo(db *sql.DB) ports.SubscriptionPeriodRepo {
+	return &subscriptionPeriodRepo{db: db}
+}
+
+// periodColumns — порядок совпадает со scanPeriod
+const periodColumns = `
+			p.id, p.bot_id, p.telegram_id, p.plan_id, COALESCE(t.name, ''),
+			p.kind, p.status, p.payment_id,
+			p.amount, p.credit, p.voice_minutes, p.auto_renew,
+			p.promo_code, p.discount, p.bonus_days,
+			p.starts_at, p.ends_at, p.note,
+			p.created_at, p.updated_at
+`
+
+func scanPeriod(row rowScanner) (*ports.SubscriptionPeriod, error) {
+	var p ports.SubscriptionPeriod
+
+	err := row.Scan(
+		&p.ID, &p.BotID, &p.TelegramID, &p.PlanID, &p.PlanName,
+		&p.Kind, &p.Status, &p.PaymentID,
+		&p.Amount, &p.Credit, &p.VoiceMinutes, &p.AutoRenew,
+		&p.PromoCode, &p.Discount, &p.BonusDays,
+		&p.StartsAt, &p.EndsAt, &p.Note,
+		&p.CreatedAt, &p.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (r *subscriptionPeriodRepo) Create(ctx context.Context, p *ports.SubscriptionPeriod) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO subscription_periods (
+			bot_id, telegram_id, plan_id,
+			kind, status, payment_id,
+			amount, credit, voice_minutes, auto_renew,
+			promo_code, discount, bonus_days,
+			starts_at, ends_at, note
+		)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)
+		RETURNING id, created_at, updated_at
+	`,
+		p.BotID, p.TelegramID, p.PlanID,
+		p.Kind, p.Status, p.PaymentID,
+		p.Amount, p.Credit, p.VoiceMinutes, p.AutoRenew,
+		p.PromoCode, p.Discount, p.BonusDays,
+		p.StartsAt, p.EndsAt, p.Note,
+	).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+}
+
+func (r *subscriptionPeriodRepo) GetByPaymentID(ctx context.Context, paymentID string) (*ports.SubscriptionPeriod, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT `+periodColumns+`
+		FROM subscription_periods p
+		LEFT JOIN tariff_plans t ON t.id = p.plan_id
+		WHERE p.payment_id = $1
+	`, paymentID)
+
+	return scanPeriod(row)
+}
+
+func (r *subscriptionPeriodRepo) ListByUser(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+) ([]*ports.SubscriptionPeriod, error) {
+
+	return r.list(ctx, `
+		SELECT `+periodColumns+`
+		FROM subscription_periods p
+		LEFT JOIN tariff_plans t ON t.id = p.plan_id
+		WHERE p.bot_id = $1 AND p.telegram_id = $2
+		ORDER BY p.created_at, p.id
+	`, botID, telegramID)
+}
+
+func (r *subscriptionPeriodRepo) ListCurrent(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	at time.Time,
+) ([]*ports.SubscriptionPeriod, error) {
+
+	return r.list(ctx, `
+		SELECT `+periodColumns+`
+		FROM subscription_periods p
+		LEFT JOIN tariff_plans t ON t.id = p.plan_id
+		WHERE p.bot_id = $1 AND p.telegram_id = $2
+		  AND p.status = 'paid'
+		  AND p.ends_at > $3
+		ORDER BY p.ends_at, p.id
+	`, botID, telegramID, at)
+}
+
+func (r *subscriptionPeriodRepo) list(ctx context.Context, q string, args ...any) ([]*ports.SubscriptionPeriod, error) {
+	rows, err := r.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*ports.SubscriptionPeriod
+	for rows.Next() {
+		p, err := scanPeriod(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+func (r *subscriptionPeriodRepo) MarkPaid(
+	ctx context.Context,
+	id int64,
+	startsAt, endsAt time.Time,
+	credit float64,
+	supersedeAt *time.Time,
+) (bool, error) {
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var botID string
+	var telegramID int64
+	err = tx.QueryRowContext(ctx, `
+		UPDATE subscription_periods
+		SET status     = 'paid',
+		    starts_at  = $2,
+		    ends_at    = $3,
+		    credit     = $4,
+		    updated_at = NOW()
+		WHERE id = $1
+		  AND status IN ('pending', 'canceled')
+		RETURNING bot_id, telegram_id
+	`, id, startsAt, endsAt, credit).Scan(&botID, &telegramID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// смена тарифа: прежние периоды закрываются в той же транзакции,
+	// иначе сбой между шагами оставит пользователя без доступа
+	if supersedeAt != nil {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE subscription_periods
+			SET status = 'superseded', updated_at = NOW()
+			WHERE bot_id = $1 AND telegram_id = $2
+			  AND id <> $3
+			  AND status = 'paid'
+			  AND ends_at > $4
+		`, botID, telegramID, id, *supersedeAt); err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+func (r *subscriptionPeriodRepo) CreatePaid(
+	ctx context.Context,
+	p *ports.SubscriptionPeriod,
+	supersedeAt *time.Time,
+) (bool, error) {
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// повтор того же платежа упирается в уникальный payment_id, а не в проверку до вставки
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO subscription_periods (
+			bot_id, telegram_id, plan_id,
+			kind, status, payment_id,
+			amount, credit, voice_minutes, auto_renew,
+			promo_code, discount, bonus_days,
+			starts_at, ends_at, note
+		)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)
+		ON CONFLICT (payment_id) WHERE payment_id IS NOT NULL DO NOTHING
+		RETURNING id, created_at, updated_at
+	`,
+		p.BotID, p.TelegramID, p.PlanID,
+		p.Kind, p.Status, p.PaymentID,
+		p.Amount, p.Credit, p.VoiceMinutes, p.AutoRenew,
+		p.PromoCode, p.Discount, p.BonusDays,
+		p.StartsAt, p.EndsAt, p.Note,
+	).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if supersedeAt != nil {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE subscription_periods
+			SET status = 'superseded', updated_at = NOW()
+			WHERE bot_id = $1 AND telegram_id = $2
+			  AND id <> $3
+			  AND status = 'paid'
+			  AND ends_at > $4
+		`, p.BotID, p.TelegramID, p.ID, *supersedeAt); err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+func (r *subscriptionPeriodRepo) SetStatus(ctx context.Context, id int64, status string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE subscription_periods
+		SET status = $2, updated_at = NOW()
+		WHERE id = $1
+	`, id, status)
+	return err
+}
+
+func (r *subscriptionPeriodRepo) CloseCurrent(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	at time.Time,
+	status string,
+) error {
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE subscription_periods
+		SET status = $4, updated_at = NOW()
+		WHERE bot_id = $1 AND telegram_id = $2
+		  AND status = 'paid'
+		  AND ends_at > $3
+	`, botID, telegramID, at, status)
+	return err
+}
+
+func (r *subscriptionPeriodRepo) CancelStale(ctx context.Context, olderThan time.Duration) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE subscription_periods
+		SET status = 'canceled', updated_at = NOW()
+		WHERE status = 'pending'
+		  AND created_at < NOW() - $1::interval
+		RETURNING payment_id
+	`, olderThan.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var paymentID sql.NullString
+		if err := rows.Scan(&paymentID); err != nil {
+			return nil, err
+		}
+		if paymentID.Valid {
+			out = append(out, paymentID.String)
+		}
+	}
+	return out, rows.Err()
+}
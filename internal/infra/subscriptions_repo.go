@@ -19,7 +19,20 @@ func NewSubscriptionRepo(db *sql.DB) ports.SubscriptionRepo {
 	return &subscriptionRepo{db: db}
 }
 
-func (r *subscriptionRepo) scanRow(row *sql.Row) (*ports.Subscription, error) {
+// subscriptionColumns — порядок совпадает со scanRow
+const subscriptionColumns = `
+			id, bot_id, telegram_id, plan_id, status,
+			started_at, expires_at, updated_at, yookassa_payment_id,
+			voice_minutes,
+			auto_renew, payment_method_id, payment_method_provider,
+			renew_attempts, next_renew_at
+`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func (r *subscriptionRepo) scanRow(row rowScanner) (*ports.Subscription, error) {
 	var s ports.Subscription
 	var yid sql.NullString
 
@@ -34,6 +47,11 @@ func (r *subscriptionRepo) scanRow(row *sql.Row) (*ports.Subscription, error) {
 		&s.UpdatedAt,
 		&yid,
 		&s.VoiceMinutes, // ⬅️ ЯДРО ФИКСА
+		&s.AutoRenew,
+		&s.PaymentMethodID,
+		&s.PaymentMethodProvider,
+		&s.RenewAttempts,
+		&s.NextRenewAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -53,9 +71,10 @@ func (r *subscriptionRepo) Create(ctx context.Context, s *ports.Subscription) er
 	const q = `
 		INSERT INTO subscriptions (
 			bot_id, telegram_id, plan_id, status,
-			started_at, expires_at, updated_at, yookassa_payment_id
+			started_at, expires_at, updated_at, yookassa_payment_id,
+			auto_renew
 		)
-		VALUES ($1,$2,$3,$4,$5,$6, now(), $7)
+		VALUES ($1,$2,$3,$4,$5,$6, now(), $7, $8)
 		ON CONFLICT (bot_id, telegram_id)
 		DO UPDATE SET
 			plan_id = EXCLUDED.plan_id,
@@ -63,7 +82,10 @@ func (r *subscriptionRepo) Create(ctx context.Context, s *ports.Subscription) er
 			started_at = EXCLUDED.started_at,
 			expires_at = EXCLUDED.expires_at,
 			updated_at = now(),
-			yookassa_payment_id = EXCLUDED.yookassa_payment_id
+			yookassa_payment_id = EXCLUDED.yookassa_payment_id,
+			auto_renew = EXCLUDED.auto_renew,
+			renew_attempts = 0,
+			next_renew_at = NULL
 		RETURNING id
 	`
 	return r.db.QueryRowContext(
@@ -75,6 +97,7 @@ func (r *subscriptionRepo) Create(ctx context.Context, s *ports.Subscription) er
 		s.StartedAt,
 		s.ExpiresAt,
 		s.YookassaPaymentID,
+		s.AutoRenew,
 	).Scan(&s.ID)
 }
 
@@ -88,10 +111,7 @@ func (r *subscriptionRepo) Delete(ctx context.Context, botID string, telegramID
 
 func (r *subscriptionRepo) GetByPaymentID(ctx context.Context, paymentID string) (*ports.Subscription, error) {
 	row := r.db.QueryRowContext(ctx, `
-		SELECT 
-			id, bot_id, telegram_id, plan_id, status,
-			started_at, expires_at, updated_at, yookassa_payment_id,
-			voice_minutes
+		SELECT `+subscriptionColumns+`
 		FROM subscriptions 
 		WHERE yookassa_payment_id = $1
 	`, paymentID)
@@ -99,6 +119,16 @@ func (r *subscriptionRepo) GetByPaymentID(ctx context.Context, paymentID string)
 	return r.scanRow(row)
 }
 
+func (r *subscriptionRepo) GetByID(ctx context.Context, id int64) (*ports.Subscription, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT `+subscriptionColumns+`
+		FROM subscriptions
+		WHERE id = $1
+	`, id)
+
+	return r.scanRow(row)
+}
+
 func (r *subscriptionRepo) Get(
 	ctx context.Context,
 	botID string,
@@ -106,10 +136,7 @@ func (r *subscriptionRepo) Get(
 ) (*ports.Subscription, error) {
 
 	row := r.db.QueryRowContext(ctx, `
-		SELECT 
-			id, bot_id, telegram_id, plan_id, status,
-			started_at, expires_at, updated_at, yookassa_payment_id,
-			voice_minutes
+		SELECT `+subscriptionColumns+`
 		FROM subscriptions
 		WHERE bot_id = $1 AND telegram_id = $2
 		ORDER BY
@@ -142,23 +169,43 @@ func (r *subscriptionRepo) UpdateStatus(ctx context.Context, id int64, status st
 	return err
 }
 
-func (r *subscriptionRepo) Activate(
+// SetEntitlement — текущий доступ, выведенный из subscription_periods.
+// Минуты и автопродление не трогает.
+func (r *subscriptionRepo) SetEntitlement(
 	ctx context.Context,
-	id int64,
-	startedAt, expiresAt time.Time,
-	voiceMinutes float64,
-) error {
+	botID string,
+	telegramID int64,
+	planID *int64,
+	status string,
+	startedAt, expiresAt *time.Time,
+) (int64, error) {
+
+	var id int64
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO subscriptions (
+			bot_id, telegram_id, plan_id, status,
+			started_at, expires_at, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (bot_id, telegram_id)
+		DO UPDATE SET
+			plan_id    = EXCLUDED.plan_id,
+			status     = EXCLUDED.status,
+			started_at = EXCLUDED.started_at,
+			expires_at = EXCLUDED.expires_at,
+			updated_at = NOW()
+		RETURNING id
+	`, botID, telegramID, planID, status, startedAt, expiresAt).Scan(&id)
+
+	return id, err
+}
+
+func (r *subscriptionRepo) SetVoiceMinutes(ctx context.Context, id int64, minutes float64) error {
 	_, err := r.db.ExecContext(ctx, `
 		UPDATE subscriptions
-		SET 
-			status        = 'active',
-			started_at    = $2,
-			expires_at    = $3,
-			voice_minutes = $4,
-			updated_at    = NOW()
+		SET voice_minutes = $2, updated_at = NOW()
 		WHERE id = $1
-	`, id, startedAt, expiresAt, voiceMinutes)
-
+	`, id, minutes)
 	return err
 }
 
@@ -271,6 +318,33 @@ func (r *subscriptionRepo) AddVoiceMinutes(
 	return nil
 }
 
+func (r *subscriptionRepo) SubtractVoiceMinutes(
+	ctx context.Context,
+	botID string,
+	tgID int64,
+	minutes float64,
+) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE subscriptions
+		SET voice_minutes = GREATEST(voice_minutes - $3, 0),
+		    updated_at = NOW()
+		WHERE bot_id = $1 AND telegram_id = $2
+	`, botID, tgID, minutes)
+	return err
+}
+
+func (r *subscriptionRepo) Revoke(ctx context.Context, id int64, minutes float64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE subscriptions
+		SET status        = 'expired',
+		    expires_at    = NOW(),
+		    voice_minutes = GREATEST(voice_minutes - $2, 0),
+		    updated_at    = NOW()
+		WHERE id = $1
+	`, id, minutes)
+	return err
+}
+
 func (r *subscriptionRepo) CleanupPending(ctx context.Context, olderThan time.Duration) error {
 	_, err := r.db.ExecContext(ctx, `
         DELETE FROM subscriptions
@@ -443,3 +517,72 @@ func (r *subscriptionRepo) ExpireDue(ctx context.Context) ([]*ports.Subscription
 	}
 	return out, nil
 }
+
+// --------------------------------------------------
+// Автопродление
+// --------------------------------------------------
+
+func (r *subscriptionRepo) SetPaymentMethod(ctx context.Context, id int64, provider, methodID string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE subscriptions
+		SET payment_method_provider = $2,
+		    payment_method_id       = $3,
+		    updated_at              = NOW()
+		WHERE id = $1
+	`, id, provider, methodID)
+	return err
+}
+
+func (r *subscriptionRepo) SetAutoRenew(ctx context.Context, botID string, telegramID int64, on bool) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE subscriptions
+		SET auto_renew              = $3,
+		    payment_method_id       = CASE WHEN $3 THEN payment_method_id ELSE NULL END,
+		    payment_method_provider = CASE WHEN $3 THEN payment_method_provider ELSE NULL END,
+		    renew_attempts          = 0,
+		    next_renew_at           = NULL,
+		    updated_at              = NOW()
+		WHERE bot_id = $1 AND telegram_id = $2
+	`, botID, telegramID, on)
+	return err
+}
+
+func (r *subscriptionRepo) ScheduleRenewal(ctx context.Context, id int64, attempts int, next *time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE subscriptions
+		SET renew_attempts = $2,
+		    next_renew_at  = $3,
+		    updated_at     = NOW()
+		WHERE id = $1
+	`, id, attempts, next)
+	return err
+}
+
+// ListRenewalsDue — active (и expired, пока идёт дозапрос оплаты) с сохранённым способом оплаты
+func (r *subscriptionRepo) ListRenewalsDue(ctx context.Context, lead time.Duration) ([]*ports.Subscription, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+subscriptionColumns+`
+		FROM subscriptions
+		WHERE auto_renew
+		  AND payment_method_id IS NOT NULL
+		  AND status IN ('active', 'expired')
+		  AND expires_at IS NOT NULL
+		  AND expires_at <= NOW() + $1::interval
+		  AND (next_renew_at IS NULL OR next_renew_at <= NOW())
+		ORDER BY expires_at
+	`, lead.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*ports.Subscription
+	for rows.Next() {
+		s, err := r.scanRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
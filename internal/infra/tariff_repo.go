@@ -3,6 +3,7 @@ package infra
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 
 	"github.com/Vovarama1992/make_ziper/internal/ports"
 )
@@ -26,7 +27,8 @@ func (r *tariffRepo) ListAll(ctx context.Context) ([]*ports.TariffPlan, error) {
 			duration_minutes,
 			voice_minutes,
 			is_trial,
-			description
+			description,
+			COALESCE(features, '{}'::jsonb)
 		FROM tariff_plans
 		ORDER BY price ASC
 	`)
@@ -49,6 +51,7 @@ func (r *tariffRepo) ListAll(ctx context.Context) ([]*ports.TariffPlan, error) {
 			&t.VoiceMinutes,
 			&t.IsTrial,
 			&t.Description,
+			&t.Features,
 		); err != nil {
 			return nil, err
 		}
@@ -69,7 +72,8 @@ func (r *tariffRepo) GetByID(ctx context.Context, botID string, id int) (*ports.
 			duration_minutes,
 			voice_minutes,
 			is_trial,
-			description
+			description,
+			COALESCE(features, '{}'::jsonb)
 		FROM tariff_plans
 		WHERE id = $1 AND bot_id = $2
 	`, id, botID)
@@ -85,6 +89,7 @@ func (r *tariffRepo) GetByID(ctx context.Context, botID string, id int) (*ports.
 		&t.VoiceMinutes,
 		&t.IsTrial,
 		&t.Description,
+		&t.Features,
 	); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -106,7 +111,8 @@ func (r *tariffRepo) GetTrial(ctx context.Context, botID string) (*ports.TariffP
 			duration_minutes,
 			voice_minutes,
 			is_trial,
-			description
+			description,
+			COALESCE(features, '{}'::jsonb)
 		FROM tariff_plans
 		WHERE bot_id = $1 AND is_trial = true
 		LIMIT 1
@@ -123,6 +129,7 @@ func (r *tariffRepo) GetTrial(ctx context.Context, botID string) (*ports.TariffP
 		&t.VoiceMinutes,
 		&t.IsTrial,
 		&t.Description,
+		&t.Features,
 	); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -144,12 +151,13 @@ func (r *tariffRepo) Create(ctx context.Context, plan *ports.TariffPlan) (*ports
 			duration_minutes,
 			voice_minutes,
 			is_trial,
-			description
+			description,
+			features
 		)
 		VALUES (
 			$1,
 			(SELECT id FROM bot_configs WHERE bot_id = $1),
-			$2,$3,$4,$5,$6,$7,$8
+			$2,$3,$4,$5,$6,$7,$8,$9::jsonb
 		)
 		RETURNING
 			id,
@@ -160,7 +168,8 @@ func (r *tariffRepo) Create(ctx context.Context, plan *ports.TariffPlan) (*ports
 			duration_minutes,
 			voice_minutes,
 			is_trial,
-			description
+			description,
+			COALESCE(features, '{}'::jsonb)
 	`,
 		plan.BotID,
 		plan.Code,
@@ -170,6 +179,7 @@ func (r *tariffRepo) Create(ctx context.Context, plan *ports.TariffPlan) (*ports
 		plan.VoiceMinutes,
 		plan.IsTrial,
 		plan.Description,
+		featuresJSON(plan.Features),
 	)
 
 	var t ports.TariffPlan
@@ -183,6 +193,7 @@ func (r *tariffRepo) Create(ctx context.Context, plan *ports.TariffPlan) (*ports
 		&t.VoiceMinutes,
 		&t.IsTrial,
 		&t.Description,
+		&t.Features,
 	); err != nil {
 		return nil, err
 	}
@@ -200,7 +211,8 @@ func (r *tariffRepo) Update(ctx context.Context, plan *ports.TariffPlan) (*ports
 			duration_minutes = $4,
 			voice_minutes = $5,
 			is_trial = $6,
-			description = $7
+			description = $7,
+			features = $10::jsonb
 		WHERE id = $8 AND bot_id = $9
 		RETURNING
 			id,
@@ -211,7 +223,8 @@ func (r *tariffRepo) Update(ctx context.Context, plan *ports.TariffPlan) (*ports
 			duration_minutes,
 			voice_minutes,
 			is_trial,
-			description
+			description,
+			COALESCE(features, '{}'::jsonb)
 	`,
 		plan.Code,
 		plan.Name,
@@ -222,6 +235,7 @@ func (r *tariffRepo) Update(ctx context.Context, plan *ports.TariffPlan) (*ports
 		plan.Description,
 		plan.ID,
 		plan.BotID,
+		featuresJSON(plan.Features),
 	)
 
 	var t ports.TariffPlan
@@ -235,6 +249,7 @@ func (r *tariffRepo) Update(ctx context.Context, plan *ports.TariffPlan) (*ports
 		&t.VoiceMinutes,
 		&t.IsTrial,
 		&t.Description,
+		&t.Features,
 	); err != nil {
 		return nil, err
 	}
@@ -249,3 +264,11 @@ func (r *tariffRepo) Delete(ctx context.Context, id int) error {
 	`, id)
 	return err
 }
+
+// featuresJSON — пустые features пишем как {}, а не NULL
+func featuresJSON(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "{}"
+	}
+	return string(raw)
+}
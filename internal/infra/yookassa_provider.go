@@ -8,7 +8,9 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,12 +19,67 @@ import (
 
 type YooKassaProvider struct {
 	httpClient *http.Client
+	checkout   ports.CheckoutResolver
+	merchants  ports.CredentialsResolver
 }
 
-func NewYooKassaProvider() ports.PaymentProvider {
+var (
+	_ ports.PaymentProvider  = (*YooKassaProvider)(nil)
+	_ ports.PaymentVerifier  = (*YooKassaProvider)(nil)
+	_ ports.RecurringCharger = (*YooKassaProvider)(nil)
+)
+
+func NewYooKassaProvider(checkout ports.CheckoutResolver, merchants ports.CredentialsResolver) *YooKassaProvider {
 	return &YooKassaProvider{
 		httpClient: &http.Client{Timeout: 10 * time.Second},
+		checkout:   checkout,
+		merchants:  merchants,
+	}
+}
+
+// credentials — shopId и секретный ключ магазина бота
+func (p *YooKassaProvider) credentials(ctx context.Context, botID string) (*ports.MerchantCredentials, error) {
+	return p.merchants.Credentials(ctx, botID, "yookassa")
+}
+
+// ykConfirmation — redirect на страницу оплаты и возврат на сайт бота
+func ykConfirmation(co *ports.Checkout) map[string]any {
+	return map[string]any{
+		"type":       "redirect",
+		"return_url": co.ReturnURL,
+	}
+}
+
+// ykReceipt — чек 54-ФЗ на одну позицию
+func ykReceipt(co *ports.Checkout, description string, price float64) map[string]any {
+	customer := map[string]any{}
+	if co.Email != "" {
+		customer["email"] = co.Email
+	}
+	if co.Phone != "" {
+		customer["phone"] = co.Phone
+	}
+
+	receipt := map[string]any{
+		"customer": customer,
+		"items": []map[string]any{
+			{
+				"description": description,
+				"quantity":    "1.00",
+				"amount": map[string]any{
+					"value":    fmt.Sprintf("%.2f", price),
+					"currency": "RUB",
+				},
+				"vat_code":        co.VatCode,
+				"payment_subject": co.PaymentSubject,
+				"payment_mode":    "full_payment",
+			},
+		},
+	}
+	if co.TaxSystem > 0 {
+		receipt["tax_system_code"] = co.TaxSystem
 	}
+	return receipt
 }
 
 // ----------------------------------------------------
@@ -37,17 +94,15 @@ func (p *YooKassaProvider) CreateMinutePackagePayment(
 	price float64,
 	title string,
 	minutes int,
+	invoiceID string,
 ) (string, string, error) {
 
 	log.Printf("[YK] start create payment bot=%s tg=%d pkg=%d price=%.2f",
 		botID, telegramID, packageID, price)
 
-	apiURL := os.Getenv("YOOKASSA_API_URL")
-	shopID := os.Getenv("YOOKASSA_SHOP_ID")
-	secret := os.Getenv("YOOKASSA_SECRET_KEY")
-
-	if !strings.Contains(apiURL, "/v3/payments") {
-		apiURL = strings.TrimRight(apiURL, "/") + "/v3/payments"
+	co, err := p.checkout.Checkout(ctx, botID, telegramID)
+	if err != nil {
+		return "", "", err
 	}
 
 	body := map[string]any{
@@ -59,74 +114,18 @@ func (p *YooKassaProvider) CreateMinutePackagePayment(
 		"description": fmt.Sprintf(
 			"Minute package '%s' (%d min)", title, minutes,
 		),
-		"confirmation": map[string]any{
-			"type":       "redirect",
-			"return_url": "https://aifulls.com/success.html",
-		},
+		"confirmation": ykConfirmation(co),
 		"metadata": map[string]any{
 			"bot_id":       botID,
 			"telegram_id":  fmt.Sprintf("%d", telegramID),
 			"package_id":   fmt.Sprintf("%d", packageID),
 			"payment_type": "minute_package",
+			"invoice_id":   invoiceID,
 		},
-		"receipt": map[string]any{
-			"customer": map[string]any{
-				"email": "test@example.com",
-			},
-			"items": []map[string]any{
-				{
-					"description": title,
-					"quantity":    "1.00",
-					"amount": map[string]any{
-						"value":    fmt.Sprintf("%.2f", price),
-						"currency": "RUB",
-					},
-					"vat_code":        1,
-					"payment_subject": "service",
-					"payment_mode":    "full_payment",
-				},
-			},
-		},
-	}
-
-	reqBody, _ := json.Marshal(body)
-
-	log.Printf("[YK] request url=%s", apiURL)
-	log.Printf("[YK] request body=%s", string(reqBody))
-
-	req, _ := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(reqBody))
-	req.SetBasicAuth(shopID, secret)
-	req.Header.Set("Idempotence-Key", fmt.Sprintf("%d", time.Now().UnixNano()))
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := p.httpClient.Do(req)
-	if err != nil {
-		log.Printf("[YK] request error=%v", err)
-		return "", "", err
-	}
-	defer resp.Body.Close()
-
-	raw, _ := io.ReadAll(resp.Body)
-
-	log.Printf("[YK] response status=%d", resp.StatusCode)
-	log.Printf("[YK] response body=%s", string(raw))
-
-	if resp.StatusCode >= 300 {
-		return "", "", fmt.Errorf("yookassa error status=%d body=%s", resp.StatusCode, string(raw))
+		"receipt": ykReceipt(co, title, price),
 	}
 
-	var yresp struct {
-		ID           string `json:"id"`
-		Confirmation struct {
-			URL string `json:"confirmation_url"`
-		} `json:"confirmation"`
-	}
-
-	_ = json.Unmarshal(raw, &yresp)
-
-	log.Printf("[YK] created payment url=%s id=%s", yresp.Confirmation.URL, yresp.ID)
-
-	return yresp.Confirmation.URL, yresp.ID, nil
+	return p.createPayment(ctx, botID, body)
 }
 
 // ----------------------------------------------------
@@ -140,17 +139,15 @@ func (p *YooKassaProvider) CreateSubscriptionPayment(
 	planCode string,
 	price float64,
 	invoiceID string,
+	savePaymentMethod bool,
 ) (string, string, error) {
 
 	log.Printf("[YK] start create subscription bot=%s tg=%d plan=%s price=%.2f",
 		botID, telegramID, planCode, price)
 
-	apiURL := os.Getenv("YOOKASSA_API_URL")
-	shopID := os.Getenv("YOOKASSA_SHOP_ID")
-	secret := os.Getenv("YOOKASSA_SECRET_KEY")
-
-	if !strings.Contains(apiURL, "/v3/payments") {
-		apiURL = strings.TrimRight(apiURL, "/") + "/v3/payments"
+	co, err := p.checkout.Checkout(ctx, botID, telegramID)
+	if err != nil {
+		return "", "", err
 	}
 
 	body := map[string]any{
@@ -162,10 +159,7 @@ func (p *YooKassaProvider) CreateSubscriptionPayment(
 		"description": fmt.Sprintf(
 			"Subscription '%s'", planCode,
 		),
-		"confirmation": map[string]any{
-			"type":       "redirect",
-			"return_url": "https://aifulls.com/success.html",
-		},
+		"confirmation": ykConfirmation(co),
 		"metadata": map[string]any{
 			"bot_id":       botID,
 			"telegram_id":  fmt.Sprintf("%d", telegramID),
@@ -173,33 +167,86 @@ func (p *YooKassaProvider) CreateSubscriptionPayment(
 			"plan_code":    planCode,
 			"invoice_id":   invoiceID,
 		},
-		"receipt": map[string]any{
-			"customer": map[string]any{
-				"email": "test@example.com",
-			},
-			"items": []map[string]any{
-				{
-					"description": planCode,
-					"quantity":    "1.00",
-					"amount": map[string]any{
-						"value":    fmt.Sprintf("%.2f", price),
-						"currency": "RUB",
-					},
-					"vat_code":        1,
-					"payment_subject": "service",
-					"payment_mode":    "full_payment",
-				},
-			},
+		"save_payment_method": savePaymentMethod,
+		"receipt":             ykReceipt(co, planCode, price),
+	}
+
+	return p.createPayment(ctx, botID, body)
+}
+
+// ----------------------------------------------------
+// Gifts
+// ----------------------------------------------------
+
+// CreateGiftPayment — оплата тарифа в подарок: доступ получит тот, кто введёт код
+func (p *YooKassaProvider) CreateGiftPayment(
+	ctx context.Context,
+	botID string,
+	payerID int64,
+	planCode string,
+	price float64,
+	invoiceID string,
+) (string, string, error) {
+
+	log.Printf("[YK] start create gift bot=%s tg=%d plan=%s price=%.2f",
+		botID, payerID, planCode, price)
+
+	co, err := p.checkout.Checkout(ctx, botID, payerID)
+	if err != nil {
+		return "", "", err
+	}
+
+	body := map[string]any{
+		"amount": map[string]any{
+			"value":    fmt.Sprintf("%.2f", price),
+			"currency": "RUB",
+		},
+		"capture": true,
+		"description": fmt.Sprintf(
+			"Gift subscription '%s'", planCode,
+		),
+		"confirmation": ykConfirmation(co),
+		"metadata": map[string]any{
+			"bot_id":       botID,
+			"telegram_id":  fmt.Sprintf("%d", payerID),
+			"payment_type": "gift",
+			"plan_code":    planCode,
+			"invoice_id":   invoiceID,
 		},
+		"receipt": ykReceipt(co, planCode, price),
+	}
+
+	return p.createPayment(ctx, botID, body)
+}
+
+// ykLogSummary — платёж для лога без персональных данных чека
+func ykLogSummary(body map[string]any) string {
+	meta, _ := body["metadata"].(map[string]any)
+	amount, _ := body["amount"].(map[string]any)
+	return fmt.Sprintf("bot=%v tg=%v type=%v invoice=%v amount=%v",
+		meta["bot_id"], meta["telegram_id"], meta["payment_type"], meta["invoice_id"], amount["value"])
+}
+
+// createPayment — POST /v3/payments, возвращает ссылку на оплату и id платежа
+func (p *YooKassaProvider) createPayment(ctx context.Context, botID string, body map[string]any) (string, string, error) {
+	apiURL := os.Getenv("YOOKASSA_API_URL")
+
+	creds, err := p.credentials(ctx, botID)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !strings.Contains(apiURL, "/v3/payments") {
+		apiURL = strings.TrimRight(apiURL, "/") + "/v3/payments"
 	}
 
 	reqBody, _ := json.Marshal(body)
 
-	log.Printf("[YK] request url=%s", apiURL)
-	log.Printf("[YK] request body=%s", string(reqBody))
+	// тело не пишем: в receipt.customer — email и телефон покупателя
+	log.Printf("[YK] request url=%s %s", apiURL, ykLogSummary(body))
 
 	req, _ := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(reqBody))
-	req.SetBasicAuth(shopID, secret)
+	req.SetBasicAuth(creds.Login, creds.Secret)
 	req.Header.Set("Idempotence-Key", fmt.Sprintf("%d", time.Now().UnixNano()))
 	req.Header.Set("Content-Type", "application/json")
 
@@ -213,7 +260,6 @@ func (p *YooKassaProvider) CreateSubscriptionPayment(
 	raw, _ := io.ReadAll(resp.Body)
 
 	log.Printf("[YK] response status=%d", resp.StatusCode)
-	log.Printf("[YK] response body=%s", string(raw))
 
 	if resp.StatusCode >= 300 {
 		return "", "", fmt.Errorf("yookassa error status=%d body=%s", resp.StatusCode, string(raw))
@@ -228,7 +274,202 @@ func (p *YooKassaProvider) CreateSubscriptionPayment(
 
 	_ = json.Unmarshal(raw, &yresp)
 
-	log.Printf("[YK] created subscription url=%s id=%s", yresp.Confirmation.URL, yresp.ID)
+	log.Printf("[YK] created payment url=%s id=%s", yresp.Confirmation.URL, yresp.ID)
 
 	return yresp.Confirmation.URL, yresp.ID, nil
 }
+
+// ----------------------------------------------------
+// Verification (GET /v3/payments/{id}, GET /v3/refunds/{id})
+// ----------------------------------------------------
+
+type ykPaymentMethod struct {
+	ID    string `json:"id"`
+	Saved bool   `json:"saved"`
+}
+
+type ykAmount struct {
+	Value string `json:"value"`
+}
+
+func (a ykAmount) float() float64 {
+	v, _ := strconv.ParseFloat(a.Value, 64)
+	return v
+}
+
+func (p *YooKassaProvider) GetPayment(ctx context.Context, botID, paymentID string) (*ports.ProviderPayment, error) {
+	var yp struct {
+		ID             string            `json:"id"`
+		Status         string            `json:"status"`
+		Paid           bool              `json:"paid"`
+		Amount         ykAmount          `json:"amount"`
+		RefundedAmount ykAmount          `json:"refunded_amount"`
+		Metadata       map[string]string `json:"metadata"`
+		PaymentMethod  ykPaymentMethod   `json:"payment_method"`
+	}
+
+	if err := p.get(ctx, botID, "payments", paymentID, &yp); err != nil {
+		return nil, err
+	}
+
+	return &ports.ProviderPayment{
+		ID:                 yp.ID,
+		Status:             yp.Status,
+		Paid:               yp.Paid,
+		Amount:             yp.Amount.float(),
+		RefundedAmount:     yp.RefundedAmount.float(),
+		Metadata:           yp.Metadata,
+		PaymentMethodID:    yp.PaymentMethod.ID,
+		PaymentMethodSaved: yp.PaymentMethod.Saved,
+	}, nil
+}
+
+func (p *YooKassaProvider) GetRefund(ctx context.Context, botID, refundID string) (*ports.ProviderRefund, error) {
+	var yr struct {
+		ID        string   `json:"id"`
+		PaymentID string   `json:"payment_id"`
+		Status    string   `json:"status"`
+		Amount    ykAmount `json:"amount"`
+	}
+
+	if err := p.get(ctx, botID, "refunds", refundID, &yr); err != nil {
+		return nil, err
+	}
+
+	return &ports.ProviderRefund{
+		ID:        yr.ID,
+		PaymentID: yr.PaymentID,
+		Status:    yr.Status,
+		Amount:    yr.Amount.float(),
+	}, nil
+}
+
+// get — GET /v3/{resource}/{id} с авторизацией магазина бота
+func (p *YooKassaProvider) get(ctx context.Context, botID, resource, id string, out any) error {
+	if id == "" {
+		return fmt.Errorf("yookassa %s: empty id", resource)
+	}
+
+	creds, err := p.credentials(ctx, botID)
+	if err != nil {
+		return err
+	}
+
+	// YOOKASSA_API_URL обычно указывает на .../v3/payments
+	base := strings.TrimRight(os.Getenv("YOOKASSA_API_URL"), "/")
+	base = strings.TrimSuffix(base, "/payments")
+	if !strings.HasSuffix(base, "/v3") {
+		base += "/v3"
+	}
+
+	apiURL := fmt.Sprintf("%s/%s/%s", base, resource, url.PathEscape(id))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(creds.Login, creds.Secret)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("yookassa get %s/%s status=%d body=%s", resource, id, resp.StatusCode, string(raw))
+	}
+
+	return json.Unmarshal(raw, out)
+}
+
+// ----------------------------------------------------
+// Recurring (автопродление по payment_method_id)
+// ----------------------------------------------------
+
+func (p *YooKassaProvider) ChargeSaved(ctx context.Context, c *ports.RecurringCharge) (*ports.ProviderPayment, error) {
+	log.Printf("[YK] recurring charge bot=%s tg=%d sub=%d amount=%.2f",
+		c.BotID, c.TelegramID, c.SubscriptionID, c.Amount)
+
+	co, err := p.checkout.Checkout(ctx, c.BotID, c.TelegramID)
+	if err != nil {
+		return nil, &ports.CheckoutConfigError{Err: err}
+	}
+
+	creds, err := p.credentials(ctx, c.BotID)
+	if err != nil {
+		return nil, &ports.CheckoutConfigError{Err: err}
+	}
+
+	apiURL := os.Getenv("YOOKASSA_API_URL")
+	if !strings.Contains(apiURL, "/v3/payments") {
+		apiURL = strings.TrimRight(apiURL, "/") + "/v3/payments"
+	}
+
+	body := map[string]any{
+		"amount": map[string]any{
+			"value":    fmt.Sprintf("%.2f", c.Amount),
+			"currency": "RUB",
+		},
+		"capture":           true,
+		"payment_method_id": c.MethodID,
+		"description":       fmt.Sprintf("Subscription '%s' (renewal)", c.PlanCode),
+		"metadata": map[string]any{
+			"bot_id":          c.BotID,
+			"telegram_id":     fmt.Sprintf("%d", c.TelegramID),
+			"payment_type":    "renewal",
+			"plan_code":       c.PlanCode,
+			"invoice_id":      c.InvoiceID,
+			"subscription_id": fmt.Sprintf("%d", c.SubscriptionID),
+		},
+		"receipt": ykReceipt(co, c.PlanCode, c.Amount),
+	}
+
+	reqBody, _ := json.Marshal(body)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(creds.Login, creds.Secret)
+	// один invoice — одно списание, даже если запрос уйдёт повторно
+	req.Header.Set("Idempotence-Key", c.InvoiceID)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("yookassa error status=%d body=%s", resp.StatusCode, string(raw))
+	}
+
+	var yp struct {
+		ID       string            `json:"id"`
+		Status   string            `json:"status"`
+		Paid     bool              `json:"paid"`
+		Amount   ykAmount          `json:"amount"`
+		Metadata map[string]string `json:"metadata"`
+	}
+	if err := json.Unmarshal(raw, &yp); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[YK] recurring response status=%d id=%s payment_status=%s paid=%v invoice=%s",
+		resp.StatusCode, yp.ID, yp.Status, yp.Paid, c.InvoiceID)
+
+	return &ports.ProviderPayment{
+		ID:              yp.ID,
+		Status:          yp.Status,
+		Paid:            yp.Paid,
+		Amount:          yp.Amount.float(),
+		Metadata:        yp.Metadata,
+		PaymentMethodID: c.MethodID,
+	}, nil
+}
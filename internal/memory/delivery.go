@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	svc Service
+}
+
+func NewHandler(svc Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// GET /memory/{bot_id}/{telegram_id}
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	botID := chi.URLParam(r, "bot_id")
+	tgID, err := strconv.ParseInt(chi.URLParam(r, "telegram_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid telegram_id", 400)
+		return
+	}
+
+	m, err := h.svc.Get(r.Context(), botID, tgID)
+	if err != nil {
+		http.Error(w, "failed to get memory", 500)
+		return
+	}
+	if m == nil {
+		m = &Memory{BotID: botID, TelegramID: tgID}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(m)
+}
+
+// DELETE /memory/{bot_id}/{telegram_id}
+func (h *Handler) Reset(w http.ResponseWriter, r *http.Request) {
+	botID := chi.URLParam(r, "bot_id")
+	tgID, err := strconv.ParseInt(chi.URLParam(r, "telegram_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid telegram_id", 400)
+		return
+	}
+
+	if err := h.svc.Reset(r.Context(), botID, tgID); err != nil {
+		http.Error(w, "failed to reset memory", 500)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
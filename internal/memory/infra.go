@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+)
+
+type repo struct {
+	db *sql.DB
+}
+
+func NewRepo(db *sql.DB) Repo {
+	return &repo{db: db}
+}
+
+func (r *repo) Get(ctx context.Context, botID string, telegramID int64) (*Memory, error) {
+	m := &Memory{BotID: botID, TelegramID: telegramID}
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT summary, covered_until_id, updated_at
+		FROM student_memory
+		WHERE bot_id = $1 AND telegram_id = $2
+	`, botID, telegramID).Scan(&m.Summary, &m.CoveredID, &m.UpdatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (r *repo) Upsert(ctx context.Context, m *Memory) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO student_memory (bot_id, telegram_id, summary, covered_until_id, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (bot_id, telegram_id)
+		DO UPDATE SET summary = $3, covered_until_id = $4, updated_at = NOW()
+	`, m.BotID, m.TelegramID, m.Summary, m.CoveredID)
+	return err
+}
+
+func (r *repo) RecordsBetween(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	afterID, beforeID int64,
+	limit int,
+) ([]ports.Record, error) {
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, telegram_id, bot_id, user_ref, role, record_type, text_content, image_url, model, created_at
+		FROM records
+		WHERE bot_id = $1 AND telegram_id = $2
+		  AND id > $3 AND id < $4
+		ORDER BY id ASC
+		LIMIT $5
+	`, botID, telegramID, afterID, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []ports.Record
+	for rows.Next() {
+		var rec ports.Record
+		if err := rows.Scan(
+			&rec.ID,
+			&rec.TelegramID,
+			&rec.BotID,
+			&rec.UserRef,
+			&rec.Role,
+			&rec.Type,
+			&rec.Text,
+			&rec.ImageURL,
+			&rec.Model,
+			&rec.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
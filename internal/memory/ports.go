@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+)
+
+// Memory — "память об ученике": сжатое содержание истории,
+// которая уже не влезает в окно модели
+type Memory struct {
+	BotID      string    `json:"bot_id"`
+	TelegramID int64     `json:"telegram_id"`
+	Summary    string    `json:"summary"`
+	CoveredID  int64     `json:"covered_until_id"` // records.id, до которого (включительно) история уже в summary
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type Repo interface {
+	// Get — nil, nil если памяти ещё нет
+	Get(ctx context.Context, botID string, telegramID int64) (*Memory, error)
+	Upsert(ctx context.Context, m *Memory) error
+
+	// RecordsBetween — записи с afterID < id < beforeID по возрастанию id
+	RecordsBetween(ctx context.Context, botID string, telegramID int64, afterID, beforeID int64, limit int) ([]ports.Record, error)
+}
+
+// Summarizer — модель, которая сжимает историю (реализует ai.AiService)
+type Summarizer interface {
+	Summarize(ctx context.Context, botID string, telegramID int64, previous, transcript string) (string, error)
+}
+
+type Service interface {
+	Get(ctx context.Context, botID string, telegramID int64) (*Memory, error)
+
+	// Compact — дописывает в память записи, выпавшие из окна (id < beforeID).
+	// Запускается в фоне, на одного ученика — не больше одного сжатия одновременно.
+	Compact(botID string, telegramID int64, beforeID int64)
+
+	// Reset — забыть накопленное. Выпавшие ранее записи повторно не сжимаются.
+	Reset(ctx context.Context, botID string, telegramID int64) error
+}
@@ -0,0 +1,185 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+	"github.com/Vovarama1992/make_ziper/internal/tokens"
+)
+
+const (
+	// меньше стольких выпавших записей — не тратим вызов модели, ждём накопления
+	minBatchRecords = 10
+	// за один вызов модели
+	maxBatchRecords = 200
+	maxBatchTokens  = 30_000
+	// за один Compact; остальное догонится на следующих сообщениях
+	maxBatchesPerRun = 5
+
+	compactTimeout = 5 * time.Minute
+)
+
+type service struct {
+	repo       Repo
+	summarizer Summarizer
+
+	// bot_id:telegram_id → идёт сжатие
+	inflight sync.Map
+	// bot_id:telegram_id → *userLock
+	locks sync.Map
+}
+
+// userLock — запись памяти пользователя: Reset и шаг сжатия не пересекаются.
+// Вызов модели идёт без блокировки, поэтому сжатие сверяет resets перед записью.
+type userLock struct {
+	mu     sync.Mutex
+	resets int64
+}
+
+func (s *service) lock(botID string, telegramID int64) *userLock {
+	l, _ := s.locks.LoadOrStore(fmt.Sprintf("%s:%d", botID, telegramID), &userLock{})
+	return l.(*userLock)
+}
+
+func NewService(repo Repo, summarizer Summarizer) Service {
+	return &service{
+		repo:       repo,
+		summarizer: summarizer,
+	}
+}
+
+func (s *service) Get(ctx context.Context, botID string, telegramID int64) (*Memory, error) {
+	return s.repo.Get(ctx, botID, telegramID)
+}
+
+func (s *service) Reset(ctx context.Context, botID string, telegramID int64) error {
+	l := s.lock(botID, telegramID)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// идущее сжатие не запишет сводку, собранную из сброшенной
+	l.resets++
+
+	m, err := s.repo.Get(ctx, botID, telegramID)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return nil
+	}
+
+	m.Summary = ""
+	return s.repo.Upsert(ctx, m)
+}
+
+func (s *service) Compact(botID string, telegramID int64, beforeID int64) {
+	key := fmt.Sprintf("%s:%d", botID, telegramID)
+	if _, busy := s.inflight.LoadOrStore(key, struct{}{}); busy {
+		return
+	}
+
+	go func() {
+		defer s.inflight.Delete(key)
+
+		ctx, cancel := context.WithTimeout(context.Background(), compactTimeout)
+		defer cancel()
+
+		if err := s.compact(ctx, botID, telegramID, beforeID); err != nil {
+			log.Printf("[memory] compact bot=%s tg=%d: %v", botID, telegramID, err)
+		}
+	}()
+}
+
+func (s *service) compact(ctx context.Context, botID string, telegramID int64, beforeID int64) error {
+	l := s.lock(botID, telegramID)
+
+	l.mu.Lock()
+	resets := l.resets
+	m, err := s.repo.Get(ctx, botID, telegramID)
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		m = &Memory{BotID: botID, TelegramID: telegramID}
+	}
+
+	for i := 0; i < maxBatchesPerRun; i++ {
+		recs, err := s.repo.RecordsBetween(ctx, botID, telegramID, m.CoveredID, beforeID, maxBatchRecords)
+		if err != nil {
+			return err
+		}
+		if len(recs) < minBatchRecords {
+			return nil
+		}
+
+		transcript, lastID := buildTranscript(recs)
+
+		summary, err := s.summarizer.Summarize(ctx, botID, telegramID, m.Summary, transcript)
+		if err != nil {
+			return err
+		}
+
+		m.Summary = strings.TrimSpace(summary)
+		m.CoveredID = lastID
+
+		l.mu.Lock()
+		if l.resets != resets {
+			l.mu.Unlock()
+			log.Printf("[memory] bot=%s tg=%d reset during compact, summary dropped", botID, telegramID)
+			return nil
+		}
+		err = s.repo.Upsert(ctx, m)
+		l.mu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		log.Printf("[memory] bot=%s tg=%d covered until id=%d", botID, telegramID, lastID)
+	}
+
+	return nil
+}
+
+// buildTranscript — записи по порядку, пока влезают в maxBatchTokens.
+// Возвращает id последней вошедшей записи.
+func buildTranscript(recs []ports.Record) (string, int64) {
+	var b strings.Builder
+	total := 0
+	lastID := recs[0].ID
+
+	for _, r := range recs {
+		who := "Ученик"
+		if r.Role == "tutor" {
+			who = "Тьютор"
+		}
+
+		var line string
+		switch {
+		case r.Text != nil && strings.TrimSpace(*r.Text) != "":
+			line = who + ": " + strings.TrimSpace(*r.Text)
+		case r.ImageURL != nil:
+			line = who + ": [изображение]"
+		default:
+			lastID = r.ID
+			continue
+		}
+
+		t := tokens.Count("", line)
+		if total > 0 && total+t > maxBatchTokens {
+			break
+		}
+		total += t
+
+		b.WriteString(line)
+		b.WriteString("\n\n")
+		lastID = r.ID
+	}
+
+	return b.String(), lastID
+}
@@ -4,22 +4,27 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/Vovarama1992/make_ziper/internal/ports"
+	"github.com/Vovarama1992/make_ziper/internal/promo"
 )
 
 type service struct {
 	repo            MinutePackageRepo
 	paymentProvider ports.PaymentProvider
+	promo           promo.Service
 }
 
 func NewService(
 	repo MinutePackageRepo,
 	paymentProvider ports.PaymentProvider,
+	promoSvc promo.Service,
 ) MinutePackageService {
 	return &service{
 		repo:            repo,
 		paymentProvider: paymentProvider,
+		promo:           promoSvc,
 	}
 }
 
@@ -73,17 +78,35 @@ func (s *service) CreatePayment(
 	log.Printf("[PAY] package loaded id=%d price=%.2f minutes=%d",
 		pkg.ID, pkg.Price, pkg.Minutes)
 
+	invoiceID := fmt.Sprintf("pkg_%d_%d", telegramID, time.Now().Unix())
+
+	// промокод: скидка — в сумму платежа и чека, бонусные минуты — при оплате
+	price := pkg.Price
+	disc, err := s.promo.Reserve(ctx, botID, telegramID, promo.TargetMinutePackage, pkg.Price, invoiceID)
+	if err != nil {
+		return "", err
+	}
+	if disc != nil {
+		price = disc.Price
+	}
+
 	payURL, payID, err := s.paymentProvider.CreateMinutePackagePayment(
 		ctx,
 		botID,
 		telegramID,
 		packageID,
-		pkg.Price,
+		price,
 		pkg.Name,
 		pkg.Minutes,
+		invoiceID,
 	)
 	if err != nil {
 		log.Printf("[PAY] provider error: %v", err)
+		if disc != nil {
+			if rerr := s.promo.Release(ctx, invoiceID); rerr != nil {
+				log.Printf("[PAY] release promo invoice=%s: %v", invoiceID, rerr)
+			}
+		}
 		return "", err
 	}
 
@@ -0,0 +1,214 @@
+package notificator
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Vovarama1992/make_ziper/internal/audit"
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	repo Repo
+}
+
+func NewHandler(repo Repo) *Handler {
+	return &Handler{repo: repo}
+}
+
+// GET /alert-routes
+func (h *Handler) ListRoutes(w http.ResponseWriter, r *http.Request) {
+	routes, err := h.repo.ListRoutes(r.Context())
+	if err != nil {
+		http.Error(w, "failed to list alert routes", 500)
+		return
+	}
+	if routes == nil {
+		routes = []*Route{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(routes)
+}
+
+// POST /alert-routes {bot_id, chat_id, min_severity, digest}
+func (h *Handler) CreateRoute(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		BotID       string `json:"bot_id"`
+		ChatID      int64  `json:"chat_id"`
+		MinSeverity string `json:"min_severity"`
+		Digest      *bool  `json:"digest"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "invalid json", 400)
+		return
+	}
+
+	rt := &Route{
+		BotID:       in.BotID,
+		ChatID:      in.ChatID,
+		MinSeverity: in.MinSeverity,
+		Digest:      in.Digest == nil || *in.Digest,
+		Active:      true,
+	}
+	if err := rt.Validate(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	if err := h.repo.CreateRoute(r.Context(), rt); err != nil {
+		http.Error(w, "failed to create alert route", 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(rt)
+}
+
+// PATCH /alert-routes/{id} — меняются только переданные поля
+func (h *Handler) UpdateRoute(w http.ResponseWriter, r *http.Request) {
+	rt, ok := h.routeFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var in struct {
+		BotID       *string `json:"bot_id"`
+		ChatID      *int64  `json:"chat_id"`
+		MinSeverity *string `json:"min_severity"`
+		Digest      *bool   `json:"digest"`
+		Active      *bool   `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "invalid json", 400)
+		return
+	}
+
+	if in.BotID != nil {
+		rt.BotID = *in.BotID
+	}
+	if in.ChatID != nil {
+		rt.ChatID = *in.ChatID
+	}
+	if in.MinSeverity != nil {
+		rt.MinSeverity = *in.MinSeverity
+	}
+	if in.Digest != nil {
+		rt.Digest = *in.Digest
+	}
+	if in.Active != nil {
+		rt.Active = *in.Active
+	}
+	if err := rt.Validate(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	err := h.repo.UpdateRoute(r.Context(), rt)
+	if errors.Is(err, ErrRouteNotFound) {
+		http.Error(w, "not found", 404)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to update alert route", 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rt)
+}
+
+// DELETE /alert-routes/{id}
+func (h *Handler) DeleteRoute(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", 400)
+		return
+	}
+
+	err = h.repo.DeleteRoute(r.Context(), id)
+	if errors.Is(err, ErrRouteNotFound) {
+		http.Error(w, "not found", 404)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to delete alert route", 500)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /alerts?bot_id=&limit= — последние ошибки со счётчиками повторов
+func (h *Handler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", 400)
+			return
+		}
+		limit = min(n, 1000)
+	}
+
+	events, err := h.repo.ListEvents(r.Context(), r.URL.Query().Get("bot_id"), limit)
+	if err != nil {
+		http.Error(w, "failed to list alerts", 500)
+		return
+	}
+	if events == nil {
+		events = []*Event{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(events)
+}
+
+// AuditSnapshot — маршрут из пути /alert-routes/{id} для журнала
+func (h *Handler) AuditSnapshot(r *http.Request, _ string) (*audit.Snapshot, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := h.findRoute(r, id)
+	if err != nil || rt == nil {
+		return nil, err
+	}
+	return &audit.Snapshot{EntityID: chi.URLParam(r, "id"), BotID: rt.BotID, Value: rt}, nil
+}
+
+func (h *Handler) routeFromPath(w http.ResponseWriter, r *http.Request) (*Route, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", 400)
+		return nil, false
+	}
+
+	rt, err := h.findRoute(r, id)
+	if err != nil {
+		http.Error(w, "failed to get alert route", 500)
+		return nil, false
+	}
+	if rt == nil {
+		http.Error(w, "not found", 404)
+		return nil, false
+	}
+	return rt, true
+}
+
+// маршрутов единицы — отдельный запрос по id не заводим
+func (h *Handler) findRoute(r *http.Request, id int64) (*Route, error) {
+	routes, err := h.repo.ListRoutes(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	for _, rt := range routes {
+		if rt.ID == id {
+			return rt, nil
+		}
+	}
+	return nil, nil
+}
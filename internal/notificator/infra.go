@@ -2,64 +2,340 @@ package notificator
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 const (
-	adminChatID1 int64 = 1139929360
-	adminChatID2 int64 = 6789440333
+	// повтор одной и той же ошибки — не чаще раза в окно, остальное уходит в сводку
+	defaultDedupWindow = 10 * time.Minute
+
+	// лимит Telegram на сообщение с запасом
+	maxAlertText = 3500
 )
 
+// числа в тексте ошибки (id, суммы, время) не делают её новой
+var digitsRe = regexp.MustCompile(`\d+`)
+
 type Infra struct {
+	mu   sync.RWMutex
 	bots map[string]*tgbotapi.BotAPI
+
+	repo Repo
+
+	// бот для алертов (ALERTS_BOT_TOKEN, иначе ADMIN_BOT_TOKEN); nil — шлём через бота, в котором ошибка
+	alerts *tgbotapi.BotAPI
+	window time.Duration
+
+	// последние маршруты: если база недоступна (частая причина алертов), шлём по ним
+	routesMu sync.RWMutex
+	routes   []*Route
 }
 
-func NewInfra(bots map[string]*tgbotapi.BotAPI) *Infra {
-	return &Infra{bots: bots}
+func NewInfra(bots map[string]*tgbotapi.BotAPI, repo Repo) *Infra {
+	i := &Infra{
+		bots:   bots,
+		repo:   repo,
+		window: defaultDedupWindow,
+	}
+
+	if v := os.Getenv("ALERT_DEDUP_WINDOW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			i.window = d
+		} else {
+			log.Printf("[error_notificator] bad ALERT_DEDUP_WINDOW=%q, using %s", v, i.window)
+		}
+	}
+
+	// без отдельного бота — админ-бот: админы в нём уже есть, а системные ошибки (admin, global) не привязаны к тенанту
+	token := os.Getenv("ALERTS_BOT_TOKEN")
+	if token == "" {
+		token = os.Getenv("ADMIN_BOT_TOKEN")
+	}
+	if token != "" {
+		bot, err := tgbotapi.NewBotAPI(token)
+		if err != nil {
+			log.Printf("[error_notificator] alerts bot init failed, falling back to tenant bots: %v", err)
+		} else {
+			i.alerts = bot
+			log.Printf("[error_notificator] alerts bot: @%s", bot.Self.UserName)
+		}
+	}
+
+	return i
 }
 
-// SetBots — позволяет передать карту ботов ПОСЛЕ того, как они инициализировались
+// SetBots — позволяет передать карту ботов ПОСЛЕ того, как они инициализировались.
+// Вызывается повторно при каждом изменении реестра ботов.
 func (i *Infra) SetBots(bots map[string]*tgbotapi.BotAPI) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
 	i.bots = bots
 }
 
-func (i *Infra) Notify(ctx context.Context, botID string, err error, details string) error {
+func (i *Infra) getBot(botID string) (*tgbotapi.BotAPI, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
 	bot, ok := i.bots[botID]
-	if !ok || bot == nil {
-		log.Printf("[error_notificator] botID=%s not found", botID)
-		return fmt.Errorf("bot not found: %s", botID)
+	return bot, ok
+}
+
+// ==================================================
+// ALERTS
+// ==================================================
+
+func (i *Infra) Notify(ctx context.Context, botID string, err error, details string) error {
+	severity := SeverityError
+	// отменённый запрос или таймаут клиента — не авария
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		severity = SeverityWarning
+	}
+	return i.NotifyLevel(ctx, severity, botID, err, details)
+}
+
+func (i *Infra) NotifyLevel(ctx context.Context, severity string, botID string, err error, details string) error {
+	if !ValidSeverity(severity) {
+		severity = SeverityError
+	}
+
+	msg := "<nil>"
+	if err != nil {
+		msg = err.Error()
+	}
+
+	log.Printf("[error_notificator] %s bot=%s err=%s details=%s", severity, botID, msg, details)
+
+	// контекст запроса мог уже закончиться — алерт всё равно доставляем
+	ctx = context.WithoutCancel(ctx)
+
+	e := &Event{
+		Fingerprint: fingerprint(botID, severity, msg, details),
+		BotID:       botID,
+		Severity:    severity,
+		Message:     msg,
+		Details:     details,
+	}
+
+	send, repeats := true, int64(0)
+	if i.repo != nil {
+		var hitErr error
+		send, repeats, hitErr = i.repo.Hit(ctx, e, i.window)
+		if hitErr != nil {
+			// без базы не знаем о повторах — лучше лишний алерт, чем ни одного
+			log.Printf("[error_notificator] dedup: %v", hitErr)
+			send = true
+		}
+	}
+	if !send {
+		return nil
 	}
 
 	text := fmt.Sprintf(
-		"❗ Ошибка в боте (%s)\n\nОшибка: %v\n\nДетали: %s",
-		botID,
-		err,
-		details,
+		"%s Ошибка в боте (%s)\n\nОшибка: %s\n\nДетали: %s",
+		severityIcon(severity), botID, msg, details,
 	)
+	suffix := ""
+	if repeats > 0 {
+		suffix = fmt.Sprintf("\n\n🔁 Ещё %d раз за %s (подавлено)", repeats, i.window)
+	}
+	// длинный стек или ответ API не должен ронять отправку: Telegram режет по 4096
+	text = truncateRunes(text, maxAlertText-len([]rune(suffix))) + suffix
+
+	chats := i.recipients(ctx, botID, severity, false)
+	if len(chats) == 0 {
+		log.Printf("[error_notificator] no alert routes for bot=%s severity=%s", botID, severity)
+		return nil
+	}
+
+	return i.send(botID, chats, text)
+}
+
+// SendDigest — сводка подавленных повторов за период
+func (i *Infra) SendDigest(ctx context.Context) error {
+	if i.repo == nil {
+		return nil
+	}
+
+	events, err := i.repo.PendingDigest(ctx)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	// каждому получателю — только его боты и уровни
+	byChat := make(map[int64][]*Event)
+	for _, e := range events {
+		for _, chatID := range i.recipients(ctx, e.BotID, e.Severity, true) {
+			byChat[chatID] = append(byChat[chatID], e)
+		}
+	}
+
+	// событие гасим, только если сводка с ним дошла до всех его получателей:
+	// упавшая отправка или обрезанный хвост попадут в следующую сводку
+	undelivered := make(map[string]bool)
+
+	var firstErr error
+	for chatID, list := range byChat {
+		sort.Slice(list, func(a, b int) bool { return list[a].Pending > list[b].Pending })
+
+		var sb strings.Builder
+		sb.WriteString("📊 Сводка повторяющихся ошибок\n")
+		included := len(list)
+		for n, e := range list {
+			line := fmt.Sprintf("\n%s [%s] ×%d (всего %d): %s", severityIcon(e.Severity), e.BotID, e.Pending, e.Count, truncateRunes(e.Message, 300))
+			if sb.Len()+len(line) > maxAlertText {
+				sb.WriteString(fmt.Sprintf("\n…и ещё %d", len(list)-n))
+				included = n
+				break
+			}
+			sb.WriteString(line)
+		}
+		for _, e := range list[included:] {
+			undelivered[e.Fingerprint] = true
+		}
+
+		if err := i.send("", []int64{chatID}, sb.String()); err != nil {
+			for _, e := range list[:included] {
+				undelivered[e.Fingerprint] = true
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	// без получателей сводки событие тоже гасим — иначе счётчик копится вечно
+	acked := 0
+	for _, e := range events {
+		if undelivered[e.Fingerprint] {
+			continue
+		}
+		// вычитаем показанное: повторы, пришедшие во время отправки, остаются до следующей сводки
+		if err := i.repo.AckDigest(ctx, e.Fingerprint, e.Pending); err != nil {
+			log.Printf("[error_notificator] digest ack %s: %v", e.Fingerprint, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		acked++
+	}
+
+	log.Printf("[error_notificator] digest: %d events → %d chats, acked %d", len(events), len(byChat), acked)
+	return firstErr
+}
 
-	admins := []int64{adminChatID1, adminChatID2}
+// recipients — чаты маршрутов, подходящих под бот и важность
+func (i *Infra) recipients(ctx context.Context, botID, severity string, digest bool) []int64 {
+	seen := make(map[int64]bool)
+	var out []int64
+	for _, r := range i.loadRoutes(ctx) {
+		if !r.matches(botID, severity) || (digest && !r.Digest) || seen[r.ChatID] {
+			continue
+		}
+		seen[r.ChatID] = true
+		out = append(out, r.ChatID)
+	}
+	return out
+}
 
-	for _, chatID := range admins {
-		_, sendErr := bot.Send(tgbotapi.NewMessage(chatID, text))
-		if sendErr != nil {
-			log.Printf("[error_notificator] send fail to %d: %v", chatID, sendErr)
-			return sendErr
+func (i *Infra) loadRoutes(ctx context.Context) []*Route {
+	if i.repo != nil {
+		routes, err := i.repo.ListRoutes(ctx)
+		if err == nil {
+			i.routesMu.Lock()
+			i.routes = routes
+			i.routesMu.Unlock()
+			return routes
 		}
+		log.Printf("[error_notificator] load routes, using cached: %v", err)
 	}
 
-	return nil
+	i.routesMu.RLock()
+	defer i.routesMu.RUnlock()
+	return i.routes
 }
 
+// send — через бота алертов; без него — через бота, в котором случилась ошибка
+func (i *Infra) send(botID string, chats []int64, text string) error {
+	bot := i.alerts
+	if bot == nil {
+		b, ok := i.getBot(botID)
+		if !ok || b == nil {
+			log.Printf("[error_notificator] no alerts bot and botID=%s not found, alert only logged", botID)
+			return fmt.Errorf("no bot to send alert: %s", botID)
+		}
+		bot = b
+	}
+
+	var firstErr error
+	for _, chatID := range chats {
+		if _, err := bot.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+			log.Printf("[error_notificator] send fail to %d: %v", chatID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func fingerprint(botID, severity, msg, details string) string {
+	h := sha1.New()
+	for _, part := range []string{botID, severity, digitsRe.ReplaceAllString(msg, "#"), digitsRe.ReplaceAllString(details, "#")} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func severityIcon(severity string) string {
+	switch severity {
+	case SeverityCritical:
+		return "🔥"
+	case SeverityError:
+		return "❗"
+	case SeverityWarning:
+		return "⚠️"
+	default:
+		return "ℹ️"
+	}
+}
+
+// ==================================================
+// USERS
+// ==================================================
+
 func (i *Infra) UserNotify(
 	ctx context.Context,
 	botID string,
 	chatID int64,
 	text string,
 ) error {
-	bot, ok := i.bots[botID]
+	bot, ok := i.getBot(botID)
 	if !ok || bot == nil {
 		return fmt.Errorf("bot not found: %s", botID)
 	}
@@ -1,8 +1,105 @@
 package notificator
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
 
 type Notificator interface {
+	// Notify — ошибка уровня error
 	Notify(ctx context.Context, botID string, err error, details string) error
+	// NotifyLevel — алерт с явной важностью (Severity*)
+	NotifyLevel(ctx context.Context, severity string, botID string, err error, details string) error
 	UserNotify(ctx context.Context, botID string, chatID int64, text string) error
 }
+
+// важность алерта, по возрастанию
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityError    = "error"
+	SeverityCritical = "critical"
+)
+
+var severityRank = map[string]int{
+	SeverityInfo:     1,
+	SeverityWarning:  2,
+	SeverityError:    3,
+	SeverityCritical: 4,
+}
+
+// ValidSeverity — известный ли уровень
+func ValidSeverity(s string) bool {
+	_, ok := severityRank[s]
+	return ok
+}
+
+// atLeast — s не ниже min
+func atLeast(s, min string) bool {
+	return severityRank[s] >= severityRank[min]
+}
+
+var (
+	ErrInvalidRoute  = errors.New("invalid alert route")
+	ErrRouteNotFound = errors.New("alert route not found")
+)
+
+// Route — кому слать алерты: личка или группа в боте алертов
+type Route struct {
+	ID          int64     `json:"id"`
+	BotID       string    `json:"bot_id"` // "" — все боты, включая системные (admin, global, unknown)
+	ChatID      int64     `json:"chat_id"`
+	MinSeverity string    `json:"min_severity"`
+	Digest      bool      `json:"digest"` // получать сводку подавленных повторов
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (r *Route) Validate() error {
+	if r.ChatID == 0 {
+		return errors.Join(ErrInvalidRoute, errors.New("chat_id required"))
+	}
+	if r.MinSeverity == "" {
+		r.MinSeverity = SeverityError
+	}
+	if !ValidSeverity(r.MinSeverity) {
+		return errors.Join(ErrInvalidRoute, errors.New("min_severity must be info, warning, error or critical"))
+	}
+	return nil
+}
+
+// matches — получает ли маршрут алерт бота с такой важностью
+func (r *Route) matches(botID, severity string) bool {
+	return r.Active && (r.BotID == "" || r.BotID == botID) && atLeast(severity, r.MinSeverity)
+}
+
+// Event — одинаковые ошибки, склеенные по отпечатку
+type Event struct {
+	Fingerprint string     `json:"fingerprint"`
+	BotID       string     `json:"bot_id"`
+	Severity    string     `json:"severity"`
+	Message     string     `json:"message"`
+	Details     string     `json:"details"`
+	Count       int64      `json:"count"`   // всего
+	Pending     int64      `json:"pending"` // подавлено с последней отправки
+	FirstSeen   time.Time  `json:"first_seen"`
+	LastSeen    time.Time  `json:"last_seen"`
+	LastSentAt  *time.Time `json:"last_sent_at"`
+}
+
+type Repo interface {
+	ListRoutes(ctx context.Context) ([]*Route, error)
+	CreateRoute(ctx context.Context, r *Route) error
+	UpdateRoute(ctx context.Context, r *Route) error
+	DeleteRoute(ctx context.Context, id int64) error
+
+	// Hit — учесть повтор; send — отпечаток не отправляли дольше window (отправка занята за вызывающим),
+	// repeats — сколько повторов подавлено с прошлой отправки
+	Hit(ctx context.Context, e *Event, window time.Duration) (send bool, repeats int64, err error)
+	// PendingDigest — события с подавленными повторами
+	PendingDigest(ctx context.Context) ([]*Event, error)
+	// AckDigest — повторы попали в сводку: вычесть delivered из pending
+	AckDigest(ctx context.Context, fingerprint string, delivered int64) error
+	ListEvents(ctx context.Context, botID string, limit int) ([]*Event, error)
+}
@@ -0,0 +1,174 @@
+package notificator
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type repo struct {
+	db *sql.DB
+}
+
+func NewRepo(db *sql.DB) Repo {
+	return &repo{db: db}
+}
+
+// ==================================================
+// ROUTES
+// ==================================================
+
+func (r *repo) ListRoutes(ctx context.Context) ([]*Route, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, bot_id, chat_id, min_severity, digest, active, created_at
+		FROM alert_routes
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*Route
+	for rows.Next() {
+		var rt Route
+		if err := rows.Scan(&rt.ID, &rt.BotID, &rt.ChatID, &rt.MinSeverity, &rt.Digest, &rt.Active, &rt.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, &rt)
+	}
+	return out, rows.Err()
+}
+
+func (r *repo) CreateRoute(ctx context.Context, rt *Route) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO alert_routes (bot_id, chat_id, min_severity, digest, active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, rt.BotID, rt.ChatID, rt.MinSeverity, rt.Digest, rt.Active).Scan(&rt.ID, &rt.CreatedAt)
+}
+
+func (r *repo) UpdateRoute(ctx context.Context, rt *Route) error {
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE alert_routes
+		SET bot_id = $2, chat_id = $3, min_severity = $4, digest = $5, active = $6
+		WHERE id = $1
+		RETURNING created_at
+	`, rt.ID, rt.BotID, rt.ChatID, rt.MinSeverity, rt.Digest, rt.Active).Scan(&rt.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrRouteNotFound
+	}
+	return err
+}
+
+func (r *repo) DeleteRoute(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM alert_routes WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRouteNotFound
+	}
+	return nil
+}
+
+// ==================================================
+// EVENTS
+// ==================================================
+
+func (r *repo) Hit(ctx context.Context, e *Event, window time.Duration) (bool, int64, error) {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO alert_events (fingerprint, bot_id, severity, message, details)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (fingerprint) DO UPDATE SET
+			count     = alert_events.count + 1,
+			pending   = alert_events.pending + 1,
+			details   = EXCLUDED.details,
+			last_seen = NOW()
+		RETURNING count, first_seen, last_seen
+	`, e.Fingerprint, e.BotID, e.Severity, e.Message, e.Details).Scan(&e.Count, &e.FirstSeen, &e.LastSeen)
+	if err != nil {
+		return false, 0, err
+	}
+
+	// отправку забирает один вызов: условие на last_sent_at проверяется под блокировкой строки
+	var pending int64
+	err = r.db.QueryRowContext(ctx, `
+		WITH old AS (
+			SELECT fingerprint, pending
+			FROM alert_events
+			WHERE fingerprint = $1
+			FOR UPDATE
+		)
+		UPDATE alert_events a
+		SET last_sent_at = NOW(),
+		    pending      = 0
+		FROM old
+		WHERE a.fingerprint = old.fingerprint
+		  AND (a.last_sent_at IS NULL OR a.last_sent_at < NOW() - make_interval(secs => $2))
+		RETURNING old.pending
+	`, e.Fingerprint, window.Seconds()).Scan(&pending)
+	if err == sql.ErrNoRows {
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, err
+	}
+
+	// текущий алерт в pending тоже посчитан
+	return true, pending - 1, nil
+}
+
+func (r *repo) PendingDigest(ctx context.Context) ([]*Event, error) {
+	return r.listEvents(ctx, `
+		SELECT fingerprint, bot_id, severity, message, details,
+		       count, pending, first_seen, last_seen, last_sent_at
+		FROM alert_events
+		WHERE pending > 0
+	`)
+}
+
+func (r *repo) AckDigest(ctx context.Context, fingerprint string, delivered int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE alert_events
+		SET pending = GREATEST(pending - $2, 0)
+		WHERE fingerprint = $1
+	`, fingerprint, delivered)
+	return err
+}
+
+func (r *repo) ListEvents(ctx context.Context, botID string, limit int) ([]*Event, error) {
+	return r.listEvents(ctx, `
+		SELECT fingerprint, bot_id, severity, message, details,
+		       count, pending, first_seen, last_seen, last_sent_at
+		FROM alert_events
+		WHERE ($1 = '' OR bot_id = $1)
+		ORDER BY last_seen DESC
+		LIMIT $2
+	`, botID, limit)
+}
+
+func (r *repo) listEvents(ctx context.Context, query string, args ...any) ([]*Event, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(
+			&e.Fingerprint, &e.BotID, &e.Severity, &e.Message, &e.Details,
+			&e.Count, &e.Pending, &e.FirstSeen, &e.LastSeen, &e.LastSentAt,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, &e)
+	}
+	return out, rows.Err()
+}
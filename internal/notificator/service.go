@@ -14,6 +14,10 @@ func (s *Service) Notify(ctx context.Context, botID string, err error, details s
 	return s.infra.Notify(ctx, botID, err, details)
 }
 
+func (s *Service) NotifyLevel(ctx context.Context, severity string, botID string, err error, details string) error {
+	return s.infra.NotifyLevel(ctx, severity, botID, err, details)
+}
+
 func (s *Service) UserNotify(
 	ctx context.Context,
 	botID string,
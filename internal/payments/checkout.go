@@ -0,0 +1,79 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Vovarama1992/make_ziper/internal/bots"
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+	"github.com/Vovarama1992/make_ziper/internal/user"
+)
+
+// fallbackReturnURL — куда вернуть после оплаты, если не задан ни бот, ни PAYMENT_RETURN_URL
+const fallbackReturnURL = "https://aifulls.com/success.html"
+
+// Checkout — ports.CheckoutResolver: настройки чека из bot_configs, контакт из user_contacts
+type Checkout struct {
+	bots  bots.Repo
+	users user.Infra
+
+	defaultReturnURL string
+}
+
+var _ ports.CheckoutResolver = (*Checkout)(nil)
+
+func NewCheckout(botsRepo bots.Repo, users user.Infra) *Checkout {
+	return &Checkout{
+		bots:             botsRepo,
+		users:            users,
+		defaultReturnURL: strings.TrimSpace(os.Getenv("PAYMENT_RETURN_URL")),
+	}
+}
+
+func (c *Checkout) Checkout(ctx context.Context, botID string, telegramID int64) (*ports.Checkout, error) {
+	cfg, err := c.bots.Get(ctx, botID)
+	if err != nil {
+		return nil, fmt.Errorf("load bot config %s: %w", botID, err)
+	}
+
+	co := &ports.Checkout{
+		ReturnURL:      c.defaultReturnURL,
+		VatCode:        cfg.ReceiptVatCode,
+		PaymentSubject: cfg.ReceiptPaymentSubject,
+	}
+	if cfg.PaymentReturnURL != nil && strings.TrimSpace(*cfg.PaymentReturnURL) != "" {
+		co.ReturnURL = strings.TrimSpace(*cfg.PaymentReturnURL)
+	}
+	if co.ReturnURL == "" {
+		co.ReturnURL = fallbackReturnURL
+	}
+	if cfg.ReceiptTaxSystem != nil {
+		co.TaxSystem = *cfg.ReceiptTaxSystem
+	}
+	if co.VatCode == 0 {
+		co.VatCode = 1
+	}
+	if co.PaymentSubject == "" {
+		co.PaymentSubject = "service"
+	}
+
+	contact, err := c.users.GetContact(ctx, botID, telegramID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case contact != nil && (contact.Email != "" || contact.Phone != ""):
+		co.Email = contact.Email
+		co.Phone = contact.Phone
+	case cfg.ReceiptEmail != nil && strings.TrimSpace(*cfg.ReceiptEmail) != "":
+		// автопродления пользователей, оплативших до сбора контактов
+		co.Email = strings.TrimSpace(*cfg.ReceiptEmail)
+	default:
+		return nil, ports.ErrNoReceiptContact
+	}
+
+	return co, nil
+}
@@ -0,0 +1,59 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Vovarama1992/make_ziper/internal/bots"
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+)
+
+// Merchants — ports.CredentialsResolver: доступы из bot_configs, пустые — из env
+type Merchants struct {
+	bots bots.Repo
+}
+
+var _ ports.CredentialsResolver = (*Merchants)(nil)
+
+func NewMerchants(botsRepo bots.Repo) *Merchants {
+	return &Merchants{bots: botsRepo}
+}
+
+func (m *Merchants) Credentials(ctx context.Context, botID, provider string) (*ports.MerchantCredentials, error) {
+	// бот неизвестен (старый платёж без metadata) — магазин по умолчанию
+	cfg := &bots.BotConfig{}
+	if botID != "" {
+		var err error
+		if cfg, err = m.bots.Get(ctx, botID); err != nil {
+			return nil, fmt.Errorf("load bot config %s: %w", botID, err)
+		}
+	}
+
+	var c ports.MerchantCredentials
+
+	switch provider {
+	case ProviderYooKassa:
+		c.Login = firstNonEmpty(cfg.YooKassaShopID, "YOOKASSA_SHOP_ID")
+		c.Secret = firstNonEmpty(cfg.YooKassaSecretKey, "YOOKASSA_SECRET_KEY")
+	case ProviderCloudPayments:
+		c.Login = firstNonEmpty(cfg.CloudPaymentsPublicID, "CLOUDPAYMENTS_PUBLIC_ID")
+		c.Secret = firstNonEmpty(cfg.CloudPaymentsAPISecret, "CLOUDPAYMENTS_API_SECRET")
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", provider)
+	}
+
+	if c.Login == "" || c.Secret == "" {
+		return nil, fmt.Errorf("%s credentials are not configured for bot %s", provider, botID)
+	}
+	return &c, nil
+}
+
+// firstNonEmpty — значение из bot_configs, иначе переменная окружения
+func firstNonEmpty(v *string, env string) string {
+	if v != nil && strings.TrimSpace(*v) != "" {
+		return strings.TrimSpace(*v)
+	}
+	return strings.TrimSpace(os.Getenv(env))
+}
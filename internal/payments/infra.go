@@ -0,0 +1,82 @@
+package payments
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type repo struct {
+	db *sql.DB
+}
+
+func NewRepo(db *sql.DB) Repo {
+	return &repo{db: db}
+}
+
+func (r *repo) Claim(ctx context.Context, e *Event, staleAfter time.Duration) (bool, error) {
+	// новое событие, либо повтор после сбоя — в одном запросе, чтобы два вебхука не взяли одно событие
+	res, err := r.db.ExecContext(ctx, `
+		INSERT INTO payment_events (
+			provider, object_id, kind, payment_id,
+			bot_id, telegram_id, payment_type, amount,
+			payload, status, attempts, created_at, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, 'processing', 1, NOW(), NOW())
+		ON CONFLICT (provider, object_id, kind) DO UPDATE SET
+			status     = 'processing',
+			attempts   = payment_events.attempts + 1,
+			payload    = EXCLUDED.payload,
+			error      = NULL,
+			updated_at = NOW()
+		WHERE payment_events.status = 'failed'
+		   OR (payment_events.status = 'processing' AND payment_events.updated_at < NOW() - $10::interval)
+	`,
+		e.Provider,
+		e.ObjectID,
+		e.Kind,
+		e.PaymentID,
+		e.BotID,
+		e.TelegramID,
+		e.PaymentType,
+		e.Amount,
+		string(e.Payload),
+		staleAfter.String(),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *repo) Finish(ctx context.Context, e *Event, status string, errText string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE payment_events
+		SET status       = $4,
+		    error        = NULLIF($5, ''),
+		    processed_at = CASE WHEN $4 = 'done' THEN NOW() ELSE processed_at END,
+		    updated_at   = NOW()
+		WHERE provider = $1 AND object_id = $2 AND kind = $3
+	`, e.Provider, e.ObjectID, e.Kind, status, errText)
+	return err
+}
+
+func (r *repo) BotForPayment(ctx context.Context, provider, paymentID string) (string, error) {
+	var botID string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT bot_id
+		FROM payment_events
+		WHERE provider = $1 AND payment_id = $2 AND bot_id <> ''
+		ORDER BY created_at
+		LIMIT 1
+	`, provider, paymentID).Scan(&botID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return botID, err
+}
@@ -0,0 +1,89 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// провайдеры
+const (
+	ProviderYooKassa      = "yookassa"
+	ProviderCloudPayments = "cloudpayments"
+)
+
+// виды событий — так, как их называет сам провайдер в уведомлении.
+// Автопродление использует те же ключи, чтобы синхронный ответ и вебхук не применились дважды.
+const (
+	KindYKPaymentSucceeded = "payment.succeeded"
+	KindYKPaymentCanceled  = "payment.canceled"
+	KindYKRefundSucceeded  = "refund.succeeded"
+
+	KindCPPay  = "pay"
+	KindCPFail = "fail"
+)
+
+// SucceededKind / FailedKind — вид события успешного/отклонённого платежа у провайдера
+func SucceededKind(provider string) string {
+	if provider == ProviderCloudPayments {
+		return KindCPPay
+	}
+	return KindYKPaymentSucceeded
+}
+
+func FailedKind(provider string) string {
+	if provider == ProviderCloudPayments {
+		return KindCPFail
+	}
+	return KindYKPaymentCanceled
+}
+
+// статусы обработки события
+const (
+	StatusProcessing = "processing"
+	StatusDone       = "done"
+	StatusFailed     = "failed"
+	// needs_review — деньги пришли, но начислять нельзя (например, сумма не совпала с заказом):
+	// повторно не обрабатывается, разбирается и возвращается вручную
+	StatusNeedsReview = "needs_review"
+)
+
+// ErrNeedsReview — apply отказался применять событие намеренно: оно уходит в needs_review,
+// а не в failed, и провайдер получает успешный ответ
+var ErrNeedsReview = errors.New("payment needs review")
+
+// Event — одно уведомление провайдера.
+// Уникально по (Provider, ObjectID, Kind): ObjectID — id платежа, а для возвратов — id возврата.
+type Event struct {
+	Provider  string
+	ObjectID  string
+	Kind      string // payment.succeeded | payment.canceled | refund.succeeded | ...
+	PaymentID string
+
+	BotID       string
+	TelegramID  int64
+	PaymentType string // subscription | minute_package
+	Amount      float64
+
+	Payload []byte // сырое тело вебхука
+}
+
+type Repo interface {
+	// Claim — true, если событие можно обрабатывать: его ещё не было,
+	// прошлая обработка упала (failed) или зависла в processing дольше staleAfter
+	Claim(ctx context.Context, e *Event, staleAfter time.Duration) (bool, error)
+	Finish(ctx context.Context, e *Event, status string, errText string) error
+	// BotForPayment — бот, к которому относится уже известный платёж ("" — событий не было)
+	BotForPayment(ctx context.Context, provider, paymentID string) (string, error)
+}
+
+type Service interface {
+	// Process — применяет apply ровно один раз на событие.
+	// duplicate=true — событие уже обработано (или обрабатывается), apply не вызывался.
+	// Ошибка возможна и после успешного apply, если итог не записался и после повторов:
+	// провайдер повторит уведомление, а событие до staleProcessing числится в processing.
+	Process(ctx context.Context, e *Event, apply func(ctx context.Context) error) (duplicate bool, err error)
+
+	// BotForPayment — бот платежа по прошлым событиям (возврат приходит без metadata платежа)
+	BotForPayment(ctx context.Context, provider, paymentID string) (string, error)
+}
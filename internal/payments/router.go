@@ -0,0 +1,114 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Vovarama1992/make_ziper/internal/bots"
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+)
+
+// Router — ports.PaymentProvider, который выбирает платёжку по bot_configs.payment_provider.
+// Он же ports.RecurringCharger для автопродления.
+type Router struct {
+	bots      bots.Repo
+	providers map[string]ports.PaymentProvider
+}
+
+var (
+	_ ports.PaymentProvider  = (*Router)(nil)
+	_ ports.RecurringCharger = (*Router)(nil)
+)
+
+func NewRouter(botsRepo bots.Repo, providers map[string]ports.PaymentProvider) *Router {
+	return &Router{
+		bots:      botsRepo,
+		providers: providers,
+	}
+}
+
+func (r *Router) providerFor(ctx context.Context, botID string) (ports.PaymentProvider, error) {
+	cfg, err := r.bots.Get(ctx, botID)
+	if err != nil {
+		return nil, fmt.Errorf("load bot config %s: %w", botID, err)
+	}
+
+	name := strings.TrimSpace(cfg.PaymentProvider)
+	if name == "" {
+		name = ProviderYooKassa
+	}
+
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown payment provider %q for bot %s", name, botID)
+	}
+	return p, nil
+}
+
+func (r *Router) CreateMinutePackagePayment(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	packageID int64,
+	price float64,
+	title string,
+	minutes int,
+	invoiceID string,
+) (string, string, error) {
+
+	p, err := r.providerFor(ctx, botID)
+	if err != nil {
+		return "", "", err
+	}
+	return p.CreateMinutePackagePayment(ctx, botID, telegramID, packageID, price, title, minutes, invoiceID)
+}
+
+func (r *Router) CreateSubscriptionPayment(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	planCode string,
+	price float64,
+	invoiceID string,
+	savePaymentMethod bool,
+) (string, string, error) {
+
+	p, err := r.providerFor(ctx, botID)
+	if err != nil {
+		return "", "", err
+	}
+	return p.CreateSubscriptionPayment(ctx, botID, telegramID, planCode, price, invoiceID, savePaymentMethod)
+}
+
+func (r *Router) CreateGiftPayment(
+	ctx context.Context,
+	botID string,
+	payerID int64,
+	planCode string,
+	price float64,
+	invoiceID string,
+) (string, string, error) {
+
+	p, err := r.providerFor(ctx, botID)
+	if err != nil {
+		return "", "", err
+	}
+	return p.CreateGiftPayment(ctx, botID, payerID, planCode, price, invoiceID)
+}
+
+// ChargeSaved — списание идёт через ту платёжку, где сохранён способ оплаты,
+// даже если бот с тех пор переключился на другую
+func (r *Router) ChargeSaved(ctx context.Context, c *ports.RecurringCharge) (*ports.ProviderPayment, error) {
+	p, ok := r.providers[c.Provider]
+	if !ok {
+		return nil, &ports.CheckoutConfigError{Err: fmt.Errorf("unknown payment provider %q", c.Provider)}
+	}
+
+	charger, ok := p.(ports.RecurringCharger)
+	if !ok {
+		return nil, &ports.CheckoutConfigError{Err: fmt.Errorf("payment provider %q does not support recurring charges", c.Provider)}
+	}
+
+	return charger.ChargeSaved(ctx, c)
+}
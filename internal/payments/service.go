@@ -0,0 +1,91 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// processing дольше этого — считаем, что процесс упал посреди обработки, и берём событие заново
+const staleProcessing = 10 * time.Minute
+
+// повторы записи итога: 200ms, 400ms, 800ms
+const (
+	finishRetries   = 3
+	finishRetryBase = 200 * time.Millisecond
+)
+
+type service struct {
+	repo Repo
+}
+
+func NewService(repo Repo) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) Process(
+	ctx context.Context,
+	e *Event,
+	apply func(ctx context.Context) error,
+) (bool, error) {
+
+	claimed, err := s.repo.Claim(ctx, e, staleProcessing)
+	if err != nil {
+		return false, err
+	}
+	if !claimed {
+		log.Printf("[payments] duplicate %s %s %s", e.Provider, e.Kind, e.ObjectID)
+		return true, nil
+	}
+
+	if err := apply(ctx); err != nil {
+		if errors.Is(err, ErrNeedsReview) {
+			// повтор провайдера ничего не изменит — фиксируем для ручного разбора
+			if ferr := s.finish(ctx, e, StatusNeedsReview, err.Error()); ferr != nil {
+				return false, fmt.Errorf("finish %s %s: %w", e.Kind, e.ObjectID, ferr)
+			}
+			log.Printf("[payments] needs review %s %s %s bot=%s tg=%d: %v",
+				e.Provider, e.Kind, e.ObjectID, e.BotID, e.TelegramID, err)
+			return false, nil
+		}
+
+		// failed — провайдер пришлёт повтор, и событие снова возьмётся в работу
+		if ferr := s.finish(ctx, e, StatusFailed, err.Error()); ferr != nil {
+			log.Printf("[payments] finish fail %s %s %s: %v", e.Provider, e.Kind, e.ObjectID, ferr)
+		}
+		return false, err
+	}
+
+	// эффект уже применён: не записанный done через staleProcessing отдаст событие
+	// на повторное применение, поэтому сбой не глотаем — вызывающий ответит ошибкой
+	if err := s.finish(ctx, e, StatusDone, ""); err != nil {
+		log.Printf("[payments] finish fail %s %s %s: %v", e.Provider, e.Kind, e.ObjectID, err)
+		return false, fmt.Errorf("finish %s %s: %w", e.Kind, e.ObjectID, err)
+	}
+
+	log.Printf("[payments] done %s %s %s bot=%s tg=%d", e.Provider, e.Kind, e.ObjectID, e.BotID, e.TelegramID)
+	return false, nil
+}
+
+// finish — итог с повторами: после применённого эффекта короткий сбой базы не должен терять статус
+func (s *service) finish(ctx context.Context, e *Event, status, errText string) error {
+	// запрос провайдера мог оборваться, а статус записать всё равно нужно
+	ctx = context.WithoutCancel(ctx)
+
+	for attempt := 0; ; attempt++ {
+		err := s.repo.Finish(ctx, e, status, errText)
+		if err == nil || attempt >= finishRetries {
+			return err
+		}
+
+		delay := finishRetryBase << attempt
+		log.Printf("[payments] finish %s %s retry %d in %s: %v", e.Kind, e.ObjectID, attempt+1, delay, err)
+		time.Sleep(delay)
+	}
+}
+
+func (s *service) BotForPayment(ctx context.Context, provider, paymentID string) (string, error) {
+	return s.repo.BotForPayment(ctx, provider, paymentID)
+}
@@ -1,7 +1,28 @@
 package ports
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type AuthRepo interface {
+	// GetPassword — пароль из bot_auth (до появления учёток)
 	GetPassword(ctx context.Context) (string, error)
+
+	CountAdmins(ctx context.Context) (int, error)
+	CreateAdmin(ctx context.Context, a *Admin, passwordHash string) error
+	// GetAdminByLogin — nil, если нет
+	GetAdminByLogin(ctx context.Context, login string) (*Admin, string, error)
+	// GetAdmin — nil, если нет
+	GetAdmin(ctx context.Context, id int64) (*Admin, error)
+	ListAdmins(ctx context.Context) ([]*Admin, error)
+	// UpdateAdmin — passwordHash nil — пароль не меняется
+	UpdateAdmin(ctx context.Context, a *Admin, passwordHash *string) error
+
+	// ==== токены (храним HMAC токена, не сам токен) ====
+	CreateSession(ctx context.Context, tokenHash string, adminID int64, expiresAt time.Time) error
+	// GetSessionAdmin — nil, если токен неизвестен, истёк, отозван или учётка отключена
+	GetSessionAdmin(ctx context.Context, tokenHash string) (*Admin, error)
+	RevokeSession(ctx context.Context, tokenHash string) error
+	RevokeAdminSessions(ctx context.Context, adminID int64) error
 }
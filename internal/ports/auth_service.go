@@ -1,8 +1,87 @@
 package ports
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// роли админки
+const (
+	RoleOwner    = "owner"    // всё, включая глобальные настройки и учётки админов
+	RoleOperator = "operator" // только свои боты (Admin.BotIDs)
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid login or password")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrAdminExists        = errors.New("admin with this login already exists")
+	ErrAdminNotFound      = errors.New("admin not found")
+	ErrInvalidAdminInput  = errors.New("invalid admin input")
+)
+
+// Admin — учётка админки
+type Admin struct {
+	ID        int64     `json:"id"`
+	Login     string    `json:"login"`
+	Role      string    `json:"role"`
+	BotIDs    []string  `json:"bot_ids"` // для operator
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CanAccessBot — может ли админ управлять ботом
+func (a *Admin) CanAccessBot(botID string) bool {
+	if a == nil || !a.Active {
+		return false
+	}
+	if a.Role == RoleOwner {
+		return true
+	}
+	for _, id := range a.BotIDs {
+		if id == botID {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminInput — создание/изменение учётки (nil — не менять)
+type AdminInput struct {
+	Login    *string   `json:"login"`
+	Password *string   `json:"password"`
+	Role     *string   `json:"role"`
+	BotIDs   *[]string `json:"bot_ids"`
+	Active   *bool     `json:"active"`
+}
 
 type AuthService interface {
-	Login(ctx context.Context, password string) (string, error)
-	ValidateToken(ctx context.Context, token string) (bool, error)
+	// Login — токен на AUTH_TOKEN_TTL; пустой login — "admin" (старая форма входа по одному паролю)
+	Login(ctx context.Context, login, password string) (string, error)
+	// ValidateToken — владелец токена (ErrInvalidToken — нет, истёк, отозван или учётка отключена)
+	ValidateToken(ctx context.Context, token string) (*Admin, error)
+	Logout(ctx context.Context, token string) error
+
+	// Bootstrap — если учёток нет, заводит owner "admin" с паролем из bot_auth
+	Bootstrap(ctx context.Context) error
+
+	// ==== учётки ====
+	ListAdmins(ctx context.Context) ([]*Admin, error)
+	CreateAdmin(ctx context.Context, in AdminInput) (*Admin, error)
+	// UpdateAdmin — смена пароля, роли, ботов или отключение отзывает все токены учётки
+	UpdateAdmin(ctx context.Context, id int64, in AdminInput) (*Admin, error)
+	RevokeSessions(ctx context.Context, adminID int64) error
+}
+
+type adminCtxKey struct{}
+
+// WithAdmin — админ запроса (кладёт auth-middleware)
+func WithAdmin(ctx context.Context, a *Admin) context.Context {
+	return context.WithValue(ctx, adminCtxKey{}, a)
+}
+
+// AdminFromContext — админ запроса, nil — запрос без авторизации
+func AdminFromContext(ctx context.Context) *Admin {
+	a, _ := ctx.Value(adminCtxKey{}).(*Admin)
+	return a
 }
@@ -0,0 +1,48 @@
+package ports
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoReceiptContact — чек 54-ФЗ некуда отправить: у покупателя нет email/телефона, у бота — запасного email
+var ErrNoReceiptContact = errors.New("no email or phone for receipt")
+
+// CheckoutConfigError — платёж не отправлен из-за настроек бота или покупателя
+// (контакт для чека, return_url, ключи магазина). До провайдера запрос не дошёл,
+// отказом банка это не считается.
+type CheckoutConfigError struct {
+	Err error
+}
+
+func (e *CheckoutConfigError) Error() string { return "checkout config: " + e.Err.Error() }
+func (e *CheckoutConfigError) Unwrap() error { return e.Err }
+
+// Checkout — всё, что платёжке нужно знать о боте и покупателе кроме суммы
+type Checkout struct {
+	ReturnURL string // куда вернуть после оплаты
+
+	// покупатель для чека: хотя бы одно из двух
+	Email string
+	Phone string // только цифры, 79001234567
+
+	VatCode        int    // ставка НДС в кодах ЮKassa (1 — без НДС)
+	TaxSystem      int    // система налогообложения 1–6, 0 — не передаём
+	PaymentSubject string // признак предмета расчёта (service)
+}
+
+// CheckoutResolver — данные для оплаты и чека по боту и пользователю
+type CheckoutResolver interface {
+	Checkout(ctx context.Context, botID string, telegramID int64) (*Checkout, error)
+}
+
+// MerchantCredentials — доступ к API платёжки: shopId и секретный ключ ЮKassa, Public ID и API secret CloudPayments
+type MerchantCredentials struct {
+	Login  string
+	Secret string
+}
+
+// CredentialsResolver — доступы к кабинету мерчанта конкретного бота
+type CredentialsResolver interface {
+	Credentials(ctx context.Context, botID, provider string) (*MerchantCredentials, error)
+}
@@ -3,8 +3,8 @@ package ports
 import "context"
 
 type PaymentProvider interface {
-	// Возвращает redirect URL для оплаты
-	CreateMinutePackagePayment(ctx context.Context, botID string, telegramID int64, packageID int64, price float64, title string, minutes int) (payURL string, providerPaymentID string, err error)
+	// Возвращает redirect URL для оплаты. invoiceID (pkg_...) возвращается в уведомлении — по нему находится промокод.
+	CreateMinutePackagePayment(ctx context.Context, botID string, telegramID int64, packageID int64, price float64, title string, minutes int, invoiceID string) (payURL string, providerPaymentID string, err error)
 
 	// Возвращает redirect URL для оплаты подписки
 	CreateSubscriptionPayment(
@@ -14,5 +14,57 @@ type PaymentProvider interface {
 		planCode string,
 		price float64,
 		invoiceID string, // <-- добавили
+		savePaymentMethod bool, // автопродление: сохранить карту для последующих списаний
 	) (string, string, error)
+
+	// Оплата тарифа в подарок (payment_type=gift): платит payerID, доступ — по коду подарка
+	CreateGiftPayment(ctx context.Context, botID string, payerID int64, planCode string, price float64, invoiceID string) (payURL string, providerPaymentID string, err error)
+}
+
+// ProviderPayment — платёж, перечитанный по API провайдера.
+// Вебхуку верим только после такой сверки.
+type ProviderPayment struct {
+	ID             string
+	Status         string // pending | waiting_for_capture | succeeded | canceled
+	Paid           bool
+	Amount         float64
+	RefundedAmount float64
+	Metadata       map[string]string
+
+	// сохранённый способ оплаты (если просили save_payment_method)
+	PaymentMethodID    string
+	PaymentMethodSaved bool
+}
+
+// ProviderRefund — возврат, перечитанный по API провайдера
+type ProviderRefund struct {
+	ID        string
+	PaymentID string
+	Status    string // pending | succeeded | canceled
+	Amount    float64
+}
+
+// PaymentVerifier — сверка уведомлений с API провайдера (под ключами магазина бота)
+type PaymentVerifier interface {
+	GetPayment(ctx context.Context, botID, paymentID string) (*ProviderPayment, error)
+	GetRefund(ctx context.Context, botID, refundID string) (*ProviderRefund, error)
+}
+
+// RecurringCharge — списание по сохранённому способу оплаты (автопродление)
+type RecurringCharge struct {
+	Provider string // кто сохранил способ оплаты
+	MethodID string // payment_method_id YooKassa / Token CloudPayments
+
+	BotID          string
+	TelegramID     int64
+	SubscriptionID int64
+	PlanCode       string
+	Amount         float64
+	InvoiceID      string
+}
+
+// RecurringCharger — безакцептное списание. Возвращает платёж в том статусе,
+// который провайдер отдал синхронно (succeeded | canceled | pending).
+type RecurringCharger interface {
+	ChargeSaved(ctx context.Context, c *RecurringCharge) (*ProviderPayment, error)
 }
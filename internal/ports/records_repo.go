@@ -15,6 +15,7 @@ type Record struct {
 	Type       string
 	Text       *string
 	ImageURL   *string
+	Model      *string // provider:model для ответов тьютора
 	CreatedAt  time.Time
 }
 
@@ -37,6 +38,7 @@ type RecordRepo interface {
 	// уже есть
 	CreateText(ctx context.Context, botID string, telegramID int64, role, text string) (int64, error)
 	CreateImage(ctx context.Context, botID string, telegramID int64, role, imageURL string) (int64, error)
+	CreateReply(ctx context.Context, botID string, telegramID int64, text, model string) (int64, error)
 	GetHistory(ctx context.Context, botID string, telegramID int64) ([]Record, error)
 	ListUsers(ctx context.Context) ([]UserBots, error)
 
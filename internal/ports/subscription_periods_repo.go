@@ -0,0 +1,81 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// виды периодов
+const (
+	PeriodKindPurchase = "purchase" // оплата из бота / API
+	PeriodKindRenewal  = "renewal"  // автосписание
+	PeriodKindTrial    = "trial"
+	PeriodKindManual   = "manual" // правка из админки
+	PeriodKindBonus    = "bonus"  // бесплатные дни (реферальная программа)
+	PeriodKindGift     = "gift"   // оплачен другим пользователем, активирован кодом подарка
+)
+
+// статусы периода. Условия периода (тариф, сумма, даты) после оплаты не меняются,
+// меняется только статус — так история покупок остаётся полной.
+const (
+	PeriodPending    = "pending"    // заказ создан, ждём оплату
+	PeriodPaid       = "paid"       // оплачен, входит в доступ
+	PeriodCanceled   = "canceled"   // оплата не прошла
+	PeriodRefunded   = "refunded"   // деньги вернули
+	PeriodSuperseded = "superseded" // остаток зачтён в новый тариф / перекрыт правкой админа
+	PeriodRevoked    = "revoked"    // доступ снят админом / реферальный бонус за возвращённую оплату
+)
+
+// SubscriptionPeriod — одна покупка (заказ) подписки.
+// Текущий доступ пользователя выводится из оплаченных периодов, ещё не закончившихся.
+type SubscriptionPeriod struct {
+	ID         int64  `json:"id"`
+	BotID      string `json:"bot_id"`
+	TelegramID int64  `json:"telegram_id"`
+	PlanID     *int64 `json:"plan_id"`
+	PlanName   string `json:"plan_name,omitempty"`
+
+	Kind      string  `json:"kind"`
+	Status    string  `json:"status"`
+	PaymentID *string `json:"payment_id"` // invoice (sub_...), id платежа провайдера (renewal) или ref_<оплата приглашённого> (bonus)
+
+	Amount       float64 `json:"amount"`
+	Credit       float64 `json:"credit"` // зачтено из прошлых периодов при смене тарифа, ₽
+	VoiceMinutes float64 `json:"voice_minutes"`
+	AutoRenew    bool    `json:"auto_renew"` // пользователь согласился на автопродление при заказе
+
+	// промокод: Amount — уже со скидкой, BonusDays добавляются к сроку тарифа
+	PromoCode *string `json:"promo_code,omitempty"`
+	Discount  float64 `json:"discount"`
+	BonusDays int     `json:"bonus_days"`
+
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+	Note     *string    `json:"note,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type SubscriptionPeriodRepo interface {
+	Create(ctx context.Context, p *SubscriptionPeriod) error
+	GetByPaymentID(ctx context.Context, paymentID string) (*SubscriptionPeriod, error)
+
+	// ListByUser — вся история, старые сверху
+	ListByUser(ctx context.Context, botID string, telegramID int64) ([]*SubscriptionPeriod, error)
+	// ListCurrent — оплаченные периоды, которые заканчиваются позже at (по ends_at)
+	ListCurrent(ctx context.Context, botID string, telegramID int64, at time.Time) ([]*SubscriptionPeriod, error)
+
+	// MarkPaid — pending/canceled → paid с фактическими датами. false — период уже не ждал оплаты.
+	// supersedeAt != nil — заодно закрыть (superseded) прочие оплаченные периоды, идущие после supersedeAt.
+	MarkPaid(ctx context.Context, id int64, startsAt, endsAt time.Time, credit float64, supersedeAt *time.Time) (bool, error)
+	// CreatePaid — оплаченный период (автосписание) одной транзакцией с закрытием прежних
+	// (supersedeAt != nil, как в MarkPaid). false — период с таким payment_id уже есть, ничего не изменено.
+	CreatePaid(ctx context.Context, p *SubscriptionPeriod, supersedeAt *time.Time) (bool, error)
+	SetStatus(ctx context.Context, id int64, status string) error
+	// CloseCurrent — все текущие оплаченные периоды переводятся в status (superseded / revoked)
+	CloseCurrent(ctx context.Context, botID string, telegramID int64, at time.Time, status string) error
+	// CancelStale — pending старше olderThan → canceled, возвращает их payment_id.
+	// Поздняя оплата такого заказа всё равно пройдёт: MarkPaid принимает canceled.
+	CancelStale(ctx context.Context, olderThan time.Duration) ([]string, error)
+}
@@ -18,16 +18,28 @@ type Subscription struct {
 	TrialNotifiedAt   *time.Time `json:"trial_notified_at"` // ← ДОБАВИЛИ
 	YookassaPaymentID *string    `json:"yookassa_payment_id"`
 	VoiceMinutes      float64    `json:"voice_minutes"`
+
+	// автопродление: способ оплаты сохраняется при первой оплате, если пользователь согласился
+	AutoRenew             bool       `json:"auto_renew"`
+	PaymentMethodID       *string    `json:"-"`
+	PaymentMethodProvider *string    `json:"payment_method_provider"`
+	RenewAttempts         int        `json:"renew_attempts"` // неудачных списаний подряд
+	NextRenewAt           *time.Time `json:"next_renew_at"`  // не раньше — следующая попытка
 }
 
 type SubscriptionRepo interface {
 	Create(ctx context.Context, s *Subscription) error
 	GetByPaymentID(ctx context.Context, paymentID string) (*Subscription, error)
+	GetByID(ctx context.Context, id int64) (*Subscription, error)
 	Get(ctx context.Context, botID string, telegramID int64) (*Subscription, error)
 	UpdateStatus(ctx context.Context, id int64, status string) error
 	ListAll(ctx context.Context) ([]*Subscription, error)
 	UseVoiceMinutes(ctx context.Context, botID string, tgID int64, used float64) (bool, error)
 	AddVoiceMinutes(ctx context.Context, botID string, tgID int64, minutes float64) error
+	// SubtractVoiceMinutes — не уходит ниже нуля (минуты могли быть уже потрачены)
+	SubtractVoiceMinutes(ctx context.Context, botID string, tgID int64, minutes float64) error
+	// Revoke — закрывает доступ сейчас же и снимает minutes минут (возврат платежа)
+	Revoke(ctx context.Context, id int64, minutes float64) error
 
 	Delete(ctx context.Context, botID string, telegramID int64) error
 	ExpireDue(ctx context.Context) ([]*Subscription, error)
@@ -35,7 +47,9 @@ type SubscriptionRepo interface {
 	MarkTrialNotified(ctx context.Context, id int64) error
 
 	CleanupPending(ctx context.Context, olderThan time.Duration) error
-	Activate(ctx context.Context, id int64, startedAt, expiresAt time.Time, voiceMinutes float64) error
+	// SetEntitlement — upsert текущего доступа (выводится из subscription_periods), возвращает id строки
+	SetEntitlement(ctx context.Context, botID string, telegramID int64, planID *int64, status string, startedAt, expiresAt *time.Time) (int64, error)
+	SetVoiceMinutes(ctx context.Context, id int64, minutes float64) error
 	CreateDemo(ctx context.Context, botID string, telegramID int64, startedAt, expiresAt time.Time, voiceMinutes float64) error
 	UpdateLimits(
 		ctx context.Context,
@@ -44,4 +58,12 @@ type SubscriptionRepo interface {
 		voiceMinutes float64,
 		status string,
 	) error
+
+	// автопродление
+	SetPaymentMethod(ctx context.Context, id int64, provider, methodID string) error
+	// SetAutoRenew — при выключении сохранённый способ оплаты забывается
+	SetAutoRenew(ctx context.Context, botID string, telegramID int64, on bool) error
+	ScheduleRenewal(ctx context.Context, id int64, attempts int, next *time.Time) error
+	// ListRenewalsDue — подписки с автопродлением, срок которых истекает в пределах lead
+	ListRenewalsDue(ctx context.Context, lead time.Duration) ([]*Subscription, error)
 }
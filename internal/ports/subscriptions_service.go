@@ -7,11 +7,34 @@ import (
 
 type SubscriptionService interface {
 	// создание подписки (создаёт запись и создаёт платёж в Юкассе)
-	Create(ctx context.Context, botID string, telegramID int64, planCode string) (paymentURL string, err error)
+	// autoRenew — пользователь согласился на автопродление, провайдер сохранит способ оплаты
+	Create(ctx context.Context, botID string, telegramID int64, planCode string, autoRenew bool) (paymentURL string, err error)
 
 	// активация по вебхуку (по payment_id)
 	Activate(ctx context.Context, paymentID string) error
 
+	// платёж отменён — pending-подписка больше не ждёт оплаты
+	CancelPayment(ctx context.Context, paymentID string) error
+
+	// полный возврат оплаты подписки — доступ закрывается
+	Refund(ctx context.Context, paymentID string) error
+
+	// подарок: оплаченный тариф становится периодом получателя (paymentID — invoice подарка)
+	GrantGift(ctx context.Context, botID string, recipientID int64, planID int64, paymentID string, amount float64) error
+
+	// ==== автопродление ====
+
+	// способ оплаты из успешного платежа — сохраняется, только если подписка с автопродлением
+	SavePaymentMethod(ctx context.Context, paymentID, provider, methodID string) error
+	// выключение забывает сохранённый способ оплаты
+	SetAutoRenew(ctx context.Context, botID string, telegramID int64, on bool) error
+	// списания по подпискам, у которых подходит срок (джоба)
+	RenewDue(ctx context.Context) error
+	// результат списания — и из джобы, и из вебхука провайдера.
+	// paymentID — id платежа провайдера, каждый добавляет в историю один период.
+	ApplyRenewal(ctx context.Context, subscriptionID int64, paymentID string, amount float64) error
+	RenewalFailed(ctx context.Context, subscriptionID int64) error
+
 	ActivateTrial(ctx context.Context, botID string, telegramID int64, planCode string) error
 
 	// получение текущего статуса подписки пользователя
@@ -19,15 +42,42 @@ type SubscriptionService interface {
 
 	// получение подписки целиком
 	Get(ctx context.Context, botID string, telegramID int64) (*Subscription, error)
+	// GetByID — подписка по id (nil — нет такой)
+	GetByID(ctx context.Context, id int64) (*Subscription, error)
+
+	// заказ (период) по invoice/payment id (nil — не найден)
+	GetOrder(ctx context.Context, paymentID string) (*SubscriptionPeriod, error)
+
+	// вся история покупок пользователя
+	Timeline(ctx context.Context, botID string, telegramID int64) ([]*SubscriptionPeriod, error)
 
 	ExpireAndNotifyTrials(ctx context.Context) error
 
-	// начисление минут по пакету
+	// начисление минут по пакету (+ бонус промокода, привязанного к invoiceID)
 	AddMinutesFromPackage(
 		ctx context.Context,
 		botID string,
 		telegramID int64,
 		packageID int64,
+		invoiceID string,
+	) error
+
+	// PackagePrice — сумма к оплате за пакет: цена минус скидка промокода, привязанного к invoiceID
+	PackagePrice(ctx context.Context, botID string, packageID int64, invoiceID string) (float64, error)
+
+	// RenewalPrice — сумма автосписания по подписке (цена её тарифа)
+	RenewalPrice(ctx context.Context, subscriptionID int64) (float64, error)
+
+	// оплата пакета отменена — промокод снова доступен пользователю
+	CancelMinutesPayment(ctx context.Context, invoiceID string) error
+
+	// возврат оплаты пакета минут — снимаем начисленное
+	RefundMinutesFromPackage(
+		ctx context.Context,
+		botID string,
+		telegramID int64,
+		packageID int64,
+		invoiceID string,
 	) error
 
 	// списание голосовых минут. ok=false — если не хватило
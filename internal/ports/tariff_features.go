@@ -0,0 +1,124 @@
+package ports
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TariffFeatures — возможности тарифа, хранятся в tariff_plans.features.
+// Ключ не задан — значение по умолчанию: всё разрешено, лимитов нет.
+type TariffFeatures struct {
+	Voice bool `json:"voice"`
+	Photo bool `json:"photo"`
+	PDF   bool `json:"pdf"`
+	Word  bool `json:"word"`
+
+	MaxPDFPages int `json:"max_pdf_pages"` // страниц в одном PDF, 0 — без ограничения
+
+	// Quotas — лимиты расхода на сутки и календарный месяц (см. internal/quota)
+	Quotas TariffQuotas `json:"quotas"`
+
+	// DailyMessages — прежний ключ лимита ответов в сутки. Читается как quotas.messages.day,
+	// если тот не задан: тарифы, настроенные до квот, сохраняют свой лимит.
+	DailyMessages int `json:"daily_messages,omitempty"`
+
+	// Models — какие модели бота доступны ("model" или "provider:model"), пусто — любые
+	Models []string `json:"models"`
+}
+
+// QuotaLimit — 0 — без ограничения
+type QuotaLimit struct {
+	Day   int `json:"day"`
+	Month int `json:"month"`
+}
+
+type TariffQuotas struct {
+	Messages QuotaLimit `json:"messages"`  // запросов к AI (текст, голос, фото, документы)
+	Images   QuotaLimit `json:"images"`    // фото и картинок
+	PDFPages QuotaLimit `json:"pdf_pages"` // страниц PDF
+}
+
+// Limit — лимит по ключам как в JSON: metric messages|images|pdf_pages, window day|month
+func (q TariffQuotas) Limit(metric, window string) int {
+	var l QuotaLimit
+	switch metric {
+	case "messages":
+		l = q.Messages
+	case "images":
+		l = q.Images
+	case "pdf_pages":
+		l = q.PDFPages
+	default:
+		return 0
+	}
+
+	switch window {
+	case "day":
+		return l.Day
+	case "month":
+		return l.Month
+	}
+	return 0
+}
+
+func (q TariffQuotas) valid() bool {
+	for _, l := range []QuotaLimit{q.Messages, q.Images, q.PDFPages} {
+		if l.Day < 0 || l.Month < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func DefaultTariffFeatures() TariffFeatures {
+	return TariffFeatures{
+		Voice: true,
+		Photo: true,
+		PDF:   true,
+		Word:  true,
+	}
+}
+
+// ParseFeatures — features тарифа поверх значений по умолчанию
+func (p *TariffPlan) ParseFeatures() (TariffFeatures, error) {
+	f := DefaultTariffFeatures()
+
+	raw := strings.TrimSpace(string(p.Features))
+	if raw == "" || raw == "null" {
+		return f, nil
+	}
+
+	if err := json.Unmarshal([]byte(raw), &f); err != nil {
+		return DefaultTariffFeatures(), fmt.Errorf("tariff %s features: %w", p.Code, err)
+	}
+
+	if f.MaxPDFPages < 0 || f.DailyMessages < 0 || !f.Quotas.valid() {
+		return DefaultTariffFeatures(), fmt.Errorf("tariff %s features: limits must be >= 0", p.Code)
+	}
+
+	if f.Quotas.Messages.Day == 0 {
+		f.Quotas.Messages.Day = f.DailyMessages
+	}
+
+	return f, nil
+}
+
+// AllowsModel — модель из цепочки бота разрешена тарифом
+func (f TariffFeatures) AllowsModel(ref string) bool {
+	if len(f.Models) == 0 {
+		return true
+	}
+
+	bare := ref
+	if _, model, ok := strings.Cut(ref, ":"); ok {
+		bare = model
+	}
+	for _, m := range f.Models {
+		m = strings.TrimSpace(m)
+		if m == ref || m == bare {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package promo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Vovarama1992/make_ziper/internal/audit"
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	svc Service
+}
+
+func NewHandler(svc Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// GET /promo-codes?bot_id=xxx
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	botID := r.URL.Query().Get("bot_id")
+	if botID == "" {
+		http.Error(w, "bot_id required", http.StatusBadRequest)
+		return
+	}
+
+	items, err := h.svc.List(r.Context(), botID)
+	if err != nil {
+		http.Error(w, "failed to list promo codes", 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// GET /promo-codes/{id}?bot_id=xxx
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	botID := r.URL.Query().Get("bot_id")
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if botID == "" || err != nil {
+		http.Error(w, "bot_id and id required", http.StatusBadRequest)
+		return
+	}
+
+	c, err := h.svc.Get(r.Context(), botID, id)
+	if err != nil {
+		http.Error(w, "failed to get promo code", 500)
+		return
+	}
+	if c == nil {
+		http.Error(w, "not found", 404)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(c)
+}
+
+// POST /promo-codes
+func (h *Handler) Create(w http.ResponseWriter, r *http.Request) {
+	var c Code
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "invalid json", 400)
+		return
+	}
+
+	if err := h.svc.Create(r.Context(), &c); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(c)
+}
+
+// PATCH /promo-codes/{id} — тело целиком, как при создании
+func (h *Handler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id", 400)
+		return
+	}
+
+	var c Code
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "invalid json", 400)
+		return
+	}
+	c.ID = id
+
+	if err := h.svc.Update(r.Context(), &c); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(c)
+}
+
+// DELETE /promo-codes/{id}?bot_id=xxx
+func (h *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	botID := r.URL.Query().Get("bot_id")
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if botID == "" || err != nil {
+		http.Error(w, "bot_id and id required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), botID, id); err != nil {
+		http.Error(w, "failed to delete promo code", 500)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalid):
+		http.Error(w, err.Error(), 400)
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), 404)
+	case errors.Is(err, ErrDuplicate):
+		http.Error(w, err.Error(), 409)
+	default:
+		http.Error(w, "failed to save promo code", 500)
+	}
+}
+
+// AuditSnapshot — промокод из пути /promo-codes/{id} для журнала
+func (h *Handler) AuditSnapshot(r *http.Request, botID string) (*audit.Snapshot, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	c, err := h.svc.Get(r.Context(), botID, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil || c == nil {
+		return nil, err
+	}
+	return &audit.Snapshot{EntityID: chi.URLParam(r, "id"), BotID: botID, Value: c}, nil
+}
@@ -0,0 +1,345 @@
+package promo
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type repo struct {
+	db *sql.DB
+}
+
+func NewRepo(db *sql.DB) Repo {
+	return &repo{db: db}
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+const codeColumns = `
+	id, bot_id, code,
+	percent_off, amount_off, bonus_minutes, bonus_days,
+	applies_to, max_uses, used_count, expires_at, active,
+	created_at
+`
+
+func scanCode(row rowScanner) (*Code, error) {
+	var c Code
+	err := row.Scan(
+		&c.ID, &c.BotID, &c.Code,
+		&c.PercentOff, &c.AmountOff, &c.BonusMinutes, &c.BonusDays,
+		&c.AppliesTo, &c.MaxUses, &c.UsedCount, &c.ExpiresAt, &c.Active,
+		&c.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+const redemptionColumns = `
+	id, promo_id, bot_id, telegram_id, status, payment_id,
+	discount, bonus_minutes, bonus_days,
+	created_at, updated_at
+`
+
+func scanRedemption(row rowScanner) (*Redemption, error) {
+	var r Redemption
+	err := row.Scan(
+		&r.ID, &r.PromoID, &r.BotID, &r.TelegramID, &r.Status, &r.PaymentID,
+		&r.Discount, &r.BonusMinutes, &r.BonusDays,
+		&r.CreatedAt, &r.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ==================================================
+// CODES
+// ==================================================
+
+func (r *repo) Create(ctx context.Context, c *Code) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO promo_codes (
+			bot_id, code,
+			percent_off, amount_off, bonus_minutes, bonus_days,
+			applies_to, max_uses, expires_at, active
+		)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+		RETURNING id, used_count, created_at
+	`,
+		c.BotID, c.Code,
+		c.PercentOff, c.AmountOff, c.BonusMinutes, c.BonusDays,
+		c.AppliesTo, c.MaxUses, c.ExpiresAt, c.Active,
+	).Scan(&c.ID, &c.UsedCount, &c.CreatedAt)
+}
+
+func (r *repo) Update(ctx context.Context, c *Code) error {
+	row := r.db.QueryRowContext(ctx, `
+		UPDATE promo_codes
+		SET code = $3,
+		    percent_off = $4,
+		    amount_off = $5,
+		    bonus_minutes = $6,
+		    bonus_days = $7,
+		    applies_to = $8,
+		    max_uses = $9,
+		    expires_at = $10,
+		    active = $11
+		WHERE id = $1 AND bot_id = $2
+		RETURNING used_count, created_at
+	`,
+		c.ID, c.BotID, c.Code,
+		c.PercentOff, c.AmountOff, c.BonusMinutes, c.BonusDays,
+		c.AppliesTo, c.MaxUses, c.ExpiresAt, c.Active,
+	)
+
+	err := row.Scan(&c.UsedCount, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (r *repo) Delete(ctx context.Context, botID string, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM promo_codes WHERE id = $1 AND bot_id = $2
+	`, id, botID)
+	return err
+}
+
+func (r *repo) Get(ctx context.Context, botID string, id int64) (*Code, error) {
+	return scanCode(r.db.QueryRowContext(ctx, `
+		SELECT `+codeColumns+`
+		FROM promo_codes
+		WHERE id = $1 AND bot_id = $2
+	`, id, botID))
+}
+
+func (r *repo) GetByCode(ctx context.Context, botID, code string) (*Code, error) {
+	return scanCode(r.db.QueryRowContext(ctx, `
+		SELECT `+codeColumns+`
+		FROM promo_codes
+		WHERE bot_id = $1 AND code = $2
+	`, botID, code))
+}
+
+func (r *repo) List(ctx context.Context, botID string) ([]*Code, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+codeColumns+`
+		FROM promo_codes
+		WHERE bot_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, botID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := []*Code{}
+	for rows.Next() {
+		c, err := scanCode(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// ==================================================
+// REDEMPTIONS
+// ==================================================
+
+func (r *repo) GetRedemption(ctx context.Context, promoID int64, telegramID int64) (*Redemption, error) {
+	return scanRedemption(r.db.QueryRowContext(ctx, `
+		SELECT `+redemptionColumns+`
+		FROM promo_redemptions
+		WHERE promo_id = $1 AND telegram_id = $2
+	`, promoID, telegramID))
+}
+
+func (r *repo) Apply(ctx context.Context, botID string, telegramID int64, promoID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// у пользователя один текущий код
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM promo_redemptions
+		WHERE bot_id = $1 AND telegram_id = $2
+		  AND status = 'applied'
+		  AND promo_id <> $3
+	`, botID, telegramID, promoID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO promo_redemptions (promo_id, bot_id, telegram_id, status)
+		VALUES ($1, $2, $3, 'applied')
+		ON CONFLICT (promo_id, telegram_id) DO NOTHING
+	`, promoID, botID, telegramID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *repo) GetApplied(ctx context.Context, botID string, telegramID int64) (*Redemption, *Code, error) {
+	red, err := scanRedemption(r.db.QueryRowContext(ctx, `
+		SELECT `+redemptionColumns+`
+		FROM promo_redemptions
+		WHERE bot_id = $1 AND telegram_id = $2
+		  AND status = 'applied'
+		ORDER BY updated_at DESC
+		LIMIT 1
+	`, botID, telegramID))
+	if err != nil || red == nil {
+		return nil, nil, err
+	}
+
+	code, err := r.Get(ctx, botID, red.PromoID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if code == nil {
+		return nil, nil, nil
+	}
+
+	return red, code, nil
+}
+
+func (r *repo) Reserve(ctx context.Context, redemptionID, promoID int64, paymentID string, d *Discount) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE promo_codes
+		SET used_count = used_count + 1
+		WHERE id = $1
+		  AND (max_uses = 0 OR used_count < max_uses)
+	`, promoID)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	res, err = tx.ExecContext(ctx, `
+		UPDATE promo_redemptions
+		SET status = 'reserved',
+		    payment_id = $2,
+		    discount = $3,
+		    bonus_minutes = $4,
+		    bonus_days = $5,
+		    updated_at = NOW()
+		WHERE id = $1 AND status = 'applied'
+	`, redemptionID, paymentID, d.Off, d.BonusMinutes, d.BonusDays)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	return true, tx.Commit()
+}
+
+func (r *repo) GetByPayment(ctx context.Context, paymentID string) (*Redemption, error) {
+	return scanRedemption(r.db.QueryRowContext(ctx, `
+		SELECT `+redemptionColumns+`
+		FROM promo_redemptions
+		WHERE payment_id = $1
+	`, paymentID))
+}
+
+func (r *repo) MarkRedeemed(ctx context.Context, paymentID string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE promo_redemptions
+		SET status = 'redeemed',
+		    updated_at = NOW()
+		WHERE payment_id = $1 AND status = 'reserved'
+	`, paymentID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *repo) Unreserve(ctx context.Context, paymentID string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var promoID int64
+	err = tx.QueryRowContext(ctx, `
+		UPDATE promo_redemptions
+		SET status = 'applied',
+		    payment_id = NULL,
+		    discount = 0,
+		    bonus_minutes = 0,
+		    bonus_days = 0,
+		    updated_at = NOW()
+		WHERE payment_id = $1 AND status = 'reserved'
+		RETURNING promo_id
+	`, paymentID).Scan(&promoID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE promo_codes
+		SET used_count = GREATEST(used_count - 1, 0)
+		WHERE id = $1
+	`, promoID); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (r *repo) ListStaleReserved(ctx context.Context, olderThan time.Duration) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT payment_id
+		FROM promo_redemptions
+		WHERE status = 'reserved'
+		  AND payment_id IS NOT NULL
+		  AND updated_at < NOW() - $1::interval
+	`, olderThan.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var paymentID string
+		if err := rows.Scan(&paymentID); err != nil {
+			return nil, err
+		}
+		out = append(out, paymentID)
+	}
+	return out, rows.Err()
+}
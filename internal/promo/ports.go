@@ -0,0 +1,215 @@
+package promo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// на что действует промокод
+const (
+	TargetAny           = "any"
+	TargetSubscription  = "subscription"
+	TargetMinutePackage = "minute_package"
+)
+
+// статусы применения промокода пользователем
+const (
+	RedemptionApplied  = "applied"  // введён в боте, ждёт покупки
+	RedemptionReserved = "reserved" // привязан к созданному платежу
+	RedemptionRedeemed = "redeemed" // платёж прошёл
+)
+
+// минимальная сумма платежа — бесплатно через платёжку не оформить
+const minPrice = 1.0
+
+var (
+	ErrNotFound    = errors.New("promo code not found")
+	ErrExpired     = errors.New("promo code expired")
+	ErrExhausted   = errors.New("promo code usage limit reached")
+	ErrAlreadyUsed = errors.New("promo code already used")
+	ErrDuplicate   = errors.New("promo code already exists")
+	ErrInvalid     = errors.New("invalid promo code")
+)
+
+// Code — промокод бота
+type Code struct {
+	ID    int64  `json:"id"`
+	BotID string `json:"bot_id"`
+	Code  string `json:"code"`
+
+	PercentOff   int     `json:"percent_off"`   // 1–100
+	AmountOff    float64 `json:"amount_off"`    // ₽, вместо процента
+	BonusMinutes float64 `json:"bonus_minutes"` // голосовые минуты сверху
+	BonusDays    int     `json:"bonus_days"`    // дни подписки сверху
+
+	AppliesTo string     `json:"applies_to"` // any | subscription | minute_package
+	MaxUses   int        `json:"max_uses"`   // 0 — без ограничения
+	UsedCount int        `json:"used_count"` // оплаченные и ожидающие оплаты
+	ExpiresAt *time.Time `json:"expires_at"`
+	Active    bool       `json:"active"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Normalize — коды сравниваются без учёта регистра
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (c *Code) Validate() error {
+	c.Code = Normalize(c.Code)
+	if c.AppliesTo == "" {
+		c.AppliesTo = TargetAny
+	}
+
+	switch {
+	case c.BotID == "":
+		return fmt.Errorf("%w: bot_id required", ErrInvalid)
+	case c.Code == "" || strings.ContainsAny(c.Code, " \t\n"):
+		return fmt.Errorf("%w: code must be a single word", ErrInvalid)
+	case c.PercentOff < 0 || c.PercentOff > 100:
+		return fmt.Errorf("%w: percent_off must be 0..100", ErrInvalid)
+	case c.AmountOff < 0 || c.BonusMinutes < 0 || c.BonusDays < 0 || c.MaxUses < 0:
+		return fmt.Errorf("%w: amounts must be >= 0", ErrInvalid)
+	case c.PercentOff > 0 && c.AmountOff > 0:
+		return fmt.Errorf("%w: percent_off and amount_off are mutually exclusive", ErrInvalid)
+	case c.PercentOff == 0 && c.AmountOff == 0 && c.BonusMinutes == 0 && c.BonusDays == 0:
+		return fmt.Errorf("%w: promo code gives nothing", ErrInvalid)
+	}
+
+	switch c.AppliesTo {
+	case TargetAny, TargetSubscription, TargetMinutePackage:
+	default:
+		return fmt.Errorf("%w: unknown applies_to %q", ErrInvalid, c.AppliesTo)
+	}
+
+	return nil
+}
+
+// usable — можно ли применить сейчас (без учёта пользователя)
+func (c *Code) usable(now time.Time) error {
+	if !c.Active || (c.ExpiresAt != nil && !c.ExpiresAt.After(now)) {
+		return ErrExpired
+	}
+	if c.MaxUses > 0 && c.UsedCount >= c.MaxUses {
+		return ErrExhausted
+	}
+	return nil
+}
+
+func (c *Code) fits(target string) bool {
+	return c.AppliesTo == TargetAny || c.AppliesTo == target
+}
+
+// Discount — что промокод даёт конкретной покупке
+type Discount struct {
+	Code         string
+	Price        float64 // к оплате
+	Off          float64 // скидка, ₽
+	BonusMinutes float64
+	BonusDays    int
+}
+
+func (c *Code) discount(target string, price float64) *Discount {
+	off := c.AmountOff
+	if c.PercentOff > 0 {
+		off = price * float64(c.PercentOff) / 100
+	}
+	off = math.Round(off*100) / 100
+
+	final := math.Max(price-off, minPrice)
+	if final > price {
+		final = price
+	}
+
+	d := &Discount{
+		Code:  c.Code,
+		Price: final,
+		Off:   math.Round((price-final)*100) / 100,
+	}
+
+	// минуты — и к подписке, и к пакету; дни — только к подписке
+	d.BonusMinutes = c.BonusMinutes
+	if target == TargetSubscription {
+		d.BonusDays = c.BonusDays
+	}
+
+	return d
+}
+
+// Redemption — промокод у конкретного пользователя (один раз на пользователя)
+type Redemption struct {
+	ID         int64   `json:"id"`
+	PromoID    int64   `json:"promo_id"`
+	BotID      string  `json:"bot_id"`
+	TelegramID int64   `json:"telegram_id"`
+	Status     string  `json:"status"`
+	PaymentID  *string `json:"payment_id"`
+
+	Discount     float64 `json:"discount"`
+	BonusMinutes float64 `json:"bonus_minutes"`
+	BonusDays    int     `json:"bonus_days"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Repo interface {
+	Create(ctx context.Context, c *Code) error
+	Update(ctx context.Context, c *Code) error
+	Delete(ctx context.Context, botID string, id int64) error
+	Get(ctx context.Context, botID string, id int64) (*Code, error)
+	GetByCode(ctx context.Context, botID, code string) (*Code, error)
+	List(ctx context.Context, botID string) ([]*Code, error)
+
+	// GetRedemption — применение кода пользователем (nil — не применял)
+	GetRedemption(ctx context.Context, promoID int64, telegramID int64) (*Redemption, error)
+	// Apply — код становится текущим для пользователя, прежний неиспользованный снимается
+	Apply(ctx context.Context, botID string, telegramID int64, promoID int64) error
+	// GetApplied — текущий неиспользованный код пользователя
+	GetApplied(ctx context.Context, botID string, telegramID int64) (*Redemption, *Code, error)
+
+	// Reserve — applied → reserved под платёж, used_count+1 в пределах max_uses.
+	// false — лимит кончился или код уже не applied.
+	Reserve(ctx context.Context, redemptionID, promoID int64, paymentID string, d *Discount) (bool, error)
+	GetByPayment(ctx context.Context, paymentID string) (*Redemption, error)
+	// MarkRedeemed — reserved → redeemed (false — уже не reserved)
+	MarkRedeemed(ctx context.Context, paymentID string) (bool, error)
+	// Unreserve — reserved → applied, used_count-1: оплата не прошла, код можно использовать снова
+	Unreserve(ctx context.Context, paymentID string) (bool, error)
+	// ListStaleReserved — платежи, под которыми код висит в reserved дольше olderThan
+	ListStaleReserved(ctx context.Context, olderThan time.Duration) ([]string, error)
+}
+
+type Service interface {
+	// ==== админка ====
+	Create(ctx context.Context, c *Code) error
+	Update(ctx context.Context, c *Code) error
+	Delete(ctx context.Context, botID string, id int64) error
+	Get(ctx context.Context, botID string, id int64) (*Code, error)
+	List(ctx context.Context, botID string) ([]*Code, error)
+
+	// ==== бот ====
+
+	// Apply — ученик ввёл код; ошибки ErrNotFound / ErrExpired / ErrExhausted / ErrAlreadyUsed
+	Apply(ctx context.Context, botID string, telegramID int64, code string) (*Code, error)
+	// Preview — цена с применённым кодом, для меню (nil — кода нет или он не для этой покупки)
+	Preview(ctx context.Context, botID string, telegramID int64, target string, price float64) (*Discount, error)
+
+	// ==== платёж ====
+
+	// Reserve — привязать применённый код к создаваемому платежу (nil — без скидки)
+	Reserve(ctx context.Context, botID string, telegramID int64, target string, price float64, paymentID string) (*Discount, error)
+	// Redeem — платёж прошёл; redemption возвращается только при первом подтверждении
+	Redeem(ctx context.Context, paymentID string) (*Redemption, error)
+	// Release — платёж не создан или отменён, код возвращается пользователю
+	Release(ctx context.Context, paymentID string) error
+	// ReleaseStale — брошенные оплаты: резерв старше olderThan возвращается пользователю
+	ReleaseStale(ctx context.Context, olderThan time.Duration) error
+	// GetByPayment — применение кода к платежу (для возвратов)
+	GetByPayment(ctx context.Context, paymentID string) (*Redemption, error)
+}
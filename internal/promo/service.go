@@ -0,0 +1,200 @@
+package promo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+type service struct {
+	repo Repo
+}
+
+func NewService(repo Repo) Service {
+	return &service{repo: repo}
+}
+
+// ==================================================
+// ADMIN
+// ==================================================
+
+func (s *service) Create(ctx context.Context, c *Code) error {
+	if err := s.validate(ctx, c); err != nil {
+		return err
+	}
+	return s.repo.Create(ctx, c)
+}
+
+func (s *service) Update(ctx context.Context, c *Code) error {
+	if err := s.validate(ctx, c); err != nil {
+		return err
+	}
+	return s.repo.Update(ctx, c)
+}
+
+// validate — поля и уникальность кода в боте (в БД ещё и unique-индекс)
+func (s *service) validate(ctx context.Context, c *Code) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	existing, err := s.repo.GetByCode(ctx, c.BotID, c.Code)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != c.ID {
+		return ErrDuplicate
+	}
+	return nil
+}
+
+func (s *service) Delete(ctx context.Context, botID string, id int64) error {
+	return s.repo.Delete(ctx, botID, id)
+}
+
+func (s *service) Get(ctx context.Context, botID string, id int64) (*Code, error) {
+	return s.repo.Get(ctx, botID, id)
+}
+
+func (s *service) List(ctx context.Context, botID string) ([]*Code, error) {
+	return s.repo.List(ctx, botID)
+}
+
+// ==================================================
+// BOT
+// ==================================================
+
+func (s *service) Apply(ctx context.Context, botID string, telegramID int64, code string) (*Code, error) {
+	c, err := s.repo.GetByCode(ctx, botID, Normalize(code))
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrNotFound
+	}
+
+	if err := c.usable(time.Now()); err != nil {
+		return nil, err
+	}
+
+	red, err := s.repo.GetRedemption(ctx, c.ID, telegramID)
+	if err != nil {
+		return nil, err
+	}
+	if red != nil && red.Status != RedemptionApplied {
+		return nil, ErrAlreadyUsed
+	}
+
+	if err := s.repo.Apply(ctx, botID, telegramID, c.ID); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[promo] applied bot=%s tg=%d code=%s", botID, telegramID, c.Code)
+	return c, nil
+}
+
+// applied — текущий код пользователя, если он ещё действует и подходит покупке
+func (s *service) applied(ctx context.Context, botID string, telegramID int64, target string) (*Redemption, *Code, error) {
+	red, c, err := s.repo.GetApplied(ctx, botID, telegramID)
+	if err != nil || red == nil {
+		return nil, nil, err
+	}
+	if !c.fits(target) || c.usable(time.Now()) != nil {
+		return nil, nil, nil
+	}
+	return red, c, nil
+}
+
+func (s *service) Preview(ctx context.Context, botID string, telegramID int64, target string, price float64) (*Discount, error) {
+	_, c, err := s.applied(ctx, botID, telegramID, target)
+	if err != nil || c == nil {
+		return nil, err
+	}
+	return c.discount(target, price), nil
+}
+
+// ==================================================
+// PAYMENT
+// ==================================================
+
+func (s *service) Reserve(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	target string,
+	price float64,
+	paymentID string,
+) (*Discount, error) {
+
+	red, c, err := s.applied(ctx, botID, telegramID, target)
+	if err != nil || c == nil {
+		return nil, err
+	}
+
+	d := c.discount(target, price)
+
+	ok, err := s.repo.Reserve(ctx, red.ID, c.ID, paymentID, d)
+	if err != nil {
+		return nil, fmt.Errorf("reserve promo %s: %w", c.Code, err)
+	}
+	if !ok {
+		// лимит разобрали между вводом кода и оплатой — покупка по полной цене
+		log.Printf("[promo] reserve skipped bot=%s tg=%d code=%s: exhausted", botID, telegramID, c.Code)
+		return nil, nil
+	}
+
+	log.Printf("[promo] reserved bot=%s tg=%d code=%s payment=%s price=%.2f→%.2f",
+		botID, telegramID, c.Code, paymentID, price, d.Price)
+
+	return d, nil
+}
+
+func (s *service) Redeem(ctx context.Context, paymentID string) (*Redemption, error) {
+	if paymentID == "" {
+		return nil, nil
+	}
+
+	ok, err := s.repo.MarkRedeemed(ctx, paymentID)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	return s.repo.GetByPayment(ctx, paymentID)
+}
+
+func (s *service) Release(ctx context.Context, paymentID string) error {
+	if paymentID == "" {
+		return nil
+	}
+
+	ok, err := s.repo.Unreserve(ctx, paymentID)
+	if err != nil {
+		return err
+	}
+	if ok {
+		log.Printf("[promo] released payment=%s", paymentID)
+	}
+	return nil
+}
+
+func (s *service) ReleaseStale(ctx context.Context, olderThan time.Duration) error {
+	ids, err := s.repo.ListStaleReserved(ctx, olderThan)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := s.Release(ctx, id); err != nil {
+			log.Printf("[promo] release stale payment=%s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func (s *service) GetByPayment(ctx context.Context, paymentID string) (*Redemption, error) {
+	if paymentID == "" {
+		return nil, nil
+	}
+	return s.repo.GetByPayment(ctx, paymentID)
+}
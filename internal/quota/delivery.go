@@ -0,0 +1,53 @@
+package quota
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	svc Service
+}
+
+func NewHandler(svc Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// GET /quota/{bot_id}/{telegram_id} — расход в текущих сутках и месяце
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	botID := chi.URLParam(r, "bot_id")
+	tgID, err := strconv.ParseInt(chi.URLParam(r, "telegram_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid telegram_id", 400)
+		return
+	}
+
+	items, err := h.svc.Usage(r.Context(), botID, tgID)
+	if err != nil {
+		http.Error(w, "failed to get quota usage", 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// DELETE /quota/{bot_id}/{telegram_id} — обнулить текущие окна
+func (h *Handler) Reset(w http.ResponseWriter, r *http.Request) {
+	botID := chi.URLParam(r, "bot_id")
+	tgID, err := strconv.ParseInt(chi.URLParam(r, "telegram_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid telegram_id", 400)
+		return
+	}
+
+	if err := h.svc.Reset(r.Context(), botID, tgID); err != nil {
+		http.Error(w, "failed to reset quota", 500)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
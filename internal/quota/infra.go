@@ -0,0 +1,115 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type repo struct {
+	db *sql.DB
+}
+
+func NewRepo(db *sql.DB) Repo {
+	return &repo{db: db}
+}
+
+func (r *repo) Consume(ctx context.Context, botID string, telegramID int64, incs []Increment) (*Increment, int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	for i := range incs {
+		inc := &incs[i]
+
+		// upsert держит блокировку строки до конца транзакции —
+		// параллельные запросы одного пользователя не проскочат лимит
+		var used int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO quota_counters (bot_id, telegram_id, metric, window_kind, window_start, used)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (bot_id, telegram_id, metric, window_kind, window_start)
+			DO UPDATE SET
+				used = quota_counters.used + EXCLUDED.used,
+				updated_at = NOW()
+			RETURNING used
+		`, botID, telegramID, inc.Metric, inc.Window, inc.WindowStart, inc.N).Scan(&used)
+		if err != nil {
+			return nil, 0, fmt.Errorf("consume %s/%s: %w", inc.Metric, inc.Window, err)
+		}
+
+		if inc.Limit > 0 && used > inc.Limit {
+			return inc, used - inc.N, nil
+		}
+	}
+
+	return nil, 0, tx.Commit()
+}
+
+func (r *repo) Release(ctx context.Context, botID string, telegramID int64, incs []Increment) error {
+	for _, inc := range incs {
+		_, err := r.db.ExecContext(ctx, `
+			UPDATE quota_counters
+			SET used = GREATEST(used - $6, 0),
+			    updated_at = NOW()
+			WHERE bot_id = $1
+			  AND telegram_id = $2
+			  AND metric = $3
+			  AND window_kind = $4
+			  AND window_start = $5
+		`, botID, telegramID, inc.Metric, inc.Window, inc.WindowStart, inc.N)
+		if err != nil {
+			return fmt.Errorf("release %s/%s: %w", inc.Metric, inc.Window, err)
+		}
+	}
+	return nil
+}
+
+func (r *repo) List(ctx context.Context, botID string, telegramID int64, since time.Time) ([]*Counter, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT bot_id, telegram_id, metric, window_kind, window_start, used
+		FROM quota_counters
+		WHERE bot_id = $1
+		  AND telegram_id = $2
+		  AND window_start >= $3
+		ORDER BY window_kind, metric, window_start
+	`, botID, telegramID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*Counter
+	for rows.Next() {
+		c := &Counter{}
+		if err := rows.Scan(&c.BotID, &c.TelegramID, &c.Metric, &c.Window, &c.WindowStart, &c.Used); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+func (r *repo) Reset(ctx context.Context, botID string, telegramID int64, since time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM quota_counters
+		WHERE bot_id = $1
+		  AND telegram_id = $2
+		  AND window_start >= $3
+	`, botID, telegramID, since)
+	return err
+}
+
+func (r *repo) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM quota_counters
+		WHERE window_start < $1
+	`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
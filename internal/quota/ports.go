@@ -0,0 +1,92 @@
+package quota
+
+import (
+	"context"
+	"time"
+
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+)
+
+// метрики — ключи tariff_plans.features.quotas
+const (
+	MetricMessages = "messages"  // запросы к AI
+	MetricImages   = "images"    // фото и картинки
+	MetricPDFPages = "pdf_pages" // страницы PDF
+)
+
+// окна — сутки и календарный месяц по QUOTA_TZ
+const (
+	WindowDay   = "day"
+	WindowMonth = "month"
+)
+
+// Spend — сколько единиц метрики тратит запрос
+type Spend struct {
+	Metric string
+	N      int
+}
+
+// Counter — расход пользователя в одном окне
+type Counter struct {
+	BotID       string    `json:"bot_id"`
+	TelegramID  int64     `json:"telegram_id"`
+	Metric      string    `json:"metric"`
+	Window      string    `json:"window"`
+	WindowStart time.Time `json:"window_start"`
+	Used        int       `json:"used"`
+	ResetsAt    time.Time `json:"resets_at"`
+}
+
+// Exceeded — окно, в которое запрос не поместился
+type Exceeded struct {
+	Metric   string
+	Window   string
+	Limit    int
+	Used     int // уже потрачено в окне, без текущего запроса
+	Need     int // просил текущий запрос
+	ResetsAt time.Time
+}
+
+// Increment — прибавка к одному окну; Limit 0 — только учёт
+type Increment struct {
+	Metric      string
+	Window      string
+	WindowStart time.Time
+	N           int
+	Limit       int
+}
+
+// Charge — списанное одним запросом. Окна зафиксированы в момент списания:
+// возврат после полуночи уходит в те же сутки, а не в новые.
+type Charge struct {
+	BotID      string
+	TelegramID int64
+	Increments []Increment
+}
+
+type Repo interface {
+	// Consume — все прибавки в одной транзакции. Если какое-то окно переполняется,
+	// ничего не пишется, возвращается это окно и расход в нём до запроса.
+	Consume(ctx context.Context, botID string, telegramID int64, incs []Increment) (denied *Increment, used int, err error)
+	// Release — вернуть прибавки (запрос к AI не состоялся)
+	Release(ctx context.Context, botID string, telegramID int64, incs []Increment) error
+
+	List(ctx context.Context, botID string, telegramID int64, since time.Time) ([]*Counter, error)
+	Reset(ctx context.Context, botID string, telegramID int64, since time.Time) error
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+type Service interface {
+	// Consume — списать расход по лимитам тарифа. Превышение — Exceeded, ничего не списано.
+	// Charge — что списано, для Release (nil — списывать было нечего).
+	Consume(ctx context.Context, botID string, telegramID int64, limits ports.TariffQuotas, spends ...Spend) (*Charge, *Exceeded, error)
+	// Release — вернуть списанное, ошибки только логируются
+	Release(ctx context.Context, c *Charge)
+
+	// Usage — счётчики текущих окон
+	Usage(ctx context.Context, botID string, telegramID int64) ([]*Counter, error)
+	// Reset — обнулить текущие окна (поддержка / админка)
+	Reset(ctx context.Context, botID string, telegramID int64) error
+	// Cleanup — удалить счётчики давно закрытых окон
+	Cleanup(ctx context.Context) error
+}
@@ -0,0 +1,169 @@
+package quota
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+)
+
+// счётчики старше этого удаляются (месячное окно + запас для разборов)
+const retention = 62 * 24 * time.Hour
+
+type service struct {
+	repo Repo
+	loc  *time.Location
+	now  func() time.Time
+}
+
+// NewService — границы окон считаются в QUOTA_TZ (по умолчанию Europe/Moscow)
+func NewService(repo Repo) Service {
+	return &service{
+		repo: repo,
+		loc:  locationFromEnv(),
+		now:  time.Now,
+	}
+}
+
+func locationFromEnv() *time.Location {
+	name := strings.TrimSpace(os.Getenv("QUOTA_TZ"))
+	if name == "" {
+		name = "Europe/Moscow"
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		// в образе может не быть tzdata
+		log.Printf("[quota] load location %q: %v, fallback to UTC+3", name, err)
+		return time.FixedZone("MSK", 3*60*60)
+	}
+	return loc
+}
+
+// window — начало текущего окна и момент сброса
+func (s *service) window(kind string, now time.Time) (time.Time, time.Time) {
+	t := now.In(s.loc)
+	y, m, d := t.Date()
+
+	if kind == WindowMonth {
+		start := time.Date(y, m, 1, 0, 0, 0, 0, s.loc)
+		return start, start.AddDate(0, 1, 0)
+	}
+
+	start := time.Date(y, m, d, 0, 0, 0, 0, s.loc)
+	return start, start.AddDate(0, 0, 1)
+}
+
+func (s *service) increments(limits ports.TariffQuotas, spends []Spend) []Increment {
+	now := s.now()
+
+	var incs []Increment
+	for _, sp := range spends {
+		if sp.N <= 0 {
+			continue
+		}
+		for _, w := range []string{WindowDay, WindowMonth} {
+			start, _ := s.window(w, now)
+			incs = append(incs, Increment{
+				Metric:      sp.Metric,
+				Window:      w,
+				WindowStart: start,
+				N:           sp.N,
+				Limit:       limits.Limit(sp.Metric, w),
+			})
+		}
+	}
+	return incs
+}
+
+func (s *service) Consume(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	limits ports.TariffQuotas,
+	spends ...Spend,
+) (*Charge, *Exceeded, error) {
+
+	incs := s.increments(limits, spends)
+	if len(incs) == 0 {
+		return nil, nil, nil
+	}
+
+	denied, used, err := s.repo.Consume(ctx, botID, telegramID, incs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if denied == nil {
+		return &Charge{BotID: botID, TelegramID: telegramID, Increments: incs}, nil, nil
+	}
+
+	_, resets := s.window(denied.Window, s.now())
+
+	log.Printf(
+		"[quota] exceeded bot=%s tg=%d metric=%s window=%s used=%d need=%d limit=%d",
+		botID, telegramID, denied.Metric, denied.Window, used, denied.N, denied.Limit,
+	)
+
+	return nil, &Exceeded{
+		Metric:   denied.Metric,
+		Window:   denied.Window,
+		Limit:    denied.Limit,
+		Used:     used,
+		Need:     denied.N,
+		ResetsAt: resets,
+	}, nil
+}
+
+func (s *service) Release(ctx context.Context, c *Charge) {
+	if c == nil || len(c.Increments) == 0 {
+		return
+	}
+
+	if err := s.repo.Release(ctx, c.BotID, c.TelegramID, c.Increments); err != nil {
+		log.Printf("[quota] release fail bot=%s tg=%d err=%v", c.BotID, c.TelegramID, err)
+	}
+}
+
+func (s *service) Usage(ctx context.Context, botID string, telegramID int64) ([]*Counter, error) {
+	now := s.now()
+	dayStart, dayReset := s.window(WindowDay, now)
+	monthStart, monthReset := s.window(WindowMonth, now)
+
+	all, err := s.repo.List(ctx, botID, telegramID, monthStart)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []*Counter{}
+	for _, c := range all {
+		switch {
+		case c.Window == WindowDay && c.WindowStart.Equal(dayStart):
+			c.ResetsAt = dayReset
+		case c.Window == WindowMonth && c.WindowStart.Equal(monthStart):
+			c.ResetsAt = monthReset
+		default:
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func (s *service) Reset(ctx context.Context, botID string, telegramID int64) error {
+	monthStart, _ := s.window(WindowMonth, s.now())
+	return s.repo.Reset(ctx, botID, telegramID, monthStart)
+}
+
+func (s *service) Cleanup(ctx context.Context) error {
+	n, err := s.repo.DeleteBefore(ctx, s.now().Add(-retention))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("[quota] cleanup removed=%d", n)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package referral
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Vovarama1992/make_ziper/internal/audit"
+	"github.com/go-chi/chi/v5"
+)
+
+type Handler struct {
+	svc Service
+}
+
+func NewHandler(svc Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// GET /referrals/{bot_id}/settings
+func (h *Handler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	botID := chi.URLParam(r, "bot_id")
+
+	st, err := h.svc.GetSettings(r.Context(), botID)
+	if err != nil {
+		http.Error(w, "failed to get referral settings", 500)
+		return
+	}
+	if st == nil {
+		// не настраивалась — выключена
+		st = &Settings{BotID: botID}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(st)
+}
+
+// PUT /referrals/{bot_id}/settings
+func (h *Handler) SaveSettings(w http.ResponseWriter, r *http.Request) {
+	var st Settings
+	if err := json.NewDecoder(r.Body).Decode(&st); err != nil {
+		http.Error(w, "invalid json", 400)
+		return
+	}
+	st.BotID = chi.URLParam(r, "bot_id")
+
+	if err := st.Validate(); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	if err := h.svc.SaveSettings(r.Context(), &st); err != nil {
+		http.Error(w, "failed to save referral settings", 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(st)
+}
+
+// GET /referrals/{bot_id}/stats — итоги и топ пригласивших
+func (h *Handler) Stats(w http.ResponseWriter, r *http.Request) {
+	st, err := h.svc.Stats(r.Context(), chi.URLParam(r, "bot_id"))
+	if err != nil {
+		http.Error(w, "failed to get referral stats", 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(st)
+}
+
+// AuditSnapshot — настройки программы из пути /referrals/{bot_id}/settings для журнала
+func (h *Handler) AuditSnapshot(r *http.Request, _ string) (*audit.Snapshot, error) {
+	botID := chi.URLParam(r, "bot_id")
+	st, err := h.svc.GetSettings(r.Context(), botID)
+	if err != nil || st == nil {
+		return nil, err
+	}
+	return &audit.Snapshot{EntityID: botID, BotID: botID, Value: st}, nil
+}
@@ -0,0 +1,198 @@
+package referral
+
+import (
+	"context"
+	"database/sql"
+)
+
+type repo struct {
+	db *sql.DB
+}
+
+func NewRepo(db *sql.DB) Repo {
+	return &repo{db: db}
+}
+
+// ==================================================
+// SETTINGS
+// ==================================================
+
+func (r *repo) GetSettings(ctx context.Context, botID string) (*Settings, error) {
+	var s Settings
+	err := r.db.QueryRowContext(ctx, `
+		SELECT bot_id, enabled, bonus_minutes, bonus_days, max_rewards, updated_at
+		FROM referral_settings
+		WHERE bot_id = $1
+	`, botID).Scan(&s.BotID, &s.Enabled, &s.BonusMinutes, &s.BonusDays, &s.MaxRewards, &s.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *repo) SaveSettings(ctx context.Context, s *Settings) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO referral_settings (bot_id, enabled, bonus_minutes, bonus_days, max_rewards)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (bot_id) DO UPDATE SET
+			enabled       = EXCLUDED.enabled,
+			bonus_minutes = EXCLUDED.bonus_minutes,
+			bonus_days    = EXCLUDED.bonus_days,
+			max_rewards   = EXCLUDED.max_rewards,
+			updated_at    = NOW()
+		RETURNING updated_at
+	`, s.BotID, s.Enabled, s.BonusMinutes, s.BonusDays, s.MaxRewards).Scan(&s.UpdatedAt)
+}
+
+// ==================================================
+// REFERRALS
+// ==================================================
+
+func (r *repo) Create(ctx context.Context, ref *Referral) (bool, error) {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO referrals (bot_id, referrer_id, invitee_id, status)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (bot_id, invitee_id) DO NOTHING
+		RETURNING id, created_at
+	`, ref.BotID, ref.ReferrerID, ref.InviteeID, ref.Status).Scan(&ref.ID, &ref.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *repo) GetByInvitee(ctx context.Context, botID string, inviteeID int64) (*Referral, error) {
+	var ref Referral
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, bot_id, referrer_id, invitee_id, status,
+		       payment_id, bonus_minutes, bonus_days,
+		       created_at, rewarded_at
+		FROM referrals
+		WHERE bot_id = $1 AND invitee_id = $2
+	`, botID, inviteeID).Scan(
+		&ref.ID, &ref.BotID, &ref.ReferrerID, &ref.InviteeID, &ref.Status,
+		&ref.PaymentID, &ref.BonusMinutes, &ref.BonusDays,
+		&ref.CreatedAt, &ref.RewardedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ref, nil
+}
+
+func (r *repo) CountRewarded(ctx context.Context, botID string, referrerID int64) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM referrals
+		WHERE bot_id = $1 AND referrer_id = $2 AND status = 'rewarded'
+	`, botID, referrerID).Scan(&n)
+	return n, err
+}
+
+func (r *repo) Complete(
+	ctx context.Context,
+	id int64,
+	status string,
+	paymentID string,
+	minutes float64,
+	days int,
+) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE referrals
+		SET status        = $2,
+		    payment_id    = $3,
+		    bonus_minutes = $4,
+		    bonus_days    = $5,
+		    rewarded_at   = NOW()
+		WHERE id = $1 AND status = 'pending'
+	`, id, status, paymentID, minutes, days)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (r *repo) Revoke(ctx context.Context, botID, paymentID string) (*Referral, error) {
+	var ref Referral
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE referrals
+		SET status = 'revoked'
+		WHERE bot_id = $1 AND payment_id = $2 AND status = 'rewarded'
+		RETURNING id, bot_id, referrer_id, invitee_id, status,
+		          payment_id, bonus_minutes, bonus_days,
+		          created_at, rewarded_at
+	`, botID, paymentID).Scan(
+		&ref.ID, &ref.BotID, &ref.ReferrerID, &ref.InviteeID, &ref.Status,
+		&ref.PaymentID, &ref.BonusMinutes, &ref.BonusDays,
+		&ref.CreatedAt, &ref.RewardedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ref, nil
+}
+
+// ==================================================
+// STATS
+// ==================================================
+
+func (r *repo) Stats(ctx context.Context, botID string, top int) (*Stats, error) {
+	st := Stats{BotID: botID, TopReferrers: []*ReferrerStats{}}
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE status IN ('rewarded', 'limit_reached')),
+		       COUNT(*) FILTER (WHERE status = 'rewarded'),
+		       COALESCE(SUM(bonus_minutes) FILTER (WHERE status = 'rewarded'), 0),
+		       COALESCE(SUM(bonus_days) FILTER (WHERE status = 'rewarded'), 0)
+		FROM referrals
+		WHERE bot_id = $1
+	`, botID).Scan(&st.Invited, &st.Paid, &st.Rewarded, &st.BonusMinutes, &st.BonusDays)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT referrer_id,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE status IN ('rewarded', 'limit_reached')),
+		       COALESCE(SUM(bonus_minutes) FILTER (WHERE status = 'rewarded'), 0),
+		       COALESCE(SUM(bonus_days) FILTER (WHERE status = 'rewarded'), 0)
+		FROM referrals
+		WHERE bot_id = $1
+		GROUP BY referrer_id
+		ORDER BY 3 DESC, 2 DESC, referrer_id
+		LIMIT $2
+	`, botID, top)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rs ReferrerStats
+		if err := rows.Scan(&rs.TelegramID, &rs.Invited, &rs.Paid, &rs.BonusMinutes, &rs.BonusDays); err != nil {
+			return nil, err
+		}
+		st.TopReferrers = append(st.TopReferrers, &rs)
+	}
+
+	return &st, rows.Err()
+}
@@ -0,0 +1,148 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// статусы приглашения
+const (
+	StatusPending      = "pending"       // пришёл по ссылке, ещё не платил
+	StatusRewarded     = "rewarded"      // оплатил, пригласивший получил бонус
+	StatusLimitReached = "limit_reached" // оплатил, но лимит наград пригласившего исчерпан
+	StatusRevoked      = "revoked"       // оплату вернули, бонус пригласившего снят
+)
+
+// префикс deep link: t.me/<bot>?start=ref_<telegram_id>
+const payloadPrefix = "ref_"
+
+// причины, по которым приглашение не засчитано
+var (
+	ErrDisabled        = errors.New("referral programme disabled")
+	ErrSelfReferral    = errors.New("self referral")
+	ErrUnknownReferrer = errors.New("referrer is not a user of this bot")
+	ErrNotNewUser      = errors.New("invitee is not a new user")
+	ErrAlreadyReferred = errors.New("invitee already referred")
+)
+
+// Settings — реферальная программа бота
+type Settings struct {
+	BotID   string `json:"bot_id"`
+	Enabled bool   `json:"enabled"`
+
+	// бонус пригласившему за каждую первую оплату приглашённого
+	BonusMinutes float64 `json:"bonus_minutes"`
+	BonusDays    int     `json:"bonus_days"`
+
+	MaxRewards int `json:"max_rewards"` // наград на одного пригласившего, 0 — без ограничения
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (s *Settings) Validate() error {
+	if s.BotID == "" {
+		return errors.New("bot_id required")
+	}
+	if s.BonusMinutes < 0 || s.BonusDays < 0 || s.MaxRewards < 0 {
+		return errors.New("bonuses and max_rewards must be non-negative")
+	}
+	if s.Enabled && s.BonusMinutes == 0 && s.BonusDays == 0 {
+		return errors.New("bonus_minutes or bonus_days required")
+	}
+	return nil
+}
+
+// Referral — приглашённый пользователь (один пригласивший на пользователя)
+type Referral struct {
+	ID         int64  `json:"id"`
+	BotID      string `json:"bot_id"`
+	ReferrerID int64  `json:"referrer_id"`
+	InviteeID  int64  `json:"invitee_id"`
+	Status     string `json:"status"`
+
+	PaymentID    *string `json:"payment_id"` // первая оплата приглашённого
+	BonusMinutes float64 `json:"bonus_minutes"`
+	BonusDays    int     `json:"bonus_days"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	RewardedAt *time.Time `json:"rewarded_at"`
+}
+
+// Reward — что начислить пригласившему
+type Reward struct {
+	ReferrerID   int64
+	InviteeID    int64
+	BonusMinutes float64
+	BonusDays    int
+}
+
+// ReferrerStats — итоги одного пригласившего
+type ReferrerStats struct {
+	TelegramID   int64   `json:"telegram_id"`
+	Invited      int     `json:"invited"`
+	Paid         int     `json:"paid"`
+	BonusMinutes float64 `json:"bonus_minutes"`
+	BonusDays    int     `json:"bonus_days"`
+}
+
+// Stats — итоги программы по боту
+type Stats struct {
+	BotID        string           `json:"bot_id"`
+	Invited      int              `json:"invited"`
+	Paid         int              `json:"paid"`     // приглашённые, оплатившие подписку
+	Rewarded     int              `json:"rewarded"` // из них с начисленным бонусом
+	BonusMinutes float64          `json:"bonus_minutes"`
+	BonusDays    int              `json:"bonus_days"`
+	TopReferrers []*ReferrerStats `json:"top_referrers"`
+}
+
+// Payload — payload для /start
+func Payload(referrerID int64) string {
+	return payloadPrefix + strconv.FormatInt(referrerID, 10)
+}
+
+// ParsePayload — telegram id пригласившего из payload /start (ok=false — не реферальная ссылка)
+func ParsePayload(payload string) (int64, bool) {
+	if !strings.HasPrefix(payload, payloadPrefix) {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(payload, payloadPrefix), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+type Repo interface {
+	// GetSettings — nil, если программа для бота не настраивалась
+	GetSettings(ctx context.Context, botID string) (*Settings, error)
+	SaveSettings(ctx context.Context, s *Settings) error
+
+	// Create — false, если у приглашённого уже есть пригласивший
+	Create(ctx context.Context, r *Referral) (bool, error)
+	GetByInvitee(ctx context.Context, botID string, inviteeID int64) (*Referral, error)
+	CountRewarded(ctx context.Context, botID string, referrerID int64) (int, error)
+	// Complete — pending → status с итогом первой оплаты (false — уже не pending)
+	Complete(ctx context.Context, id int64, status, paymentID string, minutes float64, days int) (bool, error)
+	// Revoke — rewarded → revoked по оплате приглашённого; nil — по ней бонуса не было (или уже снят)
+	Revoke(ctx context.Context, botID, paymentID string) (*Referral, error)
+
+	Stats(ctx context.Context, botID string, top int) (*Stats, error)
+}
+
+type Service interface {
+	GetSettings(ctx context.Context, botID string) (*Settings, error)
+	SaveSettings(ctx context.Context, s *Settings) error
+
+	// Attach — пользователь пришёл по ссылке пригласившего
+	Attach(ctx context.Context, botID string, referrerID, inviteeID int64) error
+	// Reward — приглашённый оплатил подписку; бонус только за первую оплату (nil — начислять нечего)
+	Reward(ctx context.Context, botID string, inviteeID int64, paymentID string) (*Reward, error)
+	// Revoke — оплату приглашённого вернули: что снять с пригласившего (nil — снимать нечего)
+	Revoke(ctx context.Context, botID, paymentID string) (*Reward, error)
+
+	Stats(ctx context.Context, botID string) (*Stats, error)
+}
@@ -0,0 +1,185 @@
+package referral
+
+import (
+	"context"
+	"log"
+
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+	"github.com/Vovarama1992/make_ziper/internal/trial"
+)
+
+// сколько пригласивших показывать в статистике
+const statsTop = 20
+
+type service struct {
+	repo   Repo
+	subs   ports.SubscriptionRepo
+	trials trial.RepoInf
+}
+
+func NewService(repo Repo, subs ports.SubscriptionRepo, trials trial.RepoInf) Service {
+	return &service{repo: repo, subs: subs, trials: trials}
+}
+
+// ==================================================
+// SETTINGS
+// ==================================================
+
+func (s *service) GetSettings(ctx context.Context, botID string) (*Settings, error) {
+	return s.repo.GetSettings(ctx, botID)
+}
+
+func (s *service) SaveSettings(ctx context.Context, st *Settings) error {
+	if err := st.Validate(); err != nil {
+		return err
+	}
+	return s.repo.SaveSettings(ctx, st)
+}
+
+// ==================================================
+// ATTACH
+// ==================================================
+
+// Attach — засчитывается только новый пользователь (ни подписки, ни триала в этом боте),
+// пришедший по ссылке существующего пользователя. Пригласивший у пользователя один.
+func (s *service) Attach(ctx context.Context, botID string, referrerID, inviteeID int64) error {
+	st, err := s.repo.GetSettings(ctx, botID)
+	if err != nil {
+		return err
+	}
+	if st == nil || !st.Enabled {
+		return ErrDisabled
+	}
+
+	if referrerID == inviteeID {
+		return ErrSelfReferral
+	}
+
+	referrer, err := s.subs.Get(ctx, botID, referrerID)
+	if err != nil {
+		return err
+	}
+	if referrer == nil {
+		return ErrUnknownReferrer
+	}
+
+	isNew, err := s.isNewUser(ctx, botID, inviteeID)
+	if err != nil {
+		return err
+	}
+	if !isNew {
+		return ErrNotNewUser
+	}
+
+	created, err := s.repo.Create(ctx, &Referral{
+		BotID:      botID,
+		ReferrerID: referrerID,
+		InviteeID:  inviteeID,
+		Status:     StatusPending,
+	})
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrAlreadyReferred
+	}
+
+	log.Printf("[referral] attached bot=%s referrer=%d invitee=%d", botID, referrerID, inviteeID)
+	return nil
+}
+
+func (s *service) isNewUser(ctx context.Context, botID string, telegramID int64) (bool, error) {
+	sub, err := s.subs.Get(ctx, botID, telegramID)
+	if err != nil {
+		return false, err
+	}
+	if sub != nil {
+		return false, nil
+	}
+
+	usedTrial, err := s.trials.Exists(ctx, botID, telegramID)
+	if err != nil {
+		return false, err
+	}
+	return !usedTrial, nil
+}
+
+// ==================================================
+// REWARD
+// ==================================================
+
+func (s *service) Reward(ctx context.Context, botID string, inviteeID int64, paymentID string) (*Reward, error) {
+	ref, err := s.repo.GetByInvitee(ctx, botID, inviteeID)
+	if err != nil || ref == nil || ref.Status != StatusPending {
+		return nil, err
+	}
+
+	st, err := s.repo.GetSettings(ctx, botID)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil || !st.Enabled {
+		// программу выключили — приглашение ждёт, вдруг включат снова
+		return nil, nil
+	}
+
+	status := StatusRewarded
+	minutes, days := st.BonusMinutes, st.BonusDays
+
+	if st.MaxRewards > 0 {
+		n, err := s.repo.CountRewarded(ctx, botID, ref.ReferrerID)
+		if err != nil {
+			return nil, err
+		}
+		if n >= st.MaxRewards {
+			status = StatusLimitReached
+			minutes, days = 0, 0
+		}
+	}
+
+	// pending → итог атомарно: повтор Activate второй бонус не даст
+	ok, err := s.repo.Complete(ctx, ref.ID, status, paymentID, minutes, days)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	log.Printf("[referral] %s bot=%s referrer=%d invitee=%d payment=%s minutes=%.0f days=%d",
+		status, botID, ref.ReferrerID, inviteeID, paymentID, minutes, days)
+
+	if status != StatusRewarded {
+		return nil, nil
+	}
+
+	return &Reward{
+		ReferrerID:   ref.ReferrerID,
+		InviteeID:    inviteeID,
+		BonusMinutes: minutes,
+		BonusDays:    days,
+	}, nil
+}
+
+// Revoke — бонус снимается один раз: повтор вебхука возврата получит nil
+func (s *service) Revoke(ctx context.Context, botID, paymentID string) (*Reward, error) {
+	ref, err := s.repo.Revoke(ctx, botID, paymentID)
+	if err != nil || ref == nil {
+		return nil, err
+	}
+
+	log.Printf("[referral] revoked bot=%s referrer=%d invitee=%d payment=%s minutes=%.0f days=%d",
+		botID, ref.ReferrerID, ref.InviteeID, paymentID, ref.BonusMinutes, ref.BonusDays)
+
+	return &Reward{
+		ReferrerID:   ref.ReferrerID,
+		InviteeID:    ref.InviteeID,
+		BonusMinutes: ref.BonusMinutes,
+		BonusDays:    ref.BonusDays,
+	}, nil
+}
+
+// ==================================================
+// STATS
+// ==================================================
+
+func (s *service) Stats(ctx context.Context, botID string) (*Stats, error) {
+	return s.repo.Stats(ctx, botID, statsTop)
+}
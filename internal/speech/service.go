@@ -3,9 +3,11 @@ package speech
 import (
 	"context"
 	"fmt"
+	"unicode/utf8"
 
 	"github.com/Vovarama1992/make_ziper/internal/bots"
 	error_notificator "github.com/Vovarama1992/make_ziper/internal/notificator"
+	"github.com/Vovarama1992/make_ziper/internal/usage"
 )
 
 // === Интерфейсы ===
@@ -25,6 +27,7 @@ type Service struct {
 	tts         ToSpeechClient
 	botsService bots.Service
 	Notifier    error_notificator.Notificator
+	usage       usage.Service
 }
 
 func NewService(
@@ -32,25 +35,45 @@ func NewService(
 	tts ToSpeechClient,
 	botsSvc bots.Service,
 	notifier error_notificator.Notificator,
+	usageSvc usage.Service,
 ) *Service {
 	return &Service{
 		stt:         stt,
 		tts:         tts,
 		botsService: botsSvc,
 		Notifier:    notifier,
+		usage:       usageSvc,
 	}
 }
 
-func (s *Service) Transcribe(ctx context.Context, botID string, filePath string) (string, error) {
+// Transcribe — audioSeconds берём из Telegram (voice.duration), нужен только для учёта расхода
+func (s *Service) Transcribe(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	filePath string,
+	audioSeconds float64,
+) (string, error) {
 	result, err := s.stt.Transcribe(ctx, filePath)
 	if err != nil {
 		s.Notifier.Notify(ctx, botID, err, "Ошибка при транскрипции аудио (ASR)")
 		return "", err
 	}
+
+	s.usage.Record(ctx, &usage.Event{
+		BotID:        botID,
+		TelegramID:   telegramID,
+		Branch:       "voice",
+		Kind:         usage.KindSTT,
+		Provider:     "openai",
+		Model:        "whisper-1",
+		AudioSeconds: audioSeconds,
+	})
+
 	return result, nil
 }
 
-func (s *Service) Synthesize(ctx context.Context, botID string, text, outPath string) error {
+func (s *Service) Synthesize(ctx context.Context, botID string, telegramID int64, text, outPath string) error {
 	cfg, err := s.botsService.Get(ctx, botID)
 	if err != nil {
 		s.Notifier.Notify(ctx, botID, err, "Ошибка загрузки bot_config в Synthesize")
@@ -73,5 +96,15 @@ func (s *Service) Synthesize(ctx context.Context, botID string, text, outPath st
 		return err
 	}
 
+	s.usage.Record(ctx, &usage.Event{
+		BotID:      botID,
+		TelegramID: telegramID,
+		Branch:     "voice",
+		Kind:       usage.KindTTS,
+		Provider:   "elevenlabs",
+		Model:      cfg.VoiceID,
+		TTSChars:   utf8.RuneCountInString(text),
+	})
+
 	return nil
 }
@@ -0,0 +1,242 @@
+package support
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type repo struct {
+	db *sql.DB
+}
+
+func NewRepo(db *sql.DB) Repo {
+	return &repo{db: db}
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+const ticketColumns = `
+	id, bot_id, telegram_id, username, full_name,
+	sub_status, class_grade,
+	channel, status, assignee_id, assignee_name,
+	created_at, updated_at, closed_at
+`
+
+func scanTicket(row rowScanner) (*Ticket, error) {
+	var t Ticket
+	err := row.Scan(
+		&t.ID, &t.BotID, &t.TelegramID, &t.Username, &t.FullName,
+		&t.SubStatus, &t.ClassGrade,
+		&t.Channel, &t.Status, &t.AssigneeID, &t.AssigneeName,
+		&t.CreatedAt, &t.UpdatedAt, &t.ClosedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ==================================================
+// TICKETS
+// ==================================================
+
+func (r *repo) Create(ctx context.Context, t *Ticket) (*Ticket, bool, error) {
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO support_tickets (bot_id, telegram_id, username, full_name, sub_status, class_grade, channel, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (bot_id, telegram_id) WHERE status = 'open' DO NOTHING
+		RETURNING id, created_at, updated_at
+	`,
+		t.BotID, t.TelegramID, t.Username, t.FullName,
+		t.SubStatus, t.ClassGrade, t.Channel, t.Status,
+	).Scan(&t.ID, &t.CreatedAt, &t.UpdatedAt)
+	if err == nil {
+		return t, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, err
+	}
+
+	// параллельное сообщение успело открыть обращение — берём его
+	existing, err := r.GetOpen(ctx, t.BotID, t.TelegramID)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing == nil {
+		return nil, false, fmt.Errorf("open ticket bot=%s tg=%d vanished after conflict", t.BotID, t.TelegramID)
+	}
+	return existing, false, nil
+}
+
+func (r *repo) Get(ctx context.Context, id int64) (*Ticket, error) {
+	return scanTicket(r.db.QueryRowContext(ctx, `
+		SELECT `+ticketColumns+`
+		FROM support_tickets
+		WHERE id = $1
+	`, id))
+}
+
+func (r *repo) GetOpen(ctx context.Context, botID string, telegramID int64) (*Ticket, error) {
+	return scanTicket(r.db.QueryRowContext(ctx, `
+		SELECT `+ticketColumns+`
+		FROM support_tickets
+		WHERE telegram_id = $1
+		  AND status = 'open'
+		  AND ($2 = '' OR bot_id = $2)
+		ORDER BY updated_at DESC
+		LIMIT 1
+	`, telegramID, botID))
+}
+
+func (r *repo) ListOpen(ctx context.Context, limit int) ([]*Ticket, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+ticketColumns+`
+		FROM support_tickets
+		WHERE status = 'open'
+		ORDER BY updated_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanTickets(rows)
+}
+
+func (r *repo) ListOpenByChannel(ctx context.Context, channel string) ([]*Ticket, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+ticketColumns+`
+		FROM support_tickets
+		WHERE status = 'open' AND channel = $1
+	`, channel)
+	if err != nil {
+		return nil, err
+	}
+	return scanTickets(rows)
+}
+
+func scanTickets(rows *sql.Rows) ([]*Ticket, error) {
+	defer rows.Close()
+
+	var out []*Ticket
+	for rows.Next() {
+		t, err := scanTicket(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+func (r *repo) UpdateContext(ctx context.Context, id int64, c Context) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE support_tickets
+		SET bot_id = $2, channel = $3, sub_status = $4, class_grade = $5, updated_at = NOW()
+		WHERE id = $1
+	`, id, c.BotID, c.Channel, c.SubStatus, c.ClassGrade)
+	return err
+}
+
+func (r *repo) Assign(ctx context.Context, id int64, adminID int64, adminName string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE support_tickets
+		SET assignee_id = $2, assignee_name = $3, updated_at = NOW()
+		WHERE id = $1
+	`, id, adminID, adminName)
+	return err
+}
+
+func (r *repo) Close(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE support_tickets
+		SET status = 'closed', closed_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+// ==================================================
+// MESSAGES
+// ==================================================
+
+func (r *repo) AddMessage(ctx context.Context, m *Message) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO support_messages (ticket_id, direction, sender_id, kind, text, file_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, m.TicketID, m.Direction, m.SenderID, m.Kind, m.Text, m.FileID).Scan(&m.ID, &m.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	// свежие обращения — выше в /tickets
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE support_tickets SET updated_at = NOW() WHERE id = $1
+	`, m.TicketID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ListMessages — последние limit сообщений по порядку
+func (r *repo) ListMessages(ctx context.Context, ticketID int64, limit int) ([]*Message, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, ticket_id, direction, sender_id, kind, text, file_id, created_at
+		FROM (
+			SELECT *
+			FROM support_messages
+			WHERE ticket_id = $1
+			ORDER BY id DESC
+			LIMIT $2
+		) last
+		ORDER BY id
+	`, ticketID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*Message
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.ID, &m.TicketID, &m.Direction, &m.SenderID, &m.Kind, &m.Text, &m.FileID, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, &m)
+	}
+	return out, rows.Err()
+}
+
+func (r *repo) LinkAdminMessage(ctx context.Context, ticketID int64, chatID int64, messageID int) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO support_admin_messages (chat_id, message_id, ticket_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (chat_id, message_id) DO NOTHING
+	`, chatID, messageID, ticketID)
+	return err
+}
+
+func (r *repo) TicketByAdminMessage(ctx context.Context, chatID int64, messageID int) (*Ticket, error) {
+	return scanTicket(r.db.QueryRowContext(ctx, `
+		SELECT `+ticketColumns+`
+		FROM support_tickets
+		WHERE id = (
+			SELECT ticket_id
+			FROM support_admin_messages
+			WHERE chat_id = $1 AND message_id = $2
+		)
+	`, chatID, messageID))
+}
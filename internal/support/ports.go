@@ -0,0 +1,150 @@
+package support
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// статусы обращения
+const (
+	StatusOpen   = "open"
+	StatusClosed = "closed"
+)
+
+// кто написал сообщение в обращении
+const (
+	FromUser  = "user"
+	FromAdmin = "admin"
+)
+
+// где пользователь ведёт переписку — туда же уходят ответы
+const (
+	ChannelAdminBot = "admin_bot" // написал в админ-бот
+	ChannelBot      = "bot"       // режим поддержки в самом боте (кнопка «Помощь»)
+)
+
+// виды сообщений (медиа пересылаются как есть, в базе — file_id)
+const (
+	KindText      = "text"
+	KindPhoto     = "photo"
+	KindVoice     = "voice"
+	KindAudio     = "audio"
+	KindVideo     = "video"
+	KindVideoNote = "video_note"
+	KindDocument  = "document"
+	KindAnimation = "animation"
+	KindSticker   = "sticker"
+)
+
+// префикс deep link: t.me/<admin_bot>?start=support_<bot_id>
+const payloadPrefix = "support_"
+
+// payload /start — не длиннее 64 символов из [A-Za-z0-9_-]
+var payloadBotIDRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,56}$`)
+
+var (
+	ErrNotFound = errors.New("ticket not found")
+	ErrClosed   = errors.New("ticket closed")
+)
+
+// Ticket — обращение пользователя в поддержку
+type Ticket struct {
+	ID         int64  `json:"id"`
+	BotID      string `json:"bot_id"` // "" — пришёл в админ-бот не из бота
+	TelegramID int64  `json:"telegram_id"`
+	Username   string `json:"username"`
+	FullName   string `json:"full_name"`
+
+	// контекст на момент обращения
+	SubStatus  string `json:"sub_status"`
+	ClassGrade string `json:"class_grade"`
+
+	Channel      string `json:"channel"` // ChannelAdminBot | ChannelBot
+	Status       string `json:"status"`
+	AssigneeID   *int64 `json:"assignee_id"`
+	AssigneeName string `json:"assignee_name"`
+
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	ClosedAt  *time.Time `json:"closed_at"`
+}
+
+// Context — откуда пришёл пользователь и где пишет
+type Context struct {
+	BotID      string
+	Channel    string
+	SubStatus  string
+	ClassGrade string
+}
+
+// Message — сообщение в обращении
+type Message struct {
+	ID        int64     `json:"id"`
+	TicketID  int64     `json:"ticket_id"`
+	Direction string    `json:"direction"` // FromUser | FromAdmin
+	SenderID  int64     `json:"sender_id"`
+	Kind      string    `json:"kind"`
+	Text      string    `json:"text"` // текст или подпись к медиа
+	FileID    string    `json:"file_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Payload — payload для /start в админ-боте; bot_id не влезает в deep link — просто "support"
+func Payload(botID string) string {
+	if !payloadBotIDRe.MatchString(botID) {
+		return "support"
+	}
+	return payloadPrefix + botID
+}
+
+// ParsePayload — bot_id из payload /start ("" — без бота)
+func ParsePayload(payload string) string {
+	if !strings.HasPrefix(payload, payloadPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(payload, payloadPrefix)
+}
+
+type Repo interface {
+	// Create — новое обращение; если открытое уже есть — оно, created=false
+	Create(ctx context.Context, t *Ticket) (*Ticket, bool, error)
+	Get(ctx context.Context, id int64) (*Ticket, error)
+	// GetOpen — открытое обращение пользователя; botID "" — последнее из любых ботов
+	GetOpen(ctx context.Context, botID string, telegramID int64) (*Ticket, error)
+	ListOpen(ctx context.Context, limit int) ([]*Ticket, error)
+	// ListOpenByChannel — все открытые обращения канала, без лимита
+	ListOpenByChannel(ctx context.Context, channel string) ([]*Ticket, error)
+	UpdateContext(ctx context.Context, id int64, c Context) error
+	Assign(ctx context.Context, id int64, adminID int64, adminName string) error
+	Close(ctx context.Context, id int64) error
+
+	AddMessage(ctx context.Context, m *Message) error
+	ListMessages(ctx context.Context, ticketID int64, limit int) ([]*Message, error)
+
+	// сообщения обращения у админов — чтобы reply находил обращение
+	LinkAdminMessage(ctx context.Context, ticketID int64, chatID int64, messageID int) error
+	TicketByAdminMessage(ctx context.Context, chatID int64, messageID int) (*Ticket, error)
+}
+
+type Service interface {
+	// Open — открытое обращение пользователя или новое (created=true)
+	Open(ctx context.Context, telegramID int64, username, fullName string, c Context) (t *Ticket, created bool, err error)
+	Get(ctx context.Context, id int64) (*Ticket, error)
+	GetOpen(ctx context.Context, botID string, telegramID int64) (*Ticket, error)
+	ListOpen(ctx context.Context) ([]*Ticket, error)
+	// ListOpenInBot — открытые обращения в режиме поддержки ботов (AI там молчит)
+	ListOpenInBot(ctx context.Context) ([]*Ticket, error)
+	History(ctx context.Context, ticketID int64) ([]*Message, error)
+
+	AddMessage(ctx context.Context, m *Message) error
+	LinkAdminMessage(ctx context.Context, ticketID int64, chatID int64, messageID int) error
+	TicketByAdminMessage(ctx context.Context, chatID int64, messageID int) (*Ticket, error)
+
+	// Assign — взять обращение; ErrNotFound / ErrClosed
+	Assign(ctx context.Context, id int64, adminID int64, adminName string) (*Ticket, error)
+	// Close — закрыть обращение; ErrNotFound / ErrClosed
+	Close(ctx context.Context, id int64) (*Ticket, error)
+}
@@ -0,0 +1,151 @@
+package support
+
+import (
+	"context"
+	"log"
+)
+
+const (
+	// сколько открытых обращений показывать в /tickets
+	listOpenLimit = 50
+	// сколько последних сообщений показывать в истории
+	historyLimit = 20
+)
+
+type service struct {
+	repo Repo
+}
+
+func NewService(repo Repo) Service {
+	return &service{repo: repo}
+}
+
+// ==================================================
+// TICKETS
+// ==================================================
+
+func (s *service) Open(ctx context.Context, telegramID int64, username, fullName string, c Context) (*Ticket, bool, error) {
+	t, err := s.repo.GetOpen(ctx, c.BotID, telegramID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if t != nil {
+		// режим поддержки в боте из админ-бота не перехватываем: иначе AI в боте
+		// молча включится, а ответы уйдут туда, где пользователь их не ждёт
+		if t.Channel == ChannelBot && c.Channel == ChannelAdminBot {
+			return t, false, nil
+		}
+
+		// пришёл заново из бота — контекст мог поменяться (оплатил, сменил класс);
+		// написал в другом месте — ответы теперь туда
+		if c.BotID != "" || c.Channel != t.Channel {
+			if c.BotID == "" {
+				c.BotID, c.SubStatus, c.ClassGrade = t.BotID, t.SubStatus, t.ClassGrade
+			}
+			if err := s.repo.UpdateContext(ctx, t.ID, c); err != nil {
+				return nil, false, err
+			}
+			t.BotID, t.Channel, t.SubStatus, t.ClassGrade = c.BotID, c.Channel, c.SubStatus, c.ClassGrade
+		}
+		return t, false, nil
+	}
+
+	t = &Ticket{
+		BotID:      c.BotID,
+		TelegramID: telegramID,
+		Username:   username,
+		FullName:   fullName,
+		Channel:    c.Channel,
+		SubStatus:  c.SubStatus,
+		ClassGrade: c.ClassGrade,
+		Status:     StatusOpen,
+	}
+	t, created, err := s.repo.Create(ctx, t)
+	if err != nil || !created {
+		return t, false, err
+	}
+
+	log.Printf("[support] ticket #%d opened bot=%s tg=%d channel=%s", t.ID, t.BotID, t.TelegramID, t.Channel)
+	return t, true, nil
+}
+
+func (s *service) Get(ctx context.Context, id int64) (*Ticket, error) {
+	return s.repo.Get(ctx, id)
+}
+
+func (s *service) GetOpen(ctx context.Context, botID string, telegramID int64) (*Ticket, error) {
+	return s.repo.GetOpen(ctx, botID, telegramID)
+}
+
+func (s *service) ListOpen(ctx context.Context) ([]*Ticket, error) {
+	return s.repo.ListOpen(ctx, listOpenLimit)
+}
+
+func (s *service) ListOpenInBot(ctx context.Context) ([]*Ticket, error) {
+	return s.repo.ListOpenByChannel(ctx, ChannelBot)
+}
+
+func (s *service) Assign(ctx context.Context, id int64, adminID int64, adminName string) (*Ticket, error) {
+	t, err := s.openTicket(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Assign(ctx, id, adminID, adminName); err != nil {
+		return nil, err
+	}
+	t.AssigneeID, t.AssigneeName = &adminID, adminName
+
+	log.Printf("[support] ticket #%d assigned to admin=%d", id, adminID)
+	return t, nil
+}
+
+func (s *service) Close(ctx context.Context, id int64) (*Ticket, error) {
+	t, err := s.openTicket(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Close(ctx, id); err != nil {
+		return nil, err
+	}
+	t.Status = StatusClosed
+
+	log.Printf("[support] ticket #%d closed", id)
+	return t, nil
+}
+
+func (s *service) openTicket(ctx context.Context, id int64) (*Ticket, error) {
+	t, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrNotFound
+	}
+	if t.Status != StatusOpen {
+		return nil, ErrClosed
+	}
+	return t, nil
+}
+
+// ==================================================
+// MESSAGES
+// ==================================================
+
+func (s *service) AddMessage(ctx context.Context, m *Message) error {
+	return s.repo.AddMessage(ctx, m)
+}
+
+func (s *service) History(ctx context.Context, ticketID int64) ([]*Message, error) {
+	return s.repo.ListMessages(ctx, ticketID, historyLimit)
+}
+
+func (s *service) LinkAdminMessage(ctx context.Context, ticketID int64, chatID int64, messageID int) error {
+	return s.repo.LinkAdminMessage(ctx, ticketID, chatID, messageID)
+}
+
+func (s *service) TicketByAdminMessage(ctx context.Context, chatID int64, messageID int) (*Ticket, error) {
+	return s.repo.TicketByAdminMessage(ctx, chatID, messageID)
+}
@@ -2,16 +2,79 @@ package telegram
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"os"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Vovarama1992/make_ziper/internal/audit"
+	"github.com/Vovarama1992/make_ziper/internal/support"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type AdminBot struct {
-	bot *tgbotapi.BotAPI
-	app *BotApp
+	bot    *tgbotapi.BotAPI
+	app    *BotApp
+	cancel context.CancelFunc
+
+	// бот, из которого пользователь пришёл по /start support_<bot_id>, до первого сообщения.
+	// апдейты админ-бота обрабатываются по одному (см. run) — без мьютекса
+	origin map[int64]adminOrigin
+
+	// админы поддержки (Telegram ID), SUPPORT_ADMIN_IDS
+	admins []int64
+}
+
+// adminOrigin — бот из /start и до какого момента он действует (как supportInput)
+type adminOrigin struct {
+	botID string
+	until time.Time
+}
+
+// setOrigin — запоминает бот до первого сообщения, заодно чистит протухшие
+func (a *AdminBot) setOrigin(userID int64, botID string, now time.Time) {
+	for id, o := range a.origin {
+		if now.After(o.until) {
+			delete(a.origin, id)
+		}
+	}
+	a.origin[userID] = adminOrigin{botID: botID, until: now.Add(supportInputTTL)}
+}
+
+// takeOrigin — бот, из которого пришёл пользователь ("" — не из бота или давно)
+func (a *AdminBot) takeOrigin(userID int64, now time.Time) string {
+	o, ok := a.origin[userID]
+	if !ok || now.After(o.until) {
+		delete(a.origin, userID)
+		return ""
+	}
+	return o.botID
+}
+
+// loadSupportAdmins — SUPPORT_ADMIN_IDS: Telegram ID через запятую
+func loadSupportAdmins() ([]int64, error) {
+	var ids []int64
+	for _, part := range strings.Split(os.Getenv("SUPPORT_ADMIN_IDS"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad SUPPORT_ADMIN_IDS entry %q: %w", part, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (app *BotApp) SetAudit(svc audit.Service) {
+	app.Audit = svc
 }
 
 // ==================================================
@@ -23,6 +86,18 @@ func (app *BotApp) InitAdminBot(ctx context.Context, token string) error {
 		log.Println("[admin-bot] token is empty, bot disabled")
 		return nil
 	}
+	if app.Support == nil {
+		log.Println("[admin-bot] support service is not set, bot disabled")
+		return nil
+	}
+
+	admins, err := loadSupportAdmins()
+	if err != nil {
+		return err
+	}
+	if len(admins) == 0 {
+		log.Println("[admin-bot] SUPPORT_ADMIN_IDS is empty, tickets will have no recipients")
+	}
 
 	bot, err := tgbotapi.NewBotAPI(token)
 	if err != nil {
@@ -31,27 +106,50 @@ func (app *BotApp) InitAdminBot(ctx context.Context, token string) error {
 
 	log.Printf("[admin-bot] started: @%s", bot.Self.UserName)
 
+	updates, err := app.updatesChan(adminBotID, bot, 60)
+	if err != nil {
+		return err
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+
 	admin := &AdminBot{
-		bot: bot,
-		app: app,
+		bot:    bot,
+		app:    app,
+		cancel: cancel,
+		origin: make(map[int64]adminOrigin),
+		admins: admins,
 	}
 
+	// воркеры ботов уже читают adminBot (режим поддержки) — пишем под мьютексом реестра
+	app.botsMu.Lock()
 	app.adminBot = admin
+	app.botsMu.Unlock()
 
-	go admin.run(ctx)
+	// режим поддержки в ботах переживает перезапуск
+	open, err := app.Support.ListOpenInBot(ctx)
+	if err != nil {
+		log.Printf("[admin-bot] load open in-bot tickets: %v", err)
+	}
+	app.supportSessions.Load(open)
+
+	app.goLoop(func() { admin.run(runCtx, updates) })
 	return nil
 }
 
+func (app *BotApp) getAdminBot() *AdminBot {
+	app.botsMu.RLock()
+	defer app.botsMu.RUnlock()
+
+	return app.adminBot
+}
+
 // ==================================================
 // MAIN LOOP
 // ==================================================
 
-func (a *AdminBot) run(ctx context.Context) {
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-
-	updates := a.bot.GetUpdatesChan(u)
-	log.Println("[admin-bot] polling started")
+func (a *AdminBot) run(ctx context.Context, updates tgbotapi.UpdatesChannel) {
+	log.Printf("[admin-bot] %s started", a.app.updates.mode)
 
 	for {
 		select {
@@ -59,9 +157,13 @@ func (a *AdminBot) run(ctx context.Context) {
 			log.Println("[admin-bot] context cancelled, stopping")
 			return
 
-		case upd := <-updates:
+		case upd, ok := <-updates:
+			if !ok {
+				log.Println("[admin-bot] updates closed, stopping")
+				return
+			}
 			if upd.Message != nil {
-				a.handleMessage(upd.Message)
+				a.handleMessage(ctx, upd.Message)
 			}
 		}
 	}
@@ -71,77 +173,221 @@ func (a *AdminBot) run(ctx context.Context) {
 // ROLE CHECK
 // ==================================================
 
-func isAdmin(userID int64) bool {
-	switch userID {
-	case 1139929360, 6789440333:
-		return true
-	default:
-		return false
+func (a *AdminBot) isAdmin(userID int64) bool {
+	for _, id := range a.admins {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *AdminBot) handleMessage(ctx context.Context, msg *tgbotapi.Message) {
+	if msg.From == nil {
+		return
 	}
+
+	log.Printf(
+		"[admin-bot] incoming from=%d text=%q reply=%v",
+		msg.From.ID,
+		msg.Text,
+		msg.ReplyToMessage != nil,
+	)
+
+	if a.isAdmin(msg.From.ID) {
+		a.handleAdmin(ctx, msg)
+		return
+	}
+	a.handleUser(ctx, msg)
 }
 
 // ==================================================
 // USER → ADMIN
 // ==================================================
 
-func (a *AdminBot) forwardToAdmins(userID int64, text string) {
-	admins := []int64{
-		1139929360,
-		6789440333,
+func (a *AdminBot) handleUser(ctx context.Context, msg *tgbotapi.Message) {
+	userID := msg.From.ID
+
+	if msg.Text == "/start" || strings.HasPrefix(msg.Text, "/start ") {
+		payload := strings.TrimSpace(strings.TrimPrefix(msg.Text, "/start"))
+		a.setOrigin(userID, support.ParsePayload(payload), time.Now())
+
+		a.bot.Send(tgbotapi.NewMessage(msg.Chat.ID,
+			"👋 Это поддержка.\n"+
+				"Опиши проблему — можно несколькими сообщениями, с фото, скриншотом или голосовым."))
+		return
 	}
 
-	for _, adminID := range admins {
-		log.Printf(
-			"[admin-bot] forward user=%d → admin=%d",
-			userID,
-			adminID,
-		)
+	// контекст (подписка, класс) собираем, только когда пользователь пришёл из бота
+	botID := a.takeOrigin(userID, time.Now())
+	c := support.Context{BotID: botID}
+	if botID != "" {
+		c = a.app.supportContext(ctx, botID, userID)
+	}
+	c.Channel = support.ChannelAdminBot
 
-		msg := tgbotapi.NewMessage(
-			adminID,
-			text,
-		)
-		msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true}
+	t, created, err := a.app.Support.Open(ctx, userID, msg.From.UserName, fullName(msg.From), c)
+	if err != nil {
+		a.app.ErrorNotify.Notify(ctx, adminBotID, err, "Ошибка открытия обращения в поддержку")
+		a.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "⚠️ Не удалось отправить сообщение. Попробуй позже."))
+		return
+	}
+	delete(a.origin, userID)
+
+	a.fromUser(ctx, t, created, a.bot, msg)
+
+	switch {
+	case created:
+		a.bot.Send(tgbotapi.NewMessage(msg.Chat.ID,
+			fmt.Sprintf("✅ Обращение #%d создано. Ответ придёт сюда.", t.ID)))
+	case t.Channel == support.ChannelBot:
+		a.bot.Send(tgbotapi.NewMessage(msg.Chat.ID,
+			fmt.Sprintf("ℹ️ Сообщение добавлено к обращению #%d. Оно открыто в боте — ответ придёт туда.", t.ID)))
+	}
+}
 
-		if _, err := a.bot.Send(msg); err != nil {
-			log.Printf(
-				"[admin-bot] failed to forward user=%d → admin=%d err=%v",
-				userID,
-				adminID,
-				err,
-			)
+// fromTenant — сообщение из режима поддержки в боте botID (см. support.go).
+// t — уже открытое в этом боте обращение или nil.
+func (a *AdminBot) fromTenant(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	msg *tgbotapi.Message,
+	t *support.Ticket,
+) (*support.Ticket, bool, error) {
+	created := false
+	if t == nil {
+		c := a.app.supportContext(ctx, botID, msg.From.ID)
+		c.Channel = support.ChannelBot
+
+		var err error
+		t, created, err = a.app.Support.Open(ctx, msg.From.ID, msg.From.UserName, fullName(msg.From), c)
+		if err != nil {
+			return nil, false, err
 		}
 	}
+
+	a.fromUser(ctx, t, created, bot, msg)
+	return t, created, nil
 }
 
-// ==================================================
-// ADMIN MESSAGE HANDLER
-// ==================================================
+// fromUser — сообщение пользователя в историю и админам; новое обращение — с карточкой
+func (a *AdminBot) fromUser(ctx context.Context, t *support.Ticket, created bool, from *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	kind, text, fileID := supportMessage(msg)
+	m := &support.Message{
+		TicketID:  t.ID,
+		Direction: support.FromUser,
+		SenderID:  msg.From.ID,
+		Kind:      kind,
+		Text:      text,
+		FileID:    fileID,
+	}
+	if err := a.app.Support.AddMessage(ctx, m); err != nil {
+		// сообщение всё равно доставим админам
+		a.app.ErrorNotify.Notify(ctx, adminBotID, err, fmt.Sprintf("Ошибка сохранения сообщения обращения #%d", t.ID))
+	}
 
-func (a *AdminBot) handleMessage(msg *tgbotapi.Message) {
-	fromID := msg.From.ID
+	if created {
+		a.sendCard(ctx, t, a.recipients(t))
+	}
+	a.forwardToAdmins(ctx, t, from, msg)
+}
 
-	log.Printf(
-		"[admin-bot] incoming from=%d text=%q reply=%v",
-		fromID,
-		msg.Text,
-		msg.ReplyToMessage != nil,
-	)
+// recipients — назначенный админ или все, пока обращение никто не взял
+func (a *AdminBot) recipients(t *support.Ticket) []int64 {
+	if t.AssigneeID != nil {
+		return []int64{*t.AssigneeID}
+	}
+	return a.admins
+}
 
-	// ===============================
-	// USER → ADMIN
-	// ===============================
-	if !isAdmin(fromID) {
-		log.Printf(
-			"[admin-bot] user message user=%d → forward to admins",
-			fromID,
-		)
+// forwardToAdmins — копия сообщения пользователя (с медиа) каждому получателю.
+// Из бота пользователя сообщение переносится один раз — первому админу,
+// остальным копируется уже внутри админ-бота (без повторной загрузки файла).
+func (a *AdminBot) forwardToAdmins(ctx context.Context, t *support.Ticket, from *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	header := fmt.Sprintf("💬 #%d от %s", t.ID, ticketUser(t))
+
+	var srcChat int64
+	var srcID int
+
+	for _, adminID := range a.recipients(t) {
+		log.Printf("[admin-bot] forward ticket=%d user=%d → admin=%d", t.ID, t.TelegramID, adminID)
+
+		var sentID int
+		var err error
+		if srcID != 0 {
+			cp := tgbotapi.NewCopyMessage(adminID, srcChat, srcID)
+			cp.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true}
+			var sent tgbotapi.MessageID
+			sent, err = a.bot.CopyMessage(cp)
+			sentID = sent.MessageID
+		} else {
+			sentID, err = deliver(ctx, a.bot, adminID, from, msg, header, tgbotapi.ForceReply{ForceReply: true})
+			if err == nil {
+				srcChat, srcID = adminID, sentID
+			}
+		}
+		if err != nil {
+			log.Printf("[admin-bot] failed to forward ticket=%d → admin=%d err=%v", t.ID, adminID, err)
+			continue
+		}
 
-		text := "🆘 Помощь\n" +
-			"UserID: " + strconv.FormatInt(fromID, 10) + "\n\n" +
-			msg.Text
+		a.link(ctx, t.ID, adminID, sentID)
+	}
+}
 
-		a.forwardToAdmins(fromID, text)
+// sendCard — карточка обращения: кто, откуда, подписка, класс
+func (a *AdminBot) sendCard(ctx context.Context, t *support.Ticket, chats []int64) {
+	for _, chatID := range chats {
+		sent, err := a.bot.Send(tgbotapi.NewMessage(chatID, ticketCard(t)))
+		if err != nil {
+			log.Printf("[admin-bot] failed to send card ticket=%d → admin=%d err=%v", t.ID, chatID, err)
+			continue
+		}
+		a.link(ctx, t.ID, chatID, sent.MessageID)
+	}
+}
+
+// link — запоминаем сообщение у админа, чтобы reply на него попал в обращение
+func (a *AdminBot) link(ctx context.Context, ticketID int64, chatID int64, messageID int) {
+	if err := a.app.Support.LinkAdminMessage(ctx, ticketID, chatID, messageID); err != nil {
+		log.Printf("[admin-bot] link ticket=%d chat=%d msg=%d err=%v", ticketID, chatID, messageID, err)
+	}
+}
+
+// ==================================================
+// ADMIN MESSAGE HANDLER
+// ==================================================
+
+func (a *AdminBot) handleAdmin(ctx context.Context, msg *tgbotapi.Message) {
+	cmd, arg := msg.Command(), strings.TrimSpace(msg.CommandArguments())
+
+	switch cmd {
+	case "start", "help":
+		a.reply(msg, "👋 Это бот поддержки.\n\n"+
+			"Отвечай reply на сообщения обращения — текстом, фото, голосом или файлом.\n\n"+
+			"/tickets — открытые обращения\n"+
+			"/ticket N — карточка и история\n"+
+			"/take N — взять обращение (или reply)\n"+
+			"/close N — закрыть обращение (или reply)")
+		return
+	case "tickets":
+		a.listTickets(ctx, msg)
+		return
+	case "ticket":
+		if t := a.ticketArg(ctx, msg, arg); t != nil {
+			a.showTicket(ctx, msg, t)
+		}
+		return
+	case "take":
+		if t := a.ticketArg(ctx, msg, arg); t != nil {
+			a.takeTicket(ctx, msg, t)
+		}
+		return
+	case "close":
+		if t := a.ticketArg(ctx, msg, arg); t != nil {
+			a.closeTicket(ctx, msg, t)
+		}
 		return
 	}
 
@@ -149,64 +395,365 @@ func (a *AdminBot) handleMessage(msg *tgbotapi.Message) {
 	// ADMIN → USER
 	// ===============================
 
-	if msg.Text == "/start" {
-		a.bot.Send(tgbotapi.NewMessage(
-			msg.Chat.ID,
-			"👋 Это бот поддержки. Отвечай reply на сообщения пользователей.",
-		))
+	if msg.ReplyToMessage == nil {
+		a.reply(msg, "❗ Ответь reply на сообщение обращения. Список: /tickets")
 		return
 	}
 
-	if msg.ReplyToMessage == nil {
-		a.bot.Send(tgbotapi.NewMessage(
-			msg.Chat.ID,
-			"❗ Ответь reply на сообщение пользователя.",
-		))
+	t := a.ticketByReply(ctx, msg)
+	if t == nil {
+		a.reply(msg, "❗ Не удалось определить обращение.")
+		return
+	}
+	if t.Status != support.StatusOpen {
+		a.reply(msg, fmt.Sprintf("❗ Обращение #%d закрыто.", t.ID))
+		return
+	}
+
+	log.Printf("[admin-bot] reply admin=%d → ticket=%d user=%d", msg.From.ID, t.ID, t.TelegramID)
+
+	kind, text, fileID := supportMessage(msg)
+	if err := a.sendToUser(ctx, t, msg); err != nil {
+		log.Printf("[admin-bot] failed to send reply admin=%d → user=%d err=%v", msg.From.ID, t.TelegramID, err)
+		a.reply(msg, "⚠️ Не удалось доставить ответ пользователю.")
 		return
 	}
 
-	targetUserID, ok := extractUserID(msg.ReplyToMessage.Text)
+	m := &support.Message{
+		TicketID:  t.ID,
+		Direction: support.FromAdmin,
+		SenderID:  msg.From.ID,
+		Kind:      kind,
+		Text:      text,
+		FileID:    fileID,
+	}
+	if err := a.app.Support.AddMessage(ctx, m); err != nil {
+		a.app.ErrorNotify.Notify(ctx, adminBotID, err, fmt.Sprintf("Ошибка сохранения ответа в обращении #%d", t.ID))
+	}
+
+	// первый ответивший берёт обращение
+	if t.AssigneeID == nil {
+		if _, err := a.app.Support.Assign(ctx, t.ID, msg.From.ID, adminName(msg.From)); err != nil {
+			log.Printf("[admin-bot] auto-assign ticket=%d admin=%d err=%v", t.ID, msg.From.ID, err)
+		}
+	}
+
+	a.audit(msg, "support.reply", t, map[string]any{"kind": kind, "text": text})
+
+	a.reply(msg, fmt.Sprintf("✅ Ответ отправлен (#%d).", t.ID))
+}
+
+func (a *AdminBot) sendToUser(ctx context.Context, t *support.Ticket, msg *tgbotapi.Message) error {
+	bot, err := a.userBot(t)
+	if err != nil {
+		return err
+	}
+
+	_, err = deliver(ctx, bot, t.TelegramID, a.bot, msg, "💬 Ответ поддержки:", nil)
+	return err
+}
+
+// userBot — через какого бота писать пользователю: туда, где он ведёт переписку
+func (a *AdminBot) userBot(t *support.Ticket) (*tgbotapi.BotAPI, error) {
+	if t.Channel != support.ChannelBot {
+		return a.bot, nil
+	}
+
+	bot, ok := a.app.GetBots()[t.BotID]
+	if !ok || bot == nil {
+		return nil, fmt.Errorf("bot %s is not running", t.BotID)
+	}
+	return bot, nil
+}
+
+// closedByUser — пользователь сам вышел из режима поддержки
+func (a *AdminBot) closedByUser(t *support.Ticket) {
+	for _, adminID := range a.recipients(t) {
+		a.bot.Send(tgbotapi.NewMessage(adminID,
+			fmt.Sprintf("🔒 Обращение #%d закрыто пользователем (%s).", t.ID, ticketUser(t))))
+	}
+}
+
+// ticketByReply — обращение по сообщению, на которое ответил админ.
+// Сообщения до появления обращений — по строке "UserID: N".
+func (a *AdminBot) ticketByReply(ctx context.Context, msg *tgbotapi.Message) *support.Ticket {
+	reply := msg.ReplyToMessage
+
+	t, err := a.app.Support.TicketByAdminMessage(ctx, msg.Chat.ID, reply.MessageID)
+	if err != nil {
+		log.Printf("[admin-bot] ticket by message chat=%d msg=%d err=%v", msg.Chat.ID, reply.MessageID, err)
+		return nil
+	}
+	if t != nil {
+		return t
+	}
+
+	userID, ok := extractUserID(joinNonEmpty(reply.Text, reply.Caption))
 	if !ok {
-		log.Printf(
-			"[admin-bot] failed to extract userID from reply text=%q",
-			msg.ReplyToMessage.Text,
-		)
-
-		a.bot.Send(tgbotapi.NewMessage(
-			msg.Chat.ID,
-			"❗ Не удалось определить пользователя.",
+		return nil
+	}
+	t, err = a.app.Support.GetOpen(ctx, "", userID)
+	if err != nil {
+		log.Printf("[admin-bot] open ticket user=%d err=%v", userID, err)
+		return nil
+	}
+	return t
+}
+
+// ticketArg — обращение из аргумента команды ("/close 12") или из reply
+func (a *AdminBot) ticketArg(ctx context.Context, msg *tgbotapi.Message, arg string) *support.Ticket {
+	if arg == "" {
+		if msg.ReplyToMessage == nil {
+			a.reply(msg, "❗ Укажи номер обращения или ответь reply на его сообщение.")
+			return nil
+		}
+		t := a.ticketByReply(ctx, msg)
+		if t == nil {
+			a.reply(msg, "❗ Не удалось определить обращение.")
+		}
+		return t
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(arg, "#"), 10, 64)
+	if err != nil {
+		a.reply(msg, "❗ Номер обращения — число, например /close 12")
+		return nil
+	}
+
+	t, err := a.app.Support.Get(ctx, id)
+	if err != nil {
+		a.app.ErrorNotify.Notify(ctx, adminBotID, err, fmt.Sprintf("Ошибка загрузки обращения #%d", id))
+		a.reply(msg, "⚠️ Не удалось загрузить обращение.")
+		return nil
+	}
+	if t == nil {
+		a.reply(msg, fmt.Sprintf("❗ Обращение #%d не найдено.", id))
+		return nil
+	}
+	return t
+}
+
+// ==================================================
+// COMMANDS
+// ==================================================
+
+func (a *AdminBot) listTickets(ctx context.Context, msg *tgbotapi.Message) {
+	tickets, err := a.app.Support.ListOpen(ctx)
+	if err != nil {
+		a.app.ErrorNotify.Notify(ctx, adminBotID, err, "Ошибка загрузки обращений")
+		a.reply(msg, "⚠️ Не удалось загрузить обращения.")
+		return
+	}
+	if len(tickets) == 0 {
+		a.reply(msg, "✅ Открытых обращений нет.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📋 Открытые обращения: %d\n", len(tickets)))
+	for _, t := range tickets {
+		assignee := "никто"
+		if t.AssigneeID != nil {
+			assignee = t.AssigneeName
+		}
+		sb.WriteString(fmt.Sprintf(
+			"\n#%d · %s · бот %s · %s · %s",
+			t.ID, ticketUser(t), orDash(t.BotID), assignee, t.UpdatedAt.Format("02.01 15:04"),
 		))
+	}
+	sb.WriteString("\n\n/ticket N — карточка и история")
+
+	a.reply(msg, sb.String())
+}
+
+func (a *AdminBot) showTicket(ctx context.Context, msg *tgbotapi.Message, t *support.Ticket) {
+	a.sendCard(ctx, t, []int64{msg.Chat.ID})
+
+	history, err := a.app.Support.History(ctx, t.ID)
+	if err != nil {
+		a.app.ErrorNotify.Notify(ctx, adminBotID, err, fmt.Sprintf("Ошибка загрузки истории обращения #%d", t.ID))
+		return
+	}
+	if len(history) == 0 {
 		return
 	}
 
-	log.Printf(
-		"[admin-bot] reply admin=%d → user=%d",
-		fromID,
-		targetUserID,
-	)
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🧵 История #%d (последние %d):\n", t.ID, len(history)))
+	for _, m := range history {
+		who := "👤"
+		if m.Direction == support.FromAdmin {
+			who = "🛟"
+		}
+		line := m.Text
+		if m.Kind != support.KindText {
+			line = joinNonEmpty("["+m.Kind+"]", m.Text)
+		}
+		sb.WriteString(fmt.Sprintf("\n%s %s %s", m.CreatedAt.Format("02.01 15:04"), who, line))
+	}
+
+	sent, err := a.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, truncateRunes(sb.String(), 4000)))
+	if err == nil {
+		a.link(ctx, t.ID, msg.Chat.ID, sent.MessageID)
+	}
+}
+
+func (a *AdminBot) takeTicket(ctx context.Context, msg *tgbotapi.Message, t *support.Ticket) {
+	t, err := a.app.Support.Assign(ctx, t.ID, msg.From.ID, adminName(msg.From))
+	if !a.ticketOpErr(ctx, msg, t, err) {
+		return
+	}
 
-	reply := "💬 Ответ поддержки:\n\n" + msg.Text
+	a.audit(msg, "support.assign", t, map[string]any{"assignee_id": msg.From.ID})
+	a.reply(msg, fmt.Sprintf("🙋 Обращение #%d за тобой. Новые сообщения будут приходить только тебе.", t.ID))
+}
 
-	if _, err := a.bot.Send(tgbotapi.NewMessage(targetUserID, reply)); err != nil {
-		log.Printf(
-			"[admin-bot] failed to send reply admin=%d → user=%d err=%v",
-			fromID,
-			targetUserID,
-			err,
-		)
+func (a *AdminBot) closeTicket(ctx context.Context, msg *tgbotapi.Message, t *support.Ticket) {
+	id := t.ID
+	t, err := a.app.Support.Close(ctx, id)
+	if !a.ticketOpErr(ctx, msg, t, err) {
 		return
 	}
 
-	a.bot.Send(tgbotapi.NewMessage(
-		msg.Chat.ID,
-		"✅ Ответ отправлен пользователю.",
-	))
+	text := fmt.Sprintf("✅ Обращение #%d закрыто. Если вопрос остался — просто напиши сюда снова.", t.ID)
+	if t.Channel == support.ChannelBot {
+		a.app.supportSessions.Remove(t.BotID, t.TelegramID)
+		text = fmt.Sprintf("✅ Обращение #%d закрыто, можно продолжать занятия. Если вопрос остался — снова нажми «❓ Помощь».", t.ID)
+	}
+	if bot, err := a.userBot(t); err == nil {
+		bot.Send(tgbotapi.NewMessage(t.TelegramID, text))
+	}
+
+	a.audit(msg, "support.close", t, map[string]any{"status": t.Status})
+	a.reply(msg, fmt.Sprintf("🔒 Обращение #%d закрыто.", t.ID))
+}
+
+// ticketOpErr — ответ админу на ошибку взятия/закрытия; true — ошибки нет
+func (a *AdminBot) ticketOpErr(ctx context.Context, msg *tgbotapi.Message, t *support.Ticket, err error) bool {
+	switch {
+	case err == nil && t != nil:
+		return true
+	case errors.Is(err, support.ErrClosed):
+		a.reply(msg, "❗ Обращение уже закрыто.")
+	case errors.Is(err, support.ErrNotFound):
+		a.reply(msg, "❗ Обращение не найдено.")
+	default:
+		a.app.ErrorNotify.Notify(ctx, adminBotID, err, "Ошибка изменения обращения")
+		a.reply(msg, "⚠️ Не удалось изменить обращение.")
+	}
+	return false
+}
+
+func (a *AdminBot) reply(msg *tgbotapi.Message, text string) {
+	a.bot.Send(tgbotapi.NewMessage(msg.Chat.ID, text))
+}
+
+// audit — действия поддержки в журнал изменений
+func (a *AdminBot) audit(msg *tgbotapi.Message, action string, t *support.Ticket, after map[string]any) {
+	if a.app.Audit == nil {
+		return
+	}
+
+	after["telegram_id"] = t.TelegramID
+	raw, _ := json.Marshal(after)
+
+	e := &audit.Entry{
+		ActorType: audit.ActorAdminBot,
+		ActorID:   strconv.FormatInt(msg.From.ID, 10),
+		ActorName: msg.From.UserName,
+		BotID:     t.BotID,
+		Action:    action,
+		Entity:    "support_ticket",
+		EntityID:  strconv.FormatInt(t.ID, 10),
+		After:     raw,
+	}
+	if err := a.app.Audit.Record(context.Background(), e); err != nil {
+		log.Printf("[admin-bot] audit %s admin=%d ticket=%d err=%v", action, msg.From.ID, t.ID, err)
+	}
 }
 
 // ==================================================
 // UTILS
 // ==================================================
 
+func ticketCard(t *support.Ticket) string {
+	assignee := "никто"
+	if t.AssigneeID != nil {
+		assignee = t.AssigneeName
+	}
+	status := "открыто"
+	if t.Status != support.StatusOpen {
+		status = "закрыто"
+	}
+	channel := "в админ-боте"
+	if t.Channel == support.ChannelBot {
+		channel = "в боте"
+	}
+
+	return fmt.Sprintf(
+		"🆘 Обращение #%d (%s)\n"+
+			"UserID: %d\n"+
+			"Пользователь: %s\n"+
+			"Бот: %s (пишет %s)\n"+
+			"Подписка: %s\n"+
+			"Класс: %s\n"+
+			"Взял: %s\n\n"+
+			"Reply на сообщения обращения — ответ пользователю.\n"+
+			"/take %d — взять, /close %d — закрыть",
+		t.ID, status,
+		t.TelegramID,
+		joinNonEmpty(ticketUser(t), t.FullName),
+		orDash(t.BotID), channel,
+		orDash(t.SubStatus),
+		orDash(t.ClassGrade),
+		assignee,
+		t.ID, t.ID,
+	)
+}
+
+func ticketUser(t *support.Ticket) string {
+	if t.Username != "" {
+		return "@" + t.Username
+	}
+	return strconv.FormatInt(t.TelegramID, 10)
+}
+
+func fullName(u *tgbotapi.User) string {
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+func adminName(u *tgbotapi.User) string {
+	if u.UserName != "" {
+		return "@" + u.UserName
+	}
+	return fullName(u)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return s
+}
+
+func joinNonEmpty(head, tail string) string {
+	if tail == "" {
+		return head
+	}
+	if head == "" {
+		return tail
+	}
+	return head + "\n\n" + tail
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
 func extractUserID(text string) (int64, bool) {
 	re := regexp.MustCompile(`UserID:\s*(\d+)`)
 	m := re.FindStringSubmatch(text)
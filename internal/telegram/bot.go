@@ -8,57 +8,134 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/Vovarama1992/make_ziper/internal/support"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-// runBotLoop — главный цикл получения апдейтов
-func (app *BotApp) runBotLoop(botID string, bot *tgbotapi.BotAPI) {
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 30
-
-	updates := bot.GetUpdatesChan(u)
+// runBotLoop — главный цикл получения апдейтов.
+// Сам цикл только читает апдейты, обработка уходит в воркеры пользователей.
+// Выходит, когда бот остановлен через реестр (ctx) или канал апдейтов закрыт.
+func (app *BotApp) runBotLoop(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	updates tgbotapi.UpdatesChannel,
+) {
 	log.Printf("[bot_loop] started botID=%s username=@%s", botID, bot.Self.UserName)
 
-	for update := range updates {
+	dispatcher := newUpdateDispatcher(
+		botID,
+		botMaxWorkers,
+		func(tgID int64, update tgbotapi.Update) {
+			app.processUpdate(context.Background(), botID, bot, tgID, update)
+		},
+	)
+	defer dispatcher.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// апдейты в канале Telegram уже считает доставленными — отдаём их воркерам
+			// до dispatcher.Wait(), иначе сообщения пропадут
+			n := app.drainUpdates(botID, bot, dispatcher, updates)
+			log.Printf("[bot_loop] stopped botID=%s drained=%d", botID, n)
+			return
 
-		var fromID int64
-		switch {
-		case update.Message != nil && update.Message.From != nil:
-			fromID = update.Message.From.ID
-		case update.CallbackQuery != nil && update.CallbackQuery.From != nil:
-			fromID = update.CallbackQuery.From.ID
+		case upd, ok := <-updates:
+			if !ok {
+				log.Printf("[bot_loop] updates closed botID=%s", botID)
+				return
+			}
+			app.enqueueUpdate(botID, bot, dispatcher, upd)
 		}
+	}
+}
 
-		if fromID != 0 {
-			log.Printf(
-				"[bot_touch] botID=%s fromTG=%d updateID=%d",
-				botID,
-				fromID,
-				update.UpdateID,
-			)
+// drainUpdates — без блокировки разбирает то, что уже лежит в канале
+func (app *BotApp) drainUpdates(
+	botID string,
+	bot *tgbotapi.BotAPI,
+	dispatcher *updateDispatcher,
+	updates tgbotapi.UpdatesChannel,
+) int {
+	n := 0
+	for {
+		select {
+		case upd, ok := <-updates:
+			if !ok {
+				return n
+			}
+			app.enqueueUpdate(botID, bot, dispatcher, upd)
+			n++
+		default:
+			return n
 		}
+	}
+}
+
+// enqueueUpdate — флуд-контроль и передача апдейта в воркер пользователя
+func (app *BotApp) enqueueUpdate(
+	botID string,
+	bot *tgbotapi.BotAPI,
+	dispatcher *updateDispatcher,
+	update tgbotapi.Update,
+) {
+	var fromID int64
+	switch {
+	case update.Message != nil && update.Message.From != nil:
+		fromID = update.Message.From.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.From != nil:
+		fromID = update.CallbackQuery.From.ID
+	}
 
-		ctx := context.Background()
+	if fromID != 0 {
+		log.Printf(
+			"[bot_touch] botID=%s fromTG=%d updateID=%d",
+			botID,
+			fromID,
+			update.UpdateID,
+		)
+	}
 
-		tgID := extractTelegramID(update)
-		if tgID == 0 {
-			continue
-		}
+	tgID := extractTelegramID(update)
+	if tgID == 0 {
+		return
+	}
 
-		status, err := app.SubscriptionService.GetStatus(ctx, botID, tgID)
-		if err != nil {
-			log.Printf(
-				"[bot_loop] getStatus fail botID=%s tgID=%d err=%v",
-				botID,
-				tgID,
-				err,
-			)
-			continue
+	if ok, warn := app.flood.Allow(botID, tgID, time.Now()); !ok {
+		log.Printf("[flood] drop update botID=%s tgID=%d updateID=%d", botID, tgID, update.UpdateID)
+		if warn {
+			// не блокируем чтение апдейтов сетевым вызовом
+			go bot.Send(tgbotapi.NewMessage(tgID, "⏳ Слишком много сообщений подряд. Подожди немного и повтори."))
 		}
+		return
+	}
 
-		app.dispatchUpdate(ctx, botID, bot, tgID, status, update)
+	dispatcher.Dispatch(tgID, update)
+}
+
+// processUpdate — обработка одного апдейта в воркере пользователя
+func (app *BotApp) processUpdate(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	tgID int64,
+	update tgbotapi.Update,
+) {
+	status, err := app.SubscriptionService.GetStatus(ctx, botID, tgID)
+	if err != nil {
+		log.Printf(
+			"[bot_loop] getStatus fail botID=%s tgID=%d err=%v",
+			botID,
+			tgID,
+			err,
+		)
+		return
 	}
+
+	app.dispatchUpdate(ctx, botID, bot, tgID, status, update)
 }
 
 func (app *BotApp) dispatchUpdate(
@@ -103,6 +180,41 @@ func (app *BotApp) handleMessage(
 	anchor.ReplyMarkup = app.BuildMainKeyboard(botID, status)
 	bot.Send(anchor)
 
+	// =====================================================
+	// 0.02) РЕЖИМ ПОДДЕРЖКИ: сообщения уходят админам, AI не отвечает
+	// =====================================================
+	if app.handleSupportMessage(ctx, botID, bot, msg, tgID, status) {
+		return
+	}
+
+	// =====================================================
+	// 0.03) КОНТАКТ ДЛЯ ЧЕКА (/contact или ответ на запрос перед оплатой)
+	// =====================================================
+	if app.handleContactText(ctx, botID, bot, tgID, chatID, text, status) {
+		return
+	}
+
+	// =====================================================
+	// 0.04) /start gift_<code>, /gift И /gifts
+	// =====================================================
+	if app.handleGiftText(ctx, botID, bot, msg, tgID, status) {
+		return
+	}
+
+	// =====================================================
+	// 0.05) /start ref_<id> И /invite
+	// =====================================================
+	if app.handleReferralText(ctx, botID, bot, msg, tgID, status) {
+		return
+	}
+
+	// =====================================================
+	// 0.1) ПРОМОКОД (/promo CODE или после кнопки)
+	// =====================================================
+	if app.handlePromoText(ctx, botID, bot, tgID, chatID, text) {
+		return
+	}
+
 	// =====================================================
 	// 1) СБРОС НАСТРОЕК
 	// =====================================================
@@ -122,7 +234,7 @@ func (app *BotApp) handleMessage(
 	// 2) ГЛОБАЛЬНЫЕ КНОПКИ
 	// =====================================================
 	if strings.Contains(textLower, "ариф") {
-		menu := app.BuildSubscriptionMenu(ctx, botID)
+		menu := app.BuildSubscriptionMenu(ctx, botID, tgID)
 		out := tgbotapi.NewMessage(chatID, "💳 Выбери тариф:")
 		out.ReplyMarkup = menu
 		bot.Send(out)
@@ -155,12 +267,18 @@ func (app *BotApp) handleMessage(
 	}
 
 	if text == "❓ Помощь" {
+		// переписка прямо здесь, без перехода в админ-бот
+		if app.supportInBot() {
+			app.startSupport(ctx, botID, bot, tgID, chatID)
+			return
+		}
+
 		if app.adminBotUsername == "" {
 			bot.Send(tgbotapi.NewMessage(chatID, "Поддержка недоступна."))
 			return
 		}
 
-		url := "https://t.me/" + app.adminBotUsername + "?start=support"
+		url := "https://t.me/" + app.adminBotUsername + "?start=" + support.Payload(botID)
 
 		m := tgbotapi.NewMessage(chatID, "🆘 Поддержка:")
 		m.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
@@ -193,6 +311,7 @@ func (app *BotApp) handleMessage(
 	// =====================================================
 	if status == "active" {
 		mainKB := app.BuildMainKeyboard(botID, "active")
+		ctx = app.withEntitlements(ctx, botID, tgID)
 
 		switch {
 		case msg.Voice != nil:
@@ -228,7 +347,7 @@ func (app *BotApp) handleMessage(
 
 	// --- 4.1 TRIAL УЖЕ БЫЛ → СРАЗУ ПЛАТНЫЕ ТАРИФЫ
 	if trialUsed {
-		menu := app.BuildSubscriptionMenu(ctx, botID)
+		menu := app.BuildSubscriptionMenu(ctx, botID, tgID)
 		out := tgbotapi.NewMessage(
 			chatID,
 			"⛔ Пробный тариф уже использован.\nВыбери тариф:",
@@ -314,7 +433,7 @@ func (app *BotApp) handlePerplexity(
 		out.Close()
 		defer os.Remove(path)
 
-		text, err := app.SpeechService.Transcribe(ctx, "perplexity", path)
+		text, err := app.SpeechService.Transcribe(ctx, "perplexity", msg.From.ID, path, float64(msg.Voice.Duration))
 		if err != nil {
 			bot.Request(tgbotapi.NewDeleteMessage(chatID, sentThinking.MessageID))
 			bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось распознать голос."))
@@ -382,10 +501,6 @@ func (app *BotApp) checkVoiceAllowed(ctx context.Context, botID string, tgID int
 	return sub.VoiceMinutes > 0
 }
 
-func (app *BotApp) checkImageAllowed(ctx context.Context, botID string, tgID int64) bool {
-	return true
-}
-
 func isPDF(doc *tgbotapi.Document) bool {
 	name := strings.ToLower(doc.FileName)
 	return strings.HasSuffix(name, ".pdf")
@@ -3,19 +3,28 @@ package telegram
 import (
 	"context"
 	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"os"
 
 	"github.com/Vovarama1992/make_ziper/internal/ai"
+	"github.com/Vovarama1992/make_ziper/internal/audit"
 	"github.com/Vovarama1992/make_ziper/internal/bots"
 	"github.com/Vovarama1992/make_ziper/internal/classes"
 	"github.com/Vovarama1992/make_ziper/internal/doc"
+	"github.com/Vovarama1992/make_ziper/internal/entitlements"
+	"github.com/Vovarama1992/make_ziper/internal/gifts"
 	mpkg "github.com/Vovarama1992/make_ziper/internal/minutes_packages"
 	notificator "github.com/Vovarama1992/make_ziper/internal/notificator"
 	"github.com/Vovarama1992/make_ziper/internal/pdf"
 	"github.com/Vovarama1992/make_ziper/internal/ports"
+	"github.com/Vovarama1992/make_ziper/internal/promo"
+	"github.com/Vovarama1992/make_ziper/internal/quota"
+	"github.com/Vovarama1992/make_ziper/internal/referral"
 	"github.com/Vovarama1992/make_ziper/internal/speech"
+	"github.com/Vovarama1992/make_ziper/internal/support"
 	"github.com/Vovarama1992/make_ziper/internal/textrules"
 	"github.com/Vovarama1992/make_ziper/internal/trial"
 	"github.com/Vovarama1992/make_ziper/internal/user"
@@ -51,11 +60,59 @@ type BotApp struct {
 	ErrorNotify  notificator.Notificator
 	ClassService classes.ClassService
 
+	// возможности тарифа (features), nil — без ограничений
+	Entitlements entitlements.Service
+	// лимиты расхода тарифа (features.quotas), nil — без учёта
+	Quota quota.Service
+	// промокоды, nil — кнопки и /promo нет
+	Promo promo.Service
+	// реферальная программа, nil — ссылки ref_ не обрабатываются
+	Referral referral.Service
+	// подарочные подписки, nil — кнопки «В подарок» и /gift нет
+	Gifts gifts.Service
+	// журнал изменений: ответы поддержки из админ-бота, nil — не пишем
+	Audit audit.Service
+	// обращения в поддержку, nil — админ-бот не запускается, «Помощь» без режима поддержки
+	Support support.Service
+
+	// реестр запущенных ботов, меняется на лету (см. registry.go)
+	// lifecycleMu — запуск и остановка ботов по одному: stopBot → startBot атомарны
+	lifecycleMu   sync.Mutex
+	botsMu        sync.RWMutex
 	bots          map[string]*tgbotapi.BotAPI
-	shownKeyboard map[string]map[int64]bool
+	running       map[string]*runningBot
+	runCtx        context.Context
+	onBotsChanged func(map[string]*tgbotapi.BotAPI)
+
+	// циклы ботов и тикеры, которых ждёт Shutdown
+	loops    sync.WaitGroup
+	stopping atomic.Bool
+
+	// апдейты обрабатываются параллельно — доступ только через keyboardShown/markKeyboardShown
+	shownKeyboardMu sync.Mutex
+	shownKeyboard   map[string]map[int64]bool
 
 	adminBot         *AdminBot
 	adminBotUsername string
+
+	// polling или webhook, см. webhook.go
+	updates  updatesConfig
+	webhooks *webhookHub
+
+	// флуд-лимит до очереди пользователя, см. flood.go
+	flood *floodLimiter
+
+	// ожидание промокода после кнопки, см. promo.go
+	promoInput *promoInput
+
+	// ожидание email/телефона для чека, см. contact.go
+	contactInput *contactInput
+
+	// ожидание первого сообщения в поддержку после «Помощь», см. support.go
+	supportInput *supportInput
+
+	// у кого открыто обращение в самом боте, см. support.go
+	supportSessions *supportSessions
 }
 
 // ==================================================
@@ -102,7 +159,18 @@ func NewBotApp(
 		ClassService: classSvc,
 
 		bots:          make(map[string]*tgbotapi.BotAPI),
+		running:       make(map[string]*runningBot),
+		runCtx:        context.Background(),
 		shownKeyboard: make(map[string]map[int64]bool),
+
+		webhooks: newWebhookHub(),
+		flood:    newFloodLimiter(),
+
+		promoInput:   newPromoInput(),
+		contactInput: newContactInput(),
+		supportInput: newSupportInput(),
+
+		supportSessions: newSupportSessions(),
 	}
 }
 
@@ -111,54 +179,87 @@ func NewBotApp(
 // ==================================================
 
 func (app *BotApp) InitBots(ctx context.Context) error {
-	app.bots = make(map[string]*tgbotapi.BotAPI)
+	app.runCtx = ctx
+
+	app.shownKeyboardMu.Lock()
 	app.shownKeyboard = make(map[string]map[int64]bool)
+	app.shownKeyboardMu.Unlock()
+
+	updatesCfg, err := loadUpdatesConfig()
+	if err != nil {
+		return err
+	}
+	app.updates = updatesCfg
+	log.Printf("[bot_app] updates mode: %s", app.updates.mode)
 
 	cfgs, err := app.BotsService.ListAll(ctx)
 	if err != nil {
 		return err
 	}
 
+	app.lifecycleMu.Lock()
+	defer app.lifecycleMu.Unlock()
+
 	for _, cfg := range cfgs {
 		if cfg.Token == "" {
 			continue
 		}
 
-		bot, err := tgbotapi.NewBotAPI(cfg.Token)
-		if err != nil {
+		if err := app.startBot(cfg.BotID, cfg.Token); err != nil {
 			log.Printf("[bot_app] init fail for %s: %v", cfg.BotID, err)
 			continue
 		}
-
-		app.bots[cfg.BotID] = bot
-		log.Printf("[bot_app] ready: @%s (%s)", bot.Self.UserName, cfg.BotID)
-
-		go app.runBotLoop(cfg.BotID, bot)
-
 	}
 
 	// --- Perplexity bot (hardcoded) ---
 	perplexityToken := os.Getenv("PERPLEXITY_BOT_TOKEN")
 	if perplexityToken != "" {
-		bot, err := tgbotapi.NewBotAPI(perplexityToken)
-		if err != nil {
+		if err := app.startBot("perplexity", perplexityToken); err != nil {
 			log.Printf("[bot_app] init fail for perplexity: %v", err)
-		} else {
-			botID := "perplexity"
-			app.bots[botID] = bot
-			log.Printf("[bot_app] ready: @%s (%s)", bot.Self.UserName, botID)
-
-			go app.runBotLoop(botID, bot)
 		}
 	}
 
+	// дальнейшие изменения bot_configs подхватываем без рестарта
+	app.BotsService.SetChangeListener(app)
+
 	app.startTrialCleanupTicker(ctx, 1*time.Minute)
 
 	return nil
 }
 
+// GetBots — снимок реестра ботов
 func (app *BotApp) GetBots() map[string]*tgbotapi.BotAPI {
-	return app.bots
+	app.botsMu.RLock()
+	defer app.botsMu.RUnlock()
+
+	out := make(map[string]*tgbotapi.BotAPI, len(app.bots))
+	for botID, bot := range app.bots {
+		out[botID] = bot
+	}
+	return out
+}
+
+// ==================================================
+// SHOWN KEYBOARD
+// ==================================================
+
+func (app *BotApp) keyboardShown(botID string, tgID int64) bool {
+	app.shownKeyboardMu.Lock()
+	defer app.shownKeyboardMu.Unlock()
+
+	return app.shownKeyboard[botID][tgID]
+}
+
+func (app *BotApp) markKeyboardShown(botID string, tgID int64) {
+	app.shownKeyboardMu.Lock()
+	defer app.shownKeyboardMu.Unlock()
+
+	users, ok := app.shownKeyboard[botID]
+	if !ok {
+		users = make(map[int64]bool)
+		app.shownKeyboard[botID] = users
+	}
+	users[tgID] = true
 }
 
 // ==================================================
@@ -171,7 +272,7 @@ func (app *BotApp) startTrialCleanupTicker(
 ) {
 	ticker := time.NewTicker(interval)
 
-	go func() {
+	app.goLoop(func() {
 		defer ticker.Stop()
 
 		for {
@@ -180,7 +281,7 @@ func (app *BotApp) startTrialCleanupTicker(
 				return
 
 			case <-ticker.C:
-				for botID := range app.bots {
+				for botID := range app.GetBots() {
 					if err := app.SubscriptionService.CleanupExpiredTrials(ctx, botID); err != nil {
 						app.ErrorNotify.Notify(
 							ctx,
@@ -192,7 +293,7 @@ func (app *BotApp) startTrialCleanupTicker(
 				}
 			}
 		}
-	}()
+	})
 }
 
 func (app *BotApp) SetAdminBotUsername(username string) {
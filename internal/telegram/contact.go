@@ -0,0 +1,163 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vovarama1992/make_ziper/internal/user"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// сколько ждём email/телефон после запроса
+const contactInputTTL = 10 * time.Minute
+
+// pendingContact — ждём контакт; data — callback оплаты, которую продолжим после него ("" — просто /contact)
+type pendingContact struct {
+	data  string
+	until time.Time
+}
+
+// contactInput — кого спросили email/телефон для чека и какую оплату он начинал
+type contactInput struct {
+	mu      sync.Mutex
+	pending map[floodKey]pendingContact
+}
+
+func newContactInput() *contactInput {
+	return &contactInput{pending: make(map[floodKey]pendingContact)}
+}
+
+func (c *contactInput) Wait(botID string, tgID int64, data string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// заодно чистим протухшие ожидания
+	for key, p := range c.pending {
+		if now.After(p.until) {
+			delete(c.pending, key)
+		}
+	}
+	c.pending[floodKey{botID: botID, tgID: tgID}] = pendingContact{data: data, until: now.Add(contactInputTTL)}
+}
+
+// Take — ждали ли контакт от пользователя и какую оплату продолжить (ожидание снимается)
+func (c *contactInput) Take(botID string, tgID int64, now time.Time) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := floodKey{botID: botID, tgID: tgID}
+	p, ok := c.pending[key]
+	delete(c.pending, key)
+	if !ok || now.After(p.until) {
+		return "", false
+	}
+	return p.data, true
+}
+
+// requireContact — есть ли куда отправить чек; если нет — спрашиваем и запоминаем оплату (false)
+func (app *BotApp) requireContact(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	tgID int64,
+	chatID int64,
+	data string,
+) bool {
+	c, err := app.UserService.GetContact(ctx, botID, tgID)
+	if err != nil {
+		app.ErrorNotify.Notify(ctx, botID, err, "Ошибка загрузки контакта для чека")
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось создать оплату. Попробуй позже."))
+		return false
+	}
+	if c != nil {
+		return true
+	}
+
+	app.askContact(botID, bot, tgID, chatID, data)
+	return false
+}
+
+func (app *BotApp) askContact(botID string, bot *tgbotapi.BotAPI, tgID, chatID int64, data string) {
+	app.contactInput.Wait(botID, tgID, data, time.Now())
+	bot.Send(tgbotapi.NewMessage(chatID,
+		"🧾 По закону после оплаты мы отправляем чек.\n"+
+			"Отправь email или номер телефона одним сообщением — туда он и придёт."))
+}
+
+// handleContactText — "/contact [email|телефон]" или ответ на запрос контакта.
+// true — сообщение обработано.
+func (app *BotApp) handleContactText(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	tgID int64,
+	chatID int64,
+	text string,
+	status string,
+) bool {
+	if text == "" {
+		return false
+	}
+
+	var raw, data string
+	switch {
+	case text == "/contact" || strings.HasPrefix(text, "/contact "):
+		raw = strings.TrimSpace(strings.TrimPrefix(text, "/contact"))
+		if raw == "" {
+			app.askContact(botID, bot, tgID, chatID, "")
+			return true
+		}
+	default:
+		var ok bool
+		data, ok = app.contactInput.Take(botID, tgID, time.Now())
+		if !ok {
+			return false
+		}
+		// другая команда или кнопка меню — пользователь передумал
+		if strings.HasPrefix(text, "/") || !looksLikeContact(text) {
+			return false
+		}
+		raw = text
+	}
+
+	c, err := app.UserService.SaveContact(ctx, botID, tgID, raw)
+	if errors.Is(err, user.ErrInvalidContact) {
+		app.contactInput.Wait(botID, tgID, data, time.Now())
+		bot.Send(tgbotapi.NewMessage(chatID,
+			"❗ Не похоже на email или телефон. Пример: name@mail.ru или +7 900 123-45-67"))
+		return true
+	}
+	if err != nil {
+		app.ErrorNotify.Notify(ctx, botID, err, "Ошибка сохранения контакта для чека")
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось сохранить контакт. Попробуй позже."))
+		return true
+	}
+
+	to := c.Email
+	if to == "" {
+		to = "+" + c.Phone
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, "✅ Чеки будут приходить на "+to+"\nИзменить: /contact"))
+
+	if data != "" {
+		app.resumePayment(ctx, botID, bot, tgID, chatID, data, status)
+	}
+	return true
+}
+
+// looksLikeContact — похоже ли сообщение на попытку ввести email или телефон
+func looksLikeContact(text string) bool {
+	if strings.Contains(text, "@") {
+		return true
+	}
+	digits := 0
+	for _, r := range text {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return digits >= 5
+}
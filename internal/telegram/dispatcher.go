@@ -0,0 +1,132 @@
+package telegram
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// сколько пользователей одного бота обрабатываются параллельно
+	botMaxWorkers = 32
+	// очередь апдейтов одного пользователя; всё сверх — флуд, отбрасываем
+	userQueueSize = 16
+)
+
+// updateDispatcher — раскидывает апдейты бота по воркерам пользователей.
+// Внутри одного telegramID порядок сохраняется, разные пользователи идут параллельно.
+// Число одновременно работающих воркеров ограничено: когда все заняты,
+// Dispatch блокируется и тем самым притормаживает чтение апдейтов (backpressure).
+type updateDispatcher struct {
+	botID  string
+	handle func(tgID int64, update tgbotapi.Update)
+
+	slots chan struct{}
+
+	mu     sync.Mutex
+	queues map[int64]chan tgbotapi.Update
+
+	wg sync.WaitGroup
+}
+
+func newUpdateDispatcher(
+	botID string,
+	maxWorkers int,
+	handle func(tgID int64, update tgbotapi.Update),
+) *updateDispatcher {
+	return &updateDispatcher{
+		botID:  botID,
+		handle: handle,
+		slots:  make(chan struct{}, maxWorkers),
+		queues: make(map[int64]chan tgbotapi.Update),
+	}
+}
+
+// Dispatch — ставит апдейт в очередь пользователя, при необходимости поднимает воркер
+func (d *updateDispatcher) Dispatch(tgID int64, update tgbotapi.Update) {
+	d.mu.Lock()
+	if q, ok := d.queues[tgID]; ok {
+		d.push(tgID, q, update)
+		d.mu.Unlock()
+		return
+	}
+	d.mu.Unlock()
+
+	// воркера нет — ждём свободный слот
+	d.slots <- struct{}{}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if q, ok := d.queues[tgID]; ok {
+		// пока ждали слот, воркер уже появился
+		<-d.slots
+		d.push(tgID, q, update)
+		return
+	}
+
+	q := make(chan tgbotapi.Update, userQueueSize)
+	q <- update
+	d.queues[tgID] = q
+
+	d.wg.Add(1)
+	go d.worker(tgID, q)
+}
+
+// Wait — ждёт, пока все воркеры разберут свои очереди
+func (d *updateDispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// push — вызывается под d.mu: воркер проверяет пустоту очереди под ним же
+func (d *updateDispatcher) push(tgID int64, q chan tgbotapi.Update, update tgbotapi.Update) {
+	select {
+	case q <- update:
+	default:
+		log.Printf(
+			"[dispatcher] queue full, drop update botID=%s tgID=%d updateID=%d",
+			d.botID,
+			tgID,
+			update.UpdateID,
+		)
+	}
+}
+
+func (d *updateDispatcher) worker(tgID int64, q chan tgbotapi.Update) {
+	defer d.wg.Done()
+	defer func() { <-d.slots }()
+
+	for {
+		select {
+		case update := <-q:
+			d.safeHandle(tgID, update)
+
+		default:
+			d.mu.Lock()
+			if len(q) == 0 {
+				delete(d.queues, tgID)
+				d.mu.Unlock()
+				return
+			}
+			d.mu.Unlock()
+		}
+	}
+}
+
+func (d *updateDispatcher) safeHandle(tgID int64, update tgbotapi.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf(
+				"[dispatcher] panic botID=%s tgID=%d: %v\n%s",
+				d.botID,
+				tgID,
+				r,
+				debug.Stack(),
+			)
+		}
+	}()
+
+	d.handle(tgID, update)
+}
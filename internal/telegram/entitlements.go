@@ -0,0 +1,145 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/Vovarama1992/make_ziper/internal/ai"
+	"github.com/Vovarama1992/make_ziper/internal/entitlements"
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (app *BotApp) SetEntitlements(svc entitlements.Service) {
+	app.Entitlements = svc
+}
+
+// withEntitlements — возможности тарифа считаются один раз на апдейт
+// и кладутся в ctx вместе с разрешёнными моделями для AI
+func (app *BotApp) withEntitlements(ctx context.Context, botID string, tgID int64) context.Context {
+	if app.Entitlements == nil {
+		return ctx
+	}
+
+	ent, err := app.Entitlements.Resolve(ctx, botID, tgID)
+	if err != nil {
+		log.Printf("[entitlements] resolve bot=%s tg=%d: %v", botID, tgID, err)
+		return ctx
+	}
+
+	ctx = entitlements.WithContext(ctx, ent)
+	return ai.WithAllowedModels(ctx, ent.Features.Models)
+}
+
+// allow — проверка возможности тарифа; при отказе сам отправляет предложение апгрейда.
+// Сбой проверки не блокирует пользователя — только лог.
+func (app *BotApp) allow(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	chatID int64,
+	tgID int64,
+	c entitlements.Check,
+	mainKB tgbotapi.ReplyKeyboardMarkup,
+) bool {
+	if app.Entitlements == nil {
+		return true
+	}
+
+	ent := entitlements.FromContext(ctx)
+	if ent == nil {
+		return true
+	}
+
+	denial, err := app.Entitlements.Authorize(ctx, botID, tgID, ent, c)
+	if err != nil {
+		log.Printf("[entitlements] authorize bot=%s tg=%d feature=%s: %v", botID, tgID, c.Feature, err)
+		return true
+	}
+	if denial == nil {
+		return true
+	}
+
+	log.Printf("[entitlements] denied bot=%s tg=%d plan=%s feature=%s", botID, tgID, ent.PlanCode, c.Feature)
+	app.sendUpsell(bot, chatID, denial, mainKB)
+	return false
+}
+
+// denyModel — ни одна модель бота не разрешена тарифом (ai.ErrModelNotAllowed)
+func (app *BotApp) denyModel(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	chatID int64,
+	tgID int64,
+	mainKB tgbotapi.ReplyKeyboardMarkup,
+) {
+	model := ""
+	if cfg, err := app.BotsService.Get(ctx, botID); err == nil && cfg != nil {
+		model = cfg.Model
+	}
+
+	if app.allow(ctx, botID, bot, chatID, tgID, entitlements.Check{Feature: entitlements.FeatureModel, Model: model}, mainKB) {
+		// основная модель разрешена, но вся цепочка отфильтрована — без апгрейда
+		m := tgbotapi.NewMessage(chatID, "🤖 Модели этого бота недоступны в вашем тарифе.")
+		m.ReplyMarkup = mainKB
+		bot.Send(m)
+	}
+}
+
+func (app *BotApp) sendUpsell(
+	bot *tgbotapi.BotAPI,
+	chatID int64,
+	d *entitlements.Denial,
+	mainKB tgbotapi.ReplyKeyboardMarkup,
+) {
+	plan := planPhrase(d.Current)
+
+	var text string
+	switch d.Check.Feature {
+	case entitlements.FeatureVoice:
+		text = "🔇 Голосовые недоступны в " + plan + "."
+	case entitlements.FeaturePhoto:
+		text = "🖼 Разбор фото и файлов недоступен в " + plan + "."
+	case entitlements.FeaturePDF:
+		text = "📄 Разбор PDF недоступен в " + plan + "."
+	case entitlements.FeatureWord:
+		text = "📝 Разбор Word-документов недоступен в " + plan + "."
+	case entitlements.FeaturePDFPages:
+		text = fmt.Sprintf(
+			"📄 В %s — до %d стр. PDF, в документе %d.",
+			plan, d.Current.Features.MaxPDFPages, d.Check.Pages,
+		)
+	case entitlements.FeatureModel:
+		text = "🤖 Модель этого бота недоступна в " + plan + "."
+	default:
+		text = "⛔ Недоступно в " + plan + "."
+	}
+
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = mainKB
+
+	if up := d.Upgrade; up != nil {
+		m.Text += fmt.Sprintf("\n\nЕсть в тарифе «%s» — %s.", up.Name, formatRUB(up.Price))
+		m.ReplyMarkup = upgradeKeyboard(up)
+	}
+
+	bot.Send(m)
+}
+
+// planPhrase — «тарифе «Базовый»» для текстов отказа
+func planPhrase(ent *entitlements.Entitlements) string {
+	if ent != nil && ent.PlanName != "" {
+		return fmt.Sprintf("тарифе «%s»", ent.PlanName)
+	}
+	return "вашем тарифе"
+}
+
+func upgradeKeyboard(up *ports.TariffPlan) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬆️ Перейти на «"+up.Name+"»", "sub:"+up.Code),
+		),
+	)
+}
@@ -0,0 +1,119 @@
+package telegram
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	floodDefaultMax    = 8
+	floodDefaultWindow = 10 * time.Second
+	floodGCInterval    = time.Minute
+)
+
+// floodLimiter — короткий лимит апдейтов на пользователя бота (скользящее окно).
+// Срабатывает до очереди пользователя, поэтому флуд не доходит до AI вообще.
+// FLOOD_MAX_UPDATES за FLOOD_WINDOW; FLOOD_MAX_UPDATES=0 — выключен.
+type floodLimiter struct {
+	max    int
+	window time.Duration
+
+	mu     sync.Mutex
+	hits   map[floodKey]*floodState
+	lastGC time.Time
+}
+
+type floodKey struct {
+	botID string
+	tgID  int64
+}
+
+type floodState struct {
+	times  []time.Time
+	warned bool // о флуде уже предупредили в этой серии
+}
+
+func newFloodLimiter() *floodLimiter {
+	l := &floodLimiter{
+		max:    floodDefaultMax,
+		window: floodDefaultWindow,
+		hits:   make(map[floodKey]*floodState),
+	}
+
+	if v := os.Getenv("FLOOD_MAX_UPDATES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Printf("[flood] bad FLOOD_MAX_UPDATES=%q, using %d", v, l.max)
+		} else {
+			l.max = n
+		}
+	}
+
+	if v := os.Getenv("FLOOD_WINDOW"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			log.Printf("[flood] bad FLOOD_WINDOW=%q, using %s", v, l.window)
+		} else {
+			l.window = d
+		}
+	}
+
+	return l
+}
+
+// Allow — пропустить ли апдейт и нужно ли предупредить пользователя
+// (предупреждаем один раз, пока флуд не прекратится)
+func (l *floodLimiter) Allow(botID string, tgID int64, now time.Time) (ok bool, warn bool) {
+	if l == nil || l.max == 0 {
+		return true, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.gc(now)
+
+	key := floodKey{botID: botID, tgID: tgID}
+	st, found := l.hits[key]
+	if !found {
+		st = &floodState{}
+		l.hits[key] = st
+	}
+
+	cutoff := now.Add(-l.window)
+	kept := st.times[:0]
+	for _, t := range st.times {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	st.times = kept
+
+	if len(st.times) >= l.max {
+		warn = !st.warned
+		st.warned = true
+		return false, warn
+	}
+
+	st.times = append(st.times, now)
+	st.warned = false
+	return true, false
+}
+
+// gc — вызывается под l.mu: выкидывает пользователей, которые давно молчат
+func (l *floodLimiter) gc(now time.Time) {
+	if now.Sub(l.lastGC) < floodGCInterval {
+		return
+	}
+	l.lastGC = now
+
+	cutoff := now.Add(-l.window)
+	for key, st := range l.hits {
+		if n := len(st.times); n == 0 || !st.times[n-1].After(cutoff) {
+			delete(l.hits, key)
+		}
+	}
+}
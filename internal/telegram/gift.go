@@ -0,0 +1,175 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Vovarama1992/make_ziper/internal/gifts"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (app *BotApp) SetGifts(svc gifts.Service) {
+	app.Gifts = svc
+}
+
+// handleGiftText — "/start gift_<code>", "/gift CODE" и "/gifts".
+// true — сообщение обработано; неудачный /start нового пользователя идёт дальше в онбординг.
+func (app *BotApp) handleGiftText(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	msg *tgbotapi.Message,
+	tgID int64,
+	status string,
+) bool {
+	if app.Gifts == nil || !msg.IsCommand() {
+		return false
+	}
+
+	chatID := msg.Chat.ID
+
+	switch msg.Command() {
+	case "gifts":
+		app.sendMyGifts(ctx, botID, bot, tgID, chatID)
+		return true
+
+	case "gift":
+		code := strings.TrimSpace(msg.CommandArguments())
+		if code == "" {
+			bot.Send(tgbotapi.NewMessage(chatID,
+				"🎁 Чтобы активировать подарок, отправь код так:\n/gift КОД\n\n"+
+					"Подарить тариф можно в меню «💳 Тарифы» — кнопка «🎁 В подарок»."))
+			return true
+		}
+		app.redeemGift(ctx, botID, bot, tgID, chatID, code, status)
+		return true
+
+	case "start":
+		code, ok := gifts.ParsePayload(msg.CommandArguments())
+		if !ok {
+			return false
+		}
+		if app.redeemGift(ctx, botID, bot, tgID, chatID, code, status) {
+			return true
+		}
+		return status == "active"
+	}
+
+	return false
+}
+
+// redeemGift — активация подарка получателем (true — доступ выдан)
+func (app *BotApp) redeemGift(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	tgID int64,
+	chatID int64,
+	code string,
+	status string,
+) bool {
+	g, err := app.Gifts.Redeem(ctx, botID, tgID, code)
+	if err != nil {
+		var text string
+		switch {
+		case errors.Is(err, gifts.ErrNotFound):
+			text = "❗ Подарок с таким кодом не найден. Проверь написание."
+		case errors.Is(err, gifts.ErrNotPaid):
+			text = "⏳ Подарок ещё не оплачен. Попробуй чуть позже."
+		case errors.Is(err, gifts.ErrAlreadyRedeemed):
+			text = "❗ Этот подарок уже активирован."
+		case errors.Is(err, gifts.ErrUnavailable):
+			text = "❗ Подарок больше не действует."
+		default:
+			app.ErrorNotify.Notify(ctx, botID, err, "Ошибка активации подарка")
+			text = "⚠️ Не удалось активировать подарок. Попробуй позже."
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, text))
+		return false
+	}
+
+	m := tgbotapi.NewMessage(chatID, fmt.Sprintf("🎁 Подарок активирован: тариф «%s». Можем начинать 👍", g.PlanName))
+	m.ReplyMarkup = app.BuildMainKeyboard(botID, "active")
+	bot.Send(m)
+
+	// новый пользователь ещё не выбирал класс
+	if status == "none" {
+		app.ShowClassPicker(ctx, botID, bot, tgID, chatID)
+	}
+	return true
+}
+
+// startGiftPayment — заказ подарка и ссылка на оплату
+func (app *BotApp) startGiftPayment(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	tgID int64,
+	chatID int64,
+	planCode string,
+) {
+	if app.Gifts == nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "Подарки сейчас недоступны."))
+		return
+	}
+
+	payURL, err := app.Gifts.Create(ctx, botID, tgID, planCode)
+	if err != nil {
+		app.ErrorNotify.Notify(ctx, botID, err, "Ошибка создания подарка")
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось создать оплату."))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(
+		chatID,
+		"🎁 После оплаты пришлём код подарка и ссылку — перешли их получателю.\n\n"+
+			"Перед оплатой ознакомьтесь с документами:",
+	)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📄 Документы", "docs"),
+		),
+	)
+	bot.Send(msg)
+
+	bot.Send(tgbotapi.NewMessage(chatID, "💳 Ссылка для оплаты:\n"+payURL))
+}
+
+// sendMyGifts — подарки, купленные пользователем, со ссылками для получателей
+func (app *BotApp) sendMyGifts(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	tgID int64,
+	chatID int64,
+) {
+	items, err := app.Gifts.ListByPayer(ctx, botID, tgID)
+	if err != nil {
+		app.ErrorNotify.Notify(ctx, botID, err, "Ошибка загрузки подарков")
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось загрузить подарки."))
+		return
+	}
+
+	var lines []string
+	for _, g := range items {
+		switch g.Status {
+		case gifts.StatusPaid:
+			link := fmt.Sprintf("https://t.me/%s?start=%s", bot.Self.UserName, gifts.Payload(g.Code))
+			lines = append(lines, fmt.Sprintf("🎁 «%s» — ждёт получателя\nКод: %s\nСсылка: %s", g.PlanName, g.Code, link))
+		case gifts.StatusRedeemed:
+			lines = append(lines, fmt.Sprintf("✅ «%s» — активирован", g.PlanName))
+		case gifts.StatusPending:
+			lines = append(lines, fmt.Sprintf("⏳ «%s» — ждёт оплату", g.PlanName))
+		}
+	}
+
+	if len(lines) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID,
+			"У тебя пока нет подарков. Подарить тариф можно в меню «💳 Тарифы» — кнопка «🎁 В подарок»."))
+		return
+	}
+
+	bot.Send(tgbotapi.NewMessage(chatID, "Твои подарки:\n\n"+strings.Join(lines, "\n\n")))
+}
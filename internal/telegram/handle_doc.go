@@ -2,10 +2,14 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"io"
 	"log"
 	"net/http"
 
+	"github.com/Vovarama1992/make_ziper/internal/ai"
+	"github.com/Vovarama1992/make_ziper/internal/entitlements"
+	"github.com/Vovarama1992/make_ziper/internal/quota"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -22,6 +26,24 @@ func (app *BotApp) handleDoc(
 
 	log.Printf("[doc] start bot=%s tg=%d file=%s", botID, tgID, doc.FileName)
 
+	if !app.allow(ctx, botID, bot, chatID, tgID, entitlements.Check{Feature: entitlements.FeatureWord}, mainKB) {
+		return
+	}
+
+	spend := quota.Spend{Metric: quota.MetricMessages, N: 1}
+	charge, ok := app.consumeQuota(ctx, botID, bot, chatID, tgID, mainKB, spend)
+	if !ok {
+		return
+	}
+
+	// до ответа AI не дошли — расход возвращаем
+	replied := false
+	defer func() {
+		if !replied {
+			app.releaseQuota(ctx, charge)
+		}
+	}()
+
 	// === 1. СКАЧИВАЕМ ===
 	log.Printf("[doc] GetFile fileID=%s", doc.FileID)
 	fileInfo, err := bot.GetFile(tgbotapi.FileConfig{FileID: doc.FileID})
@@ -78,55 +100,43 @@ func (app *BotApp) handleDoc(
 		log.Printf("[doc] ERROR sending thinking: %v", err)
 	}
 
-	// === 4. GPT ===
+	// === 4. GPT (стрим в индикатор) ===
 	log.Printf("[doc] GPT request…")
-	reply, err := app.AiService.GetReply(
+	stream := newStreamReply(bot, chatID, sentThinking.MessageID)
+
+	reply, err := app.AiService.GetReplyStream(
 		ctx,
 		botID,
 		tgID,
-		"text",
+		"doc",
 		text,
 		nil,
+		stream.Update,
 	)
 	log.Printf("[doc] GPT done err=%v", err)
 
+	if errors.Is(err, ai.ErrModelNotAllowed) {
+		stream.Delete()
+		app.denyModel(ctx, botID, bot, chatID, tgID, mainKB)
+		return
+	}
 	if err != nil {
 		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Ошибка обработки документа AI."))
 
 		// delete indicator
-		_, derr := bot.Request(tgbotapi.NewDeleteMessage(chatID, sentThinking.MessageID))
-		if derr != nil {
-			log.Printf("[doc] ERROR deleting thinking: %v", derr)
-		}
-
+		stream.Delete()
 		return
 	}
+	replied = true
 
-	// === 5. отправляем ответ ===
-	log.Printf("[doc] send reply len=%d", len(reply))
-	out := tgbotapi.NewMessage(chatID, reply)
-	out.ReplyMarkup = mainKB
-
-	sendRes, sendErr := bot.Send(out)
-	if sendErr != nil {
-		log.Printf("[doc] ERROR sending reply: %v", sendErr)
-	} else {
-		log.Printf("[doc] reply sent OK: msgID=%d", sendRes.MessageID)
-	}
+	// === 5. финальный ответ ===
+	log.Printf("[doc] send reply len=%d model=%s", len(reply.Text), reply.ModelRef())
+	stream.Finish(reply.Text, mainKB)
 
 	// === 6. сохраняем историю ===
 	log.Printf("[doc] save history")
 	app.RecordService.AddText(ctx, botID, tgID, "user", text)
-	app.RecordService.AddText(ctx, botID, tgID, "tutor", reply)
-
-	// === 7. убираем индикатор ===
-	delReq := tgbotapi.NewDeleteMessage(chatID, sentThinking.MessageID)
-	_, delErr := bot.Request(delReq)
-	if delErr != nil {
-		log.Printf("[doc] ERROR deleting thinking: %v", delErr)
-	} else {
-		log.Printf("[doc] thinking deleted")
-	}
+	app.RecordService.AddReply(ctx, botID, tgID, reply.Text, reply.ModelRef())
 
 	log.Printf("[doc] done bot=%s tg=%d", botID, tgID)
 }
@@ -3,9 +3,13 @@ package telegram
 import (
 	"bytes"
 	"context"
+	"errors"
 	"log"
 	"net/http"
 
+	"github.com/Vovarama1992/make_ziper/internal/ai"
+	"github.com/Vovarama1992/make_ziper/internal/entitlements"
+	"github.com/Vovarama1992/make_ziper/internal/quota"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -23,8 +27,7 @@ func (app *BotApp) handlePDF(
 	log.Printf("[pdf] START bot=%s tg=%d filename=%s mime=%s",
 		botID, tgID, d.FileName, d.MimeType)
 
-	if !app.checkImageAllowed(ctx, botID, tgID) {
-		bot.Send(tgbotapi.NewMessage(chatID, "📄 В этом тарифе разбор PDF недоступен."))
+	if !app.allow(ctx, botID, bot, chatID, tgID, entitlements.Check{Feature: entitlements.FeaturePDF}, mainKB) {
 		return
 	}
 
@@ -58,6 +61,28 @@ func (app *BotApp) handlePDF(
 	}
 	log.Printf("[pdf] pages generated: %d", len(pages))
 
+	if !app.allow(ctx, botID, bot, chatID, tgID, entitlements.Check{Feature: entitlements.FeaturePDFPages, Pages: len(pages)}, mainKB) {
+		return
+	}
+
+	// запрос и страницы списываются вместе — число страниц известно только после конвертации
+	spends := []quota.Spend{
+		{Metric: quota.MetricMessages, N: 1},
+		{Metric: quota.MetricPDFPages, N: len(pages)},
+	}
+	charge, ok := app.consumeQuota(ctx, botID, bot, chatID, tgID, mainKB, spends...)
+	if !ok {
+		return
+	}
+
+	// до ответа AI не дошли — расход возвращаем
+	replied := false
+	defer func() {
+		if !replied {
+			app.releaseQuota(ctx, charge)
+		}
+	}()
+
 	// 3. SAVE EACH PAGE
 	var firstImageURL *string
 
@@ -86,26 +111,34 @@ func (app *BotApp) handlePDF(
 	thinking.ReplyMarkup = mainKB
 	sentThinking, _ := bot.Send(thinking)
 
-	reply, err := app.AiService.GetReplyPDFOptimized(
+	stream := newStreamReply(bot, chatID, sentThinking.MessageID)
+
+	reply, err := app.AiService.GetReplyPDFOptimizedStream(
 		ctx,
 		botID,
 		tgID,
 		"Разбери документ.", // или ""
 		1,                   // maxImages: 1–2
+		stream.Update,
 	)
+	if errors.Is(err, ai.ErrModelNotAllowed) {
+		stream.Delete()
+		app.denyModel(ctx, botID, bot, chatID, tgID, mainKB)
+		return
+	}
 	if err != nil {
 		log.Printf("[pdf] GPT ERROR: %v", err)
-		bot.Request(tgbotapi.NewDeleteMessage(chatID, sentThinking.MessageID))
+		stream.Delete()
 		m := tgbotapi.NewMessage(chatID, "⚠️ Ошибка обработки PDF.")
 		m.ReplyMarkup = mainKB
 		bot.Send(m)
 		return
 	}
+	replied = true
+
+	stream.Finish(reply.Text, mainKB)
 
-	out := tgbotapi.NewMessage(chatID, reply)
-	out.ReplyMarkup = mainKB
-	bot.Send(out)
-	bot.Request(tgbotapi.NewDeleteMessage(chatID, sentThinking.MessageID))
+	app.RecordService.AddReply(ctx, botID, tgID, reply.Text, reply.ModelRef())
 
 	log.Printf("[pdf] DONE bot=%s tg=%d", botID, tgID)
 }
@@ -26,6 +26,14 @@ func (app *BotApp) handleCallback(
 
 	log.Printf("[callback] botID=%s tgID=%d data=%s", botID, tgID, data)
 
+	// ---------------------------
+	// 1) Выход из режима поддержки
+	// ---------------------------
+	if data == supportExitData {
+		app.exitSupport(ctx, botID, bot, tgID, chatID)
+		return
+	}
+
 	// ---------------------------
 	// 2) Выбор класса
 	// ---------------------------
@@ -70,38 +78,11 @@ func (app *BotApp) handleCallback(
 	// 3) Пакеты минут
 	// ---------------------------
 	if strings.HasPrefix(data, "pkg_") {
-		idStr := strings.TrimPrefix(data, "pkg_")
-		id, _ := strconv.ParseInt(idStr, 10, 64)
-
-		pkg, err := app.MinutePackageService.GetByID(ctx, botID, id)
-		if err != nil || pkg == nil || !pkg.Active {
-			bot.Send(tgbotapi.NewMessage(chatID, "❗ Пакет недоступен."))
+		// без email/телефона чек не пробить — сначала спрашиваем контакт
+		if !app.requireContact(ctx, botID, bot, tgID, chatID, data) {
 			return
 		}
-
-		payURL, err := app.MinutePackageService.CreatePayment(ctx, botID, tgID, pkg.ID)
-		if err != nil {
-			app.ErrorNotify.Notify(ctx, botID, err, "Ошибка создания платежа")
-			bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось создать оплату."))
-			return
-		}
-
-		msg := tgbotapi.NewMessage(
-			chatID,
-			"Перед оплатой ознакомьтесь с документами:",
-		)
-
-		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("📄 Документы", "docs"),
-			),
-		)
-
-		bot.Send(msg)
-
-		// ссылка отдельным сообщением
-		bot.Send(tgbotapi.NewMessage(chatID, "Ссылка для оплаты:\n"+payURL))
-
+		app.resumePayment(ctx, botID, bot, tgID, chatID, data, status)
 		return
 	}
 
@@ -112,41 +93,63 @@ func (app *BotApp) handleCallback(
 		planCode := strings.TrimPrefix(data, "sub:")
 
 		switch status {
-		case "active":
-			bot.Send(tgbotapi.NewMessage(chatID, MsgAlreadySubscribed))
-			return
-
 		case "pending":
 			bot.Send(tgbotapi.NewMessage(chatID, "⏳ Ожидается подтверждение оплаты."))
 			return
 
-		case "none", "expired":
-			paymentURL, err := app.SubscriptionService.Create(ctx, botID, tgID, planCode)
-			if err != nil {
-				app.ErrorNotify.Notify(ctx, botID, err, "Ошибка создания подписки")
-				bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось создать оплату."))
-				return
+		case "none", "expired", "active":
+			text := "Как оплатить?\n\n"
+			if status == "active" {
+				text = "У вас уже есть подписка.\n" +
+					"Тот же тариф продлит её с даты окончания, другой начнётся сразу, " +
+					"а неиспользованный остаток добавится днями нового тарифа.\n\n" + text
 			}
 
-			// сообщение с кнопкой документов
 			msg := tgbotapi.NewMessage(
 				chatID,
-				"Перед оплатой ознакомьтесь с документами:",
+				text+
+					"🔁 С автопродлением — подписка продлится сама, карта сохранится у платёжной системы. "+
+					"Отключить можно в любой момент в меню тарифов.",
 			)
-
-			msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			rows := [][]tgbotapi.InlineKeyboardButton{
 				tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("📄 Документы", "docs"),
+					tgbotapi.NewInlineKeyboardButtonData("💳 Разовая оплата", "subpay:"+planCode),
 				),
-			)
-
+				tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData("🔁 С автопродлением", "subauto:"+planCode),
+				),
+			}
+			if app.Gifts != nil {
+				rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData("🎁 В подарок", "subgift:"+planCode),
+				))
+			}
+			msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
 			bot.Send(msg)
+			return
+		}
+	}
 
-			// ссылка отдельным сообщением
-			bot.Send(tgbotapi.NewMessage(chatID, "💳 Ссылка для оплаты:\n"+paymentURL))
+	// подарок оплачивается независимо от своей подписки
+	if strings.HasPrefix(data, "subgift:") ||
+		strings.HasPrefix(data, "subpay:") ||
+		strings.HasPrefix(data, "subauto:") {
+		if !app.requireContact(ctx, botID, bot, tgID, chatID, data) {
+			return
+		}
+		app.resumePayment(ctx, botID, bot, tgID, chatID, data, status)
+		return
+	}
 
+	if data == "autorenew_off" {
+		if err := app.SubscriptionService.SetAutoRenew(ctx, botID, tgID, false); err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось отключить автопродление."))
 			return
 		}
+
+		bot.Send(tgbotapi.NewMessage(chatID,
+			"Автопродление отключено. Подписка будет действовать до конца оплаченного периода."))
+		return
 	}
 
 	// ---------------------------
@@ -191,6 +194,16 @@ func (app *BotApp) handleCallback(
 		return
 	}
 
+	if data == "invite" {
+		app.sendInviteLink(ctx, botID, bot, tgID, chatID)
+		return
+	}
+
+	if data == "promo" {
+		app.askPromo(botID, bot, tgID, chatID)
+		return
+	}
+
 	if data == "docs" {
 		bot.Send(tgbotapi.NewMessage(chatID, RequisitesText))
 		bot.Send(tgbotapi.NewMessage(chatID, OfferText))
@@ -205,3 +218,110 @@ func (app *BotApp) handleCallback(
 	app.ErrorNotify.Notify(ctx, botID, err, "Неизвестный callback")
 	bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Произошла ошибка."))
 }
+
+// resumePayment — оплата по callback data пакета, подписки или подарка
+// (сразу из меню или после того, как пользователь оставил контакт для чека)
+func (app *BotApp) resumePayment(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	tgID int64,
+	chatID int64,
+	data string,
+	status string,
+) {
+	switch {
+	case strings.HasPrefix(data, "pkg_"):
+		id, _ := strconv.ParseInt(strings.TrimPrefix(data, "pkg_"), 10, 64)
+		app.startPackagePayment(ctx, botID, bot, tgID, chatID, id)
+
+	// подарок оплачивается независимо от своей подписки
+	case strings.HasPrefix(data, "subgift:"):
+		app.startGiftPayment(ctx, botID, bot, tgID, chatID, strings.TrimPrefix(data, "subgift:"))
+
+	case strings.HasPrefix(data, "subpay:"), strings.HasPrefix(data, "subauto:"):
+		if status == "pending" {
+			bot.Send(tgbotapi.NewMessage(chatID, "⏳ Ожидается подтверждение оплаты."))
+			return
+		}
+
+		autoRenew := strings.HasPrefix(data, "subauto:")
+		planCode := data[strings.Index(data, ":")+1:]
+
+		app.startSubscriptionPayment(ctx, botID, bot, tgID, chatID, planCode, autoRenew)
+	}
+}
+
+// startPackagePayment — заказ пакета минут и ссылка на оплату
+func (app *BotApp) startPackagePayment(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	tgID int64,
+	chatID int64,
+	packageID int64,
+) {
+	pkg, err := app.MinutePackageService.GetByID(ctx, botID, packageID)
+	if err != nil || pkg == nil || !pkg.Active {
+		bot.Send(tgbotapi.NewMessage(chatID, "❗ Пакет недоступен."))
+		return
+	}
+
+	payURL, err := app.MinutePackageService.CreatePayment(ctx, botID, tgID, pkg.ID)
+	if err != nil {
+		app.ErrorNotify.Notify(ctx, botID, err, "Ошибка создания платежа")
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось создать оплату."))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(
+		chatID,
+		"Перед оплатой ознакомьтесь с документами:",
+	)
+
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📄 Документы", "docs"),
+		),
+	)
+
+	bot.Send(msg)
+
+	// ссылка отдельным сообщением
+	bot.Send(tgbotapi.NewMessage(chatID, "Ссылка для оплаты:\n"+payURL))
+}
+
+// startSubscriptionPayment — заказ подписки и ссылка на оплату
+func (app *BotApp) startSubscriptionPayment(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	tgID int64,
+	chatID int64,
+	planCode string,
+	autoRenew bool,
+) {
+	paymentURL, err := app.SubscriptionService.Create(ctx, botID, tgID, planCode, autoRenew)
+	if err != nil {
+		app.ErrorNotify.Notify(ctx, botID, err, "Ошибка создания подписки")
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось создать оплату."))
+		return
+	}
+
+	// сообщение с кнопкой документов
+	msg := tgbotapi.NewMessage(
+		chatID,
+		"Перед оплатой ознакомьтесь с документами:",
+	)
+
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📄 Документы", "docs"),
+		),
+	)
+
+	bot.Send(msg)
+
+	// ссылка отдельным сообщением
+	bot.Send(tgbotapi.NewMessage(chatID, "💳 Ссылка для оплаты:\n"+paymentURL))
+}
@@ -2,10 +2,14 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 
+	"github.com/Vovarama1992/make_ziper/internal/ai"
+	"github.com/Vovarama1992/make_ziper/internal/entitlements"
+	"github.com/Vovarama1992/make_ziper/internal/quota"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -46,13 +50,27 @@ func (app *BotApp) handlePhoto(
 	//--------------------------------------------------------
 	// тариф
 	//--------------------------------------------------------
-	if !app.checkImageAllowed(ctx, botID, tgID) {
-		m := tgbotapi.NewMessage(chatID, "🖼 В этом тарифе разбор файлов недоступен.")
-		m.ReplyMarkup = mainKB
-		bot.Send(m)
+	if !app.allow(ctx, botID, bot, chatID, tgID, entitlements.Check{Feature: entitlements.FeaturePhoto}, mainKB) {
+		return
+	}
+
+	spends := []quota.Spend{
+		{Metric: quota.MetricMessages, N: 1},
+		{Metric: quota.MetricImages, N: 1},
+	}
+	charge, ok := app.consumeQuota(ctx, botID, bot, chatID, tgID, mainKB, spends...)
+	if !ok {
 		return
 	}
 
+	// до ответа AI не дошли — расход возвращаем
+	replied := false
+	defer func() {
+		if !replied {
+			app.releaseQuota(ctx, charge)
+		}
+	}()
+
 	//--------------------------------------------------------
 	// 1. Получаем файл из Telegram
 	//--------------------------------------------------------
@@ -115,6 +133,11 @@ func (app *BotApp) handlePhoto(
 		publicURL, // прямая картинка
 	)
 
+	if errors.Is(err, ai.ErrModelNotAllowed) {
+		bot.Request(tgbotapi.NewDeleteMessage(chatID, sentThinking.MessageID))
+		app.denyModel(ctx, botID, bot, chatID, tgID, mainKB)
+		return
+	}
 	if err != nil {
 		bot.Request(tgbotapi.NewDeleteMessage(chatID, sentThinking.MessageID))
 
@@ -123,17 +146,19 @@ func (app *BotApp) handlePhoto(
 		bot.Send(m)
 		return
 	}
+	replied = true
 
 	//--------------------------------------------------------
 	// 7. Ответ
 	//--------------------------------------------------------
-	out := tgbotapi.NewMessage(chatID, reply)
+	out := tgbotapi.NewMessage(chatID, reply.Text)
 	out.ReplyMarkup = mainKB
 	bot.Send(out)
 
 	//--------------------------------------------------------
-	// 8. История
+	// 8. История — ответ вместе с моделью, которая его дала
 	//--------------------------------------------------------
+	app.RecordService.AddReply(ctx, botID, tgID, reply.Text, reply.ModelRef())
 
 	//--------------------------------------------------------
 	// 9. Удаляем индикатор
@@ -2,8 +2,11 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"log"
 
+	"github.com/Vovarama1992/make_ziper/internal/ai"
+	"github.com/Vovarama1992/make_ziper/internal/quota"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -20,21 +23,43 @@ func (app *BotApp) handleText(
 
 	log.Printf("[text] start botID=%s tgID=%d", botID, tgID)
 
+	spend := quota.Spend{Metric: quota.MetricMessages, N: 1}
+	charge, ok := app.consumeQuota(ctx, botID, bot, chatID, tgID, mainKB, spend)
+	if !ok {
+		return
+	}
+
+	// до ответа AI не дошли — расход возвращаем
+	replied := false
+	defer func() {
+		if !replied {
+			app.releaseQuota(ctx, charge)
+		}
+	}()
+
 	// === 0. показываем 'AI думает…' ===
 	thinkingMsg := tgbotapi.NewMessage(chatID, "🤖 AI думает…")
 	thinkingMsg.ReplyMarkup = mainKB // ← держим меню
 	sentThinking, _ := bot.Send(thinkingMsg)
 
-	// === 1. GPT ===
-	reply, err := app.AiService.GetReply(
+	// === 1. GPT (ответ стримится прямо в сообщение "думает") ===
+	stream := newStreamReply(bot, chatID, sentThinking.MessageID)
+
+	reply, err := app.AiService.GetReplyStream(
 		ctx,
 		botID,
 		tgID,
 		"text",
 		userText,
 		nil,
+		stream.Update,
 	)
 
+	if errors.Is(err, ai.ErrModelNotAllowed) {
+		stream.Delete()
+		app.denyModel(ctx, botID, bot, chatID, tgID, mainKB)
+		return
+	}
 	if err != nil {
 		log.Printf("[text] ai reply fail botID=%s tgID=%d: %v", botID, tgID, err)
 
@@ -42,23 +67,17 @@ func (app *BotApp) handleText(
 		out.ReplyMarkup = mainKB // ← держим меню
 		bot.Send(out)
 
-		del := tgbotapi.NewDeleteMessage(chatID, sentThinking.MessageID)
-		bot.Request(del)
+		stream.Delete()
 		return
 	}
+	replied = true
 
-	// === 2. GPT ответ ===
-	out := tgbotapi.NewMessage(chatID, reply)
-	out.ReplyMarkup = mainKB // ← КРИТИЧЕСКОЕ МЕСТО
-	bot.Send(out)
+	// === 2. GPT ответ (финальная правка индикатора) ===
+	stream.Finish(reply.Text, mainKB)
 
 	// === 3. история ===
 	app.RecordService.AddText(ctx, botID, tgID, "user", userText)
-	app.RecordService.AddText(ctx, botID, tgID, "tutor", reply)
-
-	// === 4. удаляем индикатор "думает" ===
-	del := tgbotapi.NewDeleteMessage(chatID, sentThinking.MessageID)
-	bot.Request(del)
+	app.RecordService.AddReply(ctx, botID, tgID, reply.Text, reply.ModelRef())
 
 	log.Printf("[text] done botID=%s tgID=%d", botID, tgID)
 }
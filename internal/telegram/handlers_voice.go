@@ -2,12 +2,16 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"strings"
 
+	"github.com/Vovarama1992/make_ziper/internal/ai"
+	"github.com/Vovarama1992/make_ziper/internal/entitlements"
+	"github.com/Vovarama1992/make_ziper/internal/quota"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -22,6 +26,24 @@ func (app *BotApp) handleVoice(
 	chatID := msg.Chat.ID
 	fileID := msg.Voice.FileID
 
+	if !app.allow(ctx, botID, bot, chatID, tgID, entitlements.Check{Feature: entitlements.FeatureVoice}, mainKB) {
+		return
+	}
+
+	spend := quota.Spend{Metric: quota.MetricMessages, N: 1}
+	charge, ok := app.consumeQuota(ctx, botID, bot, chatID, tgID, mainKB, spend)
+	if !ok {
+		return
+	}
+
+	// до ответа AI не дошли — расход возвращаем
+	replied := false
+	defer func() {
+		if !replied {
+			app.releaseQuota(ctx, charge)
+		}
+	}()
+
 	if !app.checkVoiceAllowed(ctx, botID, tgID) {
 		m := tgbotapi.NewMessage(chatID, "🔇 В этом тарифе голос недоступен.")
 		m.ReplyMarkup = mainKB
@@ -74,7 +96,7 @@ func (app *BotApp) handleVoice(
 	out.Close()
 	defer os.Remove(path)
 
-	text, err := app.SpeechService.Transcribe(ctx, botID, path)
+	text, err := app.SpeechService.Transcribe(ctx, botID, tgID, path, float64(msg.Voice.Duration))
 	if err != nil {
 		m := tgbotapi.NewMessage(chatID, "⚠️ Не удалось распознать голос.")
 		m.ReplyMarkup = mainKB
@@ -89,6 +111,11 @@ func (app *BotApp) handleVoice(
 	sentThinking, _ := bot.Send(thinking)
 
 	reply, err := app.AiService.GetReply(ctx, botID, tgID, "voice", text, nil)
+	if errors.Is(err, ai.ErrModelNotAllowed) {
+		bot.Request(tgbotapi.NewDeleteMessage(chatID, sentThinking.MessageID))
+		app.denyModel(ctx, botID, bot, chatID, tgID, mainKB)
+		return
+	}
 	if err != nil {
 		bot.Request(tgbotapi.NewDeleteMessage(chatID, sentThinking.MessageID))
 		m := tgbotapi.NewMessage(chatID, "⚠️ Ошибка AI.")
@@ -96,8 +123,9 @@ func (app *BotApp) handleVoice(
 		bot.Send(m)
 		return
 	}
+	replied = true
 
-	processed, err := app.TextRuleService.Process(ctx, reply)
+	processed, err := app.TextRuleService.Process(ctx, reply.Text)
 	if err != nil {
 		bot.Request(tgbotapi.NewDeleteMessage(chatID, sentThinking.MessageID))
 		m := tgbotapi.NewMessage(chatID, "⚠️ Ошибка обработки текста.")
@@ -105,10 +133,10 @@ func (app *BotApp) handleVoice(
 		bot.Send(m)
 		return
 	}
-	reply = processed
+	replyText := processed
 
 	outVoice := fmt.Sprintf("/tmp/reply_%s.mp3", fileID)
-	if err := app.SpeechService.Synthesize(ctx, botID, reply, outVoice); err != nil {
+	if err := app.SpeechService.Synthesize(ctx, botID, tgID, replyText, outVoice); err != nil {
 		bot.Request(tgbotapi.NewDeleteMessage(chatID, sentThinking.MessageID))
 		m := tgbotapi.NewMessage(chatID, "⚠️ Ошибка озвучки.")
 		m.ReplyMarkup = mainKB
@@ -121,9 +149,9 @@ func (app *BotApp) handleVoice(
 
 	bot.Send(tgbotapi.NewVoice(chatID, tgbotapi.FilePath(outVoice)))
 
-	textMsg := tgbotapi.NewMessage(chatID, reply)
+	textMsg := tgbotapi.NewMessage(chatID, replyText)
 	textMsg.ReplyMarkup = mainKB
 	bot.Send(textMsg)
 
-	app.RecordService.AddText(ctx, botID, tgID, "tutor", reply)
+	app.RecordService.AddReply(ctx, botID, tgID, replyText, reply.ModelRef())
 }
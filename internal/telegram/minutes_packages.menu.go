@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/Vovarama1992/make_ziper/internal/promo"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
@@ -32,7 +33,7 @@ func (app *BotApp) BuildMinutePackagesMenu(
 			"%s — %d мин / %s",
 			p.Name,
 			p.Minutes,
-			formatRUB(p.Price),
+			app.priceLabel(ctx, botID, tgID, promo.TargetMinutePackage, p.Price),
 		)
 
 		rows = append(rows,
@@ -45,5 +46,9 @@ func (app *BotApp) BuildMinutePackagesMenu(
 		)
 	}
 
+	if app.Promo != nil && len(rows) > 0 {
+		rows = append(rows, app.promoButtonRow())
+	}
+
 	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
@@ -0,0 +1,192 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Vovarama1992/make_ziper/internal/promo"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// сколько ждём код после кнопки «Ввести промокод»
+const promoInputTTL = 5 * time.Minute
+
+func (app *BotApp) SetPromo(svc promo.Service) {
+	app.Promo = svc
+}
+
+// promoInput — кто нажал «Ввести промокод» и чьё следующее сообщение считается кодом
+type promoInput struct {
+	mu    sync.Mutex
+	until map[floodKey]time.Time
+}
+
+func newPromoInput() *promoInput {
+	return &promoInput{until: make(map[floodKey]time.Time)}
+}
+
+func (p *promoInput) Wait(botID string, tgID int64, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// заодно чистим протухшие ожидания
+	for key, until := range p.until {
+		if now.After(until) {
+			delete(p.until, key)
+		}
+	}
+	p.until[floodKey{botID: botID, tgID: tgID}] = now.Add(promoInputTTL)
+}
+
+// Take — ждали ли код от пользователя (ожидание снимается)
+func (p *promoInput) Take(botID string, tgID int64, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := floodKey{botID: botID, tgID: tgID}
+	until, ok := p.until[key]
+	delete(p.until, key)
+	return ok && !now.After(until)
+}
+
+// promoButtonRow — кнопка ввода промокода для меню тарифов и пакетов
+func (app *BotApp) promoButtonRow() []tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🎟 Ввести промокод", "promo"),
+	)
+}
+
+// priceLabel — цена в меню с учётом применённого промокода
+func (app *BotApp) priceLabel(ctx context.Context, botID string, tgID int64, target string, price float64) string {
+	if app.Promo == nil {
+		return formatRUB(price)
+	}
+
+	d, err := app.Promo.Preview(ctx, botID, tgID, target, price)
+	if err != nil {
+		log.Printf("[promo] preview bot=%s tg=%d: %v", botID, tgID, err)
+		return formatRUB(price)
+	}
+	if d == nil || d.Off <= 0 {
+		return formatRUB(price)
+	}
+	return formatRUB(price) + " → " + formatRUB(d.Price)
+}
+
+// askPromo — callback "promo": следующее сообщение пользователя — код
+func (app *BotApp) askPromo(botID string, bot *tgbotapi.BotAPI, tgID, chatID int64) {
+	if app.Promo == nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "Промокоды сейчас не принимаются."))
+		return
+	}
+
+	app.promoInput.Wait(botID, tgID, time.Now())
+	bot.Send(tgbotapi.NewMessage(chatID, "🎟 Отправь промокод одним сообщением."))
+}
+
+// handlePromoText — "/promo CODE" или ответ на «Ввести промокод».
+// true — сообщение было промокодом и уже обработано.
+func (app *BotApp) handlePromoText(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	tgID int64,
+	chatID int64,
+	text string,
+) bool {
+	if app.Promo == nil || text == "" {
+		return false
+	}
+
+	var code string
+	switch {
+	case text == "/promo" || strings.HasPrefix(text, "/promo "):
+		code = strings.TrimSpace(strings.TrimPrefix(text, "/promo"))
+		if code == "" {
+			app.askPromo(botID, bot, tgID, chatID)
+			return true
+		}
+	case app.promoInput.Take(botID, tgID, time.Now()):
+		code = text
+	default:
+		return false
+	}
+
+	app.applyPromo(ctx, botID, bot, tgID, chatID, code)
+	return true
+}
+
+func (app *BotApp) applyPromo(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	tgID int64,
+	chatID int64,
+	code string,
+) {
+	c, err := app.Promo.Apply(ctx, botID, tgID, code)
+	if err != nil {
+		var text string
+		switch {
+		case errors.Is(err, promo.ErrNotFound):
+			text = "❗ Такого промокода нет. Проверь написание."
+		case errors.Is(err, promo.ErrExpired):
+			text = "⌛ Срок действия промокода закончился."
+		case errors.Is(err, promo.ErrExhausted):
+			text = "❗ Промокод больше недоступен — все активации израсходованы."
+		case errors.Is(err, promo.ErrAlreadyUsed):
+			text = "❗ Ты уже использовал этот промокод."
+		default:
+			app.ErrorNotify.Notify(ctx, botID, err, "Ошибка применения промокода")
+			text = "⚠️ Не удалось применить промокод. Попробуй позже."
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, text))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, promoText(c))
+	if c.AppliesTo == promo.TargetMinutePackage {
+		msg.ReplyMarkup = app.BuildMinutePackagesMenu(ctx, botID, tgID)
+	} else {
+		msg.ReplyMarkup = app.BuildSubscriptionMenu(ctx, botID, tgID)
+	}
+	bot.Send(msg)
+}
+
+// promoText — что даёт промокод, человеческим языком
+func promoText(c *promo.Code) string {
+	var parts []string
+
+	switch {
+	case c.PercentOff > 0:
+		parts = append(parts, fmt.Sprintf("скидка %d%%", c.PercentOff))
+	case c.AmountOff > 0:
+		parts = append(parts, "скидка "+formatRUB(c.AmountOff))
+	}
+	if c.BonusDays > 0 {
+		parts = append(parts, fmt.Sprintf("+%d дн подписки", c.BonusDays))
+	}
+	if c.BonusMinutes > 0 {
+		parts = append(parts, fmt.Sprintf("+%.0f мин голоса", c.BonusMinutes))
+	}
+
+	target := "на тариф или пакет минут"
+	switch c.AppliesTo {
+	case promo.TargetSubscription:
+		target = "на тариф"
+	case promo.TargetMinutePackage:
+		target = "на пакет минут"
+	}
+
+	return fmt.Sprintf(
+		"✅ Промокод %s применён: %s.\nДействует %s при следующей оплате — выбери ниже ⬇️",
+		c.Code,
+		strings.Join(parts, ", "),
+		target,
+	)
+}
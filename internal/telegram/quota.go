@@ -0,0 +1,118 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/Vovarama1992/make_ziper/internal/entitlements"
+	"github.com/Vovarama1992/make_ziper/internal/ports"
+	"github.com/Vovarama1992/make_ziper/internal/quota"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (app *BotApp) SetQuota(svc quota.Service) {
+	app.Quota = svc
+}
+
+// consumeQuota — списывает расход по лимитам тарифа (лимиты берутся из ctx, см. withEntitlements).
+// При превышении сам отвечает «лимит исчерпан» с кнопкой апгрейда. Сбой учёта не блокирует.
+// Charge передаётся в releaseQuota, если запрос к AI не состоялся.
+func (app *BotApp) consumeQuota(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	chatID int64,
+	tgID int64,
+	mainKB tgbotapi.ReplyKeyboardMarkup,
+	spends ...quota.Spend,
+) (*quota.Charge, bool) {
+	if app.Quota == nil {
+		return nil, true
+	}
+
+	ent := entitlements.FromContext(ctx)
+
+	var limits ports.TariffQuotas
+	if ent != nil {
+		limits = ent.Features.Quotas
+	}
+
+	charge, ex, err := app.Quota.Consume(ctx, botID, tgID, limits, spends...)
+	if err != nil {
+		log.Printf("[quota] consume fail bot=%s tg=%d err=%v", botID, tgID, err)
+		return nil, true
+	}
+	if ex == nil {
+		return charge, true
+	}
+
+	app.sendQuotaExceeded(ctx, botID, bot, chatID, tgID, ent, ex, mainKB)
+	return nil, false
+}
+
+// releaseQuota — запрос к AI не состоялся, расход возвращаем в те окна, где списали
+func (app *BotApp) releaseQuota(ctx context.Context, charge *quota.Charge) {
+	if app.Quota == nil {
+		return
+	}
+	app.Quota.Release(ctx, charge)
+}
+
+func (app *BotApp) sendQuotaExceeded(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	chatID int64,
+	tgID int64,
+	ent *entitlements.Entitlements,
+	ex *quota.Exceeded,
+	mainKB tgbotapi.ReplyKeyboardMarkup,
+) {
+	what := map[string]string{
+		quota.MetricMessages: "запросов",
+		quota.MetricImages:   "фото",
+		quota.MetricPDFPages: "страниц PDF",
+	}[ex.Metric]
+
+	per := "в сутки"
+	if ex.Window == quota.WindowMonth {
+		per = "в месяц"
+	}
+
+	text := fmt.Sprintf("⏳ В %s — %d %s %s, лимит исчерпан.", planPhrase(ent), ex.Limit, what, per)
+	if ex.Need > 1 {
+		left := max(ex.Limit-ex.Used, 0)
+		text = fmt.Sprintf("⏳ В %s — %d %s %s: нужно %d, осталось %d.", planPhrase(ent), ex.Limit, what, per, ex.Need, left)
+	}
+	text += fmt.Sprintf("\nЛимит обновится %s.", ex.ResetsAt.Format("02.01 в 15:04 (MST)"))
+
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = mainKB
+
+	// самый дешёвый тариф, где этого хватит; нет такого — весь список тарифов
+	var upgrade *ports.TariffPlan
+	if app.Entitlements != nil && ent != nil {
+		d, err := app.Entitlements.Authorize(ctx, botID, tgID, ent, entitlements.Check{
+			Feature: entitlements.FeatureQuota,
+			Metric:  ex.Metric,
+			Window:  ex.Window,
+			Used:    ex.Used + ex.Need,
+		})
+		if err != nil {
+			log.Printf("[quota] upgrade lookup fail bot=%s tg=%d err=%v", botID, tgID, err)
+		} else if d != nil {
+			upgrade = d.Upgrade
+		}
+	}
+
+	if upgrade != nil {
+		m.Text += fmt.Sprintf("\n\nБольше — в тарифе «%s» за %s.", upgrade.Name, formatRUB(upgrade.Price))
+		m.ReplyMarkup = upgradeKeyboard(upgrade)
+	} else {
+		m.Text += "\n\nМожно выбрать другой тариф:"
+		m.ReplyMarkup = app.BuildSubscriptionMenu(ctx, botID, tgID)
+	}
+
+	bot.Send(m)
+}
@@ -0,0 +1,109 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Vovarama1992/make_ziper/internal/referral"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (app *BotApp) SetReferral(svc referral.Service) {
+	app.Referral = svc
+}
+
+// handleReferralText — "/start ref_<id>" и "/invite".
+// true — сообщение обработано; /start нового пользователя идёт дальше в онбординг.
+func (app *BotApp) handleReferralText(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	msg *tgbotapi.Message,
+	tgID int64,
+	status string,
+) bool {
+	if app.Referral == nil || !msg.IsCommand() {
+		return false
+	}
+
+	switch msg.Command() {
+	case "invite":
+		app.sendInviteLink(ctx, botID, bot, tgID, msg.Chat.ID)
+		return true
+
+	case "start":
+		referrerID, ok := referral.ParsePayload(msg.CommandArguments())
+		if !ok {
+			return false
+		}
+
+		err := app.Referral.Attach(ctx, botID, referrerID, tgID)
+		switch {
+		case err == nil:
+		case errors.Is(err, referral.ErrDisabled),
+			errors.Is(err, referral.ErrSelfReferral),
+			errors.Is(err, referral.ErrUnknownReferrer),
+			errors.Is(err, referral.ErrNotNewUser),
+			errors.Is(err, referral.ErrAlreadyReferred):
+			log.Printf("[referral] skip bot=%s referrer=%d invitee=%d: %v", botID, referrerID, tgID, err)
+		default:
+			app.ErrorNotify.Notify(ctx, botID, err, "Ошибка реферальной ссылки")
+		}
+
+		// с подпиской /start в диалог с AI не отправляем
+		return status == "active"
+	}
+
+	return false
+}
+
+// referralEnabled — показывать ли «Пригласить друга»
+func (app *BotApp) referralEnabled(ctx context.Context, botID string) bool {
+	if app.Referral == nil {
+		return false
+	}
+	st, err := app.Referral.GetSettings(ctx, botID)
+	return err == nil && st != nil && st.Enabled
+}
+
+// sendInviteLink — личная ссылка пользователя и условия программы
+func (app *BotApp) sendInviteLink(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	tgID int64,
+	chatID int64,
+) {
+	if app.Referral == nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "Приглашения сейчас не действуют."))
+		return
+	}
+
+	st, err := app.Referral.GetSettings(ctx, botID)
+	if err != nil {
+		app.ErrorNotify.Notify(ctx, botID, err, "Ошибка загрузки реферальной программы")
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Не удалось получить ссылку. Попробуй позже."))
+		return
+	}
+	if st == nil || !st.Enabled {
+		bot.Send(tgbotapi.NewMessage(chatID, "Приглашения сейчас не действуют."))
+		return
+	}
+
+	var bonus string
+	if st.BonusDays > 0 {
+		bonus += fmt.Sprintf("\n📅 +%d дн подписки", st.BonusDays)
+	}
+	if st.BonusMinutes > 0 {
+		bonus += fmt.Sprintf("\n🎧 +%.0f мин голоса", st.BonusMinutes)
+	}
+
+	link := fmt.Sprintf("https://t.me/%s?start=%s", bot.Self.UserName, referral.Payload(tgID))
+
+	bot.Send(tgbotapi.NewMessage(chatID,
+		"🤝 Пригласи одноклассника по своей ссылке.\n"+
+			"Когда он оформит подписку, ты получишь:"+bonus+
+			"\n\nТвоя ссылка:\n"+link))
+}
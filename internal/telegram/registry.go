@@ -0,0 +1,149 @@
+package telegram
+
+import (
+	"context"
+	"log"
+
+	"github.com/Vovarama1992/make_ziper/internal/bots"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ==================================================
+// BOT REGISTRY
+// ==================================================
+
+// runningBot — запущенный runBotLoop конкретного бота
+type runningBot struct {
+	bot    *tgbotapi.BotAPI
+	token  string
+	cancel context.CancelFunc
+}
+
+// startBot — поднимает бота и его цикл апдейтов. Уже запущенный с тем же botID останавливается.
+// Вызывается под lifecycleMu.
+func (app *BotApp) startBot(botID, token string) error {
+	bot, err := tgbotapi.NewBotAPI(token)
+	if err != nil {
+		return err
+	}
+
+	app.stopBot(botID)
+
+	updates, err := app.updatesChan(botID, bot, 30)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(app.runCtx)
+
+	app.botsMu.Lock()
+	app.bots[botID] = bot
+	app.running[botID] = &runningBot{
+		bot:    bot,
+		token:  token,
+		cancel: cancel,
+	}
+	app.botsMu.Unlock()
+
+	log.Printf("[bot_app] ready: @%s (%s)", bot.Self.UserName, botID)
+
+	app.goLoop(func() { app.runBotLoop(ctx, botID, bot, updates) })
+
+	app.notifyBotsChanged()
+	return nil
+}
+
+// stopBot — останавливает цикл апдейтов и убирает бота из реестра. Вызывается под lifecycleMu.
+func (app *BotApp) stopBot(botID string) {
+	app.botsMu.Lock()
+	rb, ok := app.running[botID]
+	if ok {
+		delete(app.running, botID)
+		delete(app.bots, botID)
+	}
+	app.botsMu.Unlock()
+
+	if !ok {
+		return
+	}
+
+	// в вебхук-режиме сначала закрываем канал: всё принятое хендлером уже в буфере,
+	// и цикл дочитает его перед выходом
+	if app.updates.mode == updatesModeWebhook {
+		app.webhooks.close(botID)
+	}
+
+	rb.cancel()
+
+	if app.updates.mode == updatesModeWebhook {
+		if _, err := rb.bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			log.Printf("[bot_app] delete webhook fail botID=%s err=%v", botID, err)
+		}
+	} else {
+		rb.bot.StopReceivingUpdates()
+	}
+
+	log.Printf("[bot_app] stopped: %s", botID)
+
+	app.notifyBotsChanged()
+}
+
+// SetBotsChangedHook — вызывается со снимком карты ботов после каждого изменения реестра
+func (app *BotApp) SetBotsChangedHook(fn func(map[string]*tgbotapi.BotAPI)) {
+	app.onBotsChanged = fn
+}
+
+func (app *BotApp) notifyBotsChanged() {
+	if app.onBotsChanged != nil {
+		app.onBotsChanged(app.GetBots())
+	}
+}
+
+// ==================================================
+// bots.ChangeListener
+// ==================================================
+
+var _ bots.ChangeListener = (*BotApp)(nil)
+
+func (app *BotApp) BotUpserted(ctx context.Context, prevBotID string, cfg *bots.BotConfig) {
+	// параллельные PATCH/POST/DELETE одного бота иначе поднимут два цикла,
+	// и cancel первого потеряется
+	app.lifecycleMu.Lock()
+	defer app.lifecycleMu.Unlock()
+
+	// после SIGTERM новые циклы не поднимаем (Shutdown ставит флаг под тем же lifecycleMu)
+	if app.stopping.Load() {
+		return
+	}
+
+	app.botsMu.RLock()
+	rb, ok := app.running[prevBotID]
+	app.botsMu.RUnlock()
+
+	// токен и bot_id не менялись — перезапуск не нужен
+	if ok && prevBotID == cfg.BotID && rb.token == cfg.Token {
+		return
+	}
+
+	if prevBotID != cfg.BotID {
+		app.stopBot(prevBotID)
+	}
+
+	if cfg.Token == "" {
+		app.stopBot(cfg.BotID)
+		return
+	}
+
+	if err := app.startBot(cfg.BotID, cfg.Token); err != nil {
+		log.Printf("[bot_app] reload fail for %s: %v", cfg.BotID, err)
+		app.stopBot(cfg.BotID)
+	}
+}
+
+func (app *BotApp) BotDeleted(ctx context.Context, botID string) {
+	app.lifecycleMu.Lock()
+	defer app.lifecycleMu.Unlock()
+
+	app.stopBot(botID)
+}
@@ -0,0 +1,72 @@
+package telegram
+
+import (
+	"context"
+	"log"
+)
+
+// ==================================================
+// GRACEFUL SHUTDOWN
+// ==================================================
+
+// Shutdown — перестаёт принимать апдейты и ждёт, пока начатые обработчики
+// (GPT, синтез голоса, запись истории) доработают. Не дольше, чем живёт ctx.
+// Вебхуки не снимаем: Telegram придержит апдейты до следующего запуска.
+func (app *BotApp) Shutdown(ctx context.Context) error {
+	// под lifecycleMu: начатый перезапуск бота доходит до конца и попадает в running,
+	// следующий уже увидит stopping
+	app.lifecycleMu.Lock()
+	if app.stopping.Swap(true) {
+		app.lifecycleMu.Unlock()
+		return nil
+	}
+
+	log.Println("[bot_app] shutdown: stop receiving updates")
+
+	// каналы вебхуков закрываем до отмены циклов — апдейт, принятый с 200, не потеряется
+	if app.updates.mode == updatesModeWebhook {
+		app.webhooks.closeAll()
+	}
+
+	app.botsMu.RLock()
+	for _, rb := range app.running {
+		rb.cancel()
+		if app.updates.mode == updatesModePolling {
+			rb.bot.StopReceivingUpdates()
+		}
+	}
+	app.botsMu.RUnlock()
+	app.lifecycleMu.Unlock()
+
+	if app.adminBot != nil {
+		app.adminBot.cancel()
+		if app.updates.mode == updatesModePolling {
+			app.adminBot.bot.StopReceivingUpdates()
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		app.loops.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("[bot_app] shutdown: handlers drained")
+		return nil
+
+	case <-ctx.Done():
+		log.Printf("[bot_app] shutdown: drain deadline exceeded: %v", ctx.Err())
+		return ctx.Err()
+	}
+}
+
+// goLoop — фоновая горутина, которую Shutdown дожидается
+func (app *BotApp) goLoop(fn func()) {
+	app.loops.Add(1)
+	go func() {
+		defer app.loops.Done()
+		fn()
+	}()
+}
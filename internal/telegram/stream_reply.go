@@ -0,0 +1,160 @@
+package telegram
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// Telegram режет editMessageText примерно после одной правки в секунду на чат
+	streamEditInterval = 1200 * time.Millisecond
+	// не дёргаем Telegram ради пары новых символов
+	streamMinDelta = 40
+	// лимит длины текста одного сообщения
+	tgMaxMessageLen = 4096
+
+	streamCursor = " ▍"
+
+	// ответ начался заново (повтор или другая модель) — вместо недописанного текста
+	streamRestartText = "🤖 AI думает…"
+)
+
+// streamReply — прогрессивно правит сообщение «AI думает…» текстом ответа
+type streamReply struct {
+	bot    *tgbotapi.BotAPI
+	chatID int64
+	msgID  int
+
+	lastEdit time.Time
+	lastText string
+}
+
+func newStreamReply(bot *tgbotapi.BotAPI, chatID int64, msgID int) *streamReply {
+	return &streamReply{
+		bot:    bot,
+		chatID: chatID,
+		msgID:  msgID,
+	}
+}
+
+// Update — колбэк для стриминга: накопленный текст ответа.
+// Правки троттлятся, лишние промежуточные состояния просто пропускаются.
+// "" или текст, не продолжающий показанный, — ответ пошёл заново: недописанное убираем сразу.
+func (s *streamReply) Update(text string) {
+	if s.msgID == 0 {
+		return
+	}
+
+	if s.lastText != "" && !strings.HasPrefix(text, s.lastText) {
+		s.lastText = ""
+		// в паузе после 429 не правим — текст заменится первой правкой после неё
+		if !time.Now().Before(s.lastEdit) {
+			s.edit(streamRestartText)
+		}
+		return
+	}
+
+	if time.Now().Before(s.lastEdit.Add(streamEditInterval)) {
+		return
+	}
+	if len(text)-len(s.lastText) < streamMinDelta {
+		return
+	}
+
+	runes := []rune(text)
+	if len(runes) > tgMaxMessageLen-len([]rune(streamCursor)) {
+		// дальше лимита показывать нечего — финал уйдёт отдельными сообщениями
+		return
+	}
+
+	s.edit(text + streamCursor)
+	// курсор не считаем частью текста, иначе дельта «съедается»
+	s.lastText = text
+}
+
+// Finish — финальный ответ.
+// Правка на месте клавиатуру не меняет: kb уже висит на индикаторе (его отправляют с mainKB),
+// а reply-клавиатуру editMessageText и не принимает.
+// Если ответ не влезает в одно сообщение или правка не удалась —
+// удаляем индикатор и отправляем ответ обычными сообщениями с kb.
+func (s *streamReply) Finish(text string, kb tgbotapi.ReplyKeyboardMarkup) {
+	if strings.TrimSpace(text) == "" {
+		s.Delete()
+		return
+	}
+
+	if s.msgID != 0 && len([]rune(text)) <= tgMaxMessageLen {
+		if s.edit(text) {
+			return
+		}
+	}
+
+	s.Delete()
+
+	for _, part := range splitMessage(text, tgMaxMessageLen) {
+		out := tgbotapi.NewMessage(s.chatID, part)
+		out.ReplyMarkup = kb
+		if _, err := s.bot.Send(out); err != nil {
+			log.Printf("[stream] send fail chat=%d err=%v", s.chatID, err)
+		}
+	}
+}
+
+// Delete — убирает индикатор
+func (s *streamReply) Delete() {
+	if s.msgID == 0 {
+		return
+	}
+	s.bot.Request(tgbotapi.NewDeleteMessage(s.chatID, s.msgID))
+	s.msgID = 0
+}
+
+func (s *streamReply) edit(text string) bool {
+	_, err := s.bot.Request(tgbotapi.NewEditMessageText(s.chatID, s.msgID, text))
+	s.lastEdit = time.Now()
+
+	if err != nil {
+		// "message is not modified" — не ошибка
+		if strings.Contains(err.Error(), "message is not modified") {
+			return true
+		}
+
+		log.Printf("[stream] edit fail chat=%d msg=%d err=%v", s.chatID, s.msgID, err)
+
+		// 429 — выдерживаем паузу, которую попросил Telegram
+		var tgErr *tgbotapi.Error
+		if errors.As(err, &tgErr) && tgErr.RetryAfter > 0 {
+			s.lastEdit = time.Now().Add(time.Duration(tgErr.RetryAfter) * time.Second)
+		}
+		return false
+	}
+
+	return true
+}
+
+// splitMessage — режет текст на куски не длиннее limit рун, по возможности по переносу строки
+func splitMessage(text string, limit int) []string {
+	runes := []rune(text)
+	var parts []string
+
+	for len(runes) > limit {
+		cut := limit
+		for i := limit; i > limit/2; i-- {
+			if runes[i-1] == '\n' {
+				cut = i
+				break
+			}
+		}
+		parts = append(parts, string(runes[:cut]))
+		runes = runes[cut:]
+	}
+
+	if len(runes) > 0 {
+		parts = append(parts, string(runes))
+	}
+	return parts
+}
@@ -7,12 +7,14 @@ import (
 	"math"
 	"strings"
 
+	"github.com/Vovarama1992/make_ziper/internal/promo"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 func (app *BotApp) BuildSubscriptionMenu(
 	ctx context.Context,
 	botID string,
+	tgID int64,
 ) tgbotapi.InlineKeyboardMarkup {
 
 	tariffs, err := app.TariffService.ListAll(ctx)
@@ -63,7 +65,7 @@ func (app *BotApp) BuildSubscriptionMenu(
 		label := fmt.Sprintf(
 			"%s — %s (%s, %s)",
 			t.Name,
-			formatRUB(t.Price),
+			app.priceLabel(ctx, botID, tgID, promo.TargetSubscription, t.Price),
 			minutesToDays(t.DurationMinutes),
 			voice,
 		)
@@ -95,6 +97,24 @@ func (app *BotApp) BuildSubscriptionMenu(
 		return errorMenu("Нет доступных тарифов")
 	}
 
+	if app.Promo != nil {
+		rows = append(rows, app.promoButtonRow())
+	}
+	if app.referralEnabled(ctx, botID) {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🤝 Пригласить друга", "invite"),
+		))
+	}
+
+	// автопродление включено — даём выключить прямо из меню
+	if sub, err := app.SubscriptionService.Get(ctx, botID, tgID); err == nil && sub != nil && sub.AutoRenew {
+		rows = append(rows,
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("❌ Отменить автопродление", "autorenew_off"),
+			),
+		)
+	}
+
 	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
 
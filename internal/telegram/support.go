@@ -0,0 +1,435 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/Vovarama1992/make_ziper/internal/support"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// сколько ждём первое сообщение после «Помощь»; дальше режим держится открытым обращением
+	supportInputTTL = 30 * time.Minute
+
+	// больше Bot API всё равно не отдаёт (getFile — до 20 МБ)
+	maxSupportFile = 20 << 20
+
+	// админ-бот разбирает апдейты по одному: зависшая загрузка не должна стопорить поддержку
+	supportDownloadTimeout = 60 * time.Second
+
+	supportExitData = "support:exit"
+)
+
+var supportHTTPClient = &http.Client{Timeout: supportDownloadTimeout}
+
+func (app *BotApp) SetSupport(svc support.Service) {
+	app.Support = svc
+}
+
+// supportInput — кто нажал «Помощь», но ещё ничего не написал (обращения пока нет)
+type supportInput struct {
+	mu      sync.Mutex
+	pending map[floodKey]time.Time
+}
+
+func newSupportInput() *supportInput {
+	return &supportInput{pending: make(map[floodKey]time.Time)}
+}
+
+func (s *supportInput) Wait(botID string, tgID int64, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// заодно чистим протухшие ожидания
+	for key, until := range s.pending {
+		if now.After(until) {
+			delete(s.pending, key)
+		}
+	}
+	s.pending[floodKey{botID: botID, tgID: tgID}] = now.Add(supportInputTTL)
+}
+
+func (s *supportInput) Waiting(botID string, tgID int64, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, ok := s.pending[floodKey{botID: botID, tgID: tgID}]
+	return ok && now.Before(until)
+}
+
+func (s *supportInput) Done(botID string, tgID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.pending, floodKey{botID: botID, tgID: tgID})
+}
+
+// supportSessions — пользователи с открытым в боте обращением.
+// Без него каждое сообщение каждого пользователя ходило бы в БД за обращением.
+type supportSessions struct {
+	mu   sync.RWMutex
+	open map[floodKey]struct{}
+}
+
+func newSupportSessions() *supportSessions {
+	return &supportSessions{open: make(map[floodKey]struct{})}
+}
+
+// Load — обращения, открытые до перезапуска
+func (s *supportSessions) Load(tickets []*support.Ticket) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range tickets {
+		s.open[floodKey{botID: t.BotID, tgID: t.TelegramID}] = struct{}{}
+	}
+}
+
+func (s *supportSessions) Add(botID string, tgID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.open[floodKey{botID: botID, tgID: tgID}] = struct{}{}
+}
+
+func (s *supportSessions) Has(botID string, tgID int64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.open[floodKey{botID: botID, tgID: tgID}]
+	return ok
+}
+
+func (s *supportSessions) Remove(botID string, tgID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.open, floodKey{botID: botID, tgID: tgID})
+}
+
+// ==================================================
+// TENANT BOT: РЕЖИМ ПОДДЕРЖКИ
+// ==================================================
+
+// supportInBot — переписка с поддержкой идёт в самом боте через админ-бот
+func (app *BotApp) supportInBot() bool {
+	return app.Support != nil && app.getAdminBot() != nil
+}
+
+// supportSession — открытое в этом боте обращение; nil — режима поддержки нет.
+// В БД идём, только если обращение есть в supportSessions.
+func (app *BotApp) supportSession(ctx context.Context, botID string, tgID int64) (*support.Ticket, error) {
+	if !app.supportSessions.Has(botID, tgID) {
+		return nil, nil
+	}
+
+	t, err := app.Support.GetOpen(ctx, botID, tgID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil || t.Channel != support.ChannelBot {
+		// закрыто мимо этого процесса
+		app.supportSessions.Remove(botID, tgID)
+		return nil, nil
+	}
+	return t, nil
+}
+
+// startSupport — кнопка «Помощь»: дальше сообщения уходят админам, AI молчит
+func (app *BotApp) startSupport(ctx context.Context, botID string, bot *tgbotapi.BotAPI, tgID, chatID int64) {
+	t, err := app.supportSession(ctx, botID, tgID)
+	if err != nil {
+		app.ErrorNotify.Notify(ctx, botID, err, "Ошибка загрузки обращения в поддержку")
+		bot.Send(tgbotapi.NewMessage(chatID, "⚠️ Поддержка временно недоступна. Попробуй позже."))
+		return
+	}
+
+	text := "🆘 Режим поддержки.\n\n" +
+		"Опиши проблему — можно несколькими сообщениями, с фото, скриншотом или голосовым. " +
+		"Ответ придёт сюда.\n\n" +
+		"Пока режим включён, репетитор не отвечает. Вернуться к занятиям — /exit"
+	if t != nil {
+		text = fmt.Sprintf("🆘 Обращение #%d открыто — пиши сюда, ответ придёт в этот чат.\n\nВернуться к занятиям — /exit", t.ID)
+	} else {
+		app.supportInput.Wait(botID, tgID, time.Now())
+	}
+
+	m := tgbotapi.NewMessage(chatID, text)
+	m.ReplyMarkup = supportExitKeyboard()
+	bot.Send(m)
+}
+
+// handleSupportMessage — сообщение в режиме поддержки уходит админам. true — обработано.
+// Кнопки главного меню работают как обычно.
+func (app *BotApp) handleSupportMessage(
+	ctx context.Context,
+	botID string,
+	bot *tgbotapi.BotAPI,
+	msg *tgbotapi.Message,
+	tgID int64,
+	status string,
+) bool {
+	if !app.supportInBot() || msg.From == nil {
+		return false
+	}
+	if msg.Text != "" && isMainButton(app.BuildMainKeyboard(botID, status), msg.Text) {
+		return false
+	}
+
+	waiting := app.supportInput.Waiting(botID, tgID, time.Now())
+	if !waiting && !app.supportSessions.Has(botID, tgID) {
+		return false
+	}
+
+	t, err := app.supportSession(ctx, botID, tgID)
+	if err != nil {
+		// обращение открыто — AI не включаем, но и не шлём алерт на каждое сообщение
+		log.Printf("[support] load ticket bot=%s tg=%d err=%v", botID, tgID, err)
+		bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "⚠️ Не удалось отправить сообщение в поддержку. Попробуй позже."))
+		return true
+	}
+	if t == nil && !waiting {
+		return false
+	}
+
+	if msg.Text == "/exit" {
+		app.exitSupport(ctx, botID, bot, tgID, msg.Chat.ID)
+		return true
+	}
+
+	t, created, err := app.getAdminBot().fromTenant(ctx, botID, bot, msg, t)
+	if err != nil {
+		app.ErrorNotify.Notify(ctx, botID, err, "Ошибка отправки сообщения в поддержку")
+		bot.Send(tgbotapi.NewMessage(msg.Chat.ID, "⚠️ Не удалось отправить сообщение в поддержку. Попробуй позже."))
+		return true
+	}
+	app.supportInput.Done(botID, tgID)
+	app.supportSessions.Add(botID, tgID)
+
+	if created {
+		m := tgbotapi.NewMessage(msg.Chat.ID,
+			fmt.Sprintf("✅ Обращение #%d создано. Ответ придёт сюда.", t.ID))
+		m.ReplyMarkup = supportExitKeyboard()
+		bot.Send(m)
+	}
+	return true
+}
+
+// exitSupport — /exit или кнопка: обращение закрывается, AI снова отвечает
+func (app *BotApp) exitSupport(ctx context.Context, botID string, bot *tgbotapi.BotAPI, tgID, chatID int64) {
+	app.supportInput.Done(botID, tgID)
+	defer app.supportSessions.Remove(botID, tgID)
+
+	if app.supportInBot() {
+		t, err := app.supportSession(ctx, botID, tgID)
+		if err != nil {
+			app.ErrorNotify.Notify(ctx, botID, err, "Ошибка загрузки обращения в поддержку")
+		}
+		if t != nil {
+			if _, err := app.Support.Close(ctx, t.ID); err != nil {
+				app.ErrorNotify.Notify(ctx, botID, err, fmt.Sprintf("Ошибка закрытия обращения #%d", t.ID))
+			} else {
+				app.getAdminBot().closedByUser(t)
+			}
+		}
+	}
+
+	bot.Send(tgbotapi.NewMessage(chatID, "↩️ Режим поддержки выключен, можно продолжать занятия."))
+}
+
+func supportExitKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Выйти из поддержки", supportExitData),
+		),
+	)
+}
+
+func isMainButton(kb tgbotapi.ReplyKeyboardMarkup, text string) bool {
+	for _, row := range kb.Keyboard {
+		for _, b := range row {
+			if b.Text == text {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// supportContext — откуда пришёл пользователь: бот, подписка, класс
+func (app *BotApp) supportContext(ctx context.Context, botID string, tgID int64) support.Context {
+	c := support.Context{BotID: botID}
+	if botID == "" {
+		return c
+	}
+
+	if status, err := app.SubscriptionService.GetStatus(ctx, botID, tgID); err == nil {
+		c.SubStatus = status
+	} else {
+		log.Printf("[support] sub status bot=%s tg=%d err=%v", botID, tgID, err)
+	}
+
+	uc, err := app.ClassService.GetUserClass(ctx, botID, tgID)
+	if err != nil {
+		log.Printf("[support] user class bot=%s tg=%d err=%v", botID, tgID, err)
+		return c
+	}
+	if uc != nil {
+		if cl, err := app.ClassService.GetClassByID(ctx, botID, uc.ClassID); err == nil && cl != nil {
+			c.ClassGrade = cl.Grade
+		}
+	}
+	return c
+}
+
+// ==================================================
+// ДОСТАВКА СООБЩЕНИЙ
+// ==================================================
+
+// supportMessage — вид, текст (или подпись) и file_id сообщения для истории обращения
+func supportMessage(msg *tgbotapi.Message) (kind, text, fileID string) {
+	switch {
+	case len(msg.Photo) > 0:
+		// последний размер — самый большой
+		return support.KindPhoto, msg.Caption, msg.Photo[len(msg.Photo)-1].FileID
+	case msg.Voice != nil:
+		return support.KindVoice, msg.Caption, msg.Voice.FileID
+	case msg.Audio != nil:
+		return support.KindAudio, msg.Caption, msg.Audio.FileID
+	case msg.Video != nil:
+		return support.KindVideo, msg.Caption, msg.Video.FileID
+	case msg.VideoNote != nil:
+		return support.KindVideoNote, "", msg.VideoNote.FileID
+	case msg.Animation != nil:
+		return support.KindAnimation, msg.Caption, msg.Animation.FileID
+	case msg.Document != nil:
+		return support.KindDocument, msg.Caption, msg.Document.FileID
+	case msg.Sticker != nil:
+		return support.KindSticker, "", msg.Sticker.FileID
+	default:
+		return support.KindText, msg.Text, ""
+	}
+}
+
+// captionable — можно ли подписать медиа при копировании (кружки и стикеры без подписи)
+func captionable(kind string) bool {
+	switch kind {
+	case support.KindText, support.KindVideoNote, support.KindSticker:
+		return false
+	default:
+		return true
+	}
+}
+
+// deliver — сообщение (текст или медиа) из чата бота from в чат chatID бота to, с заголовком.
+// В пределах одного бота медиа копируется, между ботами — перезаливается: file_id у каждого бота свой.
+func deliver(
+	ctx context.Context,
+	to *tgbotapi.BotAPI,
+	chatID int64,
+	from *tgbotapi.BotAPI,
+	msg *tgbotapi.Message,
+	header string,
+	markup any,
+) (int, error) {
+	kind, _, fileID := supportMessage(msg)
+
+	if kind == support.KindText {
+		out := tgbotapi.NewMessage(chatID, joinNonEmpty(header, msg.Text))
+		out.ReplyMarkup = markup
+		sent, err := to.Send(out)
+		return sent.MessageID, err
+	}
+
+	caption := ""
+	if captionable(kind) {
+		caption = joinNonEmpty(header, msg.Caption)
+	}
+
+	if to.Token == from.Token {
+		cp := tgbotapi.NewCopyMessage(chatID, msg.Chat.ID, msg.MessageID)
+		cp.Caption = caption
+		cp.ReplyMarkup = markup
+		sent, err := to.CopyMessage(cp)
+		return sent.MessageID, err
+	}
+
+	file, err := downloadFile(ctx, from, fileID)
+	if err != nil {
+		return 0, err
+	}
+	sent, err := to.Send(mediaConfig(chatID, kind, file, caption, markup))
+	return sent.MessageID, err
+}
+
+func downloadFile(ctx context.Context, bot *tgbotapi.BotAPI, fileID string) (tgbotapi.FileBytes, error) {
+	f, err := bot.GetFile(tgbotapi.FileConfig{FileID: fileID})
+	if err != nil {
+		return tgbotapi.FileBytes{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.Link(bot.Token), nil)
+	if err != nil {
+		return tgbotapi.FileBytes{}, err
+	}
+
+	resp, err := supportHTTPClient.Do(req)
+	if err != nil {
+		return tgbotapi.FileBytes{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return tgbotapi.FileBytes{}, fmt.Errorf("download file: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSupportFile))
+	if err != nil {
+		return tgbotapi.FileBytes{}, err
+	}
+	return tgbotapi.FileBytes{Name: path.Base(f.FilePath), Bytes: data}, nil
+}
+
+func mediaConfig(chatID int64, kind string, file tgbotapi.RequestFileData, caption string, markup any) tgbotapi.Chattable {
+	switch kind {
+	case support.KindPhoto:
+		c := tgbotapi.NewPhoto(chatID, file)
+		c.Caption, c.ReplyMarkup = caption, markup
+		return c
+	case support.KindVoice:
+		c := tgbotapi.NewVoice(chatID, file)
+		c.Caption, c.ReplyMarkup = caption, markup
+		return c
+	case support.KindAudio:
+		c := tgbotapi.NewAudio(chatID, file)
+		c.Caption, c.ReplyMarkup = caption, markup
+		return c
+	case support.KindVideo:
+		c := tgbotapi.NewVideo(chatID, file)
+		c.Caption, c.ReplyMarkup = caption, markup
+		return c
+	case support.KindVideoNote:
+		c := tgbotapi.NewVideoNote(chatID, 0, file)
+		c.ReplyMarkup = markup
+		return c
+	case support.KindAnimation:
+		c := tgbotapi.NewAnimation(chatID, file)
+		c.Caption, c.ReplyMarkup = caption, markup
+		return c
+	case support.KindSticker:
+		c := tgbotapi.NewSticker(chatID, file)
+		c.ReplyMarkup = markup
+		return c
+	default:
+		c := tgbotapi.NewDocument(chatID, file)
+		c.Caption, c.ReplyMarkup = caption, markup
+		return c
+	}
+}
@@ -0,0 +1,267 @@
+package telegram
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Vovarama1992/go-utils/httputil"
+	"github.com/go-chi/chi/v5"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ==================================================
+// UPDATES MODE
+// ==================================================
+
+const (
+	updatesModePolling = "polling"
+	updatesModeWebhook = "webhook"
+
+	// botID админ-бота в маршрутах вебхука
+	adminBotID = "admin-bot"
+
+	// буфер апдейтов от вебхука — как у GetUpdatesChan
+	webhookBufferSize = 100
+
+	webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
+)
+
+// updatesConfig — откуда бот берёт апдейты.
+// TG_UPDATES_MODE=polling (по умолчанию) | webhook
+type updatesConfig struct {
+	mode    string
+	baseURL string
+	secret  string
+}
+
+func loadUpdatesConfig() (updatesConfig, error) {
+	cfg := updatesConfig{
+		mode:    strings.ToLower(strings.TrimSpace(os.Getenv("TG_UPDATES_MODE"))),
+		baseURL: strings.TrimRight(os.Getenv("TG_WEBHOOK_BASE_URL"), "/"),
+		secret:  os.Getenv("TG_WEBHOOK_SECRET"),
+	}
+
+	switch cfg.mode {
+	case "", updatesModePolling:
+		cfg.mode = updatesModePolling
+	case updatesModeWebhook:
+		if cfg.baseURL == "" || cfg.secret == "" {
+			return cfg, fmt.Errorf("webhook mode requires TG_WEBHOOK_BASE_URL and TG_WEBHOOK_SECRET")
+		}
+	default:
+		return cfg, fmt.Errorf("unknown TG_UPDATES_MODE=%q", cfg.mode)
+	}
+
+	return cfg, nil
+}
+
+// webhookHub — каналы апдейтов ботов, которые пишет HTTP-хендлер вебхука.
+// Хендлер пишет в канал под RLock, закрывается канал под Lock — поэтому апдейт,
+// на который ответили 200, всегда успевает попасть в канал до close и будет вычитан циклом бота.
+type webhookHub struct {
+	mu    sync.RWMutex
+	chans map[string]*webhookChan
+}
+
+type webhookChan struct {
+	ch chan tgbotapi.Update
+	// закрывается первым: будит хендлеры, ждущие места в буфере, чтобы close не ждал их таймаута
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+func (c *webhookChan) stop() {
+	c.doneOnce.Do(func() { close(c.done) })
+}
+
+func newWebhookHub() *webhookHub {
+	return &webhookHub{
+		chans: make(map[string]*webhookChan),
+	}
+}
+
+func (h *webhookHub) register(botID string) chan tgbotapi.Update {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if old, ok := h.chans[botID]; ok {
+		old.stop()
+		close(old.ch)
+	}
+
+	c := &webhookChan{
+		ch:   make(chan tgbotapi.Update, webhookBufferSize),
+		done: make(chan struct{}),
+	}
+	h.chans[botID] = c
+	return c.ch
+}
+
+// close — убирает канал бота и закрывает его. После возврата новых апдейтов в канале не будет:
+// цикл бота дочитывает буфер и выходит по закрытому каналу.
+func (h *webhookHub) close(botID string) {
+	h.mu.RLock()
+	c, ok := h.chans[botID]
+	h.mu.RUnlock()
+	if !ok {
+		return
+	}
+
+	c.stop()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.chans[botID] == c {
+		delete(h.chans, botID)
+		close(c.ch)
+	}
+}
+
+// closeAll — close для всех ботов (остановка приложения)
+func (h *webhookHub) closeAll() {
+	h.mu.RLock()
+	for _, c := range h.chans {
+		c.stop()
+	}
+	h.mu.RUnlock()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for botID, c := range h.chans {
+		delete(h.chans, botID)
+		close(c.ch)
+	}
+}
+
+// send — кладёт апдейт в канал бота. false — бота нет, канал закрывается
+// или ждать места в буфере дольше нельзя: апдейт не принят.
+func (h *webhookHub) send(ctx context.Context, botID string, update tgbotapi.Update) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	c, ok := h.chans[botID]
+	if !ok {
+		return false
+	}
+
+	select {
+	case c.ch <- update:
+		return true
+	case <-c.done:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// ==================================================
+// UPDATES CHANNEL
+// ==================================================
+
+// updatesChan — единая точка получения апдейтов бота: long polling или вебхук.
+// Дальше апдейты идут по одному и тому же пайплайну.
+func (app *BotApp) updatesChan(botID string, bot *tgbotapi.BotAPI, timeout int) (tgbotapi.UpdatesChannel, error) {
+	if app.updates.mode == updatesModeWebhook {
+		return app.startWebhook(botID, bot)
+	}
+
+	// вебхук и getUpdates взаимоисключающие — снимаем оставшийся с прошлого запуска
+	if _, err := bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		log.Printf("[webhook] delete fail botID=%s err=%v", botID, err)
+	}
+
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = timeout
+
+	return bot.GetUpdatesChan(u), nil
+}
+
+func (app *BotApp) startWebhook(botID string, bot *tgbotapi.BotAPI) (tgbotapi.UpdatesChannel, error) {
+	secret := app.webhookSecret(botID)
+	url := fmt.Sprintf("%s/tg/%s/%s", app.updates.baseURL, botID, secret)
+
+	// ставим канал до setWebhook, чтобы первые апдейты не получили 503
+	ch := app.webhooks.register(botID)
+
+	// в telegram-bot-api v5.5.1 нет secret_token в WebhookConfig — собираем запрос руками
+	params := tgbotapi.Params{}
+	params["url"] = url
+	params["secret_token"] = secret
+	params.AddNonEmpty("allowed_updates", `["message","callback_query"]`)
+
+	if _, err := bot.MakeRequest("setWebhook", params); err != nil {
+		app.webhooks.close(botID)
+		return nil, fmt.Errorf("setWebhook %s: %w", botID, err)
+	}
+
+	log.Printf("[webhook] set botID=%s url=%s/tg/%s/***", botID, app.updates.baseURL, botID)
+	return ch, nil
+}
+
+// webhookSecret — секрет конкретного бота, выведенный из общего TG_WEBHOOK_SECRET.
+// Подходит и для пути, и для secret_token (только [A-Za-z0-9_-]).
+func (app *BotApp) webhookSecret(botID string) string {
+	mac := hmac.New(sha256.New, []byte(app.updates.secret))
+	mac.Write([]byte(botID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ==================================================
+// HTTP
+// ==================================================
+
+// RegisterWebhookRoutes — POST /tg/{bot_id}/{secret}
+func (app *BotApp) RegisterWebhookRoutes(r chi.Router) {
+	r.With(httputil.RecoverMiddleware).
+		Post("/tg/{bot_id}/{secret}", app.handleWebhook)
+}
+
+func (app *BotApp) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if app.updates.mode != updatesModeWebhook {
+		http.NotFound(w, r)
+		return
+	}
+
+	// идёт остановка — пусть Telegram доставит апдейт следующему инстансу
+	if app.stopping.Load() {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	botID := chi.URLParam(r, "bot_id")
+	expected := app.webhookSecret(botID)
+
+	if !hmac.Equal([]byte(chi.URLParam(r, "secret")), []byte(expected)) ||
+		!hmac.Equal([]byte(r.Header.Get(webhookSecretHeader)), []byte(expected)) {
+		log.Printf("[webhook] bad secret botID=%s remote=%s", botID, r.RemoteAddr)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	var update tgbotapi.Update
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		log.Printf("[webhook] bad body botID=%s err=%v", botID, err)
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	// бот остановлен, канал закрыт или буфер так и не освободился — 503, Telegram повторит доставку сам
+	if !app.webhooks.send(r.Context(), botID, update) {
+		log.Printf("[webhook] update not accepted botID=%s updateID=%d", botID, update.UpdateID)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,64 @@
+package tokens
+
+import "strings"
+
+const (
+	// резерв под ответ модели (лимит ответа в промпте — 3000 символов, берём с запасом)
+	ReplyReserve = 4096
+
+	// потолок истории независимо от окна модели: миллион токенов на каждый вопрос — это дорого
+	MaxHistoryTokens = 100_000
+
+	// окно для неизвестных (self-hosted и т.п.) моделей
+	defaultContextWindow = 32_000
+)
+
+// окна контекста; модель ищется по самому длинному префиксу
+var contextWindows = map[string]int{
+	"gpt-4o":       128_000,
+	"gpt-4o-mini":  128_000,
+	"gpt-4.1":      1_047_576,
+	"gpt-4.1-mini": 1_047_576,
+	"gpt-4.1-nano": 1_047_576,
+	"gpt-5":        400_000,
+	"o4-mini":      200_000,
+	"sonar":        127_000,
+	"sonar-pro":    200_000,
+}
+
+// ContextWindow — размер контекста модели в токенах
+func ContextWindow(model string) int {
+	model = strings.ToLower(model)
+
+	best, bestLen := defaultContextWindow, 0
+	for prefix, size := range contextWindows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > bestLen {
+			best, bestLen = size, len(prefix)
+		}
+	}
+	return best
+}
+
+// HistoryBudget — сколько токенов можно отдать под историю,
+// если системные промпты и текущее сообщение уже занимают promptTokens
+func HistoryBudget(model string, promptTokens int) int {
+	budget := ContextWindow(model) - ReplyReserve - promptTokens
+	if budget > MaxHistoryTokens {
+		budget = MaxHistoryTokens
+	}
+	if budget < 0 {
+		return 0
+	}
+	return budget
+}
+
+// ==================================================
+// КАРТИНКИ
+// ==================================================
+
+// Оценка OpenAI для detail=high/auto: картинка вписывается в 2048×2048,
+// короткая сторона уменьшается до 768, дальше 85 + 170 за каждый тайл 512×512.
+// Telegram отдаёт фото не больше 1280px по длинной стороне →
+// 1280×960 → 1024×768 → 2×2 тайла → ImageTokens.
+// detail=low — всегда imageBaseTokens.
+const imageBaseTokens = 85
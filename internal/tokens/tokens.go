@@ -0,0 +1,73 @@
+package tokens
+
+import (
+	"log"
+	"sync"
+
+	tiktoken "github.com/pkoukk/tiktoken-go"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	// MessageOverhead — служебные токены на каждое сообщение чата (role, разделители)
+	MessageOverhead = 3
+
+	// картинка из Telegram (см. budget.go)
+	ImageTokens = 765
+
+	fallbackEncoding = "o200k_base"
+)
+
+var encodings sync.Map // model → *tiktoken.Tiktoken
+
+func encodingFor(model string) *tiktoken.Tiktoken {
+	if enc, ok := encodings.Load(model); ok {
+		return enc.(*tiktoken.Tiktoken)
+	}
+
+	enc, err := tiktoken.EncodingForModel(model)
+	if err != nil {
+		// не-OpenAI модели (sonar, llama, …) — считаем приблизительно
+		enc, err = tiktoken.GetEncoding(fallbackEncoding)
+		if err != nil {
+			log.Printf("[tokens] encoding init fail model=%s err=%v", model, err)
+			return nil
+		}
+	}
+
+	encodings.Store(model, enc)
+	return enc
+}
+
+// Count — токены текста для модели; без токенизатора — ~4 символа на токен
+func Count(model, text string) int {
+	enc := encodingFor(model)
+	if enc == nil {
+		return len(text) / 4
+	}
+	return len(enc.Encode(text, nil, nil))
+}
+
+// CountMessages — оценка prompt_tokens для набора сообщений
+func CountMessages(model string, messages []openai.ChatCompletionMessage) int {
+	total := 0
+
+	for _, m := range messages {
+		total += MessageOverhead + Count(model, m.Content)
+
+		for _, part := range m.MultiContent {
+			switch part.Type {
+			case openai.ChatMessagePartTypeText:
+				total += Count(model, part.Text)
+			case openai.ChatMessagePartTypeImageURL:
+				if part.ImageURL != nil && part.ImageURL.Detail == openai.ImageURLDetailLow {
+					total += imageBaseTokens
+				} else {
+					total += ImageTokens
+				}
+			}
+		}
+	}
+
+	return total
+}
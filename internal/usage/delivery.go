@@ -0,0 +1,76 @@
+package usage
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type Handler struct {
+	svc Service
+}
+
+func NewHandler(svc Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// GET /usage/bots?from=2026-01-01&to=2026-02-01
+func (h *Handler) ByBot(w http.ResponseWriter, r *http.Request) {
+	h.aggregate(w, r, GroupByBot)
+}
+
+// GET /usage/users?bot_id=...&from=...&to=...
+func (h *Handler) ByUser(w http.ResponseWriter, r *http.Request) {
+	h.aggregate(w, r, GroupByUser)
+}
+
+// GET /usage/days?bot_id=...&from=...&to=...
+func (h *Handler) ByDay(w http.ResponseWriter, r *http.Request) {
+	h.aggregate(w, r, GroupByDay)
+}
+
+func (h *Handler) aggregate(w http.ResponseWriter, r *http.Request, group GroupBy) {
+	f, err := parseFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	items, err := h.svc.Aggregate(r.Context(), group, f)
+	if err != nil {
+		http.Error(w, "failed to aggregate usage", 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// по умолчанию — последние 30 дней; to — не включительно
+func parseFilter(r *http.Request) (Filter, error) {
+	q := r.URL.Query()
+
+	f := Filter{
+		BotID: q.Get("bot_id"),
+		To:    time.Now(),
+	}
+	f.From = f.To.AddDate(0, 0, -30)
+
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return f, err
+		}
+		f.From = t
+	}
+
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			return f, err
+		}
+		f.To = t
+	}
+
+	return f, nil
+}
@@ -0,0 +1,125 @@
+package usage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type repo struct {
+	db *sql.DB
+}
+
+func NewRepo(db *sql.DB) Repo {
+	return &repo{db: db}
+}
+
+func (r *repo) Insert(ctx context.Context, e *Event) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO usage_events (
+			bot_id,
+			telegram_id,
+			branch,
+			kind,
+			provider,
+			model,
+			prompt_tokens,
+			completion_tokens,
+			audio_seconds,
+			tts_chars,
+			cost_usd
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`,
+		e.BotID,
+		e.TelegramID,
+		e.Branch,
+		e.Kind,
+		e.Provider,
+		e.Model,
+		e.PromptTokens,
+		e.CompletionTokens,
+		e.AudioSeconds,
+		e.TTSChars,
+		e.CostUSD,
+	)
+	return err
+}
+
+// --------------------------------------------------
+// Aggregate
+// --------------------------------------------------
+
+func (r *repo) Aggregate(ctx context.Context, group GroupBy, f Filter) ([]*Totals, error) {
+	var key string
+	switch group {
+	case GroupByBot:
+		key = "bot_id"
+	case GroupByUser:
+		key = "bot_id, telegram_id"
+	case GroupByDay:
+		key = "date_trunc('day', created_at)"
+	default:
+		return nil, fmt.Errorf("unknown group %q", group)
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+key+`,
+		       COUNT(*),
+		       COALESCE(SUM(prompt_tokens), 0),
+		       COALESCE(SUM(completion_tokens), 0),
+		       COALESCE(SUM(audio_seconds), 0),
+		       COALESCE(SUM(tts_chars), 0),
+		       COALESCE(SUM(cost_usd), 0)
+		FROM usage_events
+		WHERE ($1 = '' OR bot_id = $1)
+		  AND created_at >= $2
+		  AND created_at < $3
+		GROUP BY `+key+`
+		ORDER BY `+key+`
+	`, f.BotID, f.From, f.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*Totals
+
+	for rows.Next() {
+		var (
+			t    Totals
+			keys []any
+			day  time.Time
+		)
+
+		switch group {
+		case GroupByBot:
+			keys = []any{&t.BotID}
+		case GroupByUser:
+			keys = []any{&t.BotID, &t.TelegramID}
+		case GroupByDay:
+			keys = []any{&day}
+		}
+
+		dest := append(keys,
+			&t.Requests,
+			&t.PromptTokens,
+			&t.CompletionTokens,
+			&t.AudioSeconds,
+			&t.TTSChars,
+			&t.CostUSD,
+		)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		if group == GroupByDay {
+			t.Day = &day
+			t.BotID = f.BotID
+		}
+
+		out = append(out, &t)
+	}
+
+	return out, rows.Err()
+}
@@ -0,0 +1,74 @@
+package usage
+
+import (
+	"context"
+	"time"
+)
+
+// виды расхода
+const (
+	KindChat = "chat" // LLM
+	KindSTT  = "stt"  // распознавание речи
+	KindTTS  = "tts"  // синтез речи
+)
+
+// BranchMemory — служебные вызовы (сжатие истории), это не ответы пользователю
+const BranchMemory = "memory"
+
+// Event — один вызов платного AI-API
+type Event struct {
+	BotID      string
+	TelegramID int64
+	Branch     string // text | voice | photo | pdf | doc
+	Kind       string
+	Provider   string
+	Model      string
+
+	PromptTokens     int
+	CompletionTokens int
+	AudioSeconds     float64
+	TTSChars         int
+
+	// считается в Record по прайсу на момент вызова
+	CostUSD float64
+}
+
+// GroupBy — разрез агрегации
+type GroupBy string
+
+const (
+	GroupByBot  GroupBy = "bot"
+	GroupByUser GroupBy = "user"
+	GroupByDay  GroupBy = "day"
+)
+
+type Filter struct {
+	BotID string // пусто — все боты
+	From  time.Time
+	To    time.Time
+}
+
+// Totals — агрегат по одному ключу разреза
+type Totals struct {
+	BotID      string     `json:"bot_id,omitempty"`
+	TelegramID int64      `json:"telegram_id,omitempty"`
+	Day        *time.Time `json:"day,omitempty"`
+
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	AudioSeconds     float64 `json:"audio_seconds"`
+	TTSChars         int64   `json:"tts_chars"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+type Repo interface {
+	Insert(ctx context.Context, e *Event) error
+	Aggregate(ctx context.Context, group GroupBy, f Filter) ([]*Totals, error)
+}
+
+type Service interface {
+	// Record — не мешает основному сценарию: ошибки только логируются
+	Record(ctx context.Context, e *Event)
+	Aggregate(ctx context.Context, group GroupBy, f Filter) ([]*Totals, error)
+}
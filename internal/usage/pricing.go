@@ -0,0 +1,66 @@
+package usage
+
+import (
+	"strings"
+)
+
+// цены в USD; модели сопоставляются по самому длинному префиксу
+// (gpt-4o-mini-2024-07-18 → gpt-4o-mini)
+type chatPrice struct {
+	promptPer1M     float64
+	completionPer1M float64
+}
+
+var chatPrices = map[string]chatPrice{
+	"gpt-4o":       {2.50, 10.00},
+	"gpt-4o-mini":  {0.15, 0.60},
+	"gpt-4.1":      {2.00, 8.00},
+	"gpt-4.1-mini": {0.40, 1.60},
+	"gpt-4.1-nano": {0.10, 0.40},
+	"gpt-5":        {1.25, 10.00},
+	"gpt-5-mini":   {0.25, 2.00},
+	"gpt-5-nano":   {0.05, 0.40},
+	"o4-mini":      {1.10, 4.40},
+	"sonar":        {1.00, 1.00},
+	"sonar-pro":    {3.00, 15.00},
+}
+
+const (
+	whisperPerMinute   = 0.006
+	elevenLabsPer1KChr = 0.30
+)
+
+// Cost — стоимость события; неизвестная модель → 0
+func Cost(e *Event) float64 {
+	switch e.Kind {
+	case KindSTT:
+		return e.AudioSeconds / 60 * whisperPerMinute
+
+	case KindTTS:
+		return float64(e.TTSChars) / 1000 * elevenLabsPer1KChr
+
+	case KindChat:
+		p, ok := lookupChatPrice(e.Model)
+		if !ok {
+			return 0
+		}
+		return float64(e.PromptTokens)/1e6*p.promptPer1M +
+			float64(e.CompletionTokens)/1e6*p.completionPer1M
+	}
+	return 0
+}
+
+func lookupChatPrice(model string) (chatPrice, bool) {
+	model = strings.ToLower(model)
+
+	var (
+		best    chatPrice
+		bestLen int
+	)
+	for prefix, p := range chatPrices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > bestLen {
+			best, bestLen = p, len(prefix)
+		}
+	}
+	return best, bestLen > 0
+}
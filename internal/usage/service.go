@@ -0,0 +1,33 @@
+package usage
+
+import (
+	"context"
+	"log"
+)
+
+type service struct {
+	repo Repo
+}
+
+func NewService(repo Repo) Service {
+	return &service{repo: repo}
+}
+
+func (s *service) Record(ctx context.Context, e *Event) {
+	e.CostUSD = Cost(e)
+
+	if err := s.repo.Insert(ctx, e); err != nil {
+		log.Printf(
+			"[usage] insert fail bot=%s tg=%d kind=%s model=%s err=%v",
+			e.BotID,
+			e.TelegramID,
+			e.Kind,
+			e.Model,
+			err,
+		)
+	}
+}
+
+func (s *service) Aggregate(ctx context.Context, group GroupBy, f Filter) ([]*Totals, error) {
+	return s.repo.Aggregate(ctx, group, f)
+}
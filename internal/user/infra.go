@@ -35,3 +35,38 @@ func (i *infra) ResetUserSettings(
 
 	return tx.Commit()
 }
+
+func (i *infra) GetContact(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+) (*Contact, error) {
+
+	c := Contact{BotID: botID, TelegramID: telegramID}
+
+	err := i.db.QueryRowContext(ctx, `
+		SELECT COALESCE(email, ''), COALESCE(phone, ''), updated_at
+		FROM user_contacts
+		WHERE bot_id = $1 AND telegram_id = $2
+	`, botID, telegramID).Scan(&c.Email, &c.Phone, &c.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// SaveContact — новый контакт заменяет прежний целиком (email ИЛИ телефон)
+func (i *infra) SaveContact(ctx context.Context, c *Contact) error {
+	return i.db.QueryRowContext(ctx, `
+		INSERT INTO user_contacts (bot_id, telegram_id, email, phone)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''))
+		ON CONFLICT (bot_id, telegram_id) DO UPDATE SET
+			email      = EXCLUDED.email,
+			phone      = EXCLUDED.phone,
+			updated_at = NOW()
+		RETURNING updated_at
+	`, c.BotID, c.TelegramID, c.Email, c.Phone).Scan(&c.UpdatedAt)
+}
@@ -1,6 +1,64 @@
 package user
 
+import (
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode"
+)
+
 type UserID struct {
 	BotID      string
 	TelegramID int64
 }
+
+// ErrInvalidContact — не похоже ни на email, ни на телефон
+var ErrInvalidContact = errors.New("invalid email or phone")
+
+// Contact — куда отправлять чеки 54-ФЗ
+type Contact struct {
+	BotID      string    `json:"bot_id"`
+	TelegramID int64     `json:"telegram_id"`
+	Email      string    `json:"email,omitempty"`
+	Phone      string    `json:"phone,omitempty"` // только цифры, 79001234567
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ParseContact — email или телефон из того, что прислал пользователь.
+// Российские 8XXXXXXXXXX и 10 цифр без кода приводятся к 7XXXXXXXXXX.
+func ParseContact(raw string) (email, phone string, err error) {
+	raw = strings.TrimSpace(raw)
+
+	if strings.Contains(raw, "@") {
+		addr, err := mail.ParseAddress(raw)
+		if err != nil || addr.Address != raw || !strings.Contains(raw[strings.LastIndex(raw, "@"):], ".") {
+			return "", "", ErrInvalidContact
+		}
+		return strings.ToLower(raw), "", nil
+	}
+
+	var digits strings.Builder
+	for _, r := range raw {
+		switch {
+		case unicode.IsDigit(r):
+			digits.WriteRune(r)
+		case r == '+' || r == ' ' || r == '-' || r == '(' || r == ')':
+		default:
+			return "", "", ErrInvalidContact
+		}
+	}
+
+	d := digits.String()
+	switch {
+	case len(d) == 10:
+		d = "7" + d
+	case len(d) == 11 && d[0] == '8':
+		d = "7" + d[1:]
+	}
+
+	if len(d) < 11 || len(d) > 15 {
+		return "", "", ErrInvalidContact
+	}
+	return "", d, nil
+}
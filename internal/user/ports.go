@@ -5,9 +5,17 @@ import "context"
 // Infra — работа с БД
 type Infra interface {
 	ResetUserSettings(ctx context.Context, botID string, telegramID int64) error
+
+	// GetContact — nil, если пользователь контакт не оставлял
+	GetContact(ctx context.Context, botID string, telegramID int64) (*Contact, error)
+	SaveContact(ctx context.Context, c *Contact) error
 }
 
 // Service — бизнес-операции
 type Service interface {
 	ResetUserSettings(ctx context.Context, botID string, telegramID int64) error
+
+	GetContact(ctx context.Context, botID string, telegramID int64) (*Contact, error)
+	// SaveContact — email или телефон для чеков (ErrInvalidContact — не распознан)
+	SaveContact(ctx context.Context, botID string, telegramID int64, raw string) (*Contact, error)
 }
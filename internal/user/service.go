@@ -17,3 +17,34 @@ func (s *service) ResetUserSettings(
 ) error {
 	return s.infra.ResetUserSettings(ctx, botID, telegramID)
 }
+
+func (s *service) GetContact(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+) (*Contact, error) {
+	return s.infra.GetContact(ctx, botID, telegramID)
+}
+
+func (s *service) SaveContact(
+	ctx context.Context,
+	botID string,
+	telegramID int64,
+	raw string,
+) (*Contact, error) {
+	email, phone, err := ParseContact(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Contact{
+		BotID:      botID,
+		TelegramID: telegramID,
+		Email:      email,
+		Phone:      phone,
+	}
+	if err := s.infra.SaveContact(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
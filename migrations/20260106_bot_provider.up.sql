@@ -0,0 +1,2 @@
+ALTER TABLE bot_configs
+ADD COLUMN IF NOT EXISTS provider text NOT NULL DEFAULT 'openai';
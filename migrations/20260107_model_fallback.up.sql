@@ -0,0 +1,6 @@
+ALTER TABLE bot_configs
+ADD COLUMN IF NOT EXISTS fallback_models text[] NOT NULL DEFAULT '{}';
+
+-- какая модель реально ответила (provider:model)
+ALTER TABLE records
+ADD COLUMN IF NOT EXISTS model text;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS usage_events (
+	id BIGSERIAL PRIMARY KEY,
+	bot_id VARCHAR(64) NOT NULL,
+	telegram_id BIGINT NOT NULL,
+	branch VARCHAR(16) NOT NULL,
+	kind VARCHAR(8) NOT NULL, -- chat | stt | tts
+	provider VARCHAR(32) NOT NULL DEFAULT '',
+	model VARCHAR(128) NOT NULL DEFAULT '',
+	prompt_tokens INT NOT NULL DEFAULT 0,
+	completion_tokens INT NOT NULL DEFAULT 0,
+	audio_seconds NUMERIC(10, 2) NOT NULL DEFAULT 0,
+	tts_chars INT NOT NULL DEFAULT 0,
+	cost_usd NUMERIC(14, 6) NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS usage_events_bot_created_idx
+	ON usage_events (bot_id, created_at);
+
+CREATE INDEX IF NOT EXISTS usage_events_user_created_idx
+	ON usage_events (bot_id, telegram_id, created_at);
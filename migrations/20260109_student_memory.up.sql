@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS student_memory (
+    bot_id           TEXT      NOT NULL,
+    telegram_id      BIGINT    NOT NULL,
+    summary          TEXT      NOT NULL DEFAULT '',
+    covered_until_id BIGINT    NOT NULL DEFAULT 0,  -- records.id, до которого история уже сжата
+    updated_at       TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (bot_id, telegram_id)
+);
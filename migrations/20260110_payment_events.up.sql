@@ -0,0 +1,21 @@
+-- уведомления платёжных провайдеров: каждое применяется ровно один раз
+CREATE TABLE IF NOT EXISTS payment_events (
+    provider     TEXT        NOT NULL,              -- yookassa | cloudpayments
+    object_id    TEXT        NOT NULL,              -- id платежа, для возвратов — id возврата
+    kind         TEXT        NOT NULL,              -- payment.succeeded | payment.canceled | refund.succeeded | ...
+    payment_id   TEXT        NOT NULL,
+    bot_id       TEXT        NOT NULL DEFAULT '',
+    telegram_id  BIGINT      NOT NULL DEFAULT 0,
+    payment_type TEXT        NOT NULL DEFAULT '',   -- subscription | minute_package
+    amount       NUMERIC(10,2) NOT NULL DEFAULT 0,
+    payload      TEXT,                              -- сырое тело уведомления
+    status       TEXT        NOT NULL,              -- processing | done | failed | needs_review
+    attempts     INT         NOT NULL DEFAULT 1,
+    error        TEXT,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    processed_at TIMESTAMPTZ,
+    PRIMARY KEY (provider, object_id, kind)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_events_payment ON payment_events (provider, payment_id);
@@ -0,0 +1,3 @@
+-- у каждого тенанта свой мерчант: yookassa | cloudpayments
+ALTER TABLE bot_configs
+ADD COLUMN IF NOT EXISTS payment_provider text NOT NULL DEFAULT 'yookassa';
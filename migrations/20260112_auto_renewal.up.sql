@@ -0,0 +1,11 @@
+-- автопродление подписок по сохранённому способу оплаты
+ALTER TABLE subscriptions
+ADD COLUMN IF NOT EXISTS auto_renew              BOOLEAN NOT NULL DEFAULT false,
+ADD COLUMN IF NOT EXISTS payment_method_id       TEXT,          -- payment_method_id YooKassa / Token CloudPayments
+ADD COLUMN IF NOT EXISTS payment_method_provider TEXT,          -- yookassa | cloudpayments
+ADD COLUMN IF NOT EXISTS renew_attempts          INT NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS next_renew_at           TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_renewal
+    ON subscriptions (expires_at)
+    WHERE auto_renew AND payment_method_id IS NOT NULL;
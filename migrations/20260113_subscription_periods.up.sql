@@ -0,0 +1,56 @@
+-- история покупок: каждая оплата — отдельный период, subscriptions хранит только текущий доступ
+CREATE TABLE IF NOT EXISTS subscription_periods (
+    id            BIGSERIAL PRIMARY KEY,
+    bot_id        VARCHAR(64) NOT NULL,
+    telegram_id   BIGINT      NOT NULL,
+    plan_id       INT REFERENCES tariff_plans(id) ON DELETE SET NULL,
+
+    kind          TEXT NOT NULL,                    -- purchase | renewal | trial | manual
+    status        TEXT NOT NULL,                    -- pending | paid | canceled | refunded | superseded | revoked
+    payment_id    TEXT,                             -- invoice (sub_...) / id платежа провайдера
+
+    amount        NUMERIC(10,2) NOT NULL DEFAULT 0,
+    credit        NUMERIC(10,2) NOT NULL DEFAULT 0, -- зачтённый остаток прошлых периодов
+    voice_minutes REAL          NOT NULL DEFAULT 0,
+    auto_renew    BOOLEAN       NOT NULL DEFAULT false,
+
+    starts_at     TIMESTAMPTZ,
+    ends_at       TIMESTAMPTZ,
+    note          TEXT,
+
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_subscription_periods_payment
+    ON subscription_periods (payment_id)
+    WHERE payment_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_subscription_periods_user
+    ON subscription_periods (bot_id, telegram_id, created_at);
+
+-- переносим то, что есть сейчас: по одному периоду на строку subscriptions
+INSERT INTO subscription_periods (
+    bot_id, telegram_id, plan_id, kind, status, payment_id,
+    amount, voice_minutes, auto_renew, starts_at, ends_at, created_at
+)
+SELECT
+    s.bot_id,
+    s.telegram_id,
+    s.plan_id,
+    CASE WHEN t.is_trial THEN 'trial' ELSE 'purchase' END,
+    CASE s.status
+        WHEN 'pending'  THEN 'pending'
+        WHEN 'inactive' THEN 'canceled'
+        ELSE 'paid'
+    END,
+    s.yookassa_payment_id,
+    COALESCE(t.price, 0),
+    COALESCE(t.voice_minutes, 0),
+    s.auto_renew,
+    s.started_at,
+    s.expires_at,
+    COALESCE(s.started_at, s.updated_at, NOW())
+FROM subscriptions s
+LEFT JOIN tariff_plans t ON t.id = s.plan_id
+WHERE NOT EXISTS (SELECT 1 FROM subscription_periods);
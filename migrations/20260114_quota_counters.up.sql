@@ -0,0 +1,15 @@
+-- расход пользователя по окнам (сутки / календарный месяц), лимиты — tariff_plans.features.quotas
+CREATE TABLE IF NOT EXISTS quota_counters (
+    bot_id       VARCHAR(64) NOT NULL,
+    telegram_id  BIGINT      NOT NULL,
+    metric       TEXT        NOT NULL, -- messages | images | pdf_pages
+    window_kind  TEXT        NOT NULL, -- day | month
+    window_start TIMESTAMPTZ NOT NULL,
+    used         INTEGER     NOT NULL DEFAULT 0,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (bot_id, telegram_id, metric, window_kind, window_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quota_counters_window_start
+    ON quota_counters (window_start);
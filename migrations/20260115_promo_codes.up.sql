@@ -0,0 +1,54 @@
+-- промокоды бота: скидка (процент или сумма) и/или бонусные минуты и дни
+CREATE TABLE IF NOT EXISTS promo_codes (
+    id            BIGSERIAL PRIMARY KEY,
+    bot_id        VARCHAR(64) NOT NULL,
+    code          TEXT        NOT NULL, -- в верхнем регистре
+
+    percent_off   INT           NOT NULL DEFAULT 0,
+    amount_off    NUMERIC(10,2) NOT NULL DEFAULT 0,
+    bonus_minutes REAL          NOT NULL DEFAULT 0,
+    bonus_days    INT           NOT NULL DEFAULT 0,
+
+    applies_to    TEXT        NOT NULL DEFAULT 'any', -- any | subscription | minute_package
+    max_uses      INT         NOT NULL DEFAULT 0,     -- 0 — без ограничения
+    used_count    INT         NOT NULL DEFAULT 0,
+    expires_at    TIMESTAMPTZ,
+    active        BOOLEAN     NOT NULL DEFAULT true,
+
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    UNIQUE (bot_id, code)
+);
+
+-- применение кода пользователем: один раз на пользователя
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+    id            BIGSERIAL PRIMARY KEY,
+    promo_id      BIGINT      NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    bot_id        VARCHAR(64) NOT NULL,
+    telegram_id   BIGINT      NOT NULL,
+
+    status        TEXT        NOT NULL, -- applied | reserved | redeemed
+    payment_id    TEXT,                 -- invoice платежа (sub_... / pkg_...)
+
+    discount      NUMERIC(10,2) NOT NULL DEFAULT 0,
+    bonus_minutes REAL          NOT NULL DEFAULT 0,
+    bonus_days    INT           NOT NULL DEFAULT 0,
+
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    UNIQUE (promo_id, telegram_id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_promo_redemptions_payment
+    ON promo_redemptions (payment_id)
+    WHERE payment_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_promo_redemptions_user
+    ON promo_redemptions (bot_id, telegram_id, status);
+
+-- заказ подписки помнит промокод и что он дал
+ALTER TABLE subscription_periods
+    ADD COLUMN IF NOT EXISTS promo_code TEXT,
+    ADD COLUMN IF NOT EXISTS discount   NUMERIC(10,2) NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS bonus_days INT           NOT NULL DEFAULT 0;
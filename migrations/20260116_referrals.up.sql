@@ -0,0 +1,34 @@
+-- реферальная программа бота
+CREATE TABLE IF NOT EXISTS referral_settings (
+    bot_id        VARCHAR(64) PRIMARY KEY,
+    enabled       BOOLEAN     NOT NULL DEFAULT false,
+
+    bonus_minutes REAL        NOT NULL DEFAULT 0, -- пригласившему за оплату приглашённого
+    bonus_days    INT         NOT NULL DEFAULT 0,
+    max_rewards   INT         NOT NULL DEFAULT 0, -- на одного пригласившего, 0 — без ограничения
+
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- кто кого пригласил: у пользователя бота один пригласивший
+CREATE TABLE IF NOT EXISTS referrals (
+    id            BIGSERIAL PRIMARY KEY,
+    bot_id        VARCHAR(64) NOT NULL,
+    referrer_id   BIGINT      NOT NULL,
+    invitee_id    BIGINT      NOT NULL,
+
+    status        TEXT        NOT NULL DEFAULT 'pending', -- pending | rewarded | limit_reached | revoked
+    payment_id    TEXT,                                   -- первая оплата приглашённого
+
+    bonus_minutes REAL        NOT NULL DEFAULT 0,
+    bonus_days    INT         NOT NULL DEFAULT 0,
+
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    rewarded_at   TIMESTAMPTZ,
+
+    UNIQUE (bot_id, invitee_id),
+    CHECK (referrer_id <> invitee_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_referrals_referrer
+    ON referrals (bot_id, referrer_id, status);
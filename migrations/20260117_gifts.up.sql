@@ -0,0 +1,24 @@
+-- подарочные подписки: платит один пользователь, активирует по коду другой
+CREATE TABLE IF NOT EXISTS gifts (
+    id           BIGSERIAL PRIMARY KEY,
+    bot_id       VARCHAR(64)   NOT NULL,
+    plan_id      BIGINT        NOT NULL,
+    plan_name    TEXT          NOT NULL,
+    amount       NUMERIC(10,2) NOT NULL,
+
+    payer_id     BIGINT        NOT NULL,
+    invoice_id   TEXT          NOT NULL UNIQUE, -- gift_...: платёж и период получателя
+    code         TEXT          NOT NULL,        -- одноразовый код активации
+    status       TEXT          NOT NULL,        -- pending | paid | redeemed | canceled | refunded
+
+    recipient_id BIGINT,
+
+    created_at   TIMESTAMPTZ   NOT NULL DEFAULT NOW(),
+    paid_at      TIMESTAMPTZ,
+    redeemed_at  TIMESTAMPTZ,
+
+    UNIQUE (bot_id, code)
+);
+
+CREATE INDEX IF NOT EXISTS idx_gifts_payer
+    ON gifts (bot_id, payer_id);
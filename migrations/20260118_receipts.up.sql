@@ -0,0 +1,19 @@
+-- контакт покупателя для чеков 54-ФЗ (email или телефон)
+CREATE TABLE IF NOT EXISTS user_contacts (
+    bot_id      VARCHAR(64) NOT NULL,
+    telegram_id BIGINT      NOT NULL,
+    email       TEXT,
+    phone       TEXT, -- только цифры, 79001234567
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (bot_id, telegram_id),
+    CHECK (email IS NOT NULL OR phone IS NOT NULL)
+);
+
+-- оплата и чек — из настроек бота, а не из констант
+ALTER TABLE bot_configs
+    ADD COLUMN IF NOT EXISTS payment_return_url      TEXT,                              -- NULL — PAYMENT_RETURN_URL
+    ADD COLUMN IF NOT EXISTS receipt_vat_code        INT  NOT NULL DEFAULT 1,           -- коды ЮKassa, 1 — без НДС
+    ADD COLUMN IF NOT EXISTS receipt_tax_system      INT,                               -- 1–6, NULL — не передаём
+    ADD COLUMN IF NOT EXISTS receipt_payment_subject TEXT NOT NULL DEFAULT 'service',
+    ADD COLUMN IF NOT EXISTS receipt_email           TEXT;                              -- email магазина, если покупатель контакт не оставил
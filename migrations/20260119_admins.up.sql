@@ -0,0 +1,23 @@
+-- учётки админки вместо одного пароля из bot_auth
+CREATE TABLE IF NOT EXISTS admin_users (
+    id            BIGSERIAL PRIMARY KEY,
+    login         TEXT        NOT NULL UNIQUE,
+    password_hash TEXT        NOT NULL,                  -- bcrypt
+    role          TEXT        NOT NULL DEFAULT 'operator'
+                  CHECK (role IN ('owner', 'operator')),
+    bot_ids       TEXT[]      NOT NULL DEFAULT '{}',     -- боты operator'а
+    active        BOOLEAN     NOT NULL DEFAULT TRUE,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- выданные токены: HMAC токена, срок и отзыв
+CREATE TABLE IF NOT EXISTS admin_sessions (
+    token_hash TEXT PRIMARY KEY,
+    admin_id   BIGINT      NOT NULL REFERENCES admin_users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_sessions_admin ON admin_sessions (admin_id);
@@ -0,0 +1,18 @@
+-- журнал изменений из админки и ответов поддержки
+CREATE TABLE IF NOT EXISTS audit_log (
+    id         BIGSERIAL PRIMARY KEY,
+    actor_type TEXT        NOT NULL,              -- admin | admin_bot
+    actor_id   TEXT        NOT NULL DEFAULT '',   -- id учётки или telegram id
+    actor_name TEXT        NOT NULL DEFAULT '',
+    bot_id     TEXT        NOT NULL DEFAULT '',
+    action     TEXT        NOT NULL,              -- tariff.update, support.reply...
+    entity     TEXT        NOT NULL,
+    entity_id  TEXT        NOT NULL DEFAULT '',
+    before     JSONB,
+    after      JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_bot     ON audit_log (bot_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity  ON audit_log (entity, entity_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log (created_at);
@@ -0,0 +1,47 @@
+-- кому слать алерты об ошибках (вместо зашитых в код chat id)
+CREATE TABLE IF NOT EXISTS alert_routes (
+    id           BIGSERIAL PRIMARY KEY,
+    bot_id       TEXT        NOT NULL DEFAULT '',       -- '' — все боты
+    chat_id      BIGINT      NOT NULL,                  -- личка или группа
+    min_severity TEXT        NOT NULL DEFAULT 'error'
+        CHECK (min_severity IN ('info', 'warning', 'error', 'critical')),
+    digest       BOOLEAN     NOT NULL DEFAULT TRUE,
+    active       BOOLEAN     NOT NULL DEFAULT TRUE,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- одинаковые ошибки склеиваются по отпечатку
+CREATE TABLE IF NOT EXISTS alert_events (
+    fingerprint  TEXT PRIMARY KEY,
+    bot_id       TEXT        NOT NULL DEFAULT '',
+    severity     TEXT        NOT NULL,
+    message      TEXT        NOT NULL DEFAULT '',
+    details      TEXT        NOT NULL DEFAULT '',
+    count        BIGINT      NOT NULL DEFAULT 1,
+    pending      BIGINT      NOT NULL DEFAULT 1,        -- подавлено с последней отправки/сводки
+    first_seen   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_events_bot     ON alert_events (bot_id, last_seen DESC);
+CREATE INDEX IF NOT EXISTS idx_alert_events_pending ON alert_events (pending) WHERE pending > 0;
+
+-- разовые наполнения данными: миграции прогоняются целиком при каждом старте,
+-- а удалённое админом возвращаться не должно
+CREATE TABLE IF NOT EXISTS migration_seeds (
+    name       TEXT PRIMARY KEY,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- прежние получатели — только в первый прогон и только в пустую таблицу
+WITH seeded AS (
+    INSERT INTO migration_seeds (name) VALUES ('alert_routes_legacy')
+    ON CONFLICT (name) DO NOTHING
+    RETURNING name
+)
+INSERT INTO alert_routes (bot_id, chat_id, min_severity, digest)
+SELECT '', v.chat_id, 'error', TRUE
+FROM (VALUES (1139929360::BIGINT), (6789440333::BIGINT)) AS v(chat_id)
+WHERE EXISTS (SELECT 1 FROM seeded)
+  AND NOT EXISTS (SELECT 1 FROM alert_routes);
@@ -0,0 +1,43 @@
+-- обращения в поддержку (админ-бот)
+CREATE TABLE IF NOT EXISTS support_tickets (
+    id            BIGSERIAL PRIMARY KEY,
+    bot_id        TEXT        NOT NULL DEFAULT '',      -- бот, из которого пришёл пользователь
+    telegram_id   BIGINT      NOT NULL,
+    username      TEXT        NOT NULL DEFAULT '',
+    full_name     TEXT        NOT NULL DEFAULT '',
+    sub_status    TEXT        NOT NULL DEFAULT '',      -- статус подписки на момент обращения
+    class_grade   TEXT        NOT NULL DEFAULT '',
+    status        TEXT        NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'closed')),
+    assignee_id   BIGINT,                               -- telegram id админа
+    assignee_name TEXT        NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    closed_at     TIMESTAMPTZ
+);
+
+-- одно открытое обращение пользователя на бот
+CREATE UNIQUE INDEX IF NOT EXISTS uq_support_tickets_open
+    ON support_tickets (bot_id, telegram_id) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_support_tickets_status ON support_tickets (status, updated_at DESC);
+
+CREATE TABLE IF NOT EXISTS support_messages (
+    id         BIGSERIAL PRIMARY KEY,
+    ticket_id  BIGINT      NOT NULL REFERENCES support_tickets(id) ON DELETE CASCADE,
+    direction  TEXT        NOT NULL,                    -- user | admin
+    sender_id  BIGINT      NOT NULL,
+    kind       TEXT        NOT NULL DEFAULT 'text',     -- text | photo | voice | document...
+    text       TEXT        NOT NULL DEFAULT '',
+    file_id    TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_support_messages_ticket ON support_messages (ticket_id, id);
+
+-- сообщения обращения в чатах админов: reply на любое из них попадает в обращение
+CREATE TABLE IF NOT EXISTS support_admin_messages (
+    chat_id    BIGINT NOT NULL,
+    message_id BIGINT NOT NULL,
+    ticket_id  BIGINT NOT NULL REFERENCES support_tickets(id) ON DELETE CASCADE,
+    PRIMARY KEY (chat_id, message_id)
+);
@@ -0,0 +1,4 @@
+-- режим поддержки в самом боте: ответы уходят туда, где пользователь пишет
+ALTER TABLE support_tickets
+    ADD COLUMN IF NOT EXISTS channel TEXT NOT NULL DEFAULT 'admin_bot'
+        CHECK (channel IN ('admin_bot', 'bot'));
@@ -0,0 +1,4 @@
+-- у каждого тенанта свой кабинет CloudPayments; NULL — CLOUDPAYMENTS_* из env
+ALTER TABLE bot_configs
+ADD COLUMN IF NOT EXISTS cloudpayments_public_id text,
+ADD COLUMN IF NOT EXISTS cloudpayments_api_secret text;
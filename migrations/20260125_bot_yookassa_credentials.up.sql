@@ -0,0 +1,4 @@
+-- у каждого тенанта свой магазин ЮKassa; NULL — YOOKASSA_* из env
+ALTER TABLE bot_configs
+ADD COLUMN IF NOT EXISTS yookassa_shop_id text,
+ADD COLUMN IF NOT EXISTS yookassa_secret_key text;